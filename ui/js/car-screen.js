@@ -9,6 +9,8 @@
     const routePolylines = {};
     const routeStartEnd = {};
     let highlightedRouteId = null;
+    // 地图可视范围变化时的回调，由 initVehicleWS 设置，用于更新 websocket 订阅范围
+    let onMapViewportChange = null;
 
     // Expose a global toggleMapLayer(type, visible) for floating panel to call
     window.toggleMapLayer = function(type, visible){
@@ -377,6 +379,12 @@
             mapStyle: "amap://styles/macaron",
             resizeEnable: true
         });
+        // 可视范围变化时更新 websocket 订阅（WS 可能先于地图连接，地图就绪后立即按范围订阅一次）
+        if (onMapViewportChange) {
+            amap.on('moveend', function(){ onMapViewportChange(); });
+            amap.on('zoomend', function(){ onMapViewportChange(); });
+            onMapViewportChange();
+        }
         // amap.addControl(new AMap.Scale());
         // amap.addControl(new AMap.ToolBar());
 
@@ -1058,10 +1066,41 @@
         var host = location.hostname || 'localhost';
         var port = location.port ? (':' + location.port) : '';
         var url = scheme + '://' + host + port + '/api/vehicle/ws';
+        // 后端启用 WSAuth 令牌校验时，令牌取自页面地址的 ?token= 或 window.VEHICLE_WS_TOKEN
+        var wsToken = new URLSearchParams(location.search).get('token') || window.VEHICLE_WS_TOKEN;
+        if (wsToken) url += '?token=' + encodeURIComponent(wsToken);
 
         var wsConn = null;
+        // 每辆车已显示的最新时间戳：迟到（late）或更早的数据不移动车辆标记，避免位置回退
+        var lastWsTs = {};
         var reconnectDelay = 1000; // 起始重连间隔 ms
         var maxDelay = 30000;
+        var viewportTimer = null;
+
+        // 按当前地图可视范围（四周外扩 20%，平移时边缘车辆不会突然消失）订阅位置与告警，
+        // 后端只推送范围内车辆的数据，避免大屏缩放到某个区域时仍接收全部车辆的流量
+        // withSnapshot：后端按新的范围重新下发快照，移动到新区域后立即显示该区域的车辆
+        // （连接建立时后端已下发全量快照，无需再请求）
+        function subscribeViewport(withSnapshot){
+            if (!wsConn || wsConn.readyState !== WebSocket.OPEN) return;
+            var cmd = { action: 'subscribe', types: ['positions', 'alarms'] };
+            if (withSnapshot) cmd.snapshot = true;
+            try {
+                var bounds = amap && amap.getBounds();
+                if (bounds) {
+                    var sw = bounds.getSouthWest(), ne = bounds.getNorthEast();
+                    var dLon = (ne.lng - sw.lng) * 0.2, dLat = (ne.lat - sw.lat) * 0.2;
+                    cmd.bbox = [sw.lng - dLon, sw.lat - dLat, ne.lng + dLon, ne.lat + dLat];
+                }
+            } catch(e){ /* 地图未就绪时订阅全部车辆 */ }
+            wsConn.send(JSON.stringify(cmd));
+        }
+
+        // 地图移动/缩放结束后更新订阅范围（合并短时间内的多次变化），地图在 initMap 中绑定
+        onMapViewportChange = function(){
+            clearTimeout(viewportTimer);
+            viewportTimer = setTimeout(function(){ subscribeViewport(true); }, 300);
+        };
 
         function connect(){
             setWsStatus('connecting');
@@ -1076,13 +1115,28 @@
             wsConn.onopen = function(){
                 setWsStatus('connected');
                 reconnectDelay = 1000; // reset
+                subscribeViewport(false);
                 // flush any pending marker updates
                 flushPendingWsUpdates();
             };
 
             wsConn.onmessage = function(ev){
                 try {
-                    var data = JSON.parse(ev.data);
+                    // 后端消息均为信封 {v, type, seq, ts, data}，按 type 取出车辆数据
+                    var msg = JSON.parse(ev.data);
+                    if (!msg || !msg.v) return;
+                    var data;
+                    switch (msg.type) {
+                        // 连接建立后首先收到全量快照，其中 vehicles 为各车最新状态，之后为实时增量
+                        // （大屏每次连接都重新获取快照，不需要带 lastSeq 续传）
+                        case 'snapshot': data = (msg.data && msg.data.vehicles) || []; break;
+                        case 'positions':
+                        case 'alarms': data = msg.data; break;
+                        // 订阅命令的回复（subscription/error）等不是车辆数据
+                        case 'error': console.warn('ws subscribe error', msg.data && msg.data.message); return;
+                        default: return;
+                    }
+                    if (!data) return;
                     // 后端可能推送单条对象，也可能推送一个批量数组（我们的 Processor 现在会广播数组）
                     var items = Array.isArray(data) ? data : [data];
                     // 逐条处理每一条数据，保持向后兼容
@@ -1091,6 +1145,12 @@
                             // 支持多种命名：vehicleId / vehicleid / id
                             var vid = item.vehicleId ?? item.vehicleid ?? item.id ?? null;
                             if (!vid) return;
+                            if (item.late) return;
+                            var ts = Number(item.timestamp);
+                            if (ts) {
+                                if (lastWsTs[vid] && ts < lastWsTs[vid]) return;
+                                lastWsTs[vid] = ts;
+                            }
 
                             // 支持多种经纬字段命名：lon / longitude / lng
                             var lon = item.lon ?? item.longitude ?? item.lng ?? null;
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// fault 故障注入参数，比例取值 0~1
+type fault struct {
+	LatencyMs     int     `json:"latencyMs"`     // 固定延迟
+	JitterMs      int     `json:"jitterMs"`      // 额外的随机延迟 [0, jitterMs)
+	ErrorRate     float64 `json:"errorRate"`     // 返回 HTTP 500 的比例
+	BadCodeRate   float64 `json:"badCodeRate"`   // 返回 HTTP 200 但 code 非 0 的比例
+	BadCode       int     `json:"badCode"`       // badCodeRate 命中时返回的 code，默认 500
+	MalformedRate float64 `json:"malformedRate"` // 返回无法解析的响应体的比例
+}
+
+// 故障注入的结果
+const (
+	faultNone = iota
+	faultError
+	faultBadCode
+	faultMalformed
+)
+
+// faults 按接口名（info/position/route/runpath）配置的故障，"*" 为未单独配置接口的默认值
+type faults struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+	cfg map[string]fault
+}
+
+func newFaults(def fault) *faults {
+	return &faults{rng: rand.New(rand.NewSource(time.Now().UnixNano())), cfg: map[string]fault{"*": def}}
+}
+
+func (f *faults) get(endpoint string) fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.cfg[endpoint]; ok {
+		return c
+	}
+	return f.cfg["*"]
+}
+
+// set 设置接口的故障参数，endpoint 为空时设置默认值
+func (f *faults) set(endpoint string, c fault) {
+	if endpoint == "" {
+		endpoint = "*"
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg[endpoint] = c
+}
+
+// reset 清除全部故障
+func (f *faults) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg = map[string]fault{"*": {}}
+}
+
+func (f *faults) snapshot() map[string]fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]fault, len(f.cfg))
+	for k, v := range f.cfg {
+		out[k] = v
+	}
+	return out
+}
+
+// roll 按接口的故障参数决定本次请求的延迟与结果
+func (f *faults) roll(endpoint string) (time.Duration, int, fault) {
+	c := f.get(endpoint)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delay := time.Duration(c.LatencyMs) * time.Millisecond
+	if c.JitterMs > 0 {
+		delay += time.Duration(f.rng.Intn(c.JitterMs)) * time.Millisecond
+	}
+	p := f.rng.Float64()
+	switch {
+	case p < c.ErrorRate:
+		return delay, faultError, c
+	case p < c.ErrorRate+c.BadCodeRate:
+		return delay, faultBadCode, c
+	case p < c.ErrorRate+c.BadCodeRate+c.MalformedRate:
+		return delay, faultMalformed, c
+	}
+	return delay, faultNone, c
+}
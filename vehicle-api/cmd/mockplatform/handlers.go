@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/signature"
+)
+
+// 外部平台接口路径（与 etc/vehicle-api.yaml 中 VEHInfo/VEHPosition/VEHTrajectory/VEHRoute 的 url 一致）
+const (
+	basePath       = "/infraCloud/openapi/regionCloud/v1/api/base/vehicle/"
+	pathInfo       = basePath + "getAllList"
+	pathPosition   = basePath + "position"
+	pathRunPath    = basePath + "getRunpath"
+	pathRoutePage  = basePath + "getRoutePage"
+	pathFaults     = "/mock/faults"
+	defaultPageLen = 100
+)
+
+// platform 模拟平台的 HTTP 服务
+type platform struct {
+	store    *store
+	faults   *faults
+	verifier *signature.Verifier // 为 nil 时不校验签名
+}
+
+// resp 平台统一响应结构
+type resp struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+func (p *platform) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathInfo, p.endpoint("info", p.handleInfo))
+	mux.HandleFunc(pathPosition, p.endpoint("position", p.handlePosition))
+	mux.HandleFunc(pathRunPath, p.endpoint("runpath", p.handleRunPath))
+	mux.HandleFunc(pathRoutePage, p.endpoint("route", p.handleRoutePage))
+	mux.HandleFunc(pathFaults, p.handleFaults)
+	return mux
+}
+
+// endpoint 包装接口处理：限定 POST、校验签名、按故障配置注入延迟与错误，再解析请求体交给 fn
+func (p *platform) endpoint(name string, fn func(body []byte) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.verifier != nil {
+			h := r.Header
+			if err := p.verifier.Verify(h.Get(signature.HeaderAppId), h.Get(signature.HeaderTimestamp), h.Get(signature.HeaderNonce), h.Get(signature.HeaderSign), body); err != nil {
+				logx.Errorf("[%s] 签名校验失败 remote=%s err=%v", name, r.RemoteAddr, err)
+				writeJSON(w, http.StatusUnauthorized, resp{Code: http.StatusUnauthorized, Message: err.Error()})
+				return
+			}
+		}
+
+		delay, kind, f := p.faults.roll(name)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch kind {
+		case faultError:
+			logx.Infof("[%s] 注入故障: HTTP 500", name)
+			writeJSON(w, http.StatusInternalServerError, resp{Code: http.StatusInternalServerError, Message: "injected internal error"})
+			return
+		case faultBadCode:
+			code := f.BadCode
+			if code == 0 {
+				code = 500
+			}
+			logx.Infof("[%s] 注入故障: code=%d", name, code)
+			writeJSON(w, http.StatusOK, resp{Code: code, Message: "injected error code"})
+			return
+		case faultMalformed:
+			logx.Infof("[%s] 注入故障: 响应体格式错误", name)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"code":0,"message":"SUCCESS","data":`))
+			return
+		}
+
+		data, err := fn(body)
+		if err != nil {
+			writeJSON(w, http.StatusOK, resp{Code: 400, Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp{Code: 0, Message: "SUCCESS", Data: data})
+	}
+}
+
+// handleInfo 车辆信息列表：body {categoryCode}，不传返回全部车辆；data 为车辆数组
+func (p *platform) handleInfo(body []byte) (interface{}, error) {
+	var req struct {
+		CategoryCode int `json:"categoryCode"`
+	}
+	if err := unmarshalBody(body, &req); err != nil {
+		return nil, err
+	}
+	return p.store.vehicleList(req.CategoryCode), nil
+}
+
+// handlePosition 车辆位置与在线状态：body {categoryCode}；data 为 {position: [...]}
+func (p *platform) handlePosition(body []byte) (interface{}, error) {
+	var req struct {
+		CategoryCode int `json:"categoryCode"`
+	}
+	if err := unmarshalBody(body, &req); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"position": p.store.positionList(req.CategoryCode)}, nil
+}
+
+// handleRunPath 轨迹：body {vehicleId, startTime, endTime}（毫秒）；data 为 {runPath: [[lon, lat, timestamp], ...]}
+func (p *platform) handleRunPath(body []byte) (interface{}, error) {
+	var req struct {
+		VehicleId string `json:"vehicleId"`
+		StartTime int64  `json:"startTime"`
+		EndTime   int64  `json:"endTime"`
+	}
+	if err := unmarshalBody(body, &req); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"runPath": p.store.runPath(req.VehicleId, req.StartTime, req.EndTime)}, nil
+}
+
+// handleRoutePage 行程分页：body {vehicleId, startTime, endTime, page: {pageIndex, pageSize}}，pageIndex 从 0 开始；
+// data 为 {list, total, pageIndex, pageSize}
+func (p *platform) handleRoutePage(body []byte) (interface{}, error) {
+	var req struct {
+		VehicleId string `json:"vehicleId"`
+		StartTime int64  `json:"startTime"`
+		EndTime   int64  `json:"endTime"`
+		Page      struct {
+			PageIndex int `json:"pageIndex"`
+			PageSize  int `json:"pageSize"`
+		} `json:"page"`
+	}
+	if err := unmarshalBody(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Page.PageSize <= 0 {
+		req.Page.PageSize = defaultPageLen
+	}
+	if req.Page.PageIndex < 0 {
+		req.Page.PageIndex = 0
+	}
+	list, total := p.store.tripPage(req.VehicleId, req.StartTime, req.EndTime, req.Page.PageIndex, req.Page.PageSize)
+	return map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"pageIndex": req.Page.PageIndex,
+		"pageSize":  req.Page.PageSize,
+	}, nil
+}
+
+// handleFaults 查看或修改故障注入配置：
+//
+//	GET    /mock/faults                           返回各接口的故障配置
+//	POST   /mock/faults?endpoint=position {fault} 设置某接口（endpoint 为空时设置默认值）
+//	DELETE /mock/faults                           清除全部故障
+func (p *platform) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var f fault
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		endpoint := r.URL.Query().Get("endpoint")
+		p.faults.set(endpoint, f)
+		logx.Infof("故障注入配置已更新 endpoint=%q fault=%+v", endpoint, f)
+	case http.MethodDelete:
+		p.faults.reset()
+		logx.Infof("故障注入配置已清除")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.faults.snapshot())
+}
+
+func unmarshalBody(body []byte, v interface{}) error {
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
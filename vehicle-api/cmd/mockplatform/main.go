@@ -0,0 +1,114 @@
+// mockplatform 外部车辆平台 HTTP 接口的本地模拟：实现 VEHInfo（getAllList）、VEHPosition（position）、
+// VEHTrajectory（getRunpath）与 VEHRoute（getRoutePage）四个签名接口，请求与响应结构与 vehicle-api 的调用方一致。
+// 数据来自数据文件（-fixture），或订阅车辆状态 WebSocket（-sim，通常为 cmd/simulator）实时生成：
+// 车辆信息由车辆编号生成，位置取最近一条状态，轨迹为收到的全部点，行程按停车切分。
+// 支持按接口注入延迟、HTTP 500、非 0 code 与格式错误的响应，运行时可通过 /mock/faults 调整。
+//
+// 用法：
+//
+//	go run ./cmd/mockplatform -fixture cmd/mockplatform/fixtures/platform.json
+//	go run ./cmd/mockplatform -sim ws://127.0.0.1:34037/infraCloud/openapi/regionCloud/open-api/v1/ws/can
+//	go run ./cmd/mockplatform -sim ... -error-rate 0.1 -latency 500ms
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/apiclient"
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/signature"
+	"vehicle-api/internal/types"
+)
+
+var (
+	configFile  = flag.String("f", "etc/vehicle-api.yaml", "vehicle-api 配置文件，读取其中的 AppId/Key 作为鉴权凭证（-appId/-key 优先）")
+	addr        = flag.String("addr", ":34035", "监听地址")
+	appId       = flag.String("appId", "", "鉴权 appId")
+	key         = flag.String("key", "", "鉴权 key")
+	noAuth      = flag.Bool("noauth", false, "不校验签名")
+	window      = flag.Duration("window", 5*time.Minute, "签名时间戳允许的偏差与 nonce 保留时长")
+	fixtureFile = flag.String("fixture", "", "数据文件（vehicles/positions/routes/runPaths）")
+	simURL      = flag.String("sim", "", "订阅的车辆状态 WebSocket 地址（如 cmd/simulator），收到的状态实时生成平台数据")
+	onlineTTL   = flag.Duration("online-ttl", 30*time.Second, "订阅模式下超过该时长未收到状态的车辆视为离线")
+	latency     = flag.Duration("latency", 0, "全部接口的固定延迟")
+	jitter      = flag.Duration("jitter", 0, "全部接口的随机额外延迟上限")
+	errorRate   = flag.Float64("error-rate", 0, "返回 HTTP 500 的比例（0~1）")
+	badCodeRate = flag.Float64("badcode-rate", 0, "返回 code 非 0 的比例（0~1）")
+	malformed   = flag.Float64("malformed-rate", 0, "返回格式错误响应体的比例（0~1）")
+)
+
+func main() {
+	flag.Parse()
+	logx.DisableStat()
+
+	if !*noAuth && (*appId == "" || *key == "") {
+		var c struct {
+			AppId string `json:",optional"`
+			Key   string `json:",optional"`
+		}
+		if err := conf.Load(*configFile, &c); err != nil {
+			fatalf("读取配置文件 %s 失败: %v（可使用 -appId/-key 或 -noauth）", *configFile, err)
+		}
+		if *appId == "" {
+			*appId = c.AppId
+		}
+		if *key == "" {
+			*key = c.Key
+		}
+		if *appId == "" || *key == "" {
+			fatalf("未配置鉴权 appId/key（可使用 -appId/-key 或 -noauth）")
+		}
+	}
+	if *fixtureFile == "" && *simURL == "" {
+		fatalf("需要 -fixture 或 -sim 提供数据")
+	}
+
+	st := newStore()
+	if *fixtureFile != "" {
+		if err := st.loadFixture(*fixtureFile); err != nil {
+			fatalf("加载数据文件失败: %v", err)
+		}
+	}
+	if *simURL != "" {
+		st.live, st.onlineTTL = true, *onlineTTL
+		cfg := config.VEHStateConfig{URL: *simURL, HeartbeatTimer: 5}
+		client := apiclient.NewVEHStateClient(cfg, *appId, *key, func(d *types.VehicleStateData) error {
+			st.observe(d)
+			return nil
+		})
+		client.SetName("mockplatform")
+		go client.Start(context.Background())
+	}
+
+	p := &platform{
+		store: st,
+		faults: newFaults(fault{
+			LatencyMs:     int(latency.Milliseconds()),
+			JitterMs:      int(jitter.Milliseconds()),
+			ErrorRate:     *errorRate,
+			BadCodeRate:   *badCodeRate,
+			MalformedRate: *malformed,
+		}),
+	}
+	if !*noAuth {
+		p.verifier = signature.NewVerifier(map[string]string{*appId: *key}, *window)
+	}
+
+	logx.Infof("外部平台模拟服务已启动 addr=%s vehicles=%d fixture=%s sim=%s", *addr, len(st.vehicleList(0)), *fixtureFile, *simURL)
+	if err := http.ListenAndServe(*addr, p.routes()); err != nil {
+		fatalf("监听失败: %v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"vehicle-api/internal/geo"
+	"vehicle-api/internal/types"
+)
+
+// position 车辆位置（VEHPosition 接口 data.position 元素）
+type position struct {
+	VehicleId string  `json:"vehicleId"`
+	Lon       float64 `json:"lon"`
+	Lat       float64 `json:"lat"`
+	Online    bool    `json:"online"`
+	Timestamp int64   `json:"timestamp"` // 毫秒
+}
+
+// trip 一条行程（VEHRoute 接口 data.list 元素），时间为毫秒，里程为公里，时长为秒
+type trip struct {
+	RouteId            string  `json:"routeId"`
+	VehicleId          string  `json:"vehicleId"`
+	Vin                string  `json:"vin"`
+	PlateNo            string  `json:"plateNo"`
+	StartTime          int64   `json:"startTime"`
+	EndTime            int64   `json:"endTime"`
+	Mileage            float64 `json:"mileage"`
+	DurationTime       float64 `json:"durationTime"`
+	AutoMileage        float64 `json:"autoMileage"`
+	AutoDuration       float64 `json:"autoDuration"`
+	AutoMileageReal    float64 `json:"autoMileageReal"`
+	AutoDurationReal   float64 `json:"autoDurationReal"`
+	VehicleFactory     string  `json:"vehicleFactory"`
+	VehicleFactoryName string  `json:"vehicleFactoryName"`
+}
+
+// pathPoint 轨迹点，序列化为 [lon, lat, timestamp(ms)]
+type pathPoint [3]float64
+
+// fixture 数据文件格式
+type fixture struct {
+	Vehicles  []types.VehicleInfo    `json:"vehicles"`
+	Positions []position             `json:"positions"`
+	Routes    []trip                 `json:"routes"`
+	RunPaths  map[string][]pathPoint `json:"runPaths"` // vehicleId -> 轨迹点
+}
+
+// 订阅模式下的行程切分与保留参数
+const (
+	tripStopSpeed = 0.5  // 低于该速度（m/s）视为停车
+	tripMinMeters = 20.0 // 短于该距离的行程丢弃
+	maxPathPoints = 50000
+	maxTrips      = 2000
+)
+
+// store 模拟平台的数据：从数据文件加载，或订阅车辆状态实时生成
+type store struct {
+	mu        sync.RWMutex
+	vehicles  map[string]*types.VehicleInfo
+	positions map[string]*position
+	trips     map[string][]trip
+	paths     map[string][]pathPoint
+
+	// 订阅模式
+	live      bool
+	onlineTTL time.Duration
+	lastSeen  map[string]time.Time
+	open      map[string]*openTrip
+	tripSeq   uint64
+}
+
+// openTrip 进行中的行程
+type openTrip struct {
+	start, last      int64
+	meters, auto     float64
+	autoMs           int64
+	lastLon, lastLat float64
+	lastMode         int
+}
+
+func newStore() *store {
+	return &store{
+		vehicles:  make(map[string]*types.VehicleInfo),
+		positions: make(map[string]*position),
+		trips:     make(map[string][]trip),
+		paths:     make(map[string][]pathPoint),
+		lastSeen:  make(map[string]time.Time),
+		open:      make(map[string]*openTrip),
+	}
+}
+
+// loadFixture 从数据文件加载全部数据
+func (s *store) loadFixture(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range f.Vehicles {
+		v := f.Vehicles[i]
+		s.vehicles[v.VehicleId] = &v
+	}
+	for i := range f.Positions {
+		p := f.Positions[i]
+		s.positions[p.VehicleId] = &p
+	}
+	for _, t := range f.Routes {
+		s.trips[t.VehicleId] = append(s.trips[t.VehicleId], t)
+	}
+	for id, pts := range f.RunPaths {
+		sort.Slice(pts, func(i, j int) bool { return pts[i][2] < pts[j][2] })
+		s.paths[id] = pts
+	}
+	for id := range s.trips {
+		sortTrips(s.trips[id])
+	}
+	return nil
+}
+
+func sortTrips(ts []trip) {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].StartTime < ts[j].StartTime })
+}
+
+// observe 订阅模式下记录一条车辆状态：更新车辆信息与位置、追加轨迹点，并按停车切分行程
+func (s *store) observe(d *types.VehicleStateData) {
+	ts := int64(d.Timestamp)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.vehicles[d.VehicleId]; !ok {
+		s.vehicles[d.VehicleId] = s.synthesize(d)
+	}
+	s.positions[d.VehicleId] = &position{VehicleId: d.VehicleId, Lon: d.Lon, Lat: d.Lat, Online: true, Timestamp: ts}
+	s.lastSeen[d.VehicleId] = time.Now()
+
+	pts := append(s.paths[d.VehicleId], pathPoint{d.Lon, d.Lat, float64(ts)})
+	if len(pts) > maxPathPoints {
+		pts = pts[len(pts)-maxPathPoints:]
+	}
+	s.paths[d.VehicleId] = pts
+
+	ot := s.open[d.VehicleId]
+	switch {
+	case ot == nil && d.Speed >= tripStopSpeed:
+		s.open[d.VehicleId] = &openTrip{start: ts, last: ts, lastLon: d.Lon, lastLat: d.Lat, lastMode: d.DriveMode}
+	case ot != nil:
+		m := geo.HaversineMeters(ot.lastLat, ot.lastLon, d.Lat, d.Lon)
+		ot.meters += m
+		if ot.lastMode == 2 {
+			ot.auto += m
+			ot.autoMs += ts - ot.last
+		}
+		ot.last, ot.lastLon, ot.lastLat, ot.lastMode = ts, d.Lon, d.Lat, d.DriveMode
+		if d.Speed < tripStopSpeed {
+			s.closeTrip(d.VehicleId, ot)
+		}
+	}
+}
+
+// closeTrip 结束进行中的行程（调用方持有 s.mu）
+func (s *store) closeTrip(vehicleId string, ot *openTrip) {
+	delete(s.open, vehicleId)
+	if ot.meters < tripMinMeters {
+		return
+	}
+	s.tripSeq++
+	v := s.vehicles[vehicleId]
+	ts := append(s.trips[vehicleId], trip{
+		RouteId:            fmt.Sprintf("R%d%06d", ot.start/1000, s.tripSeq),
+		VehicleId:          vehicleId,
+		Vin:                v.VinCode,
+		PlateNo:            v.PlateNo,
+		StartTime:          ot.start,
+		EndTime:            ot.last,
+		Mileage:            round2(ot.meters / 1000),
+		DurationTime:       float64((ot.last - ot.start) / 1000),
+		AutoMileage:        round2(ot.auto / 1000),
+		AutoDuration:       float64(ot.autoMs / 1000),
+		AutoMileageReal:    round2(ot.auto / 1000),
+		AutoDurationReal:   float64(ot.autoMs / 1000),
+		VehicleFactory:     v.VehicleFactory,
+		VehicleFactoryName: v.Brand,
+	})
+	if len(ts) > maxTrips {
+		ts = ts[len(ts)-maxTrips:]
+	}
+	s.trips[vehicleId] = ts
+}
+
+// synthesize 为订阅到的新车辆生成稳定的车辆信息（车牌、VIN 由车辆编号哈希得到）
+func (s *store) synthesize(d *types.VehicleStateData) *types.VehicleInfo {
+	h := fnv.New32a()
+	h.Write([]byte(d.VehicleId))
+	sum := h.Sum32()
+	return &types.VehicleInfo{
+		VehicleId:      d.VehicleId,
+		PlateNo:        fmt.Sprintf("渝A%05d", sum%100000),
+		CategoryCode:   d.CategoryCode,
+		CategoryName:   fmt.Sprintf("类型%d", d.CategoryCode),
+		VinCode:        fmt.Sprintf("LSIM%013d", sum),
+		VehicleFactory: "SIM",
+		Brand:          "模拟车辆",
+		Size:           "4.5*1.8*1.9",
+		AutoLevel:      "L4",
+		CreateTime:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+func round2(x float64) float64 {
+	return float64(int64(x*100+0.5)) / 100
+}
+
+// vehicleList 返回车辆信息，categoryCode 为 0 时返回全部
+func (s *store) vehicleList(categoryCode int) []types.VehicleInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]types.VehicleInfo, 0, len(s.vehicles))
+	for _, v := range s.vehicles {
+		if categoryCode == 0 || v.CategoryCode == categoryCode {
+			out = append(out, *v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleId < out[j].VehicleId })
+	return out
+}
+
+// positionList 返回车辆位置；订阅模式下超过 onlineTTL 未收到数据的车辆视为离线
+func (s *store) positionList(categoryCode int) []position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]position, 0, len(s.positions))
+	for id, p := range s.positions {
+		if categoryCode != 0 {
+			if v, ok := s.vehicles[id]; !ok || v.CategoryCode != categoryCode {
+				continue
+			}
+		}
+		cp := *p
+		if s.live {
+			cp.Online = time.Since(s.lastSeen[id]) <= s.onlineTTL
+		}
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleId < out[j].VehicleId })
+	return out
+}
+
+// tripPage 返回与 [start, end] 有交集的行程的第 pageIndex 页（从 0 开始）与总数
+func (s *store) tripPage(vehicleId string, start, end int64, pageIndex, pageSize int) ([]trip, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []trip
+	for _, t := range s.trips[vehicleId] {
+		if (end == 0 || t.StartTime <= end) && (start == 0 || t.EndTime >= start) {
+			matched = append(matched, t)
+		}
+	}
+	from := pageIndex * pageSize
+	if from >= len(matched) {
+		return []trip{}, len(matched)
+	}
+	to := from + pageSize
+	if to > len(matched) {
+		to = len(matched)
+	}
+	return matched[from:to], len(matched)
+}
+
+// runPath 返回 [start, end] 内的轨迹点
+func (s *store) runPath(vehicleId string, start, end int64) []pathPoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pts := s.paths[vehicleId]
+	i := sort.Search(len(pts), func(i int) bool { return int64(pts[i][2]) >= start })
+	out := make([]pathPoint, 0)
+	for ; i < len(pts); i++ {
+		if end != 0 && int64(pts[i][2]) > end {
+			break
+		}
+		out = append(out, pts[i])
+	}
+	return out
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"vehicle-api/internal/types"
+)
+
+// 驾驶模式（模拟取值，外部平台未公开枚举定义）
+const (
+	driveManual = 1 // 人工驾驶
+	driveAuto   = 2 // 自动驾驶
+	driveRemote = 3 // 远程驾驶
+)
+
+// 车辆动力学与能耗参数
+const (
+	accelMax    = 1.5   // 起步/加速（m/s²）
+	decelNormal = 2.5   // 进站制动（m/s²）
+	decelAEB    = 6.0   // AEB 紧急制动（m/s²）
+	idleSocRate = 0.002 // 行驶中附件耗电（%/s）
+	chargeRate  = 0.2   // 站点充电速度（%/s），为便于观察远快于真实充电
+	lowSoc      = 20.0  // 低于该电量时在下一站充电
+	fullSoc     = 90.0  // 充电截止电量
+)
+
+// vehicle 一辆模拟车辆的运动与状态，只在 fleet 的推进协程中访问
+type vehicle struct {
+	id       string
+	category int
+	route    *route
+
+	s       float64 // 距路线起点的距离（米）
+	dir     int     // 行驶方向：1 正向，-1 反向（非环线在两端折返）
+	next    int     // 下一个停靠点在 route.Stops 中的下标
+	speed   float64 // m/s
+	accel   float64 // 本次推进的纵向加速度（m/s²）
+	heading float64
+	cruise  float64 // 当前巡航目标速度（m/s）
+	cruiseT float64 // 距下次调整巡航速度的秒数
+
+	dwell    float64 // 剩余停站秒数，>0 表示停站中
+	charging bool
+
+	soc       float64 // %
+	socPerKm  float64 // 每公里耗电（%）
+	mileage   float64 // km
+	driveMode int
+	modeT     float64 // 距下次切换驾驶模式的秒数
+
+	// ADAS/故障事件剩余持续秒数
+	fcwT, aebT, ldwT, dmsT, faultT float64
+}
+
+// fleet 按固定步长推进全部车辆
+type fleet struct {
+	rng      *rand.Rand
+	vehicles []*vehicle
+}
+
+// newFleet 创建 n 辆车：优先使用 route-api 中路线下属的车辆编号，其余以 prefix 加序号命名，
+// 按路线轮流分配，起始位置、方向、电量与里程随机
+func newFleet(routes []*route, n int, prefix string, categories []int, rng *rand.Rand) *fleet {
+	f := &fleet{rng: rng}
+	add := func(id string, r *route) {
+		v := &vehicle{
+			id:        id,
+			category:  categories[len(f.vehicles)%len(categories)],
+			route:     r,
+			s:         rng.Float64() * r.length(),
+			dir:       1,
+			soc:       40 + rng.Float64()*55,
+			socPerKm:  0.25 + rng.Float64()*0.15,
+			mileage:   1000 + rng.Float64()*19000,
+			driveMode: driveManual,
+			modeT:     60 + rng.Float64()*240,
+		}
+		if !r.loop && rng.Intn(2) == 0 {
+			v.dir = -1
+		}
+		v.next = r.nextStop(v.s, v.dir)
+		_, v.heading = r.locate(v.s)
+		if v.dir < 0 {
+			v.heading = math.Mod(v.heading+180, 360)
+		}
+		f.vehicles = append(f.vehicles, v)
+	}
+	for _, r := range routes {
+		for _, id := range r.Vehicles {
+			if len(f.vehicles) < n {
+				add(id, r)
+			}
+		}
+	}
+	for i := 0; len(f.vehicles) < n; i++ {
+		add(fmt.Sprintf("%s%04d", prefix, len(f.vehicles)+1), routes[i%len(routes)])
+	}
+	return f
+}
+
+// nextStop 返回从 s 处沿 dir 方向行驶遇到的下一个停靠点下标
+func (r *route) nextStop(s float64, dir int) int {
+	if dir > 0 {
+		for k, idx := range r.Stops {
+			if r.cum[idx] > s {
+				return k
+			}
+		}
+		return len(r.Stops) - 1
+	}
+	for k := len(r.Stops) - 1; k >= 0; k-- {
+		if r.cum[r.Stops[k]] < s {
+			return k
+		}
+	}
+	return 0
+}
+
+// step 推进全部车辆 dt 秒
+func (f *fleet) step(dt float64) {
+	for _, v := range f.vehicles {
+		v.step(dt, f.rng)
+	}
+}
+
+func (v *vehicle) step(dt float64, rng *rand.Rand) {
+	r := v.route
+	v.tickEvents(dt, rng)
+
+	if v.dwell > 0 {
+		v.speed, v.accel = 0, 0
+		v.dwell -= dt
+		if v.charging {
+			v.soc = math.Min(100, v.soc+chargeRate*dt)
+			if v.soc < fullSoc {
+				v.dwell = math.Max(v.dwell, dt)
+			} else {
+				v.charging = false
+			}
+		}
+		if v.dwell <= 0 {
+			v.dwell = 0
+			v.depart()
+		}
+		return
+	}
+
+	// 巡航速度每 20~60 秒随机调整一次（7~16 m/s）
+	v.cruiseT -= dt
+	if v.cruiseT <= 0 {
+		v.cruise = 7 + rng.Float64()*9
+		v.cruiseT = 20 + rng.Float64()*40
+	}
+
+	stopAt := r.cum[r.Stops[v.next]]
+	dist := math.Abs(stopAt - v.s)
+	// 按匀减速距离限制速度，保证在停靠点前停下
+	target := math.Min(v.cruise, math.Sqrt(2*decelNormal*dist))
+	decel := decelNormal
+	if v.aebT > 0 {
+		target, decel = 0, decelAEB
+	} else if target < 1 && dist > 0 {
+		target = 1
+	}
+	v.accel = math.Max(-decel, math.Min(accelMax, (target-v.speed)/dt))
+	v.speed = math.Max(0, v.speed+v.accel*dt)
+
+	ds := v.speed * dt
+	arrived := ds >= dist
+	if arrived {
+		ds = dist
+	}
+	v.s += float64(v.dir) * ds
+	v.mileage += ds / 1000
+	v.soc = math.Max(0, v.soc-v.socPerKm*ds/1000-idleSocRate*dt)
+	if ds > 0 {
+		_, h := r.locate(v.s - float64(v.dir)*0.01)
+		if v.dir < 0 {
+			h = math.Mod(h+180, 360)
+		}
+		v.heading = h
+	}
+	if arrived {
+		v.s, v.speed = stopAt, 0
+		v.dwell = 10 + rng.Float64()*30
+		v.charging = v.soc < lowSoc
+	}
+}
+
+// depart 离开当前停靠点：确定下一个停靠点，非环线到达端点时折返，环线到达终点时回到起点
+func (v *vehicle) depart() {
+	r := v.route
+	last := len(r.Stops) - 1
+	switch {
+	case r.loop && v.next == last:
+		v.s, v.next = 0, 1
+	case !r.loop && v.dir > 0 && v.next == last:
+		v.dir, v.next = -1, last-1
+	case !r.loop && v.dir < 0 && v.next == 0:
+		v.dir, v.next = 1, 1
+	default:
+		v.next += v.dir
+	}
+}
+
+// tickEvents 推进驾驶模式切换与随机 ADAS/故障事件
+func (v *vehicle) tickEvents(dt float64, rng *rand.Rand) {
+	v.modeT -= dt
+	if v.modeT <= 0 {
+		switch {
+		case v.driveMode != driveManual:
+			v.driveMode = driveManual
+			v.modeT = 120 + rng.Float64()*480
+		case rng.Float64() < 0.8:
+			v.driveMode = driveAuto
+			v.modeT = 120 + rng.Float64()*480
+		default:
+			v.driveMode = driveRemote
+			v.modeT = 30 + rng.Float64()*60
+		}
+	}
+
+	dec := func(t *float64) {
+		if *t > 0 {
+			*t = math.Max(0, *t-dt)
+		}
+	}
+	dec(&v.fcwT)
+	dec(&v.aebT)
+	dec(&v.ldwT)
+	dec(&v.dmsT)
+	dec(&v.faultT)
+
+	// 事件概率按每秒计算：前碰预警约每 15 分钟一次（30% 升级为 AEB 紧急制动），
+	// 人工驾驶时车道偏离约每 20 分钟、驾驶员分心约每 25 分钟一次，车辆故障极少
+	chance := func(perSec float64) bool { return rng.Float64() < perSec*dt }
+	if v.speed > 3 && v.fcwT == 0 && chance(1.0/900) {
+		v.fcwT = 2
+		if rng.Float64() < 0.3 {
+			v.aebT = 3
+		}
+	}
+	if v.driveMode == driveManual && v.speed > 5 {
+		if v.ldwT == 0 && chance(1.0/1200) {
+			v.ldwT = 2
+		}
+		if v.dmsT == 0 && chance(1.0/1500) {
+			v.dmsT = 3
+		}
+	}
+	if v.faultT == 0 && chance(1.0/20000) {
+		v.faultT = 60
+	}
+}
+
+// state 生成当前状态（VehicleStateResp.data）
+func (v *vehicle) state(now time.Time) types.VehicleStateData {
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	d := types.VehicleStateData{
+		CategoryCode:  v.category,
+		VehicleId:     v.id,
+		Timestamp:     uint64(now.UnixMilli()),
+		Speed:         round(v.speed, 2),
+		Heading:       round(v.heading, 4),
+		DriveMode:     v.driveMode,
+		TapPos:        flag(v.dwell == 0), // 模拟取值：0=P 1=D
+		BrakeFlag:     flag(v.accel < 0 || v.dwell > 0),
+		AbsFlag:       flag(v.aebT > 0),
+		EspFlag:       flag(v.aebT > 0),
+		LkaFlag:       flag(v.driveMode == driveAuto),
+		AccMode:       flag(v.driveMode == driveAuto),
+		FcwFlag:       flag(v.fcwT > 0),
+		LdwFlag:       flag(v.ldwT > 0),
+		AebFlag:       flag(v.aebT > 0),
+		DmsFlag:       flag(v.dmsT > 0),
+		Soc:           round(v.soc, 2),
+		Mileage:       round(v.mileage, 1),
+		AccelerationV: round(v.accel, 2),
+		HazardSignal:  flag(v.aebT > 0),
+		Parking:       flag(v.dwell > 0),
+		VehFault:      flag(v.faultT > 0),
+		Doors:         []int{flag(v.dwell > 2)},
+	}
+	p, _ := v.route.locate(v.s)
+	d.Lon, d.Lat = round(p.Lon, 7), round(p.Lat, 7)
+	if v.accel > 0 {
+		d.AccelPos = round(v.accel/accelMax*100, 1)
+	} else if v.accel < 0 {
+		d.BrakePos = round(-v.accel/decelAEB*100, 1)
+	}
+	// 前方 30 米内航向变化超过 25° 时打转向灯
+	if v.dwell == 0 {
+		_, ahead := v.route.locate(v.s + float64(v.dir)*30)
+		if v.dir < 0 {
+			ahead = math.Mod(ahead+180, 360)
+		}
+		turn := math.Mod(ahead-v.heading+540, 360) - 180
+		d.RightTurn = flag(turn > 25)
+		d.LeftTurn = flag(turn < -25)
+		// 横向加速度按 v²·曲率粗略估计，曲率取 30 米内的航向变化
+		d.AccelerationH = round(v.speed*v.speed*(turn*math.Pi/180)/30, 2)
+	}
+	if h := now.Hour(); h >= 18 || h < 7 {
+		d.LowBeam = 1
+	} else {
+		d.DaytimeRunning = 1
+	}
+	return d
+}
+
+func round(x float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(x*p) / p
+}
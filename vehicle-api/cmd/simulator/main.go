@@ -0,0 +1,194 @@
+// simulator 本地车队模拟器：生成 N 辆沿路线行驶的车辆（速度、航向、SOC 消耗、驾驶模式切换、
+// 随机 ADAS 事件），以外部平台 VEHState WebSocket 的 VehicleStateResp 格式推送，
+// 并使用与 VEHStateClient 相同的 timestamp/nonce/appId/sign 鉴权。
+// 把 vehicle-api 的 VEHState.url 指向 ws://<addr><path> 即可在离线环境下使用完整的大屏。
+//
+// 用法：
+//
+//	go run ./cmd/simulator -f etc/vehicle-api.yaml -n 20
+//	go run ./cmd/simulator -geojson routes.geojson
+//	go run ./cmd/simulator -routes http://localhost:18889 -stations stations.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/types"
+)
+
+var (
+	configFile = flag.String("f", "etc/vehicle-api.yaml", "vehicle-api 配置文件，读取其中的 AppId/Key 作为鉴权凭证（-appId/-key 优先）")
+	addr       = flag.String("addr", ":34037", "监听地址")
+	path       = flag.String("path", "/infraCloud/openapi/regionCloud/open-api/v1/ws/can", "WebSocket 路径")
+	appId      = flag.String("appId", "", "鉴权 appId")
+	key        = flag.String("key", "", "鉴权 appSecret")
+	noAuth     = flag.Bool("noauth", false, "不校验签名")
+	skew       = flag.Duration("skew", 5*time.Minute, "允许的 timestamp 偏差")
+	count      = flag.Int("n", 10, "车辆数")
+	prefix     = flag.String("prefix", "SIM", "生成的车辆编号前缀")
+	categories = flag.String("categories", "1", "车辆类型编码，逗号分隔，按车辆轮流分配")
+	interval   = flag.Duration("interval", time.Second, "每辆车的上报间隔")
+	geojson    = flag.String("geojson", "", "GeoJSON 路线文件（LineString / MultiLineString）")
+	routeAPI   = flag.String("routes", "", "route-api 路线：服务地址（如 http://localhost:18889）或 /api/route/list 响应 JSON 文件")
+	stations   = flag.String("stations", "", "站点坐标文件，配合 -routes 使用")
+	center     = flag.String("center", "106.336035,29.562830", "未提供路线时生成环线的中心点（lon,lat）")
+	seed       = flag.Int64("seed", 0, "随机种子，0 表示使用当前时间")
+)
+
+func main() {
+	flag.Parse()
+	logx.DisableStat()
+
+	if !*noAuth && (*appId == "" || *key == "") {
+		var c struct {
+			AppId string `json:",optional"`
+			Key   string `json:",optional"`
+		}
+		if err := conf.Load(*configFile, &c); err != nil {
+			fatalf("读取配置文件 %s 失败: %v（可使用 -appId/-key 或 -noauth）", *configFile, err)
+		}
+		if *appId == "" {
+			*appId = c.AppId
+		}
+		if *key == "" {
+			*key = c.Key
+		}
+		if *appId == "" || *key == "" {
+			fatalf("未配置鉴权 appId/key（可使用 -appId/-key 或 -noauth）")
+		}
+	}
+
+	routes, err := loadRoutes()
+	if err != nil {
+		fatalf("加载路线失败: %v", err)
+	}
+	cats, err := parseInts(*categories)
+	if err != nil || len(cats) == 0 {
+		fatalf("-categories 格式错误: %s", *categories)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	f := newFleet(routes, *count, *prefix, cats, rand.New(rand.NewSource(*seed)))
+
+	ids := make(map[string]struct{}, len(f.vehicles))
+	for _, v := range f.vehicles {
+		ids[v.id] = struct{}{}
+	}
+	srv := newServer(*appId, *key, *noAuth, *skew, func(id string) bool {
+		_, ok := ids[id]
+		return ok
+	})
+	go run(f, srv)
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, srv)
+	logx.Infof("车队模拟器已启动 ws://%s%s vehicles=%d routes=%d interval=%s seed=%d", *addr, *path, len(f.vehicles), len(routes), *interval, *seed)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fatalf("监听失败: %v", err)
+	}
+}
+
+// loadRoutes 按 -geojson、-routes、默认环线的优先级加载路线
+func loadRoutes() ([]*route, error) {
+	var routes []*route
+	var err error
+	switch {
+	case *geojson != "":
+		routes, err = loadGeoJSON(*geojson)
+	case *routeAPI != "":
+		if *stations == "" {
+			return nil, fmt.Errorf("-routes 需要配合 -stations 提供站点坐标")
+		}
+		var st map[string]point
+		if st, err = loadStations(*stations); err == nil {
+			routes, err = loadRouteAPI(*routeAPI, st)
+		}
+	default:
+		var c []float64
+		if c, err = parseFloats(*center); err == nil && len(c) != 2 {
+			err = fmt.Errorf("-center 格式错误: %s", *center)
+		}
+		if err == nil {
+			routes = defaultRoutes(point{Lon: c[0], Lat: c[1]}, 4)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes {
+		if err := r.prepare(); err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+// run 按上报间隔推进车队，并把每辆车的状态推送给全部连接
+func run(f *fleet, srv *server) {
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	report := time.NewTicker(time.Minute)
+	defer report.Stop()
+	last := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			f.step(now.Sub(last).Seconds())
+			last = now
+			for _, v := range f.vehicles {
+				msg, err := json.Marshal(types.VehicleStateResp{Code: 0, Message: "success", Data: v.state(now)})
+				if err != nil {
+					logx.Errorf("序列化车辆状态失败 vehicleId=%s err=%v", v.id, err)
+					continue
+				}
+				srv.broadcast(msg)
+			}
+		case <-report.C:
+			logx.Infof("车队模拟器运行中 clients=%d vehicles=%d dropped=%d", srv.clientCount(), len(f.vehicles), srv.dropped.Load())
+		}
+	}
+}
+
+func parseInts(s string) ([]int, error) {
+	var out []int
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseFloats(s string) ([]float64, error) {
+	var out []float64
+	for _, p := range strings.Split(s, ",") {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"vehicle-api/internal/geo"
+)
+
+// point 经纬度坐标
+type point struct {
+	Lon, Lat float64
+}
+
+// route 车辆行驶的折线路线；stops 为需要停靠的顶点下标（升序，含起终点）
+type route struct {
+	Name     string
+	Points   []point
+	Stops    []int
+	Vehicles []string // route-api 中该路线下属的车辆编号
+
+	cum  []float64 // 各顶点距起点的累计距离（米）
+	loop bool      // 首尾相接的环线：到达终点后从起点继续，否则折返
+}
+
+// length 路线总长（米）
+func (r *route) length() float64 {
+	return r.cum[len(r.cum)-1]
+}
+
+// prepare 计算累计距离并校验路线；未指定停靠点时在起终点停靠
+func (r *route) prepare() error {
+	if len(r.Points) < 2 {
+		return fmt.Errorf("route %s: at least 2 points required", r.Name)
+	}
+	r.cum = make([]float64, len(r.Points))
+	for i := 1; i < len(r.Points); i++ {
+		a, b := r.Points[i-1], r.Points[i]
+		r.cum[i] = r.cum[i-1] + geo.HaversineMeters(a.Lat, a.Lon, b.Lat, b.Lon)
+	}
+	if r.length() < 50 {
+		return fmt.Errorf("route %s: too short (%.0fm)", r.Name, r.length())
+	}
+	// 起终点总是停靠点，保证车辆在两端折返或绕回
+	last := len(r.Points) - 1
+	if len(r.Stops) == 0 || r.Stops[0] != 0 {
+		r.Stops = append([]int{0}, r.Stops...)
+	}
+	if r.Stops[len(r.Stops)-1] != last {
+		r.Stops = append(r.Stops, last)
+	}
+	first, end := r.Points[0], r.Points[last]
+	r.loop = geo.HaversineMeters(first.Lat, first.Lon, end.Lat, end.Lon) < 20
+	return nil
+}
+
+// locate 返回距起点 s 米处的坐标与所在路段的航向角（度，正北为 0 顺时针）
+func (r *route) locate(s float64) (point, float64) {
+	s = math.Max(0, math.Min(s, r.length()))
+	i := 1
+	for i < len(r.cum)-1 && r.cum[i] < s {
+		i++
+	}
+	a, b := r.Points[i-1], r.Points[i]
+	seg := r.cum[i] - r.cum[i-1]
+	f := 0.0
+	if seg > 0 {
+		f = (s - r.cum[i-1]) / seg
+	}
+	p := point{Lon: a.Lon + (b.Lon-a.Lon)*f, Lat: a.Lat + (b.Lat-a.Lat)*f}
+	return p, bearing(a, b)
+}
+
+// bearing 计算 a 到 b 的方位角（度，正北为 0 顺时针）
+func bearing(a, b point) float64 {
+	toRad := math.Pi / 180
+	lat1, lat2 := a.Lat*toRad, b.Lat*toRad
+	dLon := (b.Lon - a.Lon) * toRad
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)/toRad+360, 360)
+}
+
+// loadGeoJSON 从 GeoJSON 文件读取路线：支持 FeatureCollection / Feature / 裸 Geometry，
+// 使用其中的 LineString 与 MultiLineString（每条线一条路线），名称取 properties.name
+func loadGeoJSON(path string) ([]*route, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var obj geoObject
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, fmt.Errorf("parse geojson %s: %w", path, err)
+	}
+	var out []*route
+	var walk func(o geoObject, name string)
+	walk = func(o geoObject, name string) {
+		if n, ok := o.Properties["name"].(string); ok && n != "" {
+			name = n
+		}
+		switch o.Type {
+		case "FeatureCollection":
+			for i, f := range o.Features {
+				walk(f, fmt.Sprintf("%s-%d", name, i+1))
+			}
+		case "Feature":
+			if o.Geometry != nil {
+				walk(*o.Geometry, name)
+			}
+		case "LineString":
+			var coords [][]float64
+			if json.Unmarshal(o.Coordinates, &coords) == nil {
+				out = append(out, &route{Name: name, Points: toPoints(coords)})
+			}
+		case "MultiLineString":
+			var lines [][][]float64
+			if json.Unmarshal(o.Coordinates, &lines) == nil {
+				for i, coords := range lines {
+					out = append(out, &route{Name: fmt.Sprintf("%s-%d", name, i+1), Points: toPoints(coords)})
+				}
+			}
+		}
+	}
+	walk(obj, "geojson")
+	if len(out) == 0 {
+		return nil, fmt.Errorf("geojson %s: no LineString found", path)
+	}
+	return out, nil
+}
+
+type geoObject struct {
+	Type        string                 `json:"type"`
+	Features    []geoObject            `json:"features"`
+	Geometry    *geoObject             `json:"geometry"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+	Properties  map[string]interface{} `json:"properties"`
+}
+
+func toPoints(coords [][]float64) []point {
+	pts := make([]point, 0, len(coords))
+	for _, c := range coords {
+		if len(c) >= 2 {
+			pts = append(pts, point{Lon: c[0], Lat: c[1]})
+		}
+	}
+	return pts
+}
+
+// routeInfo route-api 中的路线（/api/route/list 的 routeList 元素，只取模拟需要的字段）
+type routeInfo struct {
+	RouteId      string   `json:"routeId"`
+	StartStation string   `json:"startStation"`
+	EndStation   string   `json:"endStation"`
+	PassStations []string `json:"passStations"`
+	PassVehicles []string `json:"passVehicles"`
+}
+
+// loadRouteAPI 读取 route-api 的路线：src 为 http(s) 地址时请求 <src>/api/route/list，否则作为
+// /api/route/list 或 /api/route/detail 的响应 JSON 文件读取。route-api 只保存站点编号，
+// 坐标由 stations 文件提供；路线依次经过起点站、途径站点与终点站，并在每个站点停靠
+func loadRouteAPI(src string, stations map[string]point) ([]*route, error) {
+	var b []byte
+	var err error
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		b, err = fetch(strings.TrimRight(src, "/") + "/api/route/list")
+	} else {
+		b, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		RouteList []routeInfo `json:"routeList"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("parse route-api data: %w", err)
+	}
+	if list.RouteList == nil {
+		var one routeInfo
+		if err := json.Unmarshal(b, &one); err == nil && one.RouteId != "" {
+			list.RouteList = []routeInfo{one}
+		}
+	}
+
+	var out []*route
+	for _, ri := range list.RouteList {
+		ids := append(append([]string{ri.StartStation}, ri.PassStations...), ri.EndStation)
+		r := &route{Name: ri.RouteId, Vehicles: ri.PassVehicles}
+		var missing []string
+		for _, id := range ids {
+			p, ok := stations[id]
+			if !ok {
+				missing = append(missing, id)
+				continue
+			}
+			r.Stops = append(r.Stops, len(r.Points))
+			r.Points = append(r.Points, p)
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("route %s: no coordinates for stations %v", ri.RouteId, missing)
+		}
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("route-api data contains no routes")
+	}
+	return out, nil
+}
+
+// loadStations 读取站点坐标文件，支持 {"站点编号":[lon,lat]} 或 [{"id":"..","lng":..,"lat":..}] 两种格式
+func loadStations(path string) (map[string]point, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]point)
+	var byId map[string][]float64
+	if err := json.Unmarshal(b, &byId); err == nil {
+		for id, c := range byId {
+			if len(c) >= 2 {
+				out[id] = point{Lon: c[0], Lat: c[1]}
+			}
+		}
+		return out, nil
+	}
+	var list []struct {
+		Id        string  `json:"id"`
+		StationId string  `json:"stationId"`
+		Lng       float64 `json:"lng"`
+		Lon       float64 `json:"lon"`
+		Lat       float64 `json:"lat"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("parse stations %s: %w", path, err)
+	}
+	for _, s := range list {
+		id := s.Id
+		if id == "" {
+			id = s.StationId
+		}
+		lon := s.Lng
+		if lon == 0 {
+			lon = s.Lon
+		}
+		out[id] = point{Lon: lon, Lat: s.Lat}
+	}
+	return out, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// defaultRoutes 未提供路线时在 center 周围生成 n 条闭合的多边形环线
+func defaultRoutes(center point, n int) []*route {
+	const mPerDegLat = 111320.0
+	mPerDegLon := mPerDegLat * math.Cos(center.Lat*math.Pi/180)
+	out := make([]*route, 0, n)
+	for i := 0; i < n; i++ {
+		// 各环线的中心绕 center 分布，半径 600~1500 米，顶点数 6~9
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		c := point{
+			Lon: center.Lon + 1500*math.Sin(angle)/mPerDegLon,
+			Lat: center.Lat + 1500*math.Cos(angle)/mPerDegLat,
+		}
+		radius := 600 + 300*float64(i%4)
+		sides := 6 + i%4
+		r := &route{Name: fmt.Sprintf("loop-%d", i+1)}
+		for k := 0; k <= sides; k++ {
+			a := 2 * math.Pi * float64(k%sides) / float64(sides)
+			r.Points = append(r.Points, point{
+				Lon: c.Lon + radius*math.Sin(a)/mPerDegLon,
+				Lat: c.Lat + radius*math.Cos(a)/mPerDegLat,
+			})
+			if k%2 == 0 {
+				r.Stops = append(r.Stops, k)
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 与 apiclient 中 VEHState 下行指令转发的消息类型一致
+const (
+	commandType    = "CLOUD2VEH_COMMAND"
+	commandAckType = "VEH2CLOUD_COMMAND_ACK"
+)
+
+// sendBuffer 每个连接的待发送消息数，写满时丢弃新消息
+const sendBuffer = 4096
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// server 模拟外部平台的 VEHState WebSocket 服务：校验与 VEHStateClient 相同的签名参数，
+// 向每个连接推送全部车辆的状态，并对经上游转发的下行指令回复应答
+type server struct {
+	appId  string
+	key    string
+	noAuth bool
+	skew   time.Duration // 允许的 timestamp 偏差
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	nonces  map[string]time.Time // 时间窗口内已使用的 nonce
+
+	known   func(vehicleId string) bool
+	dropped atomic.Uint64
+}
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	addr string
+}
+
+func newServer(appId, key string, noAuth bool, skew time.Duration, known func(string) bool) *server {
+	return &server{
+		appId:   appId,
+		key:     key,
+		noAuth:  noAuth,
+		skew:    skew,
+		clients: make(map[*client]struct{}),
+		nonces:  make(map[string]time.Time),
+		known:   known,
+	}
+}
+
+// authenticate 校验 timestamp（毫秒）/nonce/appId/sign 查询参数与 X-Auth-Type 请求头，
+// sign = hex(SHA-256(timestamp + "_" + appId + "_" + nonce + "_" + appSecret))
+func (s *server) authenticate(r *http.Request) error {
+	if s.noAuth {
+		return nil
+	}
+	if r.Header.Get("X-Auth-Type") != "sign" {
+		return errors.New("X-Auth-Type must be sign")
+	}
+	q := r.URL.Query()
+	tsStr, nonce, appId, sign := q.Get("timestamp"), q.Get("nonce"), q.Get("appId"), q.Get("sign")
+	if tsStr == "" || nonce == "" || appId == "" || sign == "" {
+		return errors.New("missing timestamp/nonce/appId/sign")
+	}
+	if appId != s.appId {
+		return errors.New("unknown appId")
+	}
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	now := time.Now()
+	if d := now.Sub(time.UnixMilli(ts)); d > s.skew || d < -s.skew {
+		return errors.New("timestamp outside allowed window")
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d_%s_%s_%s", ts, appId, nonce, s.key)))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(sign)) != 1 {
+		return errors.New("sign mismatch")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n, t := range s.nonces {
+		if now.Sub(t) > 2*s.skew {
+			delete(s.nonces, n)
+		}
+	}
+	if _, ok := s.nonces[nonce]; ok {
+		return errors.New("nonce already used")
+	}
+	s.nonces[nonce] = now
+	return nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.authenticate(r); err != nil {
+		logx.Errorf("拒绝连接 remote=%s err=%v", r.RemoteAddr, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": http.StatusUnauthorized, "message": err.Error()})
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logx.Errorf("WebSocket 升级失败 remote=%s err=%v", r.RemoteAddr, err)
+		return
+	}
+	c := &client{conn: conn, send: make(chan []byte, sendBuffer), addr: r.RemoteAddr}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	n := len(s.clients)
+	s.mu.Unlock()
+	logx.Infof("客户端已连接 remote=%s clients=%d", c.addr, n)
+
+	go s.writePump(c)
+	s.readPump(c)
+}
+
+// broadcast 向全部连接推送一条消息（不阻塞）
+func (s *server) broadcast(msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c.send <- msg:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+func (s *server) writePump(c *client) {
+	defer c.conn.Close()
+	for msg := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			logx.Errorf("发送失败 remote=%s err=%v", c.addr, err)
+			return
+		}
+	}
+}
+
+// readPump 读取客户端消息直到连接断开：Ping 由默认处理函数回复 Pong，应用层心跳忽略，
+// 下行指令在短暂延迟后回复应答（车辆不存在时 result=1）
+func (s *server) readPump(c *client) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		n := len(s.clients)
+		s.mu.Unlock()
+		close(c.send)
+		logx.Infof("客户端已断开 remote=%s clients=%d", c.addr, n)
+	}()
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var cmd struct {
+			Type      string `json:"type"`
+			VehicleId string `json:"vehicleId"`
+			CommandId uint32 `json:"commandId"`
+		}
+		if json.Unmarshal(msg, &cmd) != nil || cmd.Type != commandType {
+			continue
+		}
+		result := 0
+		if !s.known(cmd.VehicleId) {
+			result = 1
+		}
+		logx.Infof("收到下行指令 vehicleId=%s commandId=%d result=%d", cmd.VehicleId, cmd.CommandId, result)
+		ack, _ := json.Marshal(map[string]interface{}{
+			"type":      commandAckType,
+			"vehicleId": cmd.VehicleId,
+			"commandId": cmd.CommandId,
+			"result":    result,
+			"timestamp": time.Now().UnixMilli(),
+		})
+		time.AfterFunc(200*time.Millisecond, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := s.clients[c]; ok {
+				select {
+				case c.send <- ack:
+				default:
+					s.dropped.Add(1)
+				}
+			}
+		})
+	}
+}
+
+// clientCount 返回当前连接数
+func (s *server) clientCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
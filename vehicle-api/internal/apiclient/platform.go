@@ -0,0 +1,500 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/signature"
+	"vehicle-api/internal/types"
+)
+
+// 外部平台接口名称（与配置项名称一致）
+const (
+	EndpointVEHInfo       = "VEHInfo"
+	EndpointVEHPosition   = "VEHPosition"
+	EndpointVEHTrajectory = "VEHTrajectory"
+	EndpointVEHRoute      = "VEHRoute"
+)
+
+// PlatformError 表示接口返回了非 0 的 code（业务错误，不重试也不计入熔断）
+type PlatformError struct {
+	Endpoint string
+	Code     int
+	Message  string
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("%s API returned code %d: %s", e.Endpoint, e.Code, e.Message)
+}
+
+// PlatformPosition 车辆位置接口 data.position 的元素
+type PlatformPosition struct {
+	VehicleId string  `json:"vehicleId"`
+	Lon       float64 `json:"lon"`
+	Lat       float64 `json:"lat"`
+	Online    bool    `json:"online"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// PlatformTrip 行程查询接口 data.list 的元素，StartTime/EndTime 统一为毫秒
+type PlatformTrip struct {
+	RouteId            string  `json:"routeId"`
+	VehicleId          string  `json:"vehicleId"`
+	Vin                string  `json:"vin"`
+	PlateNo            string  `json:"plateNo"`
+	StartTime          int64   `json:"startTime"`
+	EndTime            int64   `json:"endTime"`
+	Mileage            float64 `json:"mileage"`
+	DurationTime       float64 `json:"durationTime"`
+	AutoMileage        float64 `json:"autoMileage"`
+	AutoDuration       float64 `json:"autoDuration"`
+	AutoMileageReal    float64 `json:"autoMileageReal"`
+	AutoDurationReal   float64 `json:"autoDurationReal"`
+	VehicleFactory     string  `json:"vehicleFactory"`
+	VehicleFactoryName string  `json:"vehicleFactoryName"`
+}
+
+// RunPathPoint 轨迹接口 runPath 的元素，Timestamp 统一为毫秒
+type RunPathPoint struct {
+	Lon       float64
+	Lat       float64
+	Timestamp int64
+}
+
+// UnmarshalJSON 兼容平台返回的多种轨迹点格式：[lon, lat, t]、[t, lon, lat] 与
+// {lon|lng|longitude, lat|latitude, timestamp}，秒级时间戳换算为毫秒
+func (p *RunPathPoint) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		var arr []float64
+		if err := json.Unmarshal(b, &arr); err != nil {
+			return err
+		}
+		if len(arr) < 3 {
+			return fmt.Errorf("runPath point has %d elements", len(arr))
+		}
+		if arr[0] > 1e11 {
+			p.Timestamp, p.Lon, p.Lat = int64(arr[0]), arr[1], arr[2]
+		} else {
+			p.Lon, p.Lat, p.Timestamp = arr[0], arr[1], int64(arr[2])
+		}
+	} else {
+		var obj struct {
+			Lon       *float64 `json:"lon"`
+			Lng       *float64 `json:"lng"`
+			Longitude *float64 `json:"longitude"`
+			Lat       *float64 `json:"lat"`
+			Latitude  *float64 `json:"latitude"`
+			Timestamp float64  `json:"timestamp"`
+		}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return err
+		}
+		for _, v := range []*float64{obj.Lon, obj.Lng, obj.Longitude} {
+			if v != nil {
+				p.Lon = *v
+				break
+			}
+		}
+		for _, v := range []*float64{obj.Lat, obj.Latitude} {
+			if v != nil {
+				p.Lat = *v
+				break
+			}
+		}
+		p.Timestamp = int64(obj.Timestamp)
+	}
+	p.Timestamp = toMillis(p.Timestamp)
+	return nil
+}
+
+// toMillis 把秒级时间戳换算为毫秒
+func toMillis(ts int64) int64 {
+	if ts > 0 && ts < 1e12 {
+		return ts * 1000
+	}
+	return ts
+}
+
+// platformEnvelope 平台统一响应结构
+type platformEnvelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// platformEndpoint 一个外部接口的地址、熔断器、限流器与计数
+type platformEndpoint struct {
+	name    string
+	url     string
+	breaker *breaker
+	limiter *tokenBucket
+
+	requests atomic.Uint64 // 实际发出的请求数（含重试）
+	retries  atomic.Uint64
+	failures atomic.Uint64 // 最终失败的调用数
+
+	mu            sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+}
+
+// PlatformClient 外部平台签名 HTTP 接口（VEHInfo/VEHPosition/VEHTrajectory/VEHRoute）的统一客户端：
+// 请求头签名 appid/timestamp/nonce/sign，瞬时错误按指数退避重试，按接口熔断与限流，行程查询自动翻页
+type PlatformClient struct {
+	appId     string
+	appSecret string
+	cfg       config.PlatformConfig
+	client    *http.Client
+	endpoints map[string]*platformEndpoint
+	order     []string
+}
+
+// NewPlatformClient 按配置创建客户端；未配置地址的接口在调用时返回错误
+func NewPlatformClient(c config.Config) *PlatformClient {
+	cfg := c.Platform
+	if cfg.TimeoutSec <= 0 {
+		cfg.TimeoutSec = 15
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 2
+	}
+	if cfg.RetryBackoffMs <= 0 {
+		cfg.RetryBackoffMs = 200
+	}
+	if cfg.MaxBackoffMs <= 0 {
+		cfg.MaxBackoffMs = 5000
+	}
+	if cfg.BreakerFailures == 0 {
+		cfg.BreakerFailures = 5
+	}
+	if cfg.BreakerCooldownSec <= 0 {
+		cfg.BreakerCooldownSec = 30
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = 10
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 100
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 100
+	}
+	p := &PlatformClient{
+		appId:     c.AppId,
+		appSecret: c.Key,
+		cfg:       cfg,
+		client:    &http.Client{Timeout: time.Duration(cfg.TimeoutSec) * time.Second},
+		endpoints: make(map[string]*platformEndpoint),
+	}
+	for _, e := range []struct {
+		name string
+		url  string
+	}{
+		{EndpointVEHInfo, c.VEHInfo.URL},
+		{EndpointVEHPosition, c.VEHPosition.URL},
+		{EndpointVEHTrajectory, c.VEHTrajectory.URL},
+		{EndpointVEHRoute, c.VEHRoute.URL},
+	} {
+		p.endpoints[e.name] = &platformEndpoint{
+			name:    e.name,
+			url:     e.url,
+			breaker: newBreaker(cfg.BreakerFailures, time.Duration(cfg.BreakerCooldownSec)*time.Second),
+			limiter: newTokenBucket(cfg.RateLimit, cfg.Burst),
+		}
+		p.order = append(p.order, e.name)
+	}
+	return p
+}
+
+// VehicleList 获取车辆信息列表，categoryCode 为 0 时返回全部车辆
+func (p *PlatformClient) VehicleList(ctx context.Context, categoryCode int) ([]types.VehicleInfo, error) {
+	// 外部协议说明：body 可包含 categoryCode（Integer），不传表示返回全部车辆
+	payload := map[string]interface{}{}
+	if categoryCode != 0 {
+		payload["categoryCode"] = categoryCode
+	}
+	var out []types.VehicleInfo
+	if err := p.call(ctx, EndpointVEHInfo, payload, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Positions 获取车辆位置与在线状态
+func (p *PlatformClient) Positions(ctx context.Context, categoryCode int) ([]PlatformPosition, error) {
+	var data struct {
+		Position []PlatformPosition `json:"position"`
+	}
+	if err := p.call(ctx, EndpointVEHPosition, map[string]interface{}{"categoryCode": categoryCode}, &data); err != nil {
+		return nil, err
+	}
+	return data.Position, nil
+}
+
+// ErrIncomplete 表示分页查询未取到平台声明的全部数据
+var ErrIncomplete = errors.New("platform returned incomplete result")
+
+// Trips 查询车辆在 [startMs, endMs] 内的全部行程（逐页请求直到取满平台返回的 total）。
+// 平台未返回 total 时以不足一页为最后一页；取满之前出现空页、整页重复（例如页码起始值不一致）或超过最大页数时
+// 返回已取到的行程与 ErrIncomplete，出错时返回已取到的行程与错误
+func (p *PlatformClient) Trips(ctx context.Context, vehicleId string, startMs, endMs int64) ([]PlatformTrip, error) {
+	var out []PlatformTrip
+	seen := make(map[string]struct{})
+	total := 0
+	for page := 0; page < p.cfg.MaxPages; page++ {
+		payload := map[string]interface{}{
+			"vehicleId": vehicleId,
+			"startTime": startMs,
+			"endTime":   endMs,
+			"page": map[string]interface{}{
+				"pageSize":  p.cfg.PageSize,
+				"pageIndex": page,
+			},
+		}
+		var data struct {
+			List  []PlatformTrip `json:"list"`
+			Total int            `json:"total"`
+		}
+		if err := p.call(ctx, EndpointVEHRoute, payload, &data); err != nil {
+			return out, err
+		}
+		if data.Total > total {
+			total = data.Total
+		}
+		added := 0
+		for _, t := range data.List {
+			t.StartTime, t.EndTime = toMillis(t.StartTime), toMillis(t.EndTime)
+			key := t.RouteId
+			if key == "" {
+				key = strconv.FormatInt(t.StartTime, 10) + "-" + strconv.FormatInt(t.EndTime, 10)
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			out = append(out, t)
+			added++
+		}
+		switch {
+		case total > 0 && len(out) >= total:
+			return out, nil
+		case added == 0:
+			// 平台不再返回新的行程：声明了 total 时说明未取全
+			if total > 0 {
+				return out, fmt.Errorf("%w: 车辆 %s 的行程已获取 %d/%d 条", ErrIncomplete, vehicleId, len(out), total)
+			}
+			return out, nil
+		case total == 0 && len(data.List) < p.cfg.PageSize:
+			return out, nil
+		}
+	}
+	logx.Errorf("车辆 %s 的行程超过 %d 页，后续行程未获取", vehicleId, p.cfg.MaxPages)
+	return out, fmt.Errorf("%w: 车辆 %s 的行程超过 %d 页，已获取 %d 条", ErrIncomplete, vehicleId, p.cfg.MaxPages, len(out))
+}
+
+// RunPath 获取车辆在 [startMs, endMs] 内的轨迹点（跳过经纬度均为 0 的点）
+func (p *PlatformClient) RunPath(ctx context.Context, vehicleId string, startMs, endMs int64) ([]RunPathPoint, error) {
+	var data json.RawMessage
+	payload := map[string]interface{}{
+		"vehicleId": vehicleId,
+		"startTime": startMs,
+		"endTime":   endMs,
+	}
+	if err := p.call(ctx, EndpointVEHTrajectory, payload, &data); err != nil {
+		return nil, err
+	}
+	// data 通常为 {runPath: [...]}，部分版本直接返回数组
+	var pts []RunPathPoint
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &pts); err != nil {
+			return nil, err
+		}
+	} else if len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		var obj struct {
+			RunPath []RunPathPoint `json:"runPath"`
+		}
+		if err := json.Unmarshal(trimmed, &obj); err != nil {
+			return nil, err
+		}
+		pts = obj.RunPath
+	}
+	out := pts[:0]
+	for _, pt := range pts {
+		if pt.Lon != 0 || pt.Lat != 0 {
+			out = append(out, pt)
+		}
+	}
+	return out, nil
+}
+
+// Status 返回各接口的熔断状态与调用计数
+func (p *PlatformClient) Status() []types.PlatformEndpointStatus {
+	out := make([]types.PlatformEndpointStatus, 0, len(p.order))
+	for _, name := range p.order {
+		ep := p.endpoints[name]
+		state, failures, opens := ep.breaker.snapshot()
+		st := types.PlatformEndpointStatus{
+			Name:                name,
+			URL:                 ep.url,
+			BreakerState:        state,
+			ConsecutiveFailures: failures,
+			BreakerOpens:        opens,
+			Requests:            ep.requests.Load(),
+			Retries:             ep.retries.Load(),
+			Failures:            ep.failures.Load(),
+		}
+		ep.mu.Lock()
+		st.LastError = ep.lastError
+		if !ep.lastErrorAt.IsZero() {
+			st.LastErrorAt = ep.lastErrorAt.UTC().Format(time.RFC3339)
+		}
+		if !ep.lastSuccessAt.IsZero() {
+			st.LastSuccessAt = ep.lastSuccessAt.UTC().Format(time.RFC3339)
+		}
+		ep.mu.Unlock()
+		out = append(out, st)
+	}
+	return out
+}
+
+// call 调用接口并把 data 解析到 out：熔断时直接失败，发出前等待限流令牌，瞬时错误按指数退避重试
+func (p *PlatformClient) call(ctx context.Context, name string, payload interface{}, out interface{}) error {
+	ep := p.endpoints[name]
+	if ep.url == "" {
+		return fmt.Errorf("%s API 地址未配置", name)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Duration(p.cfg.RetryBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(p.cfg.MaxBackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if err := ep.breaker.allow(); err != nil {
+			ep.recordError(err)
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := ep.limiter.wait(ctx); err != nil {
+			// 未发出请求，探测名额（若有）交还给下一次调用
+			ep.breaker.release()
+			return err
+		}
+		data, transient, err := p.do(ctx, ep, body)
+		if err == nil {
+			ep.breaker.success()
+			ep.mu.Lock()
+			ep.lastSuccessAt = time.Now()
+			ep.mu.Unlock()
+			if out == nil || len(data) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				err = fmt.Errorf("%s: decode data: %w", name, err)
+				ep.recordError(err)
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			ep.breaker.release()
+			return ctx.Err()
+		}
+		if !transient {
+			// 接口可达（业务错误、4xx），不计入熔断
+			ep.breaker.success()
+			ep.recordError(err)
+			return err
+		}
+		if ep.breaker.failure() {
+			logx.Errorf("外部 %s API 连续失败，熔断 %ds: %v", name, p.cfg.BreakerCooldownSec, err)
+		}
+		if attempt >= p.cfg.MaxRetries {
+			ep.recordError(err)
+			return err
+		}
+		// 指数退避，等待时长在 [backoff/2, backoff] 内随机
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		logx.Errorf("调用外部 %s API 失败，%v 后第 %d 次重试: %v", name, wait, attempt+1, err)
+		ep.retries.Add(1)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// do 发出一次签名请求，返回 data 字段；transient 表示错误可重试（网络错误、5xx、429、响应体损坏）
+func (p *PlatformClient) do(ctx context.Context, ep *platformEndpoint, body []byte) (json.RawMessage, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.appId != "" && p.appSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonceBytes := make([]byte, 12)
+		if _, err := crand.Read(nonceBytes); err != nil {
+			copy(nonceBytes, timestamp)
+		}
+		nonce := hex.EncodeToString(nonceBytes)
+		req.Header.Set(signature.HeaderAppId, p.appId)
+		req.Header.Set(signature.HeaderTimestamp, timestamp)
+		req.Header.Set(signature.HeaderNonce, nonce)
+		req.Header.Set(signature.HeaderSign, signature.Sign(body, timestamp, p.appId, nonce, p.appSecret))
+	}
+
+	ep.requests.Add(1)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s: %w", ep.name, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, true, fmt.Errorf("%s: read body: %w", ep.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(b) > 4096 {
+			b = b[:4096]
+		}
+		err := fmt.Errorf("%s API returned status %d: %s", ep.name, resp.StatusCode, string(bytes.TrimSpace(b)))
+		return nil, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+	}
+	var env platformEnvelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, true, fmt.Errorf("%s: decode response: %w", ep.name, err)
+	}
+	if env.Code != 0 {
+		return nil, false, &PlatformError{Endpoint: ep.name, Code: env.Code, Message: env.Message}
+	}
+	return env.Data, false, nil
+}
+
+func (ep *platformEndpoint) recordError(err error) {
+	ep.failures.Add(1)
+	ep.mu.Lock()
+	ep.lastError, ep.lastErrorAt = err.Error(), time.Now()
+	ep.mu.Unlock()
+}
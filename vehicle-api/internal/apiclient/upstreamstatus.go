@@ -0,0 +1,130 @@
+package apiclient
+
+import (
+	"sync"
+	"time"
+
+	"vehicle-api/internal/types"
+)
+
+// 上游连接状态
+const (
+	UpstreamConnecting   = "connecting"   // 正在拨号
+	UpstreamConnected    = "connected"    // 已连接
+	UpstreamBackoff      = "backoff"      // 连接断开，等待重连
+	UpstreamDisconnected = "disconnected" // 已停止
+)
+
+// upstreamStatus 记录上游连接状态与消息计数，供 /api/vehicle/upstream/status 查询
+type upstreamStatus struct {
+	mu            sync.Mutex
+	state         string
+	connectedAt   time.Time
+	lastMessageAt time.Time
+	lastPongAt    time.Time
+	lastError     string
+	lastErrorAt   time.Time
+	backoff       time.Duration
+	reconnects    uint64
+	messages      uint64
+	idleTimeouts  uint64
+	// 最近 60 秒每秒的消息数，按 Unix 秒取模定位
+	buckets [60]uint64
+	seconds [60]int64
+}
+
+func (s *upstreamStatus) setState(state string) {
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+func (s *upstreamStatus) connected() {
+	s.mu.Lock()
+	s.state = UpstreamConnected
+	s.connectedAt = time.Now()
+	s.mu.Unlock()
+}
+
+// disconnected 记录断开原因并进入退避；idle 表示由读空闲看门狗触发
+func (s *upstreamStatus) disconnected(err error, backoff time.Duration, idle bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = UpstreamBackoff
+	s.backoff = backoff
+	s.reconnects++
+	if idle {
+		s.idleTimeouts++
+	}
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorAt = time.Now()
+	}
+}
+
+func (s *upstreamStatus) message() {
+	now := time.Now()
+	sec := now.Unix()
+	i := sec % int64(len(s.buckets))
+	s.mu.Lock()
+	s.lastMessageAt = now
+	s.messages++
+	if s.seconds[i] != sec {
+		s.seconds[i] = sec
+		s.buckets[i] = 0
+	}
+	s.buckets[i]++
+	s.mu.Unlock()
+}
+
+func (s *upstreamStatus) pong() {
+	s.mu.Lock()
+	s.lastPongAt = time.Now()
+	s.mu.Unlock()
+}
+
+// since 返回 t 距今的秒数，未发生过时返回 -1
+func since(now, t time.Time) int64 {
+	if t.IsZero() {
+		return -1
+	}
+	return int64(now.Sub(t).Seconds())
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (s *upstreamStatus) snapshot() types.UpstreamStatus {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := types.UpstreamStatus{
+		State:          s.state,
+		ConnectedAt:    formatTime(s.connectedAt),
+		LastMessageAt:  formatTime(s.lastMessageAt),
+		LastMessageAgo: since(now, s.lastMessageAt),
+		LastPongAt:     formatTime(s.lastPongAt),
+		Reconnects:     s.reconnects,
+		IdleTimeouts:   s.idleTimeouts,
+		Messages:       s.messages,
+		LastError:      s.lastError,
+		LastErrorAt:    formatTime(s.lastErrorAt),
+	}
+	if s.state == UpstreamBackoff {
+		st.BackoffMs = s.backoff.Milliseconds()
+	}
+	if s.state != UpstreamConnected {
+		st.ConnectedAt = ""
+	}
+	cur := now.Unix()
+	for i := range s.buckets {
+		if cur-s.seconds[i] < int64(len(s.buckets)) {
+			st.MessagesPerMin += s.buckets[i]
+		}
+	}
+	return st
+}
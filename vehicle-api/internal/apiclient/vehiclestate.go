@@ -1,12 +1,15 @@
 package apiclient
 
 import (
+	"bytes"
 	"context"
 	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -17,44 +20,100 @@ import (
 	"github.com/zeromicro/go-zero/core/logx"
 
 	"vehicle-api/internal/config"
+	"vehicle-api/internal/protocol"
 	"vehicle-api/internal/types"
 )
 
+// 经 VEHState 上游转发的下行指令与应答的消息类型
+const (
+	upstreamCommandType    = "CLOUD2VEH_COMMAND"
+	upstreamCommandAckType = "VEH2CLOUD_COMMAND_ACK"
+)
+
+// 心跳方式
+const (
+	HeartbeatPing    = "ping"    // WebSocket Ping 控制帧
+	HeartbeatMessage = "message" // 应用层文本消息
+)
+
+const defaultHeartbeatMessage = `{"type":"HEARTBEAT"}`
+
+// upstreamCommand 为通过 VEHState WebSocket 转发给车辆的下行指令，
+// Data 为完整的 0xF2 二进制报文（base64 编码）
+type upstreamCommand struct {
+	Type      string `json:"type"`
+	VehicleId string `json:"vehicleId"`
+	CommandId uint32 `json:"commandId"`
+	Data      []byte `json:"data"`
+}
+
+// upstreamCommandAck 为上游转发回来的车端指令应答
+type upstreamCommandAck struct {
+	Type      string `json:"type"`
+	VehicleId string `json:"vehicleId"`
+	CommandId uint32 `json:"commandId"`
+	Result    int    `json:"result"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
 // VEHStateClient 负责与外部车辆状态 WebSocket 服务保持连接并将收到的数据回调给上层处理函数
 type VEHStateClient struct {
+	name      string // 来源名称，用于日志与状态
 	cfg       config.VEHStateConfig
 	appId     string
 	appSecret string
 	handle    func(*types.VehicleStateData) error
+	// onCommandAck 为经上游转发的下行指令应答回调（可选）
+	onCommandAck func(*protocol.CommandAckData)
+	// onRaw 收到的每条原始消息在解析前的回调（可选，用于录制）
+	onRaw func([]byte)
 
-	// mu 用于保护 conn 的并发访问
+	// mu 用于保护 conn 的并发访问（包括下行写入）
 	mu     sync.Mutex
 	conn   *websocket.Conn
 	closed chan struct{}
+
+	heartbeat   time.Duration // 心跳间隔，0 表示不发送心跳
+	readTimeout time.Duration // 读空闲超时，0 表示不启用看门狗
+	status      upstreamStatus
 }
 
 // NewVEHStateClient 创建新的客户端实例
 func NewVEHStateClient(cfg config.VEHStateConfig, appId, appSecret string, handle func(*types.VehicleStateData) error) *VEHStateClient {
-	return &VEHStateClient{
+	c := &VEHStateClient{
+		name:      "VEHState",
 		cfg:       cfg,
 		appId:     appId,
 		appSecret: appSecret,
 		handle:    handle,
 		closed:    make(chan struct{}),
 	}
+	if cfg.HeartbeatTimer > 0 {
+		c.heartbeat = time.Duration(cfg.HeartbeatTimer) * time.Second
+	}
+	switch {
+	case cfg.ReadIdleTimeoutSec > 0:
+		c.readTimeout = time.Duration(cfg.ReadIdleTimeoutSec) * time.Second
+	case cfg.ReadIdleTimeoutSec == 0 && c.heartbeat > 0:
+		// 未发心跳时对端可能长时间无数据（如夜间无车上报），不能据此判定连接失效，只在启用心跳时默认开启
+		c.readTimeout = 3 * c.heartbeat
+	}
+	c.status.state = UpstreamDisconnected
+	return c
 }
 
 // Start 启动客户端并在后台保持连接；在 ctx 取消或 Stop 被调用时返回
 func (c *VEHStateClient) Start(ctx context.Context) {
 	// 更健壮的重连逻辑：Dial 与读取在不同的 goroutine 中运行，
 	// 并且 Dial 使用带超时的 Context 避免被长时间阻塞，从而保证当其它任务占用资源时仍能进行重连。
-	backoff := 2 * time.Second
+	minBackoff := 2 * time.Second
 	maxBackoff := 30 * time.Second
+	backoff := minBackoff
 
 	for {
 		select {
 		case <-ctx.Done():
-			logx.Infof("VEHState 客户端收到退出信号，准备退出")
+			logx.Infof("VEHState[%s] 客户端收到退出信号，准备退出", c.name)
 			c.closeConn()
 			return
 		default:
@@ -62,11 +121,21 @@ func (c *VEHStateClient) Start(ctx context.Context) {
 
 		// 将拨号超时局部化到 dialAndServe 内部，避免把短超时 ctx 用于读循环。
 		// 这里传入的是父 ctx（由外层控制生命周期），dialAndServe 会在内部为拨号创建短超时 ctx。
-		err := c.dialAndServe(ctx)
+		c.status.setState(UpstreamConnecting)
+		connected, err := c.dialAndServe(ctx)
 
-		if err != nil {
-			// 记录错误并按照指数退避重试
-			logx.Errorf("VEHState 客户端错误：%v，%s 后重连", err, backoff)
+		if err != nil && ctx.Err() == nil {
+			// 曾成功建立连接时从最小退避重新开始，否则按照指数退避重试
+			if connected {
+				backoff = minBackoff
+			}
+			var ne net.Error
+			idle := errors.As(err, &ne) && ne.Timeout()
+			if idle {
+				err = fmt.Errorf("超过 %s 未收到任何数据，判定连接失效: %w", c.readTimeout, err)
+			}
+			c.status.disconnected(err, backoff, idle)
+			logx.Errorf("VEHState[%s] 客户端错误：%v，%s 后重连", c.name, err, backoff)
 			select {
 			case <-time.After(backoff):
 				if backoff < maxBackoff {
@@ -74,12 +143,14 @@ func (c *VEHStateClient) Start(ctx context.Context) {
 				}
 				continue
 			case <-ctx.Done():
+				c.status.setState(UpstreamDisconnected)
 				c.closeConn()
 				return
 			}
 		}
 
 		// dialAndServe 正常返回（通常表示主动关闭），直接退出
+		c.status.setState(UpstreamDisconnected)
 		c.closeConn()
 		return
 	}
@@ -87,15 +158,16 @@ func (c *VEHStateClient) Start(ctx context.Context) {
 
 // dialAndServe 封装了拨号和读循环：拨号成功后在独立的读取 goroutine 中循环读取消息，
 // 若读取出错则返回错误以触发重连。此函数假定传入的 ctx 是用于拨号的短超时 ctx。
-func (c *VEHStateClient) dialAndServe(ctx context.Context) error {
+// connected 表示本次是否成功建立过连接。
+func (c *VEHStateClient) dialAndServe(ctx context.Context) (connected bool, err error) {
 	if c.cfg.URL == "" {
-		return fmt.Errorf("VEHState 未配置 URL")
+		return false, fmt.Errorf("VEHState 未配置 URL")
 	}
 
 	// 构造带鉴权参数的 URL（timestamp, nonce, appId, sign）
 	u, err := url.Parse(c.cfg.URL)
 	if err != nil {
-		return fmt.Errorf("invalid VEHState URL: %w", err)
+		return false, fmt.Errorf("invalid VEHState URL: %w", err)
 	}
 
 	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
@@ -122,22 +194,46 @@ func (c *VEHStateClient) dialAndServe(ctx context.Context) error {
 	headers := http.Header{}
 	headers.Set("X-Auth-Type", "sign")
 
-	logx.Infof("正在拨号连接外部 VEHState WebSocket：%s", u.String())
+	logx.Infof("VEHState[%s] 正在拨号连接外部 WebSocket：%s", c.name, u.String())
 	conn, resp, err := dialer.DialContext(dialCtx, u.String(), headers)
 	if err != nil {
 		if resp != nil {
-			logx.Errorf("VEHState 握手失败 HTTP 状态：%s", resp.Status)
+			logx.Errorf("VEHState[%s] 握手失败 HTTP 状态：%s", c.name, resp.Status)
 		}
-		return err
+		return false, err
 	}
 
 	// 成功建立连接，重置退避（由调用者控制），并启动读取协程
 	c.mu.Lock()
 	c.conn = conn
 	c.mu.Unlock()
+	c.status.connected()
+	logx.Infof("VEHState[%s] 已连接，heartbeat=%s readIdleTimeout=%s", c.name, c.heartbeat, c.readTimeout)
+
+	// 读空闲看门狗：收到任何消息、Ping 或 Pong 都顺延读超时，超时后 ReadMessage 返回错误触发重连，
+	// 避免半开连接上一直阻塞在读取
+	c.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		c.status.pong()
+		c.extendReadDeadline(conn)
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		c.extendReadDeadline(conn)
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(5*time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
 
 	// 使用 buffered chan 接收读取错误，避免 goroutine 泄漏
 	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	if c.heartbeat > 0 {
+		go c.runHeartbeat(conn, done)
+	}
 
 	// 启动读取循环（在独立 goroutine 中运行，确保不会阻塞拨号逻辑）
 	go func() {
@@ -149,31 +245,13 @@ func (c *VEHStateClient) dialAndServe(ctx context.Context) error {
 				errCh <- fmt.Errorf("读取消息出错: %w", err)
 				return
 			}
+			c.extendReadDeadline(conn)
+			c.status.message()
 
-			var resp types.VehicleStateResp
-			if err := json.Unmarshal(msg, &resp); err != nil {
-				// 可能是心跳或其它格式，记录并继续
-				logx.Errorf("解析 VEHState 消息失败: %v, raw=%s", err, string(msg))
-				continue
-			}
-
-			// 期望 code==0 表示成功
-			if resp.Code != 0 {
-				logx.Infof("VEHState 返回 code=%d, message=%s", resp.Code, resp.Message)
-				continue
-			}
-
-			if resp.Data.VehicleId == "" {
-				// 空数据，跳过
-				continue
-			}
-
-			// 回调上层处理函数（不会阻塞读取，如果处理较慢建议上层异步化）
-			if c.handle != nil {
-				if err := c.handle(&resp.Data); err != nil {
-					logx.Errorf("处理 VEHState 数据出错：%v", err)
-				}
+			if c.onRaw != nil {
+				c.onRaw(msg)
 			}
+			_ = c.HandleMessage(msg)
 		}
 	}()
 
@@ -183,12 +261,156 @@ func (c *VEHStateClient) dialAndServe(ctx context.Context) error {
 	case <-ctx.Done():
 		// 上层取消（主动退出），关闭连接并返回
 		c.closeConn()
-		return ctx.Err()
+		return true, ctx.Err()
 	case err := <-errCh:
 		// 读取出错，需要重连
 		c.closeConn()
+		return true, err
+	}
+}
+
+// HandleMessage 解析一条上游文本消息：指令应答交给 onCommandAck，车辆状态回调给上层处理函数。
+// 读循环收到的每条消息都经过这里，回放录制的消息时也直接调用
+func (c *VEHStateClient) HandleMessage(msg []byte) error {
+	// 下行指令应答（由上游转发），与状态消息共用同一连接
+	if c.onCommandAck != nil && bytes.Contains(msg, []byte(upstreamCommandAckType)) {
+		var ack upstreamCommandAck
+		if err := json.Unmarshal(msg, &ack); err == nil && ack.Type == upstreamCommandAckType {
+			c.onCommandAck(&protocol.CommandAckData{VehicleId: ack.VehicleId, CommandId: ack.CommandId, Result: ack.Result, Timestamp: ack.Timestamp})
+			return nil
+		}
+	}
+
+	data, err := c.Decode(msg)
+	if err != nil || data == nil {
 		return err
 	}
+
+	// 回调上层处理函数（不会阻塞读取，如果处理较慢建议上层异步化）
+	if c.handle != nil {
+		if err := c.handle(data); err != nil {
+			logx.Errorf("处理 VEHState[%s] 数据出错：%v", c.name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode 解析一条上游消息中的车辆状态，不调用处理回调；指令应答、非成功 code 与空数据返回 nil
+func (c *VEHStateClient) Decode(msg []byte) (*types.VehicleStateData, error) {
+	if bytes.Contains(msg, []byte(upstreamCommandAckType)) {
+		var ack upstreamCommandAck
+		if err := json.Unmarshal(msg, &ack); err == nil && ack.Type == upstreamCommandAckType {
+			return nil, nil
+		}
+	}
+
+	var resp types.VehicleStateResp
+	if err := json.Unmarshal(msg, &resp); err != nil {
+		// 可能是心跳或其它格式，记录并继续
+		logx.Errorf("解析 VEHState[%s] 消息失败: %v, raw=%s", c.name, err, string(msg))
+		return nil, err
+	}
+
+	// 期望 code==0 表示成功
+	if resp.Code != 0 {
+		logx.Infof("VEHState[%s] 返回 code=%d, message=%s", c.name, resp.Code, resp.Message)
+		return nil, nil
+	}
+
+	if resp.Data.VehicleId == "" {
+		// 空数据，跳过
+		return nil, nil
+	}
+	return &resp.Data, nil
+}
+
+// extendReadDeadline 顺延读超时；未启用看门狗时不设置
+func (c *VEHStateClient) extendReadDeadline(conn *websocket.Conn) {
+	if c.readTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// runHeartbeat 按 HeartbeatTimer 周期发送心跳，直到 done 关闭；发送失败时关闭连接促使读循环退出并重连
+func (c *VEHStateClient) runHeartbeat(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.sendHeartbeat(conn); err != nil {
+				logx.Errorf("VEHState[%s] 发送心跳失败: %v", c.name, err)
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *VEHStateClient) sendHeartbeat(conn *websocket.Conn) error {
+	deadline := time.Now().Add(5 * time.Second)
+	if c.cfg.HeartbeatMode != HeartbeatMessage {
+		// WriteControl 可与其它写操作并发调用
+		return conn.WriteControl(websocket.PingMessage, nil, deadline)
+	}
+	msg := c.cfg.HeartbeatMessage
+	if msg == "" {
+		msg = defaultHeartbeatMessage
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = conn.SetWriteDeadline(deadline)
+	return conn.WriteMessage(websocket.TextMessage, []byte(msg))
+}
+
+// Status 返回当前连接状态、最近消息时间、重连次数与最近一分钟消息数
+func (c *VEHStateClient) Status() types.UpstreamStatus {
+	st := c.status.snapshot()
+	st.Name = c.name
+	st.URL = c.cfg.URL
+	st.HeartbeatSec = int(c.heartbeat / time.Second)
+	st.ReadIdleTimeoutSec = int(c.readTimeout / time.Second)
+	return st
+}
+
+// SetName 设置来源名称（多来源时区分日志与状态），需在 Start 之前调用
+func (c *VEHStateClient) SetName(name string) {
+	c.name = name
+}
+
+// SetRawHandler 设置原始消息回调（解析前调用），需在 Start 之前调用
+func (c *VEHStateClient) SetRawHandler(fn func([]byte)) {
+	c.onRaw = fn
+}
+
+// SetCommandAckHandler 设置经上游转发的下行指令应答回调，需在 Start 之前调用
+func (c *VEHStateClient) SetCommandAckHandler(fn func(*protocol.CommandAckData)) {
+	c.onCommandAck = fn
+}
+
+// SendCommand 通过当前 VEHState 连接把下行指令报文转发给上游平台，由其投递给车辆
+func (c *VEHStateClient) SendCommand(vehicleId string, commandId uint32, frame []byte) error {
+	b, err := json.Marshal(upstreamCommand{Type: upstreamCommandType, VehicleId: vehicleId, CommandId: commandId, Data: frame})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("VEHState 未连接")
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return c.conn.WriteMessage(websocket.TextMessage, b)
+}
+
+// Connected 判断当前是否与上游保持连接
+func (c *VEHStateClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
 }
 
 // Stop 主动关闭客户端
@@ -197,10 +419,12 @@ func (c *VEHStateClient) Stop() {
 }
 
 func (c *VEHStateClient) closeConn() {
+	c.mu.Lock()
 	if c.conn != nil {
 		_ = c.conn.Close()
 		c.conn = nil
 	}
+	c.mu.Unlock()
 	select {
 	case <-c.closed:
 		// already closed
@@ -5,12 +5,49 @@ import "github.com/zeromicro/go-zero/rest"
 type Config struct {
 	rest.RestConf
 	InfluxDBConfig InfluxDB
-	MySQL          MySQLConfig    `yaml:"mysql" json:"mysql"`                 // MySQL 配置，用于持久化任务记录（任务、统计等）
-	VEHState       VEHStateConfig `yaml:"VEHState" json:"VEHState"`           // VEHState 配置，用于连接外部车辆状态API获取实时车辆状态
-	VEHInfo        HttpConfig     `yaml:"VEHInfo" json:"VEHInfo"`             // 车辆信息列表API配置
-	VEHPosition    HttpConfig     `yaml:"VEHPosition" json:"VEHPosition"`     // 车辆位置在线API配置（用于拉取在线/离线车辆位置信息）
-	VEHTrajectory  HttpConfig     `yaml:"VEHTrajectory" json:"VEHTrajectory"` // 车辆轨迹API配置
-	VEHRoute       HttpConfig     `yaml:"VEHRoute" json:"VEHRoute"`           // 车辆行程查询API配置
+	MySQL          MySQLConfig    `yaml:"mysql" json:"mysql"`                         // MySQL 配置，用于持久化任务记录（任务、统计等）
+	VEHState       VEHStateConfig `yaml:"VEHState,optional" json:"VEHState,optional"` // VEHState 配置，用于连接外部车辆状态API获取实时车辆状态（未配置 Upstreams 时使用）
+	VEHInfo        HttpConfig     `yaml:"VEHInfo" json:"VEHInfo"`                     // 车辆信息列表API配置
+	VEHPosition    HttpConfig     `yaml:"VEHPosition" json:"VEHPosition"`             // 车辆位置在线API配置（用于拉取在线/离线车辆位置信息）
+	VEHTrajectory  HttpConfig     `yaml:"VEHTrajectory" json:"VEHTrajectory"`         // 车辆轨迹API配置
+	VEHRoute       HttpConfig     `yaml:"VEHRoute" json:"VEHRoute"`                   // 车辆行程查询API配置
+	// Platform 调用以上外部平台 HTTP 接口的重试、熔断、限流与分页策略
+	Platform PlatformConfig `yaml:"Platform,optional" json:"Platform,optional"`
+	// Upstreams 多个命名的上游车辆状态来源（各区域云平台），各自独立的连接、凭证、降频与车型过滤；
+	// 配置后不再使用 VEHState，未配置时 VEHState 作为名为 default 的唯一来源
+	Upstreams []UpstreamSourceConfig `yaml:"Upstreams,optional" json:"Upstreams,optional"`
+	// MQTT 车辆通过 MQTT 上报遥测数据的订阅配置，未配置 Broker 时不启用
+	MQTT MQTTConfig `yaml:"MQTT,optional" json:"MQTT,optional"`
+	// TCPServer 二进制协议（0xF2 固定报文头）TCP 接入配置，未配置 Addr 时不启动
+	TCPServer TCPServerConfig `yaml:"TCPServer,optional" json:"TCPServer,optional"`
+	// Crypto 二进制协议加密数据段的解密密钥（按车辆或 AppId 配置）
+	Crypto CryptoConfig `yaml:"Crypto,optional" json:"Crypto,optional"`
+	// Downlink 下行指令通道配置（投递方式、应答超时与重试）
+	Downlink DownlinkConfig `yaml:"Downlink,optional" json:"Downlink,optional"`
+	// EventBus 进程内车辆事件总线（各订阅者独立缓冲与丢弃策略）
+	EventBus EventBusConfig `yaml:"EventBus,optional" json:"EventBus,optional"`
+	// StatePush 合作平台通过 HTTP 推送车辆状态的鉴权配置
+	StatePush StatePushConfig `yaml:"StatePush,optional" json:"StatePush,optional"`
+	// Validation 车辆状态入库前的校验规则（范围、相邻点速度、时间戳偏差），不通过的记录写入隔离 measurement
+	Validation ValidationConfig `yaml:"Validation,optional" json:"Validation,optional"`
+	// Sinks Processor 批次的写入目标（Influx / MySQL vehicle_records / NDJSON 文件），各自独立并发与重试
+	Sinks SinksConfig `yaml:"Sinks,optional" json:"Sinks,optional"`
+	// WAL Influx 写入失败时 Processor 落盘的预写日志，恢复后按顺序重放；未配置 Dir 时不启用
+	WAL WALConfig `yaml:"WAL,optional" json:"WAL,optional"`
+	// Sampling 上游状态的变化感知降频策略（可按车辆类型覆盖）
+	Sampling SamplingConfig `yaml:"Sampling,optional" json:"Sampling,optional"`
+	// Sequencer 按车辆对状态去重、在重排窗口内按时间戳排序，并配置迟到数据的处理方式
+	Sequencer SequencerConfig `yaml:"Sequencer,optional" json:"Sequencer,optional"`
+	// Registry 未在 vehicle_list 登记的车辆识别与处理（记录上报、审核通过或拉黑），需配置 MySQL
+	Registry RegistryConfig `yaml:"Registry,optional" json:"Registry,optional"`
+	// Recorder 上游原始消息录制（gzip 压缩的 NDJSON）与回放，未配置 Dir 时不启用
+	Recorder RecorderConfig `yaml:"Recorder,optional" json:"Recorder,optional"`
+	// WebSocket /api/vehicle/ws 推送的客户端队列配置
+	WebSocket WebSocketConfig `yaml:"WebSocket,optional" json:"WebSocket,optional"`
+	// WSAuth /api/vehicle/ws 握手的来源白名单与令牌鉴权（静态令牌或 HS256 JWT），令牌限定可用的 serviceId 与车辆范围
+	WSAuth WSAuthConfig `yaml:"WSAuth,optional" json:"WSAuth,optional"`
+	// AdminAuth 运维接口（下发车辆指令、websocket 客户端管理、上游启停、回放、未登记车辆审核）的管理令牌
+	AdminAuth AdminAuthConfig `yaml:"AdminAuth,optional" json:"AdminAuth,optional"`
 	// AppId 与 Key 用于对接外部平台的接口鉴权（在 vehicle-api.yaml 中配置）
 	AppId string `yaml:"AppId" json:"AppId"`
 	Key   string `yaml:"Key" json:"Key"`
@@ -42,15 +79,258 @@ type MySQLConfig struct {
 
 // VEHStateConfig 配置用于连接外部车辆状态API
 type VEHStateConfig struct {
-	URL            string `yaml:"url" json:"url"`                                // WebSocket服务器地址，如 ws://host:port/infraCloud/openapi/regionCloud/v1/ws/can
-	HeartbeatTimer int    `yaml:"heartbeatTimer,optional" json:"heartbeatTimer"` // 心跳间隔（秒），0表示不启用心跳，默认为0
+	URL            string `yaml:"url" json:"url"`                                         // WebSocket服务器地址，如 ws://host:port/infraCloud/openapi/regionCloud/v1/ws/can
+	HeartbeatTimer int    `yaml:"heartbeatTimer,optional" json:"heartbeatTimer,optional"` // 心跳间隔（秒），0表示不启用心跳，默认为0
+	// HeartbeatMode 心跳方式：ping（默认，WebSocket Ping 控制帧，由对端回 Pong）或 message（发送 HeartbeatMessage 文本消息）
+	HeartbeatMode    string `yaml:"heartbeatMode,optional" json:"heartbeatMode,optional"`
+	HeartbeatMessage string `yaml:"heartbeatMessage,optional" json:"heartbeatMessage,optional"` // message 模式发送的内容，默认 {"type":"HEARTBEAT"}
+	// ReadIdleTimeoutSec 读空闲看门狗：超过该时长未收到任何消息/Pong 即断开重连，用于发现半开连接。
+	// 0 时启用心跳则为 3 倍心跳间隔，未启用心跳则不启用看门狗（空闲的上游可能长时间无数据）；负数表示不启用
+	ReadIdleTimeoutSec int `yaml:"readIdleTimeoutSec,optional" json:"readIdleTimeoutSec,optional"`
 	// SampleIntervalMs: 接收外部 VEHState 数据时的最低处理间隔（毫秒）。
 	// 如果外部推送非常频繁（例如 10Hz），可以配置为 1000（1s）或其他值来降频处理，
-	// 避免后端同步写入或处理被高频 I/O 压垮。0 表示不降频（逐条处理）。
-	SampleIntervalMs int `yaml:"sampleIntervalMs,optional" json:"sampleIntervalMs"`
+	// 避免后端同步写入或处理被高频 I/O 压垮。
+	// 现作为 Sampling.default.maxIntervalMs 未配置时的默认值：位置/航向/关注字段无变化时最多间隔该时长保留一条。
+	SampleIntervalMs int `yaml:"sampleIntervalMs,optional" json:"sampleIntervalMs,optional"`
+}
+
+// UpstreamSourceConfig 一个命名的上游 VEHState 来源，连接参数与 VEHState 相同
+type UpstreamSourceConfig struct {
+	Name           string `yaml:"name" json:"name"` // 来源名称，写入每条车辆状态的 source 字段
+	VEHStateConfig        // url / heartbeatTimer / readIdleTimeoutSec / sampleIntervalMs 等
+	Disabled       bool   `yaml:"disabled,optional" json:"disabled,optional"` // 启动时不连接，运行时可通过 /api/vehicle/upstream/enabled 启用
+	AppId          string `yaml:"appId,optional" json:"appId,optional"`       // 该平台的鉴权 AppId，为空时使用顶层 AppId
+	Key            string `yaml:"key,optional" json:"key,optional"`           // 该平台的鉴权 Key，为空时使用顶层 Key
+	// Categories 只接收这些车辆类型编码，为空表示全部接收
+	Categories []int `yaml:"categories,optional" json:"categories,optional"`
+	// Sampling 该来源的降频策略，未配置时与其它来源共用顶层 Sampling
+	Sampling SamplingConfig `yaml:"sampling,optional" json:"sampling,optional"`
+}
+
+// MQTTConfig 配置车辆遥测 MQTT 订阅；收到的数据与 VEHState 走相同的降频与 Processor 处理流程
+type MQTTConfig struct {
+	Broker       string `yaml:"broker,optional" json:"broker,optional"`     // Broker 地址，如 tcp://host:1883，为空表示不启用
+	ClientId     string `yaml:"clientId,optional" json:"clientId,optional"` // 客户端 ID，默认 vehicle-api；持久会话依赖固定的 ClientId
+	Username     string `yaml:"username,optional" json:"username,optional"`
+	Password     string `yaml:"password,optional" json:"password,optional"`
+	CleanSession bool   `yaml:"cleanSession,optional" json:"cleanSession,optional"` // false（默认）时断线期间的 QoS1 消息在重连后补发
+	QoS          int    `yaml:"qos,optional" json:"qos,optional"`                   // 订阅 QoS，默认 1
+	// Rules 按主题的解析规则，未配置时默认订阅 veh/+/state（自动识别 JSON/二进制，vehicleId 取主题第 2 段）
+	Rules []MQTTTopicRule `yaml:"rules,optional" json:"rules,optional"`
+}
+
+// MQTTTopicRule 单个订阅主题的负载解析规则
+type MQTTTopicRule struct {
+	Topic string `yaml:"topic" json:"topic"` // 订阅主题（可含 +/# 通配符），如 veh/+/state
+	// Format 负载格式：json | binary（0xF2 固定报文头的完整报文，或不带报文头的 VEH2CLOUD_STATE v1 数据段）| auto（默认，按首字节识别）
+	Format string `yaml:"format,optional" json:"format,optional"`
+	// VehicleIdSegment 从主题的第几段（从 0 开始）取 vehicleId，负数表示使用负载中的 vehicleId；默认 1（veh/{vehicleId}/state）
+	VehicleIdSegment *int `yaml:"vehicleIdSegment,optional" json:"vehicleIdSegment,optional"`
+	// Fields JSON 字段映射：负载字段名 -> VehicleStateData 字段名（json tag），如 lng: lon
+	Fields map[string]string `yaml:"fields,optional" json:"fields,optional"`
+	// CategoryCode 负载未携带车辆类型时使用的默认值
+	CategoryCode int `yaml:"categoryCode,optional" json:"categoryCode,optional"`
 }
 
 // HttpConfig 配置用于连接外部HTTP API
 type HttpConfig struct {
 	URL string `yaml:"url" json:"url"` // 外部API地址
 }
+
+// PlatformConfig 外部平台 HTTP 接口的调用策略，熔断与限流按接口分别计算
+type PlatformConfig struct {
+	TimeoutSec         int     `yaml:"timeoutSec,optional" json:"timeoutSec,optional"`                 // 单次请求超时（秒），默认 15
+	MaxRetries         int     `yaml:"maxRetries,optional" json:"maxRetries,optional"`                 // 网络错误、5xx、429 与响应体损坏时的重试次数，默认 2，负数不重试
+	RetryBackoffMs     int     `yaml:"retryBackoffMs,optional" json:"retryBackoffMs,optional"`         // 首次重试等待（毫秒），之后按 2 倍递增并加入随机抖动，默认 200
+	MaxBackoffMs       int     `yaml:"maxBackoffMs,optional" json:"maxBackoffMs,optional"`             // 重试等待上限（毫秒），默认 5000
+	BreakerFailures    int     `yaml:"breakerFailures,optional" json:"breakerFailures,optional"`       // 连续失败达到该次数时熔断，默认 5，负数不熔断
+	BreakerCooldownSec int     `yaml:"breakerCooldownSec,optional" json:"breakerCooldownSec,optional"` // 熔断持续时间（秒），之后放行一个探测请求，默认 30
+	RateLimit          float64 `yaml:"rateLimit,optional" json:"rateLimit,optional"`                   // 每个接口每秒请求数上限，默认 10，负数不限流
+	Burst              int     `yaml:"burst,optional" json:"burst,optional"`                           // 限流的突发请求数，默认取 rateLimit 向上取整
+	PageSize           int     `yaml:"pageSize,optional" json:"pageSize,optional"`                     // 行程查询每页条数，默认 100
+	MaxPages           int     `yaml:"maxPages,optional" json:"maxPages,optional"`                     // 行程查询最多翻页数，默认 100
+}
+
+// TCPServerConfig 配置车辆直连的二进制 TCP 接入服务
+type TCPServerConfig struct {
+	Addr           string `yaml:"addr,optional" json:"addr,optional"`                     // 监听地址，如 ":6000"，为空表示不启用
+	MaxFrameSize   int    `yaml:"maxFrameSize,optional" json:"maxFrameSize,optional"`     // 单帧数据段最大字节数，0 使用默认 64KB
+	ReadTimeoutSec int    `yaml:"readTimeoutSec,optional" json:"readTimeoutSec,optional"` // 连接读空闲超时（秒），超时断开，0 使用默认 120s
+	QuarantineFile string `yaml:"quarantineFile,optional" json:"quarantineFile,optional"` // 未知数据集/解码失败报文的隔离日志（NDJSON），为空则写入服务日志
+}
+
+// CryptoConfig 配置二进制协议数据段解密密钥，密钥均为十六进制字符串
+// （AES 支持 16/24/32 字节，SM4 为 16 字节）；数据段中的明文 keyId 对应 VehicleKeys 或 AppKeys 中的键
+type CryptoConfig struct {
+	VehicleKeys  map[string]string `yaml:"vehicleKeys,optional" json:"vehicleKeys,optional"`   // vehicleId -> 密钥
+	AppKeys      map[string]string `yaml:"appKeys,optional" json:"appKeys,optional"`           // appId -> 密钥
+	DefaultAppId string            `yaml:"defaultAppId,optional" json:"defaultAppId,optional"` // 无法确定 AppId 的接入（如 TCP 直连）使用的 AppId，为空时使用顶层 AppId
+	Required     bool              `yaml:"required,optional" json:"required,optional"`         // 所有二进制报文都必须加密；为 false 时配置了车辆密钥的车辆仍必须加密，其不加密报文进入隔离日志
+}
+
+// DownlinkConfig 配置下行指令的投递与应答跟踪
+type DownlinkConfig struct {
+	// Transport 投递通道：tcp 仅通过车辆 TCP 直连；vehstate 仅通过 VEHState 上游转发；
+	// auto（默认）优先使用车辆当前的 TCP 直连，不在线时回退到 VEHState 上游；
+	// TCP 直连只对以 Crypto.vehicleKeys 中的车辆密钥加密上报的车辆可用
+	Transport     string `yaml:"transport,optional" json:"transport,optional"`
+	AckTimeoutSec int    `yaml:"ackTimeoutSec,optional" json:"ackTimeoutSec,optional"` // 等待车端应答的超时（秒），0 使用默认 10s
+	MaxRetries    int    `yaml:"maxRetries,optional" json:"maxRetries,optional"`       // 应答超时后的最大重发次数，0 使用默认 2 次，负数表示不重发
+}
+
+// EventBusConfig 配置进程内事件总线的默认缓冲与丢弃策略，Subscribers 按订阅者类别覆盖
+// （内置类别：ws-broadcast、task-monitor、dispatch）
+type EventBusConfig struct {
+	BufferSize     int                              `yaml:"bufferSize,optional" json:"bufferSize,optional"`         // 每个订阅者的缓冲事件数，0 使用默认 1024
+	DropPolicy     string                           `yaml:"dropPolicy,optional" json:"dropPolicy,optional"`         // 缓冲区满时的策略：drop_newest（默认）| drop_oldest | block
+	BlockTimeoutMs int                              `yaml:"blockTimeoutMs,optional" json:"blockTimeoutMs,optional"` // block 策略下发布方最长等待（毫秒），0 使用默认 100ms
+	Subscribers    map[string]EventSubscriberConfig `yaml:"subscribers,optional" json:"subscribers,optional"`
+}
+
+// EventSubscriberConfig 单类订阅者的参数覆盖，零值表示沿用默认
+type EventSubscriberConfig struct {
+	BufferSize     int    `yaml:"bufferSize,optional" json:"bufferSize,optional"`
+	DropPolicy     string `yaml:"dropPolicy,optional" json:"dropPolicy,optional"`
+	BlockTimeoutMs int    `yaml:"blockTimeoutMs,optional" json:"blockTimeoutMs,optional"`
+}
+
+// StatePushConfig 配置 POST /api/vehicle/state/push 的鉴权与限制
+type StatePushConfig struct {
+	Partners        map[string]string `yaml:"partners,optional" json:"partners,optional"`               // 合作方 appId -> key，为空时仅接受顶层 AppId/Key 签名的请求
+	ReplayWindowSec int               `yaml:"replayWindowSec,optional" json:"replayWindowSec,optional"` // 时间戳允许偏差与 nonce 防重放窗口（秒），0 使用默认 300s
+	MaxBatch        int               `yaml:"maxBatch,optional" json:"maxBatch,optional"`               // 单次请求最多记录数，0 使用默认 1000
+}
+
+// ValidationConfig 配置车辆状态校验阶段，Rules 为空时使用内置默认规则
+type ValidationConfig struct {
+	Disabled              bool             `yaml:"disabled,optional" json:"disabled,optional"`                           // 关闭校验（所有记录直接放行）
+	QuarantineMeasurement string           `yaml:"quarantineMeasurement,optional" json:"quarantineMeasurement,optional"` // 被拒绝记录写入的 Influx measurement，默认 vehicle_status_quarantine
+	Rules                 []ValidationRule `yaml:"rules,optional" json:"rules,optional"`
+}
+
+// ValidationRule 单条校验规则，按 Type 使用对应参数：
+//
+//	range           Field 取值须在 [Min, Max] 内（Field: lon/lat/speed/heading/soc/mileage/timestamp）
+//	max_speed       与该车上一条通过校验的记录相比，推算速度不超过 MaxSpeedKmh（识别 GPS 漂移/瞬移）
+//	timestamp_skew  记录时间不晚于当前时间 MaxFutureSec 秒，且（MaxPastSec > 0 时）不早于当前时间 MaxPastSec 秒
+type ValidationRule struct {
+	Name         string   `yaml:"name" json:"name"` // 规则名称，用于拒绝原因与计数
+	Type         string   `yaml:"type" json:"type"`
+	Field        string   `yaml:"field,optional" json:"field,optional"`
+	Min          *float64 `yaml:"min,optional" json:"min,optional"`
+	Max          *float64 `yaml:"max,optional" json:"max,optional"`
+	MaxSpeedKmh  float64  `yaml:"maxSpeedKmh,optional" json:"maxSpeedKmh,optional"`
+	MaxFutureSec int      `yaml:"maxFutureSec,optional" json:"maxFutureSec,optional"`
+	MaxPastSec   int      `yaml:"maxPastSec,optional" json:"maxPastSec,optional"`
+}
+
+// SequencerConfig 配置按车辆的状态排序阶段（位于校验之后、发布事件与入库之前）
+type SequencerConfig struct {
+	Disabled        bool   `yaml:"disabled,optional" json:"disabled,optional"`               // 关闭排序（记录按到达顺序直接放行，不去重）
+	ReorderWindowMs int    `yaml:"reorderWindowMs,optional" json:"reorderWindowMs,optional"` // 重排窗口（毫秒），记录最多缓冲该时长等待更早的记录，默认 300，负数表示不缓冲
+	MaxBuffered     int    `yaml:"maxBuffered,optional" json:"maxBuffered,optional"`         // 每辆车最多缓冲的记录数，超出时提前放行最早的记录，默认 64
+	DedupHistory    int    `yaml:"dedupHistory,optional" json:"dedupHistory,optional"`       // 每辆车用于去重的最近时间戳个数，默认 256
+	LatePolicy      string `yaml:"latePolicy,optional" json:"latePolicy,optional"`           // 迟到数据（早于已放行记录）的处理：write_only（默认，仅入库）/ broadcast（同时广播）
+}
+
+// RegistryConfig 配置未登记车辆的识别：每条状态的 vehicleId 与内存中的 vehicle_list 缓存比对，
+// 未登记车辆的首次/最近上报时间、上报次数与最近一条数据写入 unregistered_vehicle_reports
+type RegistryConfig struct {
+	Disabled           bool   `yaml:"disabled,optional" json:"disabled,optional"`                     // 关闭识别（全部车辆照常处理，不记录）
+	UnknownPolicy      string `yaml:"unknownPolicy,optional" json:"unknownPolicy,optional"`           // 未登记车辆的数据：accept（默认，记录并照常处理）/ reject（仅记录，不入库不广播）；已拉黑车辆始终拒绝
+	RefreshIntervalSec int    `yaml:"refreshIntervalSec,optional" json:"refreshIntervalSec,optional"` // 从 MySQL 重新加载已登记/已拉黑车辆的间隔（秒），默认 300
+	FlushIntervalSec   int    `yaml:"flushIntervalSec,optional" json:"flushIntervalSec,optional"`     // 未登记车辆上报记录的批量写入间隔（秒），默认 5
+}
+
+// SamplingConfig 配置 VEHState / MQTT 上游状态的降频：满足任一触发条件的记录被保留，其余丢弃
+type SamplingConfig struct {
+	Disabled   bool             `yaml:"disabled,optional" json:"disabled,optional"`     // 关闭降频（逐条处理）
+	Default    SamplingPolicy   `yaml:"default,optional" json:"default,optional"`       // 默认策略
+	Categories []SamplingPolicy `yaml:"categories,optional" json:"categories,optional"` // 按 categoryCode 覆盖，未配置的字段沿用默认策略
+}
+
+// SamplingPolicy 降频触发条件（相对该车上一条保留的记录）；数值为负表示关闭该条件
+type SamplingPolicy struct {
+	CategoryCode   int      `yaml:"categoryCode,optional" json:"categoryCode,optional"`     // 车辆类型编码，仅用于 Categories
+	MaxIntervalMs  int      `yaml:"maxIntervalMs,optional" json:"maxIntervalMs,optional"`   // 距上一条保留记录超过该时长时保留，默认取 VEHState.sampleIntervalMs（再缺省为 1000）
+	MinIntervalMs  int      `yaml:"minIntervalMs,optional" json:"minIntervalMs,optional"`   // 距离/航向触发的最小间隔，防止高频抖动，默认 0（关注字段变化不受此限制）
+	DistanceMeters float64  `yaml:"distanceMeters,optional" json:"distanceMeters,optional"` // 移动距离超过该值时保留，默认 20
+	HeadingDeg     float64  `yaml:"headingDeg,optional" json:"headingDeg,optional"`         // 航向变化超过该角度时保留，默认 30
+	WatchFields    []string `yaml:"watchFields,optional" json:"watchFields,optional"`       // 取值变化时必定保留的字段，默认 aebFlag/doors/driveMode/vehFault
+}
+
+// WALConfig 配置 Processor 的本地分段预写日志
+type WALConfig struct {
+	Dir               string `yaml:"dir,optional" json:"dir,optional"`                             // 分段文件目录，为空表示不启用（Influx Sink 重试后仍失败的批次被丢弃）
+	SegmentMaxMB      int    `yaml:"segmentMaxMB,optional" json:"segmentMaxMB,optional"`           // 单个分段文件上限（MB），默认 16
+	MaxTotalMB        int    `yaml:"maxTotalMB,optional" json:"maxTotalMB,optional"`               // 全部分段总大小上限（MB），达到上限后新的失败批次不再落盘（计入丢弃），已落盘的记录不会被丢弃，默认 1024
+	ReplayIntervalSec int    `yaml:"replayIntervalSec,optional" json:"replayIntervalSec,optional"` // 积压数据的重放检查间隔（秒），默认 5
+}
+
+// SinksConfig 配置 Processor 的写入目标：Influx 默认启用；MySQL 在配置了 mysql 连接时默认启用；File 配置 dir 后启用
+type SinksConfig struct {
+	Influx SinkConfig `yaml:"influx,optional" json:"influx,optional"`
+	MySQL  SinkConfig `yaml:"mysql,optional" json:"mysql,optional"`
+	File   SinkConfig `yaml:"file,optional" json:"file,optional"`
+}
+
+// SinkConfig 单个写入目标的并发、重试与（文件 Sink 的）滚动参数
+type SinkConfig struct {
+	Disabled        bool   `yaml:"disabled,optional" json:"disabled,optional"`
+	Concurrency     int    `yaml:"concurrency,optional" json:"concurrency,optional"`         // 并发写入协程数，默认 4（file 为 1）
+	QueueSize       int    `yaml:"queueSize,optional" json:"queueSize,optional"`             // 等待写入的批次数上限，满时丢弃新批次（Influx 启用 WAL 时改为落盘），默认 64
+	MaxRetries      int    `yaml:"maxRetries,optional" json:"maxRetries,optional"`           // 单批失败后的重试次数，默认 2，负数表示不重试
+	RetryBackoffMs  int    `yaml:"retryBackoffMs,optional" json:"retryBackoffMs,optional"`   // 首次重试等待（毫秒），之后每次翻倍，默认 500
+	WriteTimeoutSec int    `yaml:"writeTimeoutSec,optional" json:"writeTimeoutSec,optional"` // 单次写入超时（秒），默认 10
+	Dir             string `yaml:"dir,optional" json:"dir,optional"`                         // 仅 file：输出目录
+	Prefix          string `yaml:"prefix,optional" json:"prefix,optional"`                   // 仅 file：文件名前缀，默认 vehicle-state
+	MaxFileMB       int    `yaml:"maxFileMB,optional" json:"maxFileMB,optional"`             // 仅 file：单文件大小上限（MB），默认 64，另按天滚动
+	MaxFiles        int    `yaml:"maxFiles,optional" json:"maxFiles,optional"`               // 仅 file：保留文件个数，0 表示不删除
+}
+
+// RecorderConfig 配置上游原始消息录制：VEHState / MQTT 收到的每条消息在解析前连同接收时间写入 gzip 压缩的 NDJSON 文件，
+// 可通过 /api/vehicle/replay 按原速、N 倍速或最快速度回放：按同样的格式解析，经独立的校验/降频/排序后发布到地图推送与到达检测
+type RecorderConfig struct {
+	Dir        string `yaml:"dir,optional" json:"dir,optional"`               // 录制文件目录，为空表示不启用录制与回放
+	ReplayOnly bool   `yaml:"replayOnly,optional" json:"replayOnly,optional"` // 只提供回放、不录制（例如在测试环境回放线上录制的文件）
+	Prefix     string `yaml:"prefix,optional" json:"prefix,optional"`         // 文件名前缀，默认 raw
+	MaxFileMB  int    `yaml:"maxFileMB,optional" json:"maxFileMB,optional"`   // 单文件压缩后大小上限（MB），默认 64，另按天滚动
+	MaxFiles   int    `yaml:"maxFiles,optional" json:"maxFiles,optional"`     // 保留文件个数，0 表示不删除
+	BufferSize int    `yaml:"bufferSize,optional" json:"bufferSize,optional"` // 待写入消息的缓冲条数，满时丢弃（不阻塞接收），默认 4096
+	// ReplaySinkDir 回放的车辆状态另写入该目录的 NDJSON 文件（回放不写入 Influx/MySQL 等生产 Sink），为空表示不落盘
+	ReplaySinkDir string `yaml:"replaySinkDir,optional" json:"replaySinkDir,optional"`
+}
+
+// WebSocketConfig 配置 websocket 客户端的待发送队列：位置数据按车辆只保留最新一条（写入跟不上时合并，不断开客户端），
+// 任务/告警事件无损排队，并保留在续传缓冲中供断线重连补发；服务端定期发送 Ping，超过读空闲超时未收到 Pong 或消息的客户端被断开
+type WebSocketConfig struct {
+	EventQueueSize     int `yaml:"eventQueueSize,optional" json:"eventQueueSize,optional"`         // 每个客户端待发送的任务/告警事件上限，超出时断开该客户端，默认 4096
+	ResumeBufferSize   int `yaml:"resumeBufferSize,optional" json:"resumeBufferSize,optional"`     // 保留最近的任务/告警事件数，断线重连的客户端按 lastSeq 补发，默认 10000
+	PingIntervalSec    int `yaml:"pingIntervalSec,optional" json:"pingIntervalSec,optional"`       // 向客户端发送 Ping 的间隔（秒），默认 20
+	ReadIdleTimeoutSec int `yaml:"readIdleTimeoutSec,optional" json:"readIdleTimeoutSec,optional"` // 读空闲超时（秒），超过该时长未收到 Pong 或消息即断开，默认 60，不大于 Ping 间隔时取其 3 倍
+	WriteTimeoutSec    int `yaml:"writeTimeoutSec,optional" json:"writeTimeoutSec,optional"`       // 单帧写超时（秒），超时断开（对端不再读取），默认 10
+	SnapshotMaxAgeSec  int `yaml:"snapshotMaxAgeSec,optional" json:"snapshotMaxAgeSec,optional"`   // 连接快照只包含该时长内有更新的车辆，更久未更新的车辆从最新状态存储中移除（秒），默认 600，负数表示不过期
+	PublishQueueSize   int `yaml:"publishQueueSize,optional" json:"publishQueueSize,optional"`     // 等待 Hub 路由的任务/告警消息上限，超出时丢弃新消息并计入 publishDropped，默认 65536
+}
+
+// WSAuthConfig 配置 websocket 握手校验：未配置 Tokens 与 JWTSecret 时不校验令牌（启动时告警）且拒绝带 serviceId 的连接，未配置 AllowedOrigins 时不限制来源
+type WSAuthConfig struct {
+	AllowedOrigins []string        `yaml:"allowedOrigins,optional" json:"allowedOrigins,optional"` // 允许的 Origin（如 https://screen.example.com），"*" 表示不限制；不带 Origin 的非浏览器连接始终允许
+	Tokens         []WSTokenConfig `yaml:"tokens,optional" json:"tokens,optional"`                 // 静态令牌
+	JWTSecret      string          `yaml:"jwtSecret,optional" json:"jwtSecret,optional"`           // HS256 JWT 密钥，claims 中的 serviceIds/types/vehicleIds/categoryCodes 为令牌范围，必须带 exp
+}
+
+// AdminAuthConfig 运维接口鉴权：请求头 Authorization: Bearer <token> 须与 Tokens 之一相同，未配置 Tokens 时运维接口一律返回 403（启动时告警）
+type AdminAuthConfig struct {
+	Tokens []string `yaml:"tokens,optional" json:"tokens,optional"` // 管理令牌，可配置多个便于轮换
+}
+
+// WSTokenConfig 静态令牌及其范围，types/vehicleIds/categoryCodes 为空表示不限制
+type WSTokenConfig struct {
+	Name          string   `yaml:"name,optional" json:"name,optional"`                   // 名称，仅用于日志
+	Token         string   `yaml:"token" json:"token"`                                   // 令牌，客户端通过 ?token= 或 Authorization: Bearer 传入
+	ServiceIds    []string `yaml:"serviceIds,optional" json:"serviceIds,optional"`       // 可使用的 serviceId，为空时只允许不带 serviceId 的连接
+	Types         []string `yaml:"types,optional" json:"types,optional"`                 // 可订阅的消息类型 positions/tasks/alarms/replay_positions/replay_alarms
+	VehicleIds    []string `yaml:"vehicleIds,optional" json:"vehicleIds,optional"`       // 可接收的车辆
+	CategoryCodes []int    `yaml:"categoryCodes,optional" json:"categoryCodes,optional"` // 可接收的车辆类型
+}
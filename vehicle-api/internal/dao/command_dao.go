@@ -0,0 +1,136 @@
+package dao
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"vehicle-api/internal/types"
+)
+
+// InsertVehicleCommand 新增一条下行指令记录（状态为 pending），返回自增 id 作为指令编号
+func (d *MySQLDao) InsertVehicleCommand(vehicleId, commandType, params string) (int64, error) {
+	if d == nil || d.DB == nil {
+		return 0, fmt.Errorf("mysql dao not initialized")
+	}
+	res, err := d.DB.Exec(`INSERT INTO vehicle_commands (vehicle_id, command_type, params, status, attempts) VALUES (?, ?, ?, 'pending', 0)`,
+		vehicleId, commandType, params)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateVehicleCommand 按 commandId 更新指令的投递/应答状态
+func (d *MySQLDao) UpdateVehicleCommand(cmd *types.VehicleCommand) error {
+	if d == nil || d.DB == nil {
+		return fmt.Errorf("mysql dao not initialized")
+	}
+	if cmd == nil {
+		return fmt.Errorf("command is nil")
+	}
+	_, err := d.DB.Exec(`UPDATE vehicle_commands SET
+		status = ?, transport = ?, attempts = ?, result_code = ?, last_error = ?,
+		sent_at = ?, acked_at = ?
+		WHERE id = ?`,
+		cmd.Status, cmd.Transport, cmd.Attempts, cmd.ResultCode, cmd.LastError,
+		nullableTime(cmd.SentAt), nullableTime(cmd.AckedAt), cmd.CommandId)
+	return err
+}
+
+// GetVehicleCommand 查询单条指令记录，不存在时返回 nil, nil
+func (d *MySQLDao) GetVehicleCommand(commandId int64) (*types.VehicleCommand, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	row := d.DB.QueryRow(`SELECT id, vehicle_id, command_type, params, status, transport, attempts,
+		result_code, last_error, created_at, sent_at, acked_at
+		FROM vehicle_commands WHERE id = ?`, commandId)
+	cmd, err := scanVehicleCommand(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// ListVehicleCommands 按时间倒序列出指令记录，vehicleId 为空时返回全部车辆
+func (d *MySQLDao) ListVehicleCommands(vehicleId string, limit int) ([]types.VehicleCommand, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT id, vehicle_id, command_type, params, status, transport, attempts,
+		result_code, last_error, created_at, sent_at, acked_at
+		FROM vehicle_commands`
+	args := []interface{}{}
+	if vehicleId != "" {
+		query += ` WHERE vehicle_id = ?`
+		args = append(args, vehicleId)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]types.VehicleCommand, 0)
+	for rows.Next() {
+		cmd, err := scanVehicleCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *cmd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVehicleCommand(r rowScanner) (*types.VehicleCommand, error) {
+	var cmd types.VehicleCommand
+	var params, transport, lastError sql.NullString
+	var resultCode sql.NullInt64
+	var createdAt time.Time
+	var sentAt, ackedAt sql.NullTime
+	if err := r.Scan(&cmd.CommandId, &cmd.VehicleId, &cmd.CommandType, &params, &cmd.Status, &transport, &cmd.Attempts,
+		&resultCode, &lastError, &createdAt, &sentAt, &ackedAt); err != nil {
+		return nil, err
+	}
+	cmd.Params = params.String
+	cmd.Transport = transport.String
+	cmd.LastError = lastError.String
+	cmd.ResultCode = int(resultCode.Int64)
+	cmd.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	if sentAt.Valid {
+		cmd.SentAt = sentAt.Time.UTC().Format(time.RFC3339)
+	}
+	if ackedAt.Valid {
+		cmd.AckedAt = ackedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return &cmd, nil
+}
+
+// nullableTime 将 RFC3339 字符串转换为可写入 DATETIME 的值，空串或非法格式写入 NULL
+func nullableTime(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
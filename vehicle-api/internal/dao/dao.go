@@ -15,10 +15,11 @@ import (
 )
 
 type InfluxDao struct {
-	InfluxWriter influxdb2.Client
-	WriteAPI     api.WriteAPI
-	Org          string
-	Bucket       string
+	InfluxWriter  influxdb2.Client
+	WriteAPI      api.WriteAPI
+	WriteBlocking api.WriteAPIBlocking // 同步写入，返回值即 Influx 的确认结果（用于需要落盘兜底的写入）
+	Org           string
+	Bucket        string
 }
 
 func NewInfluxDao(client influxdb2.Client, org, bucket string) *InfluxDao {
@@ -32,10 +33,11 @@ func NewInfluxDao(client influxdb2.Client, org, bucket string) *InfluxDao {
 	}()
 
 	return &InfluxDao{
-		InfluxWriter: client,
-		WriteAPI:     writeAPI,
-		Org:          org,
-		Bucket:       bucket,
+		InfluxWriter:  client,
+		WriteAPI:      writeAPI,
+		WriteBlocking: client.WriteAPIBlocking(org, bucket),
+		Org:           org,
+		Bucket:        bucket,
 	}
 }
 
@@ -44,17 +46,42 @@ func (d *InfluxDao) AddPoint(point *write.Point) error {
 	return nil
 }
 
+// WritePoints 同步写入一批数据点，Influx 返回成功后才返回 nil
+func (d *InfluxDao) WritePoints(ctx context.Context, points ...*write.Point) error {
+	return d.WriteBlocking.WritePoint(ctx, points...)
+}
+
 func (d *InfluxDao) BuildPoint(vehicleStatus *types.VehicleStateData) (*write.Point, error) {
 
 	// GNSS 时间为毫秒时间戳（UTC 毫秒），直接转换为 time.Time 后使用 UTC()
 	timeStamp := time.UnixMilli(int64(vehicleStatus.Timestamp)).UTC()
 
+	tags, fields := stateTagsAndFields(vehicleStatus)
+	point := write.NewPoint("vehicle_status", tags, fields, timeStamp)
+	return point, nil
+}
+
+// BuildQuarantinePoint 构建校验未通过记录的数据点：写入单独的 measurement，rule 作为 tag、reason 作为字段。
+// 记录自身的时间戳可能是无效值，因此点时间使用接收时间，原始时间戳保留在 timestamp 字段中。
+func (d *InfluxDao) BuildQuarantinePoint(measurement string, vehicleStatus *types.VehicleStateData, rule, reason string) *write.Point {
+	tags, fields := stateTagsAndFields(vehicleStatus)
+	tags["rule"] = rule
+	fields["reason"] = reason
+	return write.NewPoint(measurement, tags, fields, time.Now().UTC())
+}
+
+// stateTagsAndFields 把车辆状态转换为 Influx tags 与 fields
+func stateTagsAndFields(vehicleStatus *types.VehicleStateData) (map[string]string, map[string]interface{}) {
 	// 将部分复杂字段写为简单标量，字段名与 VehicleStateData 保持一致，写入为浮点/数值类型
 	tags := map[string]string{
 		"vehicleId": vehicleStatus.VehicleId,
 	}
 	// categoryCode 作为 tag 便于按车型查询
 	tags["categoryCode"] = strconv.Itoa(vehicleStatus.CategoryCode)
+	// 多上游来源时按来源区分
+	if vehicleStatus.Source != "" {
+		tags["source"] = vehicleStatus.Source
+	}
 	// 将所有 VehicleStateData 字段按名称写入 fields，尽量保持类型一致
 	// 对于数组类型（如 Doors）序列化为 JSON 字符串写入
 	doorsStr := ""
@@ -102,9 +129,7 @@ func (d *InfluxDao) BuildPoint(vehicleStatus *types.VehicleStateData) (*write.Po
 		"vehFault":        vehicleStatus.VehFault,
 		"doors":           doorsStr,
 	}
-
-	point := write.NewPoint("vehicle_status", tags, fields, timeStamp)
-	return point, nil
+	return tags, fields
 }
 
 func (d *InfluxDao) Close() {
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -13,32 +14,39 @@ type MySQLDao struct {
 
 func NewMySQLDao(db *sql.DB) *MySQLDao { return &MySQLDao{DB: db} }
 
-// BatchInsertRecords 批量插入 vehicle_records（在事务中）
-func (d *MySQLDao) BatchInsertRecords(records []struct {
+// VehicleRecord 对应 vehicle_records 表的一行
+type VehicleRecord struct {
 	VehicleId string
 	Timestamp time.Time
 	Lon, Lat  int64
 	Velocity  int
-}) error {
+	Extra     []byte // JSON，为空时写入 NULL
+}
+
+// BatchInsertRecords 批量插入 vehicle_records（在事务中），与已有记录 (vehicle_id, timestamp) 重复的行被忽略
+func (d *MySQLDao) BatchInsertRecords(ctx context.Context, records []VehicleRecord) error {
 	if d == nil || d.DB == nil {
 		return fmt.Errorf("mysql dao not initialized")
 	}
 	if len(records) == 0 {
 		return nil
 	}
-	tx, err := d.DB.Begin()
+	tx, err := d.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare(`INSERT IGNORE INTO vehicle_records (vehicle_id, timestamp, longitude, latitude, velocity, extra) VALUES (?, ?, ?, ?, ?, ?)`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT IGNORE INTO vehicle_records (vehicle_id, timestamp, longitude, latitude, velocity, extra) VALUES (?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 	defer stmt.Close()
 	for _, r := range records {
-		if _, err := stmt.Exec(r.VehicleId, r.Timestamp, r.Lon, r.Lat, r.Velocity, nil); err != nil {
-			// log and continue
+		var extra interface{}
+		if len(r.Extra) > 0 {
+			extra = string(r.Extra)
+		}
+		if _, err := stmt.ExecContext(ctx, r.VehicleId, r.Timestamp, r.Lon, r.Lat, r.Velocity, extra); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -0,0 +1,177 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"vehicle-api/internal/types"
+)
+
+// UnregisteredReport 一段时间内某辆未登记车辆的上报汇总，批量写入 unregistered_vehicle_reports
+type UnregisteredReport struct {
+	VehicleId string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Count     int64
+	Payload   []byte // 最近一条状态的 JSON，为空时保留原值
+}
+
+// ListVehicleIds 返回 vehicle_list 中全部 vehicleId
+func (d *MySQLDao) ListVehicleIds() ([]string, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	return d.queryStrings(`SELECT vehicleId FROM vehicle_list`)
+}
+
+// ListUnregisteredIdsByStatus 返回 unregistered_vehicle_reports 中指定状态的 vehicleId
+func (d *MySQLDao) ListUnregisteredIdsByStatus(status string) ([]string, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	return d.queryStrings(`SELECT vehicle_id FROM unregistered_vehicle_reports WHERE status = ?`, status)
+}
+
+func (d *MySQLDao) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := d.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]string, 0)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// UpsertUnregisteredReports 批量写入未登记车辆上报（在事务中）：新车辆插入为 pending；
+// 已有记录累加上报次数并更新最近上报时间与数据，之前审核通过（之后又从 vehicle_list 删除）的车辆重新变为 pending
+func (d *MySQLDao) UpsertUnregisteredReports(reports []UnregisteredReport) error {
+	if d == nil || d.DB == nil {
+		return fmt.Errorf("mysql dao not initialized")
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO unregistered_vehicle_reports
+		(vehicle_id, status, first_seen, last_seen, report_count, last_payload)
+		VALUES (?, 'pending', ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		status = IF(status = 'approved', 'pending', status),
+		last_seen = GREATEST(last_seen, VALUES(last_seen)),
+		report_count = report_count + VALUES(report_count),
+		last_payload = COALESCE(VALUES(last_payload), last_payload)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range reports {
+		var payload interface{}
+		if len(r.Payload) > 0 {
+			payload = string(r.Payload)
+		}
+		if _, err := stmt.Exec(r.VehicleId, r.FirstSeen, r.LastSeen, r.Count, payload); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetUnregisteredStatus 设置车辆的审核状态；车辆尚无上报记录时新建一条（上报次数为 0）
+func (d *MySQLDao) SetUnregisteredStatus(vehicleId, status string) error {
+	if d == nil || d.DB == nil {
+		return fmt.Errorf("mysql dao not initialized")
+	}
+	now := time.Now()
+	_, err := d.DB.Exec(`INSERT INTO unregistered_vehicle_reports
+		(vehicle_id, status, first_seen, last_seen, report_count)
+		VALUES (?, ?, ?, ?, 0)
+		ON DUPLICATE KEY UPDATE status = VALUES(status)`,
+		vehicleId, status, now, now)
+	return err
+}
+
+// GetUnregisteredVehicle 查询单辆车的上报记录，不存在时返回 nil, nil
+func (d *MySQLDao) GetUnregisteredVehicle(vehicleId string) (*types.UnregisteredVehicle, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	row := d.DB.QueryRow(`SELECT vehicle_id, status, first_seen, last_seen, report_count, last_payload
+		FROM unregistered_vehicle_reports WHERE vehicle_id = ?`, vehicleId)
+	v, err := scanUnregisteredVehicle(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// ListUnregisteredVehicles 按最近上报时间倒序列出上报记录，status 为空时返回全部状态
+func (d *MySQLDao) ListUnregisteredVehicles(status string, limit int) ([]types.UnregisteredVehicle, error) {
+	if d == nil || d.DB == nil {
+		return nil, fmt.Errorf("mysql dao not initialized")
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT vehicle_id, status, first_seen, last_seen, report_count, last_payload
+		FROM unregistered_vehicle_reports`
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY last_seen DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]types.UnregisteredVehicle, 0)
+	for rows.Next() {
+		v, err := scanUnregisteredVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func scanUnregisteredVehicle(r rowScanner) (*types.UnregisteredVehicle, error) {
+	var v types.UnregisteredVehicle
+	var firstSeen, lastSeen time.Time
+	var payload sql.NullString
+	if err := r.Scan(&v.VehicleId, &v.Status, &firstSeen, &lastSeen, &v.ReportCount, &payload); err != nil {
+		return nil, err
+	}
+	v.FirstSeen = firstSeen.UTC().Format(time.RFC3339)
+	v.LastSeen = lastSeen.UTC().Format(time.RFC3339)
+	if payload.Valid && payload.String != "" {
+		var d types.VehicleStateData
+		if err := json.Unmarshal([]byte(payload.String), &d); err == nil {
+			v.LastPayload = &d
+		}
+	}
+	return &v, nil
+}
@@ -0,0 +1,341 @@
+package downlink
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/dao"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/types"
+)
+
+// 指令状态
+const (
+	StatusPending  = "pending"  // 已创建，尚未投递成功
+	StatusSent     = "sent"     // 已投递，等待车端应答
+	StatusAcked    = "acked"    // 车端应答执行成功
+	StatusRejected = "rejected" // 车端应答拒绝/执行失败
+	StatusTimeout  = "timeout"  // 已投递但重试后仍未收到应答
+	StatusFailed   = "failed"   // 没有可用通道，投递失败
+)
+
+// memoryKeep 未配置 MySQL 时内存中保留的指令记录条数
+const memoryKeep = 1000
+
+// ErrNoTransport 表示当前没有可以投递到该车辆的通道
+var ErrNoTransport = errors.New("downlink: no transport available for vehicle")
+
+// Manager 负责下行指令的编码、投递、应答超时重发与状态持久化。
+// 配置了 MySQL 时指令记录写入 vehicle_commands 表（自增 id 作为报文中的 commandId），
+// 否则仅保存在内存中（重启后丢失）。
+type Manager struct {
+	cfg        config.DownlinkConfig
+	transports []Transport
+	mysql      *dao.MySQLDao
+
+	mu      sync.Mutex
+	seq     int64
+	pending map[int64]*pendingCommand // 等待应答的指令，key: commandId
+	records map[int64]*types.VehicleCommand
+	order   []int64 // records 的插入顺序，用于内存模式下的列表与淘汰
+	closed  bool
+}
+
+type pendingCommand struct {
+	cmd   *types.VehicleCommand
+	frame []byte
+	timer *time.Timer
+}
+
+// NewManager 创建下行指令管理器；transports 按优先顺序排列，nil 项会被忽略
+func NewManager(cfg config.DownlinkConfig, mysql *dao.MySQLDao, transports ...Transport) *Manager {
+	if cfg.Transport == "" {
+		cfg.Transport = TransportAuto
+	}
+	if cfg.AckTimeoutSec <= 0 {
+		cfg.AckTimeoutSec = 10
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 2
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	m := &Manager{
+		cfg:     cfg,
+		mysql:   mysql,
+		pending: make(map[int64]*pendingCommand),
+		records: make(map[int64]*types.VehicleCommand),
+	}
+	for _, t := range transports {
+		if t == nil {
+			continue
+		}
+		if cfg.Transport != TransportAuto && t.Name() != cfg.Transport {
+			continue
+		}
+		m.transports = append(m.transports, t)
+	}
+	return m
+}
+
+// Submit 创建并投递一条指令，返回投递后的指令记录。
+// 投递失败时记录仍会保存（状态为 failed 或等待重试的 pending），error 仅表示参数或存储错误。
+func (m *Manager) Submit(c *protocol.Command, params string) (*types.VehicleCommand, error) {
+	name := protocol.CommandTypeName(c.Type)
+	if name == "" {
+		return nil, fmt.Errorf("unknown command type: %d", c.Type)
+	}
+	id, err := m.nextId(c.VehicleId, name, params)
+	if err != nil {
+		return nil, err
+	}
+	c.CommandId = uint32(id)
+	payload, err := protocol.EncodeCommand(c)
+	if err != nil {
+		return nil, err
+	}
+	frame := protocol.EncodeFrame(types.FixedHeader{
+		DataCategory: protocol.DataCategoryCloud2VehCommand,
+		Version:      1,
+		Timestamp:    uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		Control:      protocol.MakeControl(protocol.PriorityHigh, protocol.EncryptNone),
+	}, payload)
+
+	cmd := &types.VehicleCommand{
+		CommandId:   id,
+		VehicleId:   c.VehicleId,
+		CommandType: name,
+		Params:      params,
+		Status:      StatusPending,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	p := &pendingCommand{cmd: cmd, frame: frame}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errors.New("downlink: manager closed")
+	}
+	m.pending[id] = p
+	m.remember(cmd)
+	m.mu.Unlock()
+
+	m.attempt(p)
+	return m.snapshot(id), nil
+}
+
+// HandleAck 处理车端应答（TCP 直连或 VEHState 上游转发），更新指令状态；
+// 只比较应答中的车辆编号，调用方须确认应答来自该车辆（TCP 直连只接受以车辆密钥认证的应答）
+func (m *Manager) HandleAck(ack *protocol.CommandAckData) {
+	if ack == nil {
+		return
+	}
+	id := int64(ack.CommandId)
+	m.mu.Lock()
+	p, ok := m.pending[id]
+	if !ok || p.cmd.VehicleId != ack.VehicleId {
+		m.mu.Unlock()
+		logx.Infof("收到未知或已结束的指令应答 vehicleId=%s commandId=%d result=%d", ack.VehicleId, ack.CommandId, ack.Result)
+		return
+	}
+	delete(m.pending, id)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.cmd.ResultCode = ack.Result
+	p.cmd.AckedAt = time.Now().UTC().Format(time.RFC3339)
+	if ack.Result == 0 {
+		p.cmd.Status = StatusAcked
+	} else {
+		p.cmd.Status = StatusRejected
+	}
+	cmd := *p.cmd
+	m.mu.Unlock()
+
+	logx.Infof("指令应答 vehicleId=%s commandId=%d type=%s status=%s result=%d", cmd.VehicleId, cmd.CommandId, cmd.CommandType, cmd.Status, cmd.ResultCode)
+	m.persist(&cmd)
+}
+
+// Get 查询指令记录，不存在时返回 nil
+func (m *Manager) Get(commandId int64) (*types.VehicleCommand, error) {
+	if cmd := m.snapshot(commandId); cmd != nil {
+		return cmd, nil
+	}
+	if m.mysql != nil {
+		return m.mysql.GetVehicleCommand(commandId)
+	}
+	return nil, nil
+}
+
+// List 按时间倒序列出指令记录，vehicleId 为空时返回全部车辆
+func (m *Manager) List(vehicleId string, limit int) ([]types.VehicleCommand, error) {
+	if m.mysql != nil {
+		return m.mysql.ListVehicleCommands(vehicleId, limit)
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]types.VehicleCommand, 0)
+	for i := len(m.order) - 1; i >= 0 && len(out) < limit; i-- {
+		cmd := m.records[m.order[i]]
+		if vehicleId != "" && cmd.VehicleId != vehicleId {
+			continue
+		}
+		out = append(out, *cmd)
+	}
+	return out, nil
+}
+
+// Close 停止所有应答等待定时器，未应答的指令保持当前状态
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, p := range m.pending {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+}
+
+// nextId 分配指令编号：配置了 MySQL 时使用插入记录的自增 id，否则使用内存序号
+func (m *Manager) nextId(vehicleId, commandType, params string) (int64, error) {
+	if m.mysql != nil {
+		return m.mysql.InsertVehicleCommand(vehicleId, commandType, params)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	return m.seq, nil
+}
+
+// attempt 选择可用通道投递一次，并启动应答超时定时器；超时后重发，重发次数用尽后结束
+func (m *Manager) attempt(p *pendingCommand) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	vehicleId := p.cmd.VehicleId
+	commandId := p.cmd.CommandId
+	m.mu.Unlock()
+
+	transport, err := m.send(vehicleId, uint32(commandId), p.frame)
+
+	m.mu.Lock()
+	if _, ok := m.pending[commandId]; !ok {
+		// 发送过程中已收到应答
+		m.mu.Unlock()
+		return
+	}
+	p.cmd.Attempts++
+	if err != nil {
+		p.cmd.LastError = err.Error()
+		logx.Errorf("下行指令投递失败 vehicleId=%s commandId=%d attempt=%d err=%v", vehicleId, commandId, p.cmd.Attempts, err)
+	} else {
+		p.cmd.Status = StatusSent
+		p.cmd.Transport = transport
+		p.cmd.SentAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if p.cmd.Attempts > m.cfg.MaxRetries {
+		// 最后一次投递：失败则直接结束，成功则仅等待应答，不再重发
+		if err != nil {
+			delete(m.pending, commandId)
+			p.cmd.Status = finalStatus(p.cmd)
+		} else {
+			p.timer = time.AfterFunc(time.Duration(m.cfg.AckTimeoutSec)*time.Second, func() { m.expire(commandId) })
+		}
+	} else {
+		p.timer = time.AfterFunc(time.Duration(m.cfg.AckTimeoutSec)*time.Second, func() { m.attempt(p) })
+	}
+	cmd := *p.cmd
+	m.mu.Unlock()
+
+	m.persist(&cmd)
+}
+
+// expire 最后一次投递后仍未收到应答，结束等待
+func (m *Manager) expire(commandId int64) {
+	m.mu.Lock()
+	p, ok := m.pending[commandId]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.pending, commandId)
+	p.cmd.Status = finalStatus(p.cmd)
+	cmd := *p.cmd
+	m.mu.Unlock()
+
+	logx.Errorf("下行指令等待应答超时 vehicleId=%s commandId=%d attempts=%d", cmd.VehicleId, cmd.CommandId, cmd.Attempts)
+	m.persist(&cmd)
+}
+
+// finalStatus 未收到应答时的最终状态：曾经投递成功为 timeout，否则为 failed
+func finalStatus(cmd *types.VehicleCommand) string {
+	if cmd.SentAt != "" {
+		return StatusTimeout
+	}
+	return StatusFailed
+}
+
+// send 按优先顺序选择当前可用的通道投递，返回实际使用的通道名称
+func (m *Manager) send(vehicleId string, commandId uint32, frame []byte) (string, error) {
+	var lastErr error
+	for _, t := range m.transports {
+		if !t.Available(vehicleId) {
+			continue
+		}
+		if err := t.Send(vehicleId, commandId, frame); err != nil {
+			lastErr = fmt.Errorf("%s: %w", t.Name(), err)
+			continue
+		}
+		return t.Name(), nil
+	}
+	if lastErr == nil {
+		lastErr = ErrNoTransport
+	}
+	return "", lastErr
+}
+
+// remember 在内存中保存记录（调用方持有 m.mu）；超过 memoryKeep 时淘汰已结束的最早记录
+func (m *Manager) remember(cmd *types.VehicleCommand) {
+	m.records[cmd.CommandId] = cmd
+	m.order = append(m.order, cmd.CommandId)
+	for len(m.order) > memoryKeep {
+		oldest := m.order[0]
+		if _, waiting := m.pending[oldest]; waiting {
+			break
+		}
+		delete(m.records, oldest)
+		m.order = m.order[1:]
+	}
+}
+
+// snapshot 返回内存中记录的副本
+func (m *Manager) snapshot(commandId int64) *types.VehicleCommand {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cmd, ok := m.records[commandId]
+	if !ok {
+		return nil
+	}
+	c := *cmd
+	return &c
+}
+
+func (m *Manager) persist(cmd *types.VehicleCommand) {
+	if m.mysql == nil {
+		return
+	}
+	if err := m.mysql.UpdateVehicleCommand(cmd); err != nil {
+		logx.Errorf("更新指令记录失败 commandId=%d err=%v", cmd.CommandId, err)
+	}
+}
@@ -0,0 +1,68 @@
+package downlink
+
+import (
+	"vehicle-api/internal/tcpserver"
+)
+
+// 下行通道名称（记录在 vehicle_commands.transport 中）
+const (
+	TransportTCP      = "tcp"
+	TransportVEHState = "vehstate"
+	TransportAuto     = "auto"
+)
+
+// Transport 为下行指令的投递通道
+type Transport interface {
+	// Name 返回通道名称
+	Name() string
+	// Available 判断当前是否可以向该车辆投递
+	Available(vehicleId string) bool
+	// Send 投递一帧完整报文（含固定报文头）
+	Send(vehicleId string, commandId uint32, frame []byte) error
+}
+
+// tcpTransport 通过车辆自身的 TCP 直连下发
+type tcpTransport struct {
+	server *tcpserver.Server
+}
+
+// NewTCPTransport 创建基于 TCP 接入服务的下行通道
+func NewTCPTransport(server *tcpserver.Server) Transport {
+	return &tcpTransport{server: server}
+}
+
+func (t *tcpTransport) Name() string { return TransportTCP }
+
+func (t *tcpTransport) Available(vehicleId string) bool {
+	return t.server.Connected(vehicleId)
+}
+
+func (t *tcpTransport) Send(vehicleId string, _ uint32, frame []byte) error {
+	return t.server.SendTo(vehicleId, frame)
+}
+
+// UpstreamSender 经 VEHState 上游连接转发下行指令（多来源时按车辆所在来源选择连接）
+type UpstreamSender interface {
+	Connected(vehicleId string) bool
+	SendCommand(vehicleId string, commandId uint32, frame []byte) error
+}
+
+// vehStateTransport 通过 VEHState 上游连接转发，由上游平台投递给车辆
+type vehStateTransport struct {
+	client UpstreamSender
+}
+
+// NewVEHStateTransport 创建基于 VEHState 上游连接的下行通道
+func NewVEHStateTransport(client UpstreamSender) Transport {
+	return &vehStateTransport{client: client}
+}
+
+func (t *vehStateTransport) Name() string { return TransportVEHState }
+
+func (t *vehStateTransport) Available(vehicleId string) bool {
+	return t.client.Connected(vehicleId)
+}
+
+func (t *vehStateTransport) Send(vehicleId string, commandId uint32, frame []byte) error {
+	return t.client.SendCommand(vehicleId, commandId, frame)
+}
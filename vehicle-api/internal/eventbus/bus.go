@@ -0,0 +1,355 @@
+package eventbus
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+// 事件类型
+const (
+	EventVehicleState = "vehicle_state" // 车辆状态（State 字段）
+	EventVehicleAlarm = "vehicle_alarm" // 车辆告警（Data 字段为 *protocol.AlarmData）
+)
+
+// Event 为总线上传递的事件；订阅者之间共享同一个 *Event，只读使用
+type Event struct {
+	Type      string
+	VehicleId string
+	State     *types.VehicleStateData
+	Data      interface{}
+	Late      bool      // 迟到的车辆状态（时间戳早于该车已发布的状态），到达检测等依赖时间顺序的订阅者应忽略
+	Replay    bool      // 录制回放产生的事件，不是线上数据：最新状态、到达检测与派单转发等订阅者应忽略
+	Time      time.Time // 发布时间
+}
+
+// DropPolicy 订阅者缓冲区已满时的处理方式
+type DropPolicy string
+
+const (
+	DropNewest DropPolicy = "drop_newest" // 丢弃新到达的事件（默认）
+	DropOldest DropPolicy = "drop_oldest" // 丢弃缓冲区中最早的事件，保留最新状态
+	Block      DropPolicy = "block"       // 阻塞发布方直到有空间或超时，超时后丢弃新事件
+)
+
+// Filter 订阅过滤条件，字段为空表示不限制
+type Filter struct {
+	VehicleIds []string
+	Types      []string
+}
+
+// SubscribeOptions 订阅参数，零值字段使用总线默认值
+type SubscribeOptions struct {
+	BufferSize   int
+	Policy       DropPolicy
+	BlockTimeout time.Duration
+}
+
+// Subscription 表示一个订阅，通过 C() 读取事件，不再使用时调用 Close
+type Subscription struct {
+	id      uint64
+	group   string
+	bus     *Bus
+	ch      chan *Event
+	vehicle map[string]struct{}
+	types   map[string]struct{}
+	opts    SubscribeOptions
+	since   time.Time
+
+	// mu 保护通道关闭：投递持有读锁，关闭持有写锁；done 在关闭前先关闭，唤醒 block 策略下等待中的投递
+	mu     sync.RWMutex
+	done   chan struct{}
+	closed bool
+
+	delivered   atomic.Uint64
+	dropped     atomic.Uint64
+	maxBuffered atomic.Int64
+}
+
+// C 返回事件通道；订阅关闭后通道被关闭
+func (s *Subscription) C() <-chan *Event {
+	return s.ch
+}
+
+// Close 取消订阅并关闭事件通道，可重复调用
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+func (s *Subscription) match(ev *Event) bool {
+	if len(s.types) > 0 {
+		if _, ok := s.types[ev.Type]; !ok {
+			return false
+		}
+	}
+	if len(s.vehicle) > 0 {
+		if _, ok := s.vehicle[ev.VehicleId]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver 按丢弃策略把事件放入订阅者缓冲区；订阅已关闭时忽略
+func (s *Subscription) deliver(ev *Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- ev:
+		s.delivered.Add(1)
+		s.watermark()
+		return
+	default:
+	}
+	switch s.opts.Policy {
+	case DropOldest:
+		// 腾出一个位置；与其它发布方竞争失败时视为丢弃新事件
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- ev:
+			s.delivered.Add(1)
+		default:
+			s.dropped.Add(1)
+		}
+	case Block:
+		t := time.NewTimer(s.opts.BlockTimeout)
+		defer t.Stop()
+		select {
+		case s.ch <- ev:
+			s.delivered.Add(1)
+		case <-t.C:
+			s.dropped.Add(1)
+		case <-s.done:
+			return
+		}
+	default:
+		s.dropped.Add(1)
+	}
+	s.watermark()
+}
+
+// shutdown 关闭事件通道：先唤醒等待中的投递，再等其退出后关闭，只由总线在移除订阅后调用一次
+func (s *Subscription) shutdown() {
+	close(s.done)
+	s.mu.Lock()
+	s.closed = true
+	close(s.ch)
+	s.mu.Unlock()
+}
+
+func (s *Subscription) watermark() {
+	n := int64(len(s.ch))
+	for {
+		cur := s.maxBuffered.Load()
+		if n <= cur || s.maxBuffered.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+func (s *Subscription) stats() types.EventSubscriberStats {
+	return types.EventSubscriberStats{
+		Id:          s.id,
+		Group:       s.group,
+		Policy:      string(s.opts.Policy),
+		VehicleIds:  joinKeys(s.vehicle),
+		Types:       joinKeys(s.types),
+		Capacity:    cap(s.ch),
+		Buffered:    len(s.ch),
+		MaxBuffered: s.maxBuffered.Load(),
+		Delivered:   s.delivered.Load(),
+		Dropped:     s.dropped.Load(),
+		Since:       s.since.UTC().Format(time.RFC3339),
+	}
+}
+
+// Bus 进程内发布/订阅总线：每个订阅者拥有独立缓冲区，按过滤条件接收全部匹配事件，
+// 慢订阅者只会按自身丢弃策略丢弃事件，不影响其它订阅者。
+type Bus struct {
+	cfg       config.EventBusConfig
+	mu        sync.RWMutex
+	subs      map[uint64]*Subscription
+	nextId    uint64
+	published atomic.Uint64
+}
+
+// NewBus 创建事件总线
+func NewBus(cfg config.EventBusConfig) *Bus {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1024
+	}
+	if cfg.DropPolicy == "" {
+		cfg.DropPolicy = string(DropNewest)
+	}
+	if cfg.BlockTimeoutMs <= 0 {
+		cfg.BlockTimeoutMs = 100
+	}
+	return &Bus{cfg: cfg, subs: make(map[uint64]*Subscription)}
+}
+
+// Subscribe 创建订阅。group 标识订阅者类别（如 task-monitor），用于读取该类别的配置覆盖与指标展示；
+// def 为订阅方期望的默认参数，配置文件中同名 group 的参数优先。
+func (b *Bus) Subscribe(group string, filter Filter, def SubscribeOptions) *Subscription {
+	opts := b.resolve(group, def)
+	s := &Subscription{
+		group: group,
+		bus:   b,
+		ch:    make(chan *Event, opts.BufferSize),
+		done:  make(chan struct{}),
+		opts:  opts,
+		since: time.Now(),
+	}
+	if len(filter.VehicleIds) > 0 {
+		s.vehicle = toSet(filter.VehicleIds)
+	}
+	if len(filter.Types) > 0 {
+		s.types = toSet(filter.Types)
+	}
+	b.mu.Lock()
+	b.nextId++
+	s.id = b.nextId
+	b.subs[s.id] = s
+	b.mu.Unlock()
+	return s
+}
+
+// Publish 向所有匹配的订阅者投递事件；除 block 策略外不会阻塞
+func (b *Bus) Publish(ev *Event) {
+	if ev == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.published.Add(1)
+	// 只在复制订阅者列表时持有总线锁，投递（block 策略下可能等待）不阻塞其它发布方与 Subscribe/Unsubscribe；
+	// 投递与关闭通道之间由各订阅自身的锁保证互斥
+	b.mu.RLock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		if s.match(ev) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.deliver(ev)
+	}
+}
+
+// PublishState 发布车辆状态事件
+func (b *Bus) PublishState(data *types.VehicleStateData) {
+	if data == nil {
+		return
+	}
+	b.Publish(&Event{Type: EventVehicleState, VehicleId: data.VehicleId, State: data})
+}
+
+// Published 返回累计发布的事件数
+func (b *Bus) Published() uint64 {
+	return b.published.Load()
+}
+
+// Stats 返回所有订阅者的指标（按 id 排序）
+func (b *Bus) Stats() []types.EventSubscriberStats {
+	b.mu.RLock()
+	out := make([]types.EventSubscriberStats, 0, len(b.subs))
+	for _, s := range b.subs {
+		out = append(out, s.stats())
+	}
+	b.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+// Close 关闭所有订阅
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for id, s := range b.subs {
+		delete(b.subs, id)
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.shutdown()
+	}
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	if _, ok := b.subs[s.id]; !ok {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.subs, s.id)
+	b.mu.Unlock()
+	s.shutdown()
+}
+
+// resolve 合并参数：配置中的同名 group > 订阅方默认值 > 总线默认值
+func (b *Bus) resolve(group string, def SubscribeOptions) SubscribeOptions {
+	opts := SubscribeOptions{
+		BufferSize:   b.cfg.BufferSize,
+		Policy:       DropPolicy(b.cfg.DropPolicy),
+		BlockTimeout: time.Duration(b.cfg.BlockTimeoutMs) * time.Millisecond,
+	}
+	if def.BufferSize > 0 {
+		opts.BufferSize = def.BufferSize
+	}
+	if def.Policy != "" {
+		opts.Policy = def.Policy
+	}
+	if def.BlockTimeout > 0 {
+		opts.BlockTimeout = def.BlockTimeout
+	}
+	if sc, ok := b.cfg.Subscribers[group]; ok {
+		if sc.BufferSize > 0 {
+			opts.BufferSize = sc.BufferSize
+		}
+		if sc.DropPolicy != "" {
+			opts.Policy = DropPolicy(sc.DropPolicy)
+		}
+		if sc.BlockTimeoutMs > 0 {
+			opts.BlockTimeout = time.Duration(sc.BlockTimeoutMs) * time.Millisecond
+		}
+	}
+	switch opts.Policy {
+	case DropNewest, DropOldest, Block:
+	default:
+		opts.Policy = DropNewest
+	}
+	return opts
+}
+
+func toSet(items []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(items))
+	for _, it := range items {
+		m[it] = struct{}{}
+	}
+	return m
+}
+
+func joinKeys(m map[string]struct{}) string {
+	if len(m) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
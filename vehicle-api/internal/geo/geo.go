@@ -0,0 +1,16 @@
+package geo
+
+import "math"
+
+// earthRadiusMeters 地球平均半径（米）
+const earthRadiusMeters = 6371000.0
+
+// HaversineMeters 计算两点间球面距离（米）
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(d float64) float64 { return d * math.Pi / 180.0 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return earthRadiusMeters * c
+}
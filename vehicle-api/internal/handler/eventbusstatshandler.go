@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"vehicle-api/internal/logic"
+	"vehicle-api/internal/svc"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func EventBusStatsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := logic.NewEventBusStatsLogic(r.Context(), svcCtx)
+		resp, err := l.EventBusStats()
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
@@ -1,39 +1,90 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"vehicle-api/internal/svc"
 	ws "vehicle-api/internal/websocket"
 
+	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 // HandleWebSocketHandler 直接在 handler 层完成 websocket 握手与 client 注册，
 // 将连接交给 hub 管理并启动读写协程。
-// 这样当 TCP 上报调用 ServiceContext.ProcessState 广播消息时，hub 会把消息转发到所有已注册的客户端。
+// 握手前校验来源白名单与令牌（?token= 或 Authorization: Bearer，见 WSAuth 配置），令牌限定可用的 serviceId 与订阅范围；
+// hub 按每个客户端的订阅条件（消息类型、车辆、车型、地图范围）转发消息，
+// 初始订阅可通过查询参数指定（见 Subscription.ApplyQuery），连接后可发送订阅命令修改（见 ws.Command）。
+// 服务端定期发送 Ping，超过读空闲超时未收到 Pong 或消息的连接被断开（见 WebSocket 配置）。
+// 下发的消息均为信封 {v, type, seq, ts, data}；断线重连时带上 lastSeq 与 epoch（最近收到的 seq 与快照中的 epoch），
+// hub 先补发续传缓冲中错过的任务/告警事件，再发送 resumed 消息。
 func HandleWebSocketHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if svcCtx == nil || svcCtx.WSHub == nil {
+		if svcCtx == nil || svcCtx.WSHub == nil || svcCtx.WSAuth == nil {
 			httpx.ErrorCtx(r.Context(), w, http.ErrServerClosed)
 			return
 		}
 
-		// 使用封装的 Upgrader（在 internal/websocket 包中定义，允许跨域）
+		q := r.URL.Query()
+		svcId := q.Get("serviceId")
+		reject := func(err error) {
+			logx.Errorf("websocket 握手被拒绝 remote=%s origin=%s serviceId=%s err=%v", r.RemoteAddr, r.Header.Get("Origin"), svcId, err)
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, ws.ErrUnauthorized):
+				status = http.StatusUnauthorized
+			case errors.Is(err, ws.ErrForbidden):
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+		}
+
+		if err := svcCtx.WSAuth.CheckOrigin(r); err != nil {
+			reject(err)
+			return
+		}
+		scope, err := svcCtx.WSAuth.Authenticate(r, svcId)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		// 按查询参数设置初始订阅（不超出令牌范围），参数错误时在握手前返回
+		sub := ws.DefaultSubscription(svcId)
+		sub.Restrict(scope)
+		if err := sub.ApplyQuery(q); err != nil {
+			reject(err)
+			return
+		}
+		var lastSeq uint64
+		resume := q.Has("lastSeq")
+		if resume {
+			if lastSeq, err = strconv.ParseUint(q.Get("lastSeq"), 10, 64); err != nil {
+				reject(fmt.Errorf("invalid lastSeq %q", q.Get("lastSeq")))
+				return
+			}
+		}
+
+		// 使用封装的 Upgrader（来源已在上面校验）
 		conn, err := ws.Upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
 
-		// 从查询参数读取可选的 serviceId，用于定向广播
-		svcId := r.URL.Query().Get("serviceId")
-		// 创建 client 并注册到 hub
-		client := &ws.Client{Conn: conn, Send: make(chan []byte, 256), ServiceId: svcId}
+		// 创建 client 并注册到 hub：hub 在注册时先排入全量快照（车辆最新状态与监控中的任务），实时数据排在快照之后
+		client := ws.NewClient(conn, svcId, sub)
+		client.WithSnapshot()
+		if resume {
+			client.ResumeFrom(lastSeq, q.Get("epoch"))
+		}
 		svcCtx.WSHub.Register <- client
 
-		// 启动写读协程：写协程负责把 hub.Broadcast 的消息写回客户端，读协程用于处理客户端消息（目前仅打印）
-		go client.WritePump()
+		// 启动写读协程：写协程负责把 hub 路由的消息写回客户端，读协程处理客户端的订阅命令
+		go client.WritePump(svcCtx.WSHub)
 		go client.ReadPump(svcCtx.WSHub)
 	}
 }
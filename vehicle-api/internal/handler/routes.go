@@ -14,11 +14,26 @@ import (
 func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 	server.AddRoutes(
 		[]rest.Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/command",
+				Handler: VehicleCommandGetHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/command/list",
+				Handler: VehicleCommandListHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodPost,
 				Path:    "/api/vehicle/dispatch",
 				Handler: VehicleDispatchHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/eventbus/stats",
+				Handler: EventBusStatsHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodPost,
 				Path:    "/api/vehicle/gettrajectory",
@@ -29,6 +44,61 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/api/vehicle/online",
 				Handler: VehicleOnlineHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/vehicle/state/push",
+				Handler: VehicleStatePushHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/validation/stats",
+				Handler: ValidationStatsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/sequencer/stats",
+				Handler: SequencerStatsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/sampling/stats",
+				Handler: SamplingStatsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/wal/stats",
+				Handler: WALStatsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/sink/stats",
+				Handler: SinkStatsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/upstream/status",
+				Handler: UpstreamStatusHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/replay/status",
+				Handler: ReplayStatusHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/platform/status",
+				Handler: PlatformStatusHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/unregistered",
+				Handler: UnregisteredListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/vehicle/ws/stats",
+				Handler: WSStatsHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/api/vehicle/ws",
@@ -56,4 +126,52 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 			},
 		},
 	)
+
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.AdminAuth},
+			[]rest.Route{
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/command",
+					Handler: VehicleCommandCreateHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/upstream/enabled",
+					Handler: UpstreamEnableHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/replay",
+					Handler: ReplayStartHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/replay/stop",
+					Handler: ReplayStopHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/unregistered/approve",
+					Handler: UnregisteredApproveHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/unregistered/block",
+					Handler: UnregisteredBlockHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/api/vehicle/ws/clients",
+					Handler: WSClientListHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPost,
+					Path:    "/api/vehicle/ws/clients/disconnect",
+					Handler: WSClientDisconnectHandler(serverCtx),
+				},
+			}...,
+		),
+	)
 }
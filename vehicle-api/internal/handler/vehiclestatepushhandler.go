@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"vehicle-api/internal/logic"
+	"vehicle-api/internal/signature"
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// maxPushBodyBytes 推送请求体上限
+const maxPushBodyBytes = 8 << 20
+
+func VehicleStatePushHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 签名基于原始 body 计算，因此不能使用 httpx.Parse，需要先完整读取
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBodyBytes))
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := logic.NewVehicleStatePushLogic(r.Context(), svcCtx)
+		resp, err := l.VehicleStatePush(r.Header.Get(signature.HeaderAppId), r.Header.Get(signature.HeaderTimestamp),
+			r.Header.Get(signature.HeaderNonce), r.Header.Get(signature.HeaderSign), body)
+		if err != nil {
+			if errors.Is(err, logic.ErrPushUnauthorized) {
+				httpx.WriteJsonCtx(r.Context(), w, http.StatusUnauthorized, &types.StatePushResp{Code: http.StatusUnauthorized, Message: err.Error()})
+				return
+			}
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type EventBusStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewEventBusStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *EventBusStatsLogic {
+	return &EventBusStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// EventBusStats 返回事件总线各订阅者的缓冲与丢弃指标，用于排查慢消费者
+func (l *EventBusStatsLogic) EventBusStats() (*types.EventBusStatsResp, error) {
+	if l.svcCtx.EventBus == nil {
+		return nil, errors.New("事件总线未初始化")
+	}
+	return &types.EventBusStatsResp{
+		Code:        0,
+		Message:     "ok",
+		Published:   l.svcCtx.EventBus.Published(),
+		Subscribers: l.svcCtx.EventBus.Stats(),
+	}, nil
+}
@@ -26,5 +26,12 @@ func (l *HandleDeleteVehicleLogic) HandleDeleteVehicle(vehicleId string) error {
 	if l.svcCtx.MySQLDao == nil {
 		return nil
 	}
-	return l.svcCtx.MySQLDao.DeleteVehicle(vehicleId)
+	if err := l.svcCtx.MySQLDao.DeleteVehicle(vehicleId); err != nil {
+		return err
+	}
+	// 删除后该车再上报时按未登记车辆处理
+	if l.svcCtx.Registry != nil {
+		l.svcCtx.Registry.Forget(vehicleId)
+	}
+	return nil
 }
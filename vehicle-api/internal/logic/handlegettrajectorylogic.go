@@ -1,17 +1,11 @@
 package logic
 
 import (
-	"bytes"
 	"context"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math"
-	"net/http"
+	"strings"
 	"time"
 
 	"vehicle-api/internal/svc"
@@ -56,77 +50,24 @@ func (l *HandleGetTrajectoryLogic) HandleGetTrajectory(req *types.TrajectoryReq)
 	startMs := start.UnixNano() / int64(time.Millisecond)
 	endMs := end.UnixNano() / int64(time.Millisecond)
 
-	// 首先调用行程查询接口（VEHRoute）以获取当天的行程列表
-	routeURL := l.svcCtx.Config.VEHRoute.URL
-	var allRoutes []map[string]interface{}
-	if routeURL != "" {
-		// 请求参数：vehicleId, startTime, endTime, page
-		routePayload := map[string]interface{}{
-			"vehicleId": req.VehicleId,
-			"startTime": startMs,
-			"endTime":   endMs,
-			"page": map[string]interface{}{
-				"pageSize":  100,
-				"pageIndex": 0,
-			},
-		}
-		rpBody, _ := json.Marshal(routePayload)
-		rreq, err := http.NewRequest("POST", routeURL, bytes.NewReader(rpBody))
-		if err != nil {
-			logx.Errorf("创建外部行程请求失败: %v", err)
-		} else {
-			rreq.Header.Set("Content-Type", "application/json")
-			// 签名头（与其它调用一致）
-			if l.svcCtx.Config.AppId != "" && l.svcCtx.Config.Key != "" {
-				timestamp := fmt.Sprintf("%d", time.Now().Unix())
-				nonceBytes := make([]byte, 12)
-				if _, err := rand.Read(nonceBytes); err != nil {
-					nonceBytes = []byte(timestamp)
-				}
-				nonce := hex.EncodeToString(nonceBytes)
-				signInput := string(rpBody) + timestamp + l.svcCtx.Config.AppId + nonce + l.svcCtx.Config.Key
-				h := sha256.New()
-				h.Write([]byte(signInput))
-				sign := hex.EncodeToString(h.Sum(nil))
-				rreq.Header.Set("appid", l.svcCtx.Config.AppId)
-				rreq.Header.Set("timestamp", timestamp)
-				rreq.Header.Set("nonce", nonce)
-				rreq.Header.Set("sign", sign)
-			}
-			client := &http.Client{Timeout: 15 * time.Second}
-			logx.Infof("调用外部行程 API: url=%s vehicleId=%s start=%d end=%d", routeURL, req.VehicleId, startMs, endMs)
-			rresp, err := client.Do(rreq)
-			if err != nil {
-				logx.Errorf("调用外部行程 API 失败: %v", err)
-			} else {
-				defer rresp.Body.Close()
-				if rresp.StatusCode == http.StatusOK {
-					var rbody map[string]interface{}
-					if err := json.NewDecoder(rresp.Body).Decode(&rbody); err != nil {
-						logx.Errorf("解析外部行程响应体失败: %v", err)
-					} else {
-						// 取 data.list 数组
-						if dataVal, ok := rbody["data"]; ok {
-							if dataMap, ok := dataVal.(map[string]interface{}); ok {
-								if listVal, ok := dataMap["list"]; ok {
-									if arr, ok := listVal.([]interface{}); ok && len(arr) > 0 {
-										// 收集所有行程，后续遍历每条行程并调用轨迹接口
-										for _, it := range arr {
-											if m, ok := it.(map[string]interface{}); ok {
-												allRoutes = append(allRoutes, m)
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-				}
-			}
+	if l.svcCtx.Platform == nil {
+		return nil, errors.New("外部平台客户端未启用")
+	}
+
+	// 首先调用行程查询接口（VEHRoute）获取时间范围内的全部行程（自动翻页）
+	logx.Infof("调用外部行程 API: vehicleId=%s start=%d end=%d", req.VehicleId, startMs, endMs)
+	trips, err := l.svcCtx.Platform.Trips(l.ctx, req.VehicleId, startMs, endMs)
+	// 未取全时仍返回已取到的行程，并以 partial 标记告知调用方；一条都没取到时直接返回错误
+	var problems []string
+	if err != nil {
+		logx.Errorf("调用外部行程 API 失败（已获取 %d 条行程）: %v", len(trips), err)
+		if len(trips) == 0 {
+			return nil, err
 		}
+		problems = append(problems, err.Error())
 	}
 	// 如果没有获取到行程，则返回空数组
-	if len(allRoutes) == 0 {
+	if len(trips) == 0 {
 		return &types.Route2TrajectoryResp{
 			Code:    0,
 			Message: "SUCCESS",
@@ -135,113 +76,51 @@ func (l *HandleGetTrajectoryLogic) HandleGetTrajectory(req *types.TrajectoryReq)
 	}
 
 	// 遍历所有行程，按每条行程的 start/end 调用轨迹接口并构建 types.Trajectory 列表
-	var result []types.Trajectory
-	for _, routeFields := range allRoutes {
-		var rStart, rEnd int64
-		if v, ok := routeFields["startTime"]; ok {
-			if f, ok := toFloat64(v); ok {
-				rStart = int64(f)
-			}
-		}
-		if v, ok := routeFields["endTime"]; ok {
-			if f, ok := toFloat64(v); ok {
-				rEnd = int64(f)
-			}
-		}
+	result := make([]types.Trajectory, 0, len(trips))
+	for _, trip := range trips {
+		rStart, rEnd := trip.StartTime, trip.EndTime
 		if rStart == 0 && rEnd == 0 {
 			// fallback 使用请求时间范围
 			rStart = startMs
 			rEnd = endMs
 		}
 
-		pts, err := l.callVEHTrajectory(req.VehicleId, rStart, rEnd)
+		pts := []types.PositionPoint{}
+		runPath, err := l.svcCtx.Platform.RunPath(l.ctx, req.VehicleId, rStart, rEnd)
 		if err != nil {
 			// 记录错误，但继续构建其他行程，轨迹点为空
-			logx.Errorf("获取轨迹失败 routeId=%v err=%v", routeFields["routeId"], err)
-			pts = []types.PositionPoint{}
-		}
-
-		t := types.Trajectory{}
-		t.PositionPoints = pts
-
-		if v, ok := routeFields["routeId"]; ok {
-			if s, ok := v.(string); ok {
-				t.RouteId = s
-			}
-		}
-		if v, ok := routeFields["vehicleId"]; ok {
-			if s, ok := v.(string); ok {
-				t.VehicleId = s
-			}
-		}
-		if v, ok := routeFields["vin"]; ok {
-			if s, ok := v.(string); ok {
-				t.Vin = s
-			}
-		}
-		if v, ok := routeFields["plateNo"]; ok {
-			if s, ok := v.(string); ok {
-				t.PlateNo = s
-			}
-		}
-		if v, ok := routeFields["mileage"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.Mileage = f
-			}
-		}
-		if v, ok := routeFields["durationTime"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.DurationTime = f
-			}
-		}
-		if v, ok := routeFields["autoMileage"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.AutoMileage = f
-			}
-		}
-		if v, ok := routeFields["autoDuration"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.AutoDuration = f
-			}
-		}
-		if v, ok := routeFields["autoMileageReal"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.AutoMileageReal = f
-			}
-		}
-		if v, ok := routeFields["autoDurationReal"]; ok {
-			if f, ok := toFloat64(v); ok {
-				t.AutoDurationReal = f
-			}
-		}
-		if v, ok := routeFields["vehicleFactory"]; ok {
-			if s, ok := v.(string); ok {
-				t.VehicleFactory = s
-			}
-		}
-		if v, ok := routeFields["vehicleFactoryName"]; ok {
-			if s, ok := v.(string); ok {
-				t.VehicleFactoryName = s
-			}
+			logx.Errorf("获取轨迹失败 routeId=%s err=%v", trip.RouteId, err)
+			problems = append(problems, fmt.Sprintf("行程 %s 轨迹获取失败: %v", trip.RouteId, err))
+		}
+		for _, p := range runPath {
+			pts = append(pts, types.PositionPoint{
+				Timestamp: time.UnixMilli(p.Timestamp).UTC().Format(time.RFC3339),
+				Longitude: int64(math.Round(p.Lon * 1e7)),
+				Latitude:  int64(math.Round(p.Lat * 1e7)),
+			})
+		}
+
+		t := types.Trajectory{
+			RouteId:            trip.RouteId,
+			VehicleId:          trip.VehicleId,
+			Vin:                trip.Vin,
+			PlateNo:            trip.PlateNo,
+			Mileage:            trip.Mileage,
+			DurationTime:       trip.DurationTime,
+			AutoMileage:        trip.AutoMileage,
+			AutoDuration:       trip.AutoDuration,
+			AutoMileageReal:    trip.AutoMileageReal,
+			AutoDurationReal:   trip.AutoDurationReal,
+			VehicleFactory:     trip.VehicleFactory,
+			VehicleFactoryName: trip.VehicleFactoryName,
+			PositionPoints:     pts,
 		}
 		// startTime/endTime 转为 RFC3339
-		if v, ok := routeFields["startTime"]; ok {
-			if f, ok := toFloat64(v); ok {
-				tms := int64(f)
-				if tms < 1e12 {
-					tms = tms * 1000
-				}
-				t.StartTime = time.Unix(0, tms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
-			}
+		if trip.StartTime != 0 {
+			t.StartTime = time.UnixMilli(trip.StartTime).UTC().Format(time.RFC3339)
 		}
-		if v, ok := routeFields["endTime"]; ok {
-			if f, ok := toFloat64(v); ok {
-				tms := int64(f)
-				if tms < 1e12 {
-					tms = tms * 1000
-				}
-				t.EndTime = time.Unix(0, tms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
-			}
+		if trip.EndTime != 0 {
+			t.EndTime = time.UnixMilli(trip.EndTime).UTC().Format(time.RFC3339)
 		}
 
 		// 若未设置 VehicleId，则使用请求中的
@@ -252,198 +131,14 @@ func (l *HandleGetTrajectoryLogic) HandleGetTrajectory(req *types.TrajectoryReq)
 		result = append(result, t)
 	}
 
-	// 返回全部行程
-	respOut := &types.Route2TrajectoryResp{
+	resp = &types.Route2TrajectoryResp{
 		Code:    0,
 		Message: "SUCCESS",
 		Data:    result,
 	}
-	return respOut, nil
-
-}
-
-// callVEHTrajectory 调用配置中的 VEHTrajectory 接口并解析返回的 runPath 为 PositionPoint 列表
-func (l *HandleGetTrajectoryLogic) callVEHTrajectory(vehicleId string, startMs, endMs int64) ([]types.PositionPoint, error) {
-	apiURL := l.svcCtx.Config.VEHTrajectory.URL
-	if apiURL == "" {
-		logx.Errorf("外部轨迹 API 地址未配置 (VEHTrajectory)")
-		return nil, fmt.Errorf("external trajectory API url not configured")
-	}
-
-	payload := map[string]interface{}{
-		"vehicleId": vehicleId,
-		"startTime": startMs,
-		"endTime":   endMs,
-	}
-	bodyBytes, _ := json.Marshal(payload)
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewReader(bodyBytes))
-	if err != nil {
-		logx.Errorf("创建外部轨迹请求失败: %v", err)
-		return nil, err
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	if l.svcCtx.Config.AppId != "" && l.svcCtx.Config.Key != "" {
-		timestamp := fmt.Sprintf("%d", time.Now().Unix())
-		nonceBytes := make([]byte, 12)
-		if _, err := rand.Read(nonceBytes); err != nil {
-			nonceBytes = []byte(timestamp)
-		}
-		nonce := hex.EncodeToString(nonceBytes)
-		signInput := string(bodyBytes) + timestamp + l.svcCtx.Config.AppId + nonce + l.svcCtx.Config.Key
-		h := sha256.New()
-		h.Write([]byte(signInput))
-		sign := hex.EncodeToString(h.Sum(nil))
-		httpReq.Header.Set("appid", l.svcCtx.Config.AppId)
-		httpReq.Header.Set("timestamp", timestamp)
-		httpReq.Header.Set("nonce", nonce)
-		httpReq.Header.Set("sign", sign)
-	}
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	logx.Infof("调用外部轨迹 API: url=%s vehicleId=%s start=%d end=%d", apiURL, vehicleId, startMs, endMs)
-	httpResp, err := client.Do(httpReq)
-	if err != nil {
-		logx.Errorf("调用外部轨迹 API 失败: %v", err)
-		return nil, err
-	}
-	defer httpResp.Body.Close()
-	if httpResp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
-		logx.Errorf("外部轨迹 API 返回非200: status=%d body=%s", httpResp.StatusCode, string(b))
-		return nil, fmt.Errorf("external API returned status %d: %s", httpResp.StatusCode, string(b))
-	}
-	var respBody map[string]interface{}
-	if err := json.NewDecoder(httpResp.Body).Decode(&respBody); err != nil {
-		logx.Errorf("解析外部轨迹响应体失败: %v", err)
-		return nil, err
-	}
-	// 检查 code
-	if codeVal, ok := respBody["code"]; ok {
-		if codeFloat, ok := codeVal.(float64); ok {
-			if int(codeFloat) != 0 {
-				logx.Errorf("外部轨迹 API 返回错误 code=%v full=%+v", codeFloat, respBody)
-				return nil, fmt.Errorf("external API returned error code: %v", codeFloat)
-			}
-		}
-	}
-	// 抽取 runPath
-	var runPathRaw interface{}
-	if dataVal, ok := respBody["data"]; ok {
-		if dataMap, ok := dataVal.(map[string]interface{}); ok {
-			if v, ok := dataMap["runPath"]; ok {
-				runPathRaw = v
-			} else {
-				runPathRaw = dataVal
-			}
-		} else {
-			runPathRaw = dataVal
-		}
-	} else {
-		runPathRaw = respBody
-	}
-
-	pts := parseRunPathToPoints(runPathRaw)
-	return pts, nil
-}
-
-// parseRunPathToPoints 将外部 runPath 多种格式解析为 []types.PositionPoint
-func parseRunPathToPoints(runPathRaw interface{}) []types.PositionPoint {
-	pts := make([]types.PositionPoint, 0)
-	if arr, ok := runPathRaw.([]interface{}); ok {
-		for _, it := range arr {
-			// 数组格式
-			if inner, ok := it.([]interface{}); ok && len(inner) >= 3 {
-				var lonF, latF, tF float64
-				if v0, ok0 := toFloat64(inner[0]); ok0 {
-					if v1, ok1 := toFloat64(inner[1]); ok1 {
-						if v2, ok2 := toFloat64(inner[2]); ok2 {
-							lonF, latF, tF = v0, v1, v2
-						}
-					}
-				}
-				if lonF == 0 && latF == 0 {
-					if v0, ok0 := toFloat64(inner[0]); ok0 {
-						if v1, ok1 := toFloat64(inner[1]); ok1 {
-							if v2, ok2 := toFloat64(inner[2]); ok2 {
-								if v0 > 1e11 {
-									tF, lonF, latF = v0, v1, v2
-								}
-							}
-						}
-					}
-				}
-				if !(math.IsNaN(lonF) || math.IsNaN(latF) || math.IsNaN(tF)) {
-					tMs := int64(tF)
-					if tMs < 1e12 {
-						tMs = tMs * 1000
-					}
-					ts := time.Unix(0, tMs*int64(time.Millisecond))
-					lonScaled := int64(math.Round(lonF * 1e7))
-					latScaled := int64(math.Round(latF * 1e7))
-					pts = append(pts, types.PositionPoint{Timestamp: ts.UTC().Format(time.RFC3339), Longitude: int64(lonScaled), Latitude: int64(latScaled)})
-					continue
-				}
-			}
-			// 对象格式
-			if m, ok := it.(map[string]interface{}); ok {
-				var lonF, latF, tF float64
-				if v, ok := m["longitude"]; ok {
-					if f, ok := toFloat64(v); ok {
-						lonF = f
-					}
-				}
-				if v, ok := m["lat"]; ok {
-					if f, ok := toFloat64(v); ok {
-						latF = f
-					}
-				}
-				if v, ok := m["latitude"]; ok {
-					if f, ok := toFloat64(v); ok {
-						latF = f
-					}
-				}
-				if v, ok := m["timestamp"]; ok {
-					if f, ok := toFloat64(v); ok {
-						tF = f
-					}
-				}
-				if lonF != 0 || latF != 0 {
-					tMs := int64(tF)
-					if tMs < 1e12 {
-						tMs = tMs * 1000
-					}
-					ts := time.Unix(0, tMs*int64(time.Millisecond))
-					lonScaled := int64(math.Round(lonF * 1e7))
-					latScaled := int64(math.Round(latF * 1e7))
-					pts = append(pts, types.PositionPoint{Timestamp: ts.UTC().Format(time.RFC3339), Longitude: int64(lonScaled), Latitude: int64(latScaled)})
-					continue
-				}
-			}
-		}
-	}
-	return pts
-}
-
-// toFloat64 尝试将接口类型转换为 float64
-func toFloat64(v interface{}) (float64, bool) {
-	switch t := v.(type) {
-	case float64:
-		return t, true
-	case float32:
-		return float64(t), true
-	case int:
-		return float64(t), true
-	case int64:
-		return float64(t), true
-	case uint64:
-		return float64(t), true
-	case json.Number:
-		f, err := t.Float64()
-		if err == nil {
-			return f, true
-		}
-		return 0, false
-	default:
-		return 0, false
+	if len(problems) > 0 {
+		resp.Partial = true
+		resp.Message = "PARTIAL: " + strings.Join(problems, "; ")
 	}
+	return resp, nil
 }
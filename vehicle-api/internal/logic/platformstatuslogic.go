@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type PlatformStatusLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewPlatformStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PlatformStatusLogic {
+	return &PlatformStatusLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// PlatformStatus 返回外部平台各接口的熔断状态、请求/重试/失败计数与最近错误
+func (l *PlatformStatusLogic) PlatformStatus() (*types.PlatformStatusResp, error) {
+	if l.svcCtx.Platform == nil {
+		return nil, errors.New("外部平台客户端未启用")
+	}
+	return &types.PlatformStatusResp{
+		Code:      0,
+		Message:   "ok",
+		Endpoints: l.svcCtx.Platform.Status(),
+	}, nil
+}
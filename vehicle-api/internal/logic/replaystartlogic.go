@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"vehicle-api/internal/recorder"
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ReplayStartLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewReplayStartLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReplayStartLogic {
+	return &ReplayStartLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ReplayStart 在后台回放一个录制文件：消息按记录的接收时间间隔（除以倍速）送回上游解析与处理链路
+func (l *ReplayStartLogic) ReplayStart(req *types.ReplayReq) (*types.ReplayStatusResp, error) {
+	if l.svcCtx.Replayer == nil {
+		return nil, errors.New("原始消息录制未启用")
+	}
+	if req.To > 0 && req.From > req.To {
+		return nil, errors.New("from 不能晚于 to")
+	}
+	err := l.svcCtx.Replayer.Start(req.File, recorder.ReplayOptions{
+		Speed:     req.Speed,
+		Max:       req.Max,
+		VehicleId: req.VehicleId,
+		From:      req.From,
+		To:        req.To,
+	})
+	if err == recorder.ErrBusy {
+		return nil, errors.New("已有回放在进行中")
+	}
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("录制文件不存在: %s", req.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return replayStatus(l.svcCtx)
+}
@@ -0,0 +1,52 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/recorder"
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ReplayStatusLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewReplayStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReplayStatusLogic {
+	return &ReplayStatusLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ReplayStatus 返回回放进度、录制计数与录制目录中的文件
+func (l *ReplayStatusLogic) ReplayStatus() (*types.ReplayStatusResp, error) {
+	return replayStatus(l.svcCtx)
+}
+
+// replayStatus 汇总回放与录制状态，回放相关接口共用
+func replayStatus(svcCtx *svc.ServiceContext) (*types.ReplayStatusResp, error) {
+	if svcCtx.Replayer == nil {
+		return nil, errors.New("原始消息录制未启用")
+	}
+	resp := svcCtx.Replayer.Status()
+	resp.Code = 0
+	resp.Message = "ok"
+	if rec := svcCtx.Recorder; rec != nil {
+		resp.Recording = true
+		resp.Recorded = rec.Recorded()
+		resp.RecordDropped = rec.Dropped()
+	}
+	files, err := recorder.Files(svcCtx.Config.Recorder.Dir)
+	if err != nil {
+		return nil, err
+	}
+	resp.Recordings = files
+	return &resp, nil
+}
@@ -0,0 +1,34 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ReplayStopLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewReplayStopLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReplayStopLogic {
+	return &ReplayStopLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ReplayStop 停止进行中的回放
+func (l *ReplayStopLogic) ReplayStop() (*types.ReplayStatusResp, error) {
+	if l.svcCtx.Replayer == nil {
+		return nil, errors.New("原始消息录制未启用")
+	}
+	l.svcCtx.Replayer.Stop()
+	return replayStatus(l.svcCtx)
+}
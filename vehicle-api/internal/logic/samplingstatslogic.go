@@ -0,0 +1,64 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type SamplingStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewSamplingStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SamplingStatsLogic {
+	return &SamplingStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SamplingStats 返回降频的累计检查、丢弃数与各触发条件的保留数：合计共用降频器与各上游来源的独立降频器，
+// 并在 sources 中分别列出
+func (l *SamplingStatsLogic) SamplingStats() (*types.SamplingStatsResp, error) {
+	var sources []types.SamplingSourceStats
+	if l.svcCtx.Sampler != nil {
+		st := l.svcCtx.Sampler.Stats()
+		sources = append(sources, types.SamplingSourceStats{
+			Checked:  st.Checked,
+			Kept:     st.Kept,
+			Dropped:  st.Dropped,
+			Triggers: st.Triggers,
+		})
+	}
+	if l.svcCtx.Upstreams != nil {
+		sources = append(sources, l.svcCtx.Upstreams.SamplingStats()...)
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("降频未启用")
+	}
+
+	resp := &types.SamplingStatsResp{Code: 0, Message: "ok", Sources: sources}
+	kept := make(map[string]uint64)
+	for _, src := range sources {
+		resp.Checked += src.Checked
+		resp.Kept += src.Kept
+		resp.Dropped += src.Dropped
+		for _, t := range src.Triggers {
+			if _, ok := kept[t.Trigger]; !ok {
+				resp.Triggers = append(resp.Triggers, types.SamplingTriggerStats{Trigger: t.Trigger})
+			}
+			kept[t.Trigger] += t.Kept
+		}
+	}
+	for i := range resp.Triggers {
+		resp.Triggers[i].Kept = kept[resp.Triggers[i].Trigger]
+	}
+	return resp, nil
+}
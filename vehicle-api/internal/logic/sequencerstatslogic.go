@@ -0,0 +1,36 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type SequencerStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewSequencerStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SequencerStatsLogic {
+	return &SequencerStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SequencerStats 返回按车辆排序阶段的去重、重排与迟到计数
+func (l *SequencerStatsLogic) SequencerStats() (*types.SequencerStatsResp, error) {
+	if l.svcCtx.Sequencer == nil {
+		return nil, errors.New("车辆状态排序未启用")
+	}
+	resp := l.svcCtx.Sequencer.Stats()
+	resp.Code = 0
+	resp.Message = "ok"
+	return resp, nil
+}
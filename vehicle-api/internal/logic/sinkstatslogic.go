@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type SinkStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewSinkStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SinkStatsLogic {
+	return &SinkStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SinkStats 返回 Processor 各写入目标的成功、失败、重试与丢弃计数
+func (l *SinkStatsLogic) SinkStats() (*types.SinkStatsResp, error) {
+	if l.svcCtx.Processor == nil {
+		return nil, errors.New("Processor 未初始化")
+	}
+	return &types.SinkStatsResp{
+		Code:    0,
+		Message: "ok",
+		Sinks:   l.svcCtx.Processor.SinkStats(),
+	}, nil
+}
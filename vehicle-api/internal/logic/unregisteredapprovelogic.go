@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UnregisteredApproveLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUnregisteredApproveLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UnregisteredApproveLogic {
+	return &UnregisteredApproveLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UnregisteredApprove 审核通过未登记车辆：写入 vehicle_list（已存在时保留原信息），解除拉黑，之后该车数据照常处理
+func (l *UnregisteredApproveLogic) UnregisteredApprove(req *types.UnregisteredApproveReq) (*types.UnregisteredVehicleResp, error) {
+	reg := l.svcCtx.Registry
+	if reg == nil {
+		return nil, errors.New("未登记车辆识别未启用")
+	}
+	if req.VehicleId == "" {
+		return nil, errors.New("vehicleId 为必填")
+	}
+	info := &types.VehicleInfo{
+		VehicleId:    req.VehicleId,
+		PlateNo:      req.PlateNo,
+		CategoryCode: req.CategoryCode,
+		CategoryName: req.CategoryName,
+		VinCode:      req.VinCode,
+	}
+	// 未指定车辆类型时取最近一次上报中的 categoryCode
+	if info.CategoryCode == 0 {
+		if v, err := reg.Get(req.VehicleId); err == nil && v != nil && v.LastPayload != nil {
+			info.CategoryCode = v.LastPayload.CategoryCode
+		}
+	}
+	if err := reg.Approve(info); err != nil {
+		return nil, err
+	}
+	return unregisteredVehicleResp(reg.Get(req.VehicleId))
+}
+
+// unregisteredVehicleResp 把审核/拉黑后的上报记录包装为响应
+func unregisteredVehicleResp(v *types.UnregisteredVehicle, err error) (*types.UnregisteredVehicleResp, error) {
+	if err != nil {
+		return nil, err
+	}
+	resp := &types.UnregisteredVehicleResp{Code: 0, Message: "ok"}
+	if v != nil {
+		resp.Vehicle = *v
+	}
+	return resp, nil
+}
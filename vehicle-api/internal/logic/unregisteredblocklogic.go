@@ -0,0 +1,40 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UnregisteredBlockLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUnregisteredBlockLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UnregisteredBlockLogic {
+	return &UnregisteredBlockLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UnregisteredBlock 拉黑车辆：之后该车的状态与告警全部拒绝，审核通过可解除
+func (l *UnregisteredBlockLogic) UnregisteredBlock(req *types.UnregisteredBlockReq) (*types.UnregisteredVehicleResp, error) {
+	reg := l.svcCtx.Registry
+	if reg == nil {
+		return nil, errors.New("未登记车辆识别未启用")
+	}
+	if req.VehicleId == "" {
+		return nil, errors.New("vehicleId 为必填")
+	}
+	if err := reg.Block(req.VehicleId); err != nil {
+		return nil, err
+	}
+	return unregisteredVehicleResp(reg.Get(req.VehicleId))
+}
@@ -0,0 +1,46 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UnregisteredListLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUnregisteredListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UnregisteredListLogic {
+	return &UnregisteredListLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UnregisteredList 按最近上报时间倒序列出未登记车辆（含已审核通过与已拉黑的记录），可按状态过滤
+func (l *UnregisteredListLogic) UnregisteredList(req *types.UnregisteredListReq) (*types.UnregisteredListResp, error) {
+	reg := l.svcCtx.Registry
+	if reg == nil {
+		return nil, errors.New("未登记车辆识别未启用")
+	}
+	list, err := reg.List(req.Status, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	known, unknown, rejected := reg.Stats()
+	return &types.UnregisteredListResp{
+		Code:          0,
+		Message:       "ok",
+		KnownVehicles: known,
+		Unknown:       unknown,
+		Rejected:      rejected,
+		Vehicles:      list,
+	}, nil
+}
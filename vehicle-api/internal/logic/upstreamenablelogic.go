@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+	"vehicle-api/internal/upstream"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpstreamEnableLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUpstreamEnableLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpstreamEnableLogic {
+	return &UpstreamEnableLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UpstreamEnable 运行时启用或停用一个上游来源，返回操作后各来源的状态
+func (l *UpstreamEnableLogic) UpstreamEnable(req *types.UpstreamEnableReq) (*types.UpstreamStatusResp, error) {
+	m := l.svcCtx.Upstreams
+	if m == nil {
+		return nil, errors.New("VEHState 未启用")
+	}
+	var err error
+	if req.Enabled {
+		err = m.Enable(req.Name)
+	} else {
+		err = m.Disable(req.Name)
+	}
+	if err == upstream.ErrNotFound {
+		return nil, fmt.Errorf("上游来源不存在: %s", req.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Infof("上游来源 %s enabled=%v", req.Name, req.Enabled)
+	return &types.UpstreamStatusResp{
+		Code:    0,
+		Message: "ok",
+		Sources: m.Status(),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpstreamStatusLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUpstreamStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpstreamStatusLogic {
+	return &UpstreamStatusLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UpstreamStatus 返回各上游 VEHState 来源的连接状态、最近消息时间、重连次数、退避与消息速率
+func (l *UpstreamStatusLogic) UpstreamStatus() (*types.UpstreamStatusResp, error) {
+	if l.svcCtx.Upstreams == nil {
+		return nil, errors.New("VEHState 未启用")
+	}
+	return &types.UpstreamStatusResp{
+		Code:    0,
+		Message: "ok",
+		Sources: l.svcCtx.Upstreams.Status(),
+	}, nil
+}
@@ -0,0 +1,36 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ValidationStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewValidationStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ValidationStatsLogic {
+	return &ValidationStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ValidationStats 返回车辆状态校验的累计校验数与各规则拒绝数
+func (l *ValidationStatsLogic) ValidationStats() (*types.ValidationStatsResp, error) {
+	if l.svcCtx.Validator == nil {
+		return nil, errors.New("车辆状态校验未启用")
+	}
+	resp := l.svcCtx.Validator.Stats()
+	resp.Code = 0
+	resp.Message = "ok"
+	return resp, nil
+}
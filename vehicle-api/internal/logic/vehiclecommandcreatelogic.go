@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type VehicleCommandCreateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewVehicleCommandCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *VehicleCommandCreateLogic {
+	return &VehicleCommandCreateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// VehicleCommandCreate 下发一条指令：立即返回投递结果，车端应答通过 GET /api/vehicle/command 查询
+func (l *VehicleCommandCreateLogic) VehicleCommandCreate(req *types.VehicleCommandReq) (*types.VehicleCommandResp, error) {
+	if l.svcCtx.Downlink == nil {
+		return nil, errors.New("下行指令通道未启用（需配置 TCPServer 或 VEHState）")
+	}
+	if req.VehicleId == "" {
+		return nil, errors.New("vehicleId 为必填")
+	}
+	t, ok := protocol.ParseCommandType(req.CommandType)
+	if !ok {
+		return nil, fmt.Errorf("不支持的 commandType: %s", req.CommandType)
+	}
+	cmd := &protocol.Command{
+		VehicleId: req.VehicleId,
+		Type:      t,
+	}
+	// params 仅记录该指令类型实际使用的参数
+	var params interface{}
+	switch t {
+	case protocol.CommandDispatchTask:
+		if req.TaskId == "" {
+			return nil, errors.New("dispatch_task 需要 taskId")
+		}
+		cmd.TaskId, cmd.Pickup, cmd.Destination = req.TaskId, req.Pickup, req.Destination
+		params = map[string]interface{}{"taskId": req.TaskId, "pickup": req.Pickup, "destination": req.Destination}
+	case protocol.CommandReturnToBase:
+		cmd.Destination = req.Destination
+		params = map[string]interface{}{"destination": req.Destination}
+	case protocol.CommandLockCompartment, protocol.CommandUnlockCompartment:
+		cmd.Compartment = req.Compartment
+		params = map[string]interface{}{"compartment": req.Compartment}
+	case protocol.CommandChangeReportRate:
+		if req.ReportIntervalMs <= 0 {
+			return nil, errors.New("change_report_rate 需要 reportIntervalMs > 0")
+		}
+		cmd.ReportIntervalMs = uint32(req.ReportIntervalMs)
+		params = map[string]interface{}{"reportIntervalMs": req.ReportIntervalMs}
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := l.svcCtx.Downlink.Submit(cmd, string(b))
+	if err != nil {
+		return nil, err
+	}
+	l.Logger.Infof("[command] vehicleId=%s commandId=%d type=%s status=%s transport=%s", rec.VehicleId, rec.CommandId, rec.CommandType, rec.Status, rec.Transport)
+	return &types.VehicleCommandResp{Code: 0, Message: "ok", Data: *rec}, nil
+}
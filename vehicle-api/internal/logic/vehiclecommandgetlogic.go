@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type VehicleCommandGetLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewVehicleCommandGetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *VehicleCommandGetLogic {
+	return &VehicleCommandGetLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// VehicleCommandGet 查询单条指令的投递与应答状态
+func (l *VehicleCommandGetLogic) VehicleCommandGet(req *types.VehicleCommandQueryReq) (*types.VehicleCommandResp, error) {
+	if l.svcCtx.Downlink == nil {
+		return nil, errors.New("下行指令通道未启用（需配置 TCPServer 或 VEHState）")
+	}
+	cmd, err := l.svcCtx.Downlink.Get(req.CommandId)
+	if err != nil {
+		return nil, err
+	}
+	if cmd == nil {
+		return nil, fmt.Errorf("指令不存在: %d", req.CommandId)
+	}
+	return &types.VehicleCommandResp{Code: 0, Message: "ok", Data: *cmd}, nil
+}
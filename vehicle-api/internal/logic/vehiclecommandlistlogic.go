@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type VehicleCommandListLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewVehicleCommandListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *VehicleCommandListLogic {
+	return &VehicleCommandListLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// VehicleCommandList 按时间倒序列出指令记录（可按车辆过滤）
+func (l *VehicleCommandListLogic) VehicleCommandList(req *types.VehicleCommandListReq) (*types.VehicleCommandListResp, error) {
+	if l.svcCtx.Downlink == nil {
+		return nil, errors.New("下行指令通道未启用（需配置 TCPServer 或 VEHState）")
+	}
+	list, err := l.svcCtx.Downlink.List(req.VehicleId, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &types.VehicleCommandListResp{Code: 0, Message: "ok", Data: list}, nil
+}
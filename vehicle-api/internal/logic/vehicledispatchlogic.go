@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"time"
 
+	"vehicle-api/internal/eventbus"
 	"vehicle-api/internal/svc"
 	"vehicle-api/internal/types"
+	"vehicle-api/internal/websocket"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
@@ -63,8 +65,8 @@ func (l *VehicleDispatchLogic) VehicleDispatch(req *types.DispatchReq) (resp *ty
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 		}
 		if b, jerr := json.Marshal(evt); jerr == nil {
-			// 优先定向广播到 orders 服务的客户端
-			l.svcCtx.WSHub.BroadcastToService("orders", b)
+			// 发给订阅了任务事件的客户端（orders 服务默认订阅）
+			l.svcCtx.WSHub.Publish(websocket.NewMessage(websocket.TypeTasks, websocket.Item{Data: b}))
 		} else {
 			l.Logger.Errorf("marshal dispatch event failed: %v", jerr)
 		}
@@ -78,11 +80,14 @@ func (l *VehicleDispatchLogic) VehicleDispatch(req *types.DispatchReq) (resp *ty
 		l.svcCtx.RegisterTaskForMonitor(taskId, req.OrderId, req.Pickup, req.Destination, "")
 	}
 
+	// 如果没有事件总线或 hub，则无需监听
+	if l.svcCtx == nil || l.svcCtx.EventBus == nil || l.svcCtx.WSHub == nil {
+		return resp, nil
+	}
+	// 每个派单监听者独立订阅，不会与 TaskMonitor 或 websocket 广播抢占事件
+	sub := l.svcCtx.EventBus.Subscribe("dispatch", eventbus.Filter{Types: []string{eventbus.EventVehicleState}}, eventbus.SubscribeOptions{BufferSize: 256})
 	go func(localTaskId string) {
-		// 如果没有事件通道或 hub，则无需监听
-		if l.svcCtx == nil || l.svcCtx.VehicleEventChan == nil || l.svcCtx.WSHub == nil {
-			return
-		}
+		defer sub.Close()
 
 		// 简单示例：监听一段时间内的车辆事件并把原始事件包装后转发到 orders 服务。
 		// 真实实现应根据 platformTaskId/assignedVehicleId/metadata 做过滤并长期订阅。
@@ -95,8 +100,13 @@ func (l *VehicleDispatchLogic) VehicleDispatch(req *types.DispatchReq) (resp *ty
 				// 超时退出监听
 				l.Logger.Infof("dispatch listener for task %s timeout exit", localTaskId)
 				return
-			case ev := <-l.svcCtx.VehicleEventChan:
-				if ev == nil {
+			case e, ok := <-sub.C():
+				if !ok {
+					return
+				}
+				ev := e.State
+				// 迟到状态不转发，避免 orders 侧的车辆轨迹回退；回放状态不是线上数据，同样不转发
+				if ev == nil || e.Late || e.Replay {
 					continue
 				}
 				// 将接收到的车辆状态包装为带 taskId 的消息并转发给 orders 客户端
@@ -109,7 +119,10 @@ func (l *VehicleDispatchLogic) VehicleDispatch(req *types.DispatchReq) (resp *ty
 					"vehicleData": ev,
 				}
 				if b, jerr := json.Marshal(wrapper); jerr == nil {
-					l.svcCtx.WSHub.BroadcastToService("orders", b)
+					// 高频车辆状态按车辆合并，客户端写入跟不上时只发送最新一条
+					msg := websocket.NewMessage(websocket.TypeTasks, websocket.StateItem(ev, b))
+					msg.Coalesce = true
+					l.svcCtx.WSHub.Publish(msg)
 				}
 			}
 		}
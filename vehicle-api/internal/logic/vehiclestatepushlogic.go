@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrPushUnauthorized 推送请求签名校验失败（handler 据此返回 401）
+var ErrPushUnauthorized = errors.New("unauthorized")
+
+type VehicleStatePushLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewVehicleStatePushLogic(ctx context.Context, svcCtx *svc.ServiceContext) *VehicleStatePushLogic {
+	return &VehicleStatePushLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// VehicleStatePush 校验签名后逐条解析推送的车辆状态，合法记录交给 ProcessVehicleState，
+// 单条记录解析或校验失败不影响同批其它记录。
+func (l *VehicleStatePushLogic) VehicleStatePush(appId, timestamp, nonce, sign string, body []byte) (*types.StatePushResp, error) {
+	if err := l.svcCtx.PushVerifier.Verify(appId, timestamp, nonce, sign, body); err != nil {
+		l.Logger.Errorf("[state push] 鉴权失败 appid=%s nonce=%s err=%v", appId, nonce, err)
+		return nil, fmt.Errorf("%w: %v", ErrPushUnauthorized, err)
+	}
+
+	// body 可以是单个对象或数组
+	var raws []json.RawMessage
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raws); err != nil {
+			return nil, fmt.Errorf("请求体不是合法的 JSON 数组: %v", err)
+		}
+	} else {
+		raws = []json.RawMessage{trimmed}
+	}
+	maxBatch := l.svcCtx.Config.StatePush.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = 1000
+	}
+	if len(raws) > maxBatch {
+		return nil, fmt.Errorf("单次推送记录数 %d 超过上限 %d", len(raws), maxBatch)
+	}
+
+	resp := &types.StatePushResp{Code: 0, Message: "ok", Results: make([]types.StatePushResult, 0, len(raws))}
+	for i, raw := range raws {
+		res := types.StatePushResult{Index: i}
+		var data types.VehicleStateData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			res.Reason = "invalid json: " + err.Error()
+		} else {
+			res.VehicleId = data.VehicleId
+			res.Reason = validatePushedState(&data)
+		}
+		if res.Reason == "" {
+			// 未通过校验规则的记录由 ProcessVehicleState 写入隔离 measurement 并返回原因
+			if err := l.svcCtx.ProcessVehicleState(&data); err != nil {
+				res.Reason = err.Error()
+			}
+		}
+		if res.Reason == "" {
+			res.Accepted = true
+			resp.Accepted++
+		} else {
+			resp.Rejected++
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	l.Logger.Infof("[state push] appid=%s records=%d accepted=%d rejected=%d", appId, len(raws), resp.Accepted, resp.Rejected)
+	return resp, nil
+}
+
+// validatePushedState 基本字段校验，返回拒绝原因，合法时返回空字符串
+func validatePushedState(d *types.VehicleStateData) string {
+	switch {
+	case d.VehicleId == "":
+		return "missing vehicleId"
+	case d.Timestamp == 0:
+		return "missing timestamp"
+	case d.Lon < -180 || d.Lon > 180 || d.Lat < -90 || d.Lat > 90:
+		return "lon/lat out of range"
+	}
+	return ""
+}
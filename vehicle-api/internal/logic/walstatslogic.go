@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type WALStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewWALStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WALStatsLogic {
+	return &WALStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// WALStats 返回 Processor 预写日志的积压大小、时长与累计落盘/重放/丢弃记录数
+func (l *WALStatsLogic) WALStats() (*types.WALStatsResp, error) {
+	var resp *types.WALStatsResp
+	if l.svcCtx.Processor != nil {
+		resp = l.svcCtx.Processor.WALStats()
+	}
+	if resp == nil {
+		return nil, errors.New("WAL 未启用")
+	}
+	resp.Code = 0
+	resp.Message = "ok"
+	return resp, nil
+}
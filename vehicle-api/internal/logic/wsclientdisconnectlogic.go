@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type WSClientDisconnectLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewWSClientDisconnectLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WSClientDisconnectLogic {
+	return &WSClientDisconnectLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// WSClientDisconnect 强制断开一个 websocket 客户端，返回其断开前的连接信息
+func (l *WSClientDisconnectLogic) WSClientDisconnect(req *types.WSClientDisconnectReq) (*types.WSClientDisconnectResp, error) {
+	if l.svcCtx.WSHub == nil {
+		return nil, errors.New("WebSocket Hub 未初始化")
+	}
+	info, ok := l.svcCtx.WSHub.Disconnect(req.Id)
+	if !ok {
+		return nil, fmt.Errorf("websocket 客户端不存在: %d", req.Id)
+	}
+	return &types.WSClientDisconnectResp{
+		Code:    0,
+		Message: "ok",
+		Client:  info,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type WSClientListLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewWSClientListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WSClientListLogic {
+	return &WSClientListLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// WSClientList 列出当前连接的 websocket 客户端：对端地址、serviceId、订阅、连接时间与收发、合并、溢出与丢弃计数，以及已断开客户端的累计数
+func (l *WSClientListLogic) WSClientList() (*types.WSClientListResp, error) {
+	if l.svcCtx.WSHub == nil {
+		return nil, errors.New("WebSocket Hub 未初始化")
+	}
+	clients := l.svcCtx.WSHub.ClientList()
+	st := l.svcCtx.WSHub.Stats()
+	return &types.WSClientListResp{
+		Code:       0,
+		Message:    "ok",
+		Total:      len(clients),
+		Evicted:    st.Evicted,
+		IdleClosed: st.IdleClosed,
+		Kicked:     st.Kicked,
+		Clients:    clients,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-api/internal/svc"
+	"vehicle-api/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type WSStatsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewWSStatsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WSStatsLogic {
+	return &WSStatsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// WSStats 返回 websocket 各客户端的待发送积压、排队时长与合并计数
+func (l *WSStatsLogic) WSStats() (*types.WSStatsResp, error) {
+	if l.svcCtx.WSHub == nil {
+		return nil, errors.New("WebSocket Hub 未初始化")
+	}
+	st := l.svcCtx.WSHub.Stats()
+	return &types.WSStatsResp{
+		Code:           0,
+		Message:        "ok",
+		Pending:        st.Pending,
+		PublishDropped: st.PublishDropped,
+		Evicted:        st.Evicted,
+		IdleClosed:     st.IdleClosed,
+		Kicked:         st.Kicked,
+		Epoch:          l.svcCtx.WSHub.Epoch(),
+		Seq:            st.Seq,
+		Buffered:       st.Buffered,
+		Clients:        st.Clients,
+	}, nil
+}
@@ -0,0 +1,388 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/types"
+)
+
+// 负载格式
+const (
+	FormatAuto   = "auto"
+	FormatJSON   = "json"
+	FormatBinary = "binary"
+)
+
+// DefaultTopic 未配置规则时订阅的主题
+const DefaultTopic = "veh/+/state"
+
+// Subscriber 订阅车辆遥测 MQTT 主题，按主题规则把 JSON 或二进制负载转换为 VehicleStateData 回调给上层。
+// 连接断开后自动重连并重新订阅；QoS1 消息在回调处理成功后才确认，处理失败或处理过程中断线的消息由 Broker 重新投递
+// （无法解析的负载重投也无法处理，记录日志后确认）。
+type Subscriber struct {
+	cfg      config.MQTTConfig
+	registry *protocol.Registry
+	keyring  *protocol.Keyring
+	handle   func(*types.VehicleStateData) error
+	// onMessage 二进制报文中除车辆状态以外的消息（告警/心跳/指令应答）回调（可选）
+	onMessage func(*protocol.Message)
+	// onRaw 收到的每条原始负载在解析前的回调（可选，用于录制）
+	onRaw func(topic string, payload []byte)
+
+	mu     sync.Mutex
+	client paho.Client
+}
+
+// NewSubscriber 创建订阅实例（不会立即连接，需调用 Start）
+// registry 为 nil 时使用内置数据集注册表；keyring 为 nil 时加密报文无法解密
+func NewSubscriber(cfg config.MQTTConfig, registry *protocol.Registry, keyring *protocol.Keyring, handle func(*types.VehicleStateData) error) *Subscriber {
+	if cfg.ClientId == "" {
+		cfg.ClientId = "vehicle-api"
+	}
+	if cfg.QoS <= 0 || cfg.QoS > 2 {
+		cfg.QoS = 1
+	}
+	if len(cfg.Rules) == 0 {
+		cfg.Rules = []config.MQTTTopicRule{{Topic: DefaultTopic}}
+	}
+	if registry == nil {
+		registry = protocol.NewDefaultRegistry()
+	}
+	return &Subscriber{cfg: cfg, registry: registry, keyring: keyring, handle: handle}
+}
+
+// SetMessageHandler 设置非状态类二进制消息的回调，需在 Start 之前调用
+func (s *Subscriber) SetMessageHandler(fn func(*protocol.Message)) {
+	s.onMessage = fn
+}
+
+// SetRawHandler 设置原始负载回调（解析前调用），需在 Start 之前调用
+func (s *Subscriber) SetRawHandler(fn func(topic string, payload []byte)) {
+	s.onRaw = fn
+}
+
+// DecodeTopic 按匹配该主题的第一条规则解析一条原始负载（例如回放录制的消息），不调用处理回调；
+// 返回车辆状态与二进制报文中的其它消息（告警、心跳、指令应答等）
+func (s *Subscriber) DecodeTopic(topic string, payload []byte) ([]*types.VehicleStateData, []*protocol.Message, error) {
+	for i := range s.cfg.Rules {
+		if topicMatches(s.cfg.Rules[i].Topic, topic) {
+			return s.Decode(&s.cfg.Rules[i], topic, payload)
+		}
+	}
+	return nil, nil, fmt.Errorf("没有匹配主题 %s 的 MQTT 规则", topic)
+}
+
+// Start 连接 Broker；首次连接失败时在后台持续重试，不阻塞调用方
+func (s *Subscriber) Start() {
+	opts := paho.NewClientOptions().
+		AddBroker(s.cfg.Broker).
+		SetClientID(s.cfg.ClientId).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetCleanSession(s.cfg.CleanSession).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(2 * time.Second).
+		SetMaxReconnectInterval(30 * time.Second).
+		SetKeepAlive(30 * time.Second).
+		// 回调处理完成后再确认 QoS1 消息
+		SetAutoAckDisabled(true).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logx.Errorf("MQTT 连接断开: %v，等待自动重连", err)
+		}).
+		SetReconnectingHandler(func(_ paho.Client, _ *paho.ClientOptions) {
+			logx.Infof("MQTT 正在重连 broker=%s", s.cfg.Broker)
+		})
+
+	client := paho.NewClient(opts)
+	s.mu.Lock()
+	s.client = client
+	s.mu.Unlock()
+	// ConnectRetry 开启时 Connect 会在后台重试，token 在首次连接成功后才完成
+	client.Connect()
+	logx.Infof("MQTT 订阅已启动 broker=%s clientId=%s rules=%d", s.cfg.Broker, s.cfg.ClientId, len(s.cfg.Rules))
+}
+
+// Connected 判断当前是否已连接 Broker
+func (s *Subscriber) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client != nil && s.client.IsConnectionOpen()
+}
+
+// Stop 断开连接（最多等待 1s 让进行中的处理完成）
+func (s *Subscriber) Stop() {
+	s.mu.Lock()
+	client := s.client
+	s.client = nil
+	s.mu.Unlock()
+	if client != nil {
+		client.Disconnect(1000)
+	}
+}
+
+// onConnect 每次（重新）连接成功后按规则订阅；持久会话下重复订阅是幂等的
+func (s *Subscriber) onConnect(c paho.Client) {
+	logx.Infof("MQTT 已连接 broker=%s", s.cfg.Broker)
+	for i := range s.cfg.Rules {
+		rule := s.cfg.Rules[i]
+		token := c.Subscribe(rule.Topic, byte(s.cfg.QoS), func(_ paho.Client, m paho.Message) {
+			s.handleMessage(&rule, m)
+		})
+		go func() {
+			if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+				logx.Errorf("MQTT 订阅失败 topic=%s err=%v", rule.Topic, token.Error())
+			}
+		}()
+	}
+}
+
+// handleMessage 解析一条消息并回调，处理成功后确认；回调失败时不确认，由 Broker 重新投递
+func (s *Subscriber) handleMessage(rule *config.MQTTTopicRule, m paho.Message) {
+	if s.onRaw != nil {
+		s.onRaw(m.Topic(), m.Payload())
+	}
+	err := s.process(rule, m.Topic(), m.Payload())
+	var de *decodeError
+	if err != nil && !errors.As(err, &de) {
+		logx.Errorf("MQTT 消息处理失败，不确认等待重新投递 topic=%s messageId=%d", m.Topic(), m.MessageID())
+		return
+	}
+	m.Ack()
+}
+
+// decodeError 负载无法解析，重新投递也无法处理
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return "decode: " + e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+// process 按规则解析负载并逐条回调；解析失败返回 *decodeError，回调失败时处理完其余记录后返回第一个错误
+func (s *Subscriber) process(rule *config.MQTTTopicRule, topic string, payload []byte) error {
+	states, others, err := s.Decode(rule, topic, payload)
+	if err != nil {
+		logx.Errorf("MQTT 消息解析失败 topic=%s err=%v", topic, err)
+		return &decodeError{err: err}
+	}
+	if s.onMessage != nil {
+		for _, msg := range others {
+			s.onMessage(msg)
+		}
+	}
+	if s.handle == nil {
+		return nil
+	}
+	var first error
+	for _, d := range states {
+		if err := s.handle(d); err != nil {
+			logx.Errorf("处理 MQTT 车辆状态出错 vehicleId=%s err=%v", d.VehicleId, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Decode 按规则把一条消息负载转换为车辆状态（JSON 负载可以是单个对象或数组）。
+// 二进制报文中的非状态消息在第二个返回值中，由调用方决定是否交给 onMessage。
+func (s *Subscriber) Decode(rule *config.MQTTTopicRule, topic string, payload []byte) ([]*types.VehicleStateData, []*protocol.Message, error) {
+	vehicleId := topicVehicleId(rule, topic)
+	format := rule.Format
+	if format == "" || format == FormatAuto {
+		format = sniffFormat(payload)
+	}
+
+	var states []*types.VehicleStateData
+	var others []*protocol.Message
+	switch format {
+	case FormatJSON:
+		var err error
+		states, err = decodeJSON(rule, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+	case FormatBinary:
+		d, other, err := s.decodeBinary(payload, vehicleId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d != nil {
+			states = append(states, d)
+		}
+		if other != nil {
+			others = append(others, other)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown payload format: %s", format)
+	}
+
+	for _, d := range states {
+		if vehicleId != "" {
+			d.VehicleId = vehicleId
+		}
+		if d.CategoryCode == 0 {
+			d.CategoryCode = rule.CategoryCode
+		}
+	}
+	return states, others, nil
+}
+
+// decodeBinary 解析 0xF2 固定报文头的完整报文，或不带报文头的 VEH2CLOUD_STATE v1 数据段；
+// 非状态消息通过第二个返回值返回；不加密的报文须通过密钥环的明文策略（见 protocol.Keyring.CheckCleartext）
+func (s *Subscriber) decodeBinary(payload []byte, vehicleId string) (*types.VehicleStateData, *protocol.Message, error) {
+	if len(payload) == 0 || payload[0] != protocol.StartByte {
+		d, err := protocol.DecodeVeh2CloudState(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.checkCleartext(d.VehicleId, vehicleId); err != nil {
+			return nil, nil, err
+		}
+		return d, nil, nil
+	}
+	h, err := protocol.ParseFixedHeader(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := payload[protocol.HeaderSize:]
+	if int(h.DataLength) != len(body) {
+		return nil, nil, fmt.Errorf("data length mismatch: header=%d actual=%d", h.DataLength, len(body))
+	}
+	frame := &protocol.Frame{Header: h, Payload: body}
+	keyId, vehicleKey, err := s.keyring.DecryptFrame(frame)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt: %w", err)
+	}
+	msg, err := s.registry.Decode(frame)
+	if err != nil {
+		return nil, nil, err
+	}
+	if protocol.ControlEncryption(h.Control) == protocol.EncryptNone {
+		if err := s.checkCleartext(msg.VehicleId(), vehicleId); err != nil {
+			return nil, nil, err
+		}
+	}
+	// 使用车辆密钥的报文只能上报该车辆（主题中的车辆编号会覆盖报文中的编号，同样需要一致）
+	if vehicleKey && (msg.VehicleId() != keyId || vehicleId != "" && vehicleId != keyId) {
+		return nil, nil, fmt.Errorf("vehicleId %q does not match keyId %q", msg.VehicleId(), keyId)
+	}
+	if msg.Kind != protocol.MessageKindState {
+		return nil, msg, nil
+	}
+	return msg.State, nil, nil
+}
+
+// checkCleartext 不加密的二进制报文须通过密钥环的明文策略；主题中的车辆编号会覆盖报文中的编号，两者都要检查
+func (s *Subscriber) checkCleartext(payloadVehicleId, topicVehicleId string) error {
+	if err := s.keyring.CheckCleartext(payloadVehicleId); err != nil {
+		return err
+	}
+	if topicVehicleId != "" && topicVehicleId != payloadVehicleId {
+		return s.keyring.CheckCleartext(topicVehicleId)
+	}
+	return nil
+}
+
+// decodeJSON 解析 JSON 负载：支持对象、数组以及 {code,message,data} 包装（同 VEHState 消息格式），
+// 并按规则重命名字段
+func decodeJSON(rule *config.MQTTTopicRule, payload []byte) ([]*types.VehicleStateData, error) {
+	trimmed := bytes.TrimSpace(payload)
+	var raws []json.RawMessage
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raws); err != nil {
+			return nil, err
+		}
+	} else {
+		raws = []json.RawMessage{trimmed}
+	}
+
+	out := make([]*types.VehicleStateData, 0, len(raws))
+	for _, raw := range raws {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		if inner, ok := obj["data"]; ok && len(inner) > 0 && inner[0] == '{' {
+			obj = nil
+			if err := json.Unmarshal(inner, &obj); err != nil {
+				return nil, err
+			}
+		}
+		for from, to := range rule.Fields {
+			if v, ok := obj[from]; ok {
+				delete(obj, from)
+				obj[to] = v
+			}
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		var d types.VehicleStateData
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, &d)
+	}
+	return out, nil
+}
+
+// sniffFormat 按首个非空白字节识别负载格式
+func sniffFormat(payload []byte) string {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return FormatJSON
+	}
+	return FormatBinary
+}
+
+// topicMatches 判断主题是否匹配订阅过滤器（支持 + 与 # 通配符）
+func topicMatches(filter, topic string) bool {
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) || (f != "+" && f != ts[i]) {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+// topicVehicleId 按规则从主题中取 vehicleId，未配置段号时默认取第 2 段（veh/{vehicleId}/state）
+func topicVehicleId(rule *config.MQTTTopicRule, topic string) string {
+	seg := 1
+	if rule.VehicleIdSegment != nil {
+		seg = *rule.VehicleIdSegment
+	}
+	if seg < 0 {
+		return ""
+	}
+	parts := strings.Split(topic, "/")
+	if seg >= len(parts) {
+		return ""
+	}
+	return parts[seg]
+}
@@ -0,0 +1,148 @@
+package mqtt
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+// startBroker 启动进程内 MQTT Broker，返回 tcp:// 地址
+func startBroker(t *testing.T) (*mqttserver.Server, string) {
+	t.Helper()
+	srv := mqttserver.New(&mqttserver.Options{InlineClient: true})
+	if err := srv.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "t", Address: "127.0.0.1:0"})
+	if err := srv.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv, "tcp://" + tcp.Address()
+}
+
+// publishUntil 重复发布直到 got 收到数据（订阅在连接后异步完成）
+func publishUntil(t *testing.T, srv *mqttserver.Server, topic string, payload []byte, got <-chan *types.VehicleStateData) *types.VehicleStateData {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := srv.Publish(topic, payload, false, 1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case d := <-got:
+			return d
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("no message received on %s", topic)
+		}
+	}
+}
+
+func TestSubscriberJSONRule(t *testing.T) {
+	srv, addr := startBroker(t)
+	got := make(chan *types.VehicleStateData, 16)
+	s := NewSubscriber(config.MQTTConfig{
+		Broker:   addr,
+		ClientId: "test-json",
+		Rules: []config.MQTTTopicRule{{
+			Topic:        "veh/+/state",
+			Format:       FormatJSON,
+			Fields:       map[string]string{"lng": "lon"},
+			CategoryCode: 3,
+		}},
+	}, nil, nil, func(d *types.VehicleStateData) error {
+		got <- d
+		return nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	d := publishUntil(t, srv, "veh/V001/state", []byte(`{"lng":116.4,"lat":39.9,"timestamp":1700000000000}`), got)
+	if d.VehicleId != "V001" {
+		t.Fatalf("vehicleId = %q, want V001 (from topic)", d.VehicleId)
+	}
+	if d.Lon != 116.4 || d.Lat != 39.9 {
+		t.Fatalf("lon/lat = %v/%v, want 116.4/39.9", d.Lon, d.Lat)
+	}
+	if d.CategoryCode != 3 {
+		t.Fatalf("categoryCode = %d, want rule default 3", d.CategoryCode)
+	}
+}
+
+func TestSubscriberRedeliversUnackedMessage(t *testing.T) {
+	srv, addr := startBroker(t)
+	cfg := config.MQTTConfig{Broker: addr, ClientId: "test-redeliver"}
+
+	// 第一个订阅者处理失败：消息不确认
+	var failed atomic.Int32
+	first := make(chan *types.VehicleStateData, 16)
+	s1 := NewSubscriber(cfg, nil, nil, func(d *types.VehicleStateData) error {
+		failed.Add(1)
+		first <- d
+		return errors.New("sink unavailable")
+	})
+	s1.Start()
+	publishUntil(t, srv, "veh/V002/state", []byte(`{"lon":1,"lat":2,"timestamp":1700000000001}`), first)
+	s1.Stop()
+
+	// 相同 ClientId 的持久会话重连后，Broker 重新投递未确认的消息
+	got := make(chan *types.VehicleStateData, 16)
+	s2 := NewSubscriber(cfg, nil, nil, func(d *types.VehicleStateData) error {
+		got <- d
+		return nil
+	})
+	s2.Start()
+	defer s2.Stop()
+	select {
+	case d := <-got:
+		if d.VehicleId != "V002" || d.Timestamp != 1700000000001 {
+			t.Fatalf("redelivered %s@%d, want V002@1700000000001", d.VehicleId, d.Timestamp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unacked message was not redelivered")
+	}
+	if failed.Load() == 0 {
+		t.Fatal("first subscriber never handled the message")
+	}
+}
+
+func TestSubscriberAcksUndecodablePayload(t *testing.T) {
+	srv, addr := startBroker(t)
+	cfg := config.MQTTConfig{Broker: addr, ClientId: "test-poison", Rules: []config.MQTTTopicRule{{Topic: "veh/+/state", Format: FormatJSON}}}
+	got := make(chan *types.VehicleStateData, 16)
+	s1 := NewSubscriber(cfg, nil, nil, func(d *types.VehicleStateData) error {
+		got <- d
+		return nil
+	})
+	s1.Start()
+	publishUntil(t, srv, "veh/V003/state", []byte(`{"lon":1,"lat":2,"timestamp":1}`), got)
+	// 无法解析的负载确认后丢弃，不会在重连后反复投递
+	if err := srv.Publish("veh/V003/state", []byte(`{not json`), false, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	s1.Stop()
+
+	s2 := NewSubscriber(cfg, nil, nil, func(d *types.VehicleStateData) error {
+		got <- d
+		return nil
+	})
+	s2.Start()
+	defer s2.Stop()
+	d := publishUntil(t, srv, "veh/V003/state", []byte(`{"lon":1,"lat":2,"timestamp":2}`), got)
+	if d.Timestamp != 2 {
+		t.Fatalf("timestamp = %d, want 2", d.Timestamp)
+	}
+}
@@ -8,57 +8,61 @@ import (
 
 	"github.com/zeromicro/go-zero/core/logx"
 
-	"vehicle-api/internal/dao"
 	"vehicle-api/internal/types"
 	"vehicle-api/internal/websocket"
 )
 
-// Processor 负责接收车辆状态数据并异步批量写入 Influx（并为将来扩展到 MySQL 留出位置）
+// Processor 负责接收车辆状态数据并异步批量写入各个 Sink（Influx / MySQL / NDJSON 文件），同时向前端广播
 type Processor struct {
-	dao           *dao.InfluxDao
 	inCh          chan *types.VehicleStateData
-	batchSize     int           // 达到此数量触发写入
-	flushInterval time.Duration // 定时触发写入
+	urgentCh      chan *types.VehicleStateData // 高优先级数据：到达即触发写入，不等待攒批
+	writeOnlyCh   chan *types.VehicleStateData // 仅入库不广播的数据（例如迟到数据），随普通批次写入
+	batchSize     int                          // 达到此数量触发写入
+	flushInterval time.Duration                // 定时触发写入
 	wg            sync.WaitGroup
 	ctx           context.Context
 	cancel        context.CancelFunc
-	// 限制同时并发写入的 goroutine 数，避免在高并发场景下过多并发
-	writeSem chan struct{}
+	// 每个 Sink 一个写入协程组，拥有独立的队列、并发、重试与错误计数，慢 Sink 不影响其它 Sink
+	workers []*sinkWorker
 	// 用于向前端广播实时位置信息
 	Hub *websocket.Hub
 }
 
 // NewProcessor 创建并启动后台批处理 goroutine
-// sc: 服务上下文（提供 Influx Dao）
 // batchSize: 单批次触发阈值（建议 50-500 之间，视流量与点大小而定）
 // flushInterval: 定时刷新间隔（例如 1s）
-// maxConcurrency: 并发写入限制
-func NewProcessor(d *dao.InfluxDao, batchSize int, flushInterval time.Duration, maxConcurrency int, hub *websocket.Hub) *Processor {
+// sinks: 写入目标及各自的并发/重试参数，每个批次会分发给全部 Sink
+func NewProcessor(batchSize int, flushInterval time.Duration, hub *websocket.Hub, sinks ...SinkOptions) *Processor {
 	if batchSize <= 0 {
 		batchSize = 200
 	}
 	if flushInterval <= 0 {
 		flushInterval = time.Second
 	}
-	if maxConcurrency <= 0 {
-		maxConcurrency = 4
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	p := &Processor{
-		dao:           d,
 		inCh:          make(chan *types.VehicleStateData, batchSize*4), // 缓冲若干批以吸收突发
+		urgentCh:      make(chan *types.VehicleStateData, 64),
+		writeOnlyCh:   make(chan *types.VehicleStateData, batchSize),
 		batchSize:     batchSize,
 		flushInterval: flushInterval,
 		ctx:           ctx,
 		cancel:        cancel,
-		writeSem:      make(chan struct{}, maxConcurrency),
 		Hub:           hub,
 	}
+	names := make([]string, 0, len(sinks))
+	for _, o := range sinks {
+		if o.Sink == nil {
+			continue
+		}
+		p.workers = append(p.workers, newSinkWorker(ctx, o, batchSize))
+		names = append(names, o.Sink.Name())
+	}
 
 	p.wg.Add(1)
 	go p.runBatcher()
-	logx.Infof("Processor 已启动: batchSize=%d, flushInterval=%s, maxConcurrency=%d", p.batchSize, p.flushInterval.String(), maxConcurrency)
+	logx.Infof("Processor 已启动: batchSize=%d, flushInterval=%s, sinks=%v", p.batchSize, p.flushInterval.String(), names)
 	return p
 }
 
@@ -70,42 +74,8 @@ func (p *Processor) Enqueue(data *types.VehicleStateData) error {
 	select {
 	case p.inCh <- data:
 		// 入队成功后，尽量提供更实时的单条广播给前端（包含更多详情字段），
-		// 以减少批量 flush 带来的延迟。广播采取非阻塞策略：若立即无法发送，
-		// 则在后台以短超时再尝试一次，避免阻塞业务路径或占用过多资源。
-		if p != nil && p.Hub != nil {
-			// 构建前端需要的实时详情数据（只包含常用字段，字段名与前端约定）
-			payload := map[string]interface{}{
-				"vehicleId":    data.VehicleId,
-				"lon":          data.Lon,
-				"lat":          data.Lat,
-				"timestamp":    data.Timestamp,
-				"speed":        data.Speed,
-				"heading":      data.Heading,
-				"categoryCode": data.CategoryCode,
-				// 常用扩展字段，前端可选展示：SOC（电量）、里程、驾驶模式等
-				"soc":       data.Soc,
-				"mileage":   data.Mileage,
-				"driveMode": data.DriveMode,
-			}
-
-			if b, err := json.Marshal(payload); err == nil {
-				select {
-				case p.Hub.Broadcast <- b:
-					// 发送成功
-				default:
-					// 后台重试一次，短超时后放弃，防止阻塞
-					go func(msg []byte) {
-						select {
-						case p.Hub.Broadcast <- msg:
-							return
-						case <-time.After(200 * time.Millisecond):
-							logx.Errorf("即时 Hub 广播超时，丢弃 vehicleId=%s", data.VehicleId)
-							return
-						}
-					}(b)
-				}
-			}
-		}
+		// 以减少批量 flush 带来的延迟。
+		p.broadcastRealtime(data)
 		return nil
 	case <-time.After(200 * time.Millisecond):
 		// 在极端高压场景下可能会触发，返回错误由调用方决定是否重试
@@ -116,13 +86,110 @@ func (p *Processor) Enqueue(data *types.VehicleStateData) error {
 	}
 }
 
-// Close 优雅停止 Processor，会等待正在进行的写入完成
+// EnqueueUrgent 入队一条高优先级车辆状态（例如安全类/告警相关报文）：
+// 不参与攒批等待，批处理协程收到后立即触发写入。
+func (p *Processor) EnqueueUrgent(data *types.VehicleStateData) error {
+	if data == nil {
+		return nil
+	}
+	select {
+	case p.urgentCh <- data:
+		p.broadcastRealtime(data)
+		return nil
+	case <-time.After(200 * time.Millisecond):
+		logx.Infof("Processor 高优先级入队超时，vehicleId=%s 时间戳=%d", data.VehicleId, data.Timestamp)
+		return context.DeadlineExceeded
+	case <-p.ctx.Done():
+		return context.Canceled
+	}
+}
+
+// EnqueueWriteOnly 入队一条只写入存储、不向前端广播的车辆状态（例如时间戳早于已广播状态的迟到数据，
+// 广播会使地图上的车辆位置回退）
+func (p *Processor) EnqueueWriteOnly(data *types.VehicleStateData) error {
+	if data == nil {
+		return nil
+	}
+	select {
+	case p.writeOnlyCh <- data:
+		return nil
+	case <-time.After(200 * time.Millisecond):
+		logx.Infof("Processor 仅入库数据入队超时，vehicleId=%s 时间戳=%d", data.VehicleId, data.Timestamp)
+		return context.DeadlineExceeded
+	case <-p.ctx.Done():
+		return context.Canceled
+	}
+}
+
+// broadcastRealtime 向前端广播单条实时详情。广播采取非阻塞策略：若立即无法发送，
+// 则在后台以短超时再尝试一次，避免阻塞业务路径或占用过多资源。
+func (p *Processor) broadcastRealtime(data *types.VehicleStateData) {
+	if p == nil || p.Hub == nil {
+		return
+	}
+	// 构建前端需要的实时详情数据（只包含常用字段，字段名与前端约定）
+	payload := map[string]interface{}{
+		"vehicleId":    data.VehicleId,
+		"lon":          data.Lon,
+		"lat":          data.Lat,
+		"timestamp":    data.Timestamp,
+		"speed":        data.Speed,
+		"heading":      data.Heading,
+		"categoryCode": data.CategoryCode,
+		// 常用扩展字段，前端可选展示：SOC（电量）、里程、驾驶模式等
+		"soc":       data.Soc,
+		"mileage":   data.Mileage,
+		"driveMode": data.DriveMode,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	msg := websocket.NewMessage(websocket.TypePositions, websocket.StateItem(data, b))
+	select {
+	case p.Hub.Broadcast <- msg:
+		// 发送成功
+	default:
+		// 后台重试一次，短超时后放弃，防止阻塞
+		go func(msg *websocket.Message) {
+			if !p.Hub.Publish(msg) {
+				logx.Errorf("即时 Hub 广播超时，丢弃 vehicleId=%s", data.VehicleId)
+			}
+		}(msg)
+	}
+}
+
+// Close 优雅停止 Processor：写完缓冲中的数据并等待各 Sink 队列写完；
+// 启用 WAL 的 Sink 中写入失败的数据留在 WAL 中，下次启动后重放
 func (p *Processor) Close() {
 	p.cancel()
 	p.wg.Wait()
+	for _, w := range p.workers {
+		w.close()
+	}
 	logx.Infof("Processor 已关闭")
 }
 
+// SinkStats 返回各 Sink 的写入计数
+func (p *Processor) SinkStats() []types.SinkStats {
+	out := make([]types.SinkStats, 0, len(p.workers))
+	for _, w := range p.workers {
+		out = append(out, w.stats())
+	}
+	return out
+}
+
+// WALStats 返回 WAL 积压情况，未启用时返回 nil
+func (p *Processor) WALStats() *types.WALStatsResp {
+	for _, w := range p.workers {
+		if w.wal != nil {
+			return w.wal.Stats()
+		}
+	}
+	return nil
+}
+
 // runBatcher 从 inCh 聚合为批次，按 size 或时间触发写入
 func (p *Processor) runBatcher() {
 	defer p.wg.Done()
@@ -130,93 +197,65 @@ func (p *Processor) runBatcher() {
 	defer ticker.Stop()
 
 	batch := make([]*types.VehicleStateData, 0, p.batchSize)
+	// 仅入库的数据单独收集，写入时与 batch 合并，但不参与 Hub 广播
+	quiet := make([]*types.VehicleStateData, 0)
 
 	flush := func() {
-		if len(batch) == 0 {
+		if len(batch) == 0 && len(quiet) == 0 {
 			return
 		}
-		// copy batch 指针切片，避免并发修改
-		toWrite := make([]*types.VehicleStateData, len(batch))
-		copy(toWrite, batch)
-		batch = batch[:0]
-
 		// 先向 Hub 广播实时位置信息（尽量快速完成，若 Hub 未配置则跳过）
-		if err := p.process2hub(toWrite); err != nil {
+		if err := p.process2hub(batch); err != nil {
 			logx.Errorf("向 Hub 广播失败: %v", err)
 		}
 
-		// 限制并发写入数量
-		p.writeSem <- struct{}{}
-		go func(items []*types.VehicleStateData) {
-			defer func() { <-p.writeSem }()
-			if err := p.process2influx(items); err != nil {
-				logx.Errorf("批量写入 Influx 失败: %v", err)
-			}
-		}(toWrite)
+		// copy batch 指针切片，避免并发修改
+		toWrite := make([]*types.VehicleStateData, 0, len(batch)+len(quiet))
+		toWrite = append(toWrite, batch...)
+		toWrite = append(toWrite, quiet...)
+		batch = batch[:0]
+		quiet = quiet[:0]
+
+		// 分发给各 Sink 的独立队列（只读共享同一切片）
+		for _, w := range p.workers {
+			w.submit(toWrite)
+		}
 	}
 
 	for {
 		select {
 		case <-p.ctx.Done():
-			// 在退出前把缓冲中的数据写完
+			// 在退出前把缓冲中的数据交给各 Sink，由 Close 等待写完
 			flush()
-			// 等待所有并发写入完成
-			for i := 0; i < cap(p.writeSem); i++ {
-				p.writeSem <- struct{}{}
-			}
 			return
 		case d := <-p.inCh:
 			batch = append(batch, d)
 			if len(batch) >= p.batchSize {
 				flush()
 			}
+		case d := <-p.writeOnlyCh:
+			quiet = append(quiet, d)
+			if len(batch)+len(quiet) >= p.batchSize {
+				flush()
+			}
+		case d := <-p.urgentCh:
+			// 高优先级数据连同已攒批的数据立即写入
+			batch = append(batch, d)
+			flush()
 		case <-ticker.C:
 			flush()
 		}
 	}
 }
 
-// process2influx 将一批车辆状态数据写入 InfluxDB。
-// 此函数会把每个数据转换为 Influx point 并交由 ServiceContext.Dao 写入（异步写入由 Influx SDK 管理）。
-// 返回错误仅在无法访问 Dao 等严重错误时返回，单条数据转换/写入错误会被记录但不会中断批次其他条目的写入。
-func (p *Processor) process2influx(batch []*types.VehicleStateData) error {
-	if p == nil || p.dao == nil {
-		return nil
-	}
-
-	for _, s := range batch {
-		if s == nil {
-			continue
-		}
-		pt, err := p.dao.BuildPoint(s)
-		if err != nil {
-			logx.Errorf("构建 Influx 数据点失败，vehicleId=%s err=%v", s.VehicleId, err)
-			continue
-		}
-		// AddPoint 内部使用 WriteAPI.WritePoint，会异步发送给 Influx 的批量机制
-		if err := p.dao.AddPoint(pt); err != nil {
-			logx.Errorf("写入 Influx 点失败，vehicleId=%s err=%v", s.VehicleId, err)
-			// } else {
-			// 	logx.Infof("成功写入 Influx 点，vehicleId=%s timestamp=%d", s.VehicleId, s.Timestamp)
-		}
-	}
-	// 不等待 Flush，使用 SDK 的批量刷新策略
-	return nil
-}
-
-// process2mysql 占位：保留将来实现 MySQL 持久化的接口与注释
-func (p *Processor) process2mysql(batch []*types.VehicleStateData) error {
-	// TODO: 将来实现：把批量数据持久化到 MySQL，可考虑使用插入/更新批处理并保证重复去重/幂等
-	return nil
-}
-
 func (p *Processor) process2hub(batch []*types.VehicleStateData) error {
 	if p == nil || p.Hub == nil || len(batch) == 0 {
 		return nil
 	}
 
-	// 构建简化的数组，只保留前端展示所需字段，减少带宽与客户端解析负担
-	payload := make([]map[string]interface{}, 0, len(batch))
+	// 构建简化的数组，只保留前端展示所需字段，减少带宽与客户端解析负担；
+	// 每辆车单独编码，Hub 按各客户端的订阅只下发匹配的元素
+	msg := &websocket.Message{Type: websocket.TypePositions, Batch: true, Items: make([]websocket.Item, 0, len(batch))}
 	for _, s := range batch {
 		if s == nil {
 			continue
@@ -229,32 +268,27 @@ func (p *Processor) process2hub(batch []*types.VehicleStateData) error {
 			"speed":     s.Speed,
 			"heading":   s.Heading,
 		}
-		payload = append(payload, m)
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		msg.Items = append(msg.Items, websocket.StateItem(s, b))
 	}
-	if len(payload) == 0 {
+	if len(msg.Items) == 0 {
 		return nil
 	}
 
-	b, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
 	// 尝试非阻塞发送到 Hub，若阻塞则在后台以短超时重试一次，避免阻塞批处理主流程
 	select {
-	case p.Hub.Broadcast <- b:
+	case p.Hub.Broadcast <- msg:
 		return nil
 	default:
 		// 背景发送，若在短时间内仍发送失败则放弃并记录
-		go func(data []byte) {
-			select {
-			case p.Hub.Broadcast <- data:
-				return
-			case <-time.After(200 * time.Millisecond):
-				logx.Errorf("Hub 广播超时，丢弃一批数据 (size=%d)", len(payload))
-				return
+		go func() {
+			if !p.Hub.Publish(msg) {
+				logx.Errorf("Hub 广播超时，丢弃一批数据 (size=%d)", len(msg.Items))
 			}
-		}(b)
+		}()
 	}
 	return nil
 }
@@ -0,0 +1,278 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/sink"
+	"vehicle-api/internal/types"
+	"vehicle-api/internal/wal"
+)
+
+// SinkOptions 单个 Sink 的写入参数，零值字段使用默认值
+type SinkOptions struct {
+	Sink         sink.Sink
+	Concurrency  int           // 并发写入协程数，默认 4
+	QueueSize    int           // 等待写入的批次数上限，默认 64
+	MaxRetries   int           // 单批失败后的重试次数，默认 2，负数表示不重试
+	RetryBackoff time.Duration // 首次重试等待，之后每次翻倍，默认 500ms
+	WriteTimeout time.Duration // 单次写入超时，默认 10s
+	// WAL 不为 nil 时，重试后仍失败或队列已满的批次落盘，并在 Sink 恢复后按顺序重放
+	WAL            *wal.WAL
+	ReplayInterval time.Duration // 积压重放检查间隔，默认 5s
+}
+
+// sinkWorker 为单个 Sink 维护写入队列与协程组，并统计写入结果
+type sinkWorker struct {
+	sink       sink.Sink
+	opts       SinkOptions
+	wal        *wal.WAL
+	ctx        context.Context // Processor 的生命周期，结束后不再重试与重放
+	queue      chan []*types.VehicleStateData
+	wg         sync.WaitGroup
+	replayDone chan struct{}
+
+	batches     atomic.Uint64
+	records     atomic.Uint64
+	failed      atomic.Uint64
+	retries     atomic.Uint64
+	dropped     atomic.Uint64
+	quarantined atomic.Uint64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func newSinkWorker(ctx context.Context, o SinkOptions, batchSize int) *sinkWorker {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 4
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 64
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 2
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 500 * time.Millisecond
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = 10 * time.Second
+	}
+	if o.ReplayInterval <= 0 {
+		o.ReplayInterval = 5 * time.Second
+	}
+	w := &sinkWorker{
+		sink:  o.Sink,
+		opts:  o,
+		wal:   o.WAL,
+		ctx:   ctx,
+		queue: make(chan []*types.VehicleStateData, o.QueueSize),
+	}
+	for i := 0; i < o.Concurrency; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+	if w.wal != nil {
+		w.replayDone = make(chan struct{})
+		go w.runReplayer(batchSize)
+	}
+	return w
+}
+
+// submit 把批次放入队列，不阻塞批处理协程；队列已满时落盘（启用 WAL）或丢弃
+func (w *sinkWorker) submit(batch []*types.VehicleStateData) {
+	// 已有积压时直接落盘，保证重放顺序与到达顺序一致
+	if w.wal != nil && w.wal.Pending() {
+		w.spill(batch)
+		return
+	}
+	select {
+	case w.queue <- batch:
+	default:
+		logx.Errorf("Sink %s 写入队列已满 size=%d", w.sink.Name(), len(batch))
+		w.spill(batch)
+	}
+}
+
+func (w *sinkWorker) run() {
+	defer w.wg.Done()
+	for batch := range w.queue {
+		err := w.writeWithRetry(batch)
+		if err == nil {
+			w.batches.Add(1)
+			w.records.Add(uint64(len(batch)))
+			continue
+		}
+		w.failed.Add(1)
+		w.mu.Lock()
+		w.lastError = err.Error()
+		w.lastErrorAt = time.Now()
+		w.mu.Unlock()
+		logx.Errorf("Sink %s 批量写入失败 size=%d err=%v", w.sink.Name(), len(batch), err)
+		if sink.IsPermanent(err) {
+			w.quarantine(batch, err)
+			continue
+		}
+		w.spill(batch)
+	}
+}
+
+// writeWithRetry 写入一批，失败后按指数退避重试；写入目标拒绝（不可重试）或 Processor 关闭后不再重试
+func (w *sinkWorker) writeWithRetry(batch []*types.VehicleStateData) error {
+	backoff := w.opts.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = w.write(batch); err == nil || attempt >= w.opts.MaxRetries || sink.IsPermanent(err) {
+			return err
+		}
+		select {
+		case <-w.ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		w.retries.Add(1)
+		backoff *= 2
+	}
+}
+
+// write 以独立的超时写入一次（不使用 Processor 的 ctx，关闭时仍能写完队列中的数据）
+func (w *sinkWorker) write(batch []*types.VehicleStateData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.WriteTimeout)
+	defer cancel()
+	return w.sink.Write(ctx, batch)
+}
+
+// spill 把写不进去的批次落盘；未启用 WAL 时丢弃并计数
+func (w *sinkWorker) spill(batch []*types.VehicleStateData) {
+	if w.wal != nil {
+		err := w.wal.Append(batch)
+		if err == nil {
+			return
+		}
+		logx.Errorf("Sink %s 批次落盘失败 size=%d err=%v", w.sink.Name(), len(batch), err)
+	}
+	w.dropped.Add(uint64(len(batch)))
+}
+
+// quarantine 把写入目标拒绝的批次移入 WAL 隔离目录（不进入重放）；未启用 WAL 时丢弃并计数
+func (w *sinkWorker) quarantine(batch []*types.VehicleStateData, cause error) {
+	if w.wal != nil {
+		err := w.wal.QuarantineBatch(batch, cause.Error())
+		if err == nil {
+			w.quarantined.Add(uint64(len(batch)))
+			return
+		}
+		logx.Errorf("Sink %s 批次写入隔离目录失败 size=%d err=%v", w.sink.Name(), len(batch), err)
+	}
+	w.dropped.Add(uint64(len(batch)))
+}
+
+// runReplayer 定期检查 WAL 积压，按分段顺序分批重放；任一批失败则等待下个周期从该分段重新开始
+// （Sink 要求重复写入幂等，同一分段重放多次不产生重复数据）；写入目标拒绝的分段移入隔离目录，继续重放之后的分段
+func (w *sinkWorker) runReplayer(batchSize int) {
+	defer close(w.replayDone)
+	ticker := time.NewTicker(w.opts.ReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.replay(batchSize)
+		}
+	}
+}
+
+func (w *sinkWorker) replay(batchSize int) {
+	for w.ctx.Err() == nil {
+		seg, err := w.wal.Oldest()
+		if err == wal.ErrEmpty {
+			return
+		}
+		if err != nil {
+			logx.Errorf("读取 WAL 分段失败: %v", err)
+			return
+		}
+		items, err := w.wal.Read(seg)
+		if err != nil {
+			logx.Errorf("读取 WAL 分段失败 file=%s err=%v", seg.Path, err)
+			return
+		}
+		var rejected error
+		for i := 0; i < len(items); i += batchSize {
+			end := i + batchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			err := w.write(items[i:end])
+			if err == nil {
+				continue
+			}
+			if sink.IsPermanent(err) {
+				rejected = err
+				break
+			}
+			logx.Errorf("WAL 重放失败，稍后重试 sink=%s file=%s err=%v", w.sink.Name(), seg.Path, err)
+			return
+		}
+		if rejected != nil {
+			if err := w.wal.Quarantine(seg, rejected.Error()); err != nil {
+				logx.Errorf("WAL 分段移入隔离目录失败 file=%s err=%v", seg.Path, err)
+				return
+			}
+			w.quarantined.Add(uint64(len(items)))
+			continue
+		}
+		if err := w.wal.Remove(seg); err != nil {
+			logx.Errorf("删除已重放的 WAL 分段失败 file=%s err=%v", seg.Path, err)
+			return
+		}
+		logx.Infof("WAL 分段重放完成 sink=%s file=%s records=%d", w.sink.Name(), seg.Path, len(items))
+	}
+}
+
+// close 等待队列写完，停止重放并关闭 WAL 与 Sink（调用前批处理协程须已退出）
+func (w *sinkWorker) close() {
+	close(w.queue)
+	w.wg.Wait()
+	if w.wal != nil {
+		<-w.replayDone
+		if err := w.wal.Close(); err != nil {
+			logx.Errorf("关闭 WAL 失败: %v", err)
+		}
+	}
+	if err := w.sink.Close(); err != nil {
+		logx.Errorf("关闭 Sink %s 失败: %v", w.sink.Name(), err)
+	}
+}
+
+func (w *sinkWorker) stats() types.SinkStats {
+	st := types.SinkStats{
+		Name:        w.sink.Name(),
+		Concurrency: w.opts.Concurrency,
+		Queued:      len(w.queue),
+		QueueSize:   cap(w.queue),
+		Batches:     w.batches.Load(),
+		Records:     w.records.Load(),
+		Failed:      w.failed.Load(),
+		Retries:     w.retries.Load(),
+		Dropped:     w.dropped.Load(),
+		Quarantined: w.quarantined.Load(),
+		WAL:         w.wal != nil,
+	}
+	w.mu.Lock()
+	st.LastError = w.lastError
+	if !w.lastErrorAt.IsZero() {
+		st.LastErrorAt = w.lastErrorAt.UTC().Format(time.RFC3339)
+	}
+	w.mu.Unlock()
+	return st
+}
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"vehicle-api/internal/types"
+)
+
+// 下行指令类型
+const (
+	CommandDispatchTask      byte = 0x01 // 派发任务
+	CommandReturnToBase      byte = 0x02 // 返回基地/场站
+	CommandLockCompartment   byte = 0x03 // 锁定货舱
+	CommandUnlockCompartment byte = 0x04 // 解锁货舱
+	CommandChangeReportRate  byte = 0x05 // 调整上报频率
+)
+
+// 指令类型名称（对外接口/数据库中使用）
+var commandTypeNames = map[byte]string{
+	CommandDispatchTask:      "dispatch_task",
+	CommandReturnToBase:      "return_to_base",
+	CommandLockCompartment:   "lock_compartment",
+	CommandUnlockCompartment: "unlock_compartment",
+	CommandChangeReportRate:  "change_report_rate",
+}
+
+// CommandTypeName 返回指令类型名称，未知类型返回空字符串
+func CommandTypeName(t byte) string {
+	return commandTypeNames[t]
+}
+
+// ParseCommandType 由名称解析指令类型
+func ParseCommandType(name string) (byte, bool) {
+	for t, n := range commandTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Command 下行指令（CLOUD2VEH_COMMAND），按 Type 使用对应参数
+type Command struct {
+	VehicleId        string
+	CommandId        uint32
+	Type             byte
+	TaskId           string           // dispatch_task：任务编号（最长 32 字节）
+	Pickup           types.Position2D // dispatch_task：取货点
+	Destination      types.Position2D // dispatch_task：目的地；return_to_base：基地位置（可为 0 表示车端默认基地）
+	Compartment      int              // lock/unlock_compartment：货舱编号，0 表示全部
+	ReportIntervalMs uint32           // change_report_rate：上报间隔（毫秒）
+}
+
+// CLOUD2VEH_COMMAND 数据段布局（版本 1，大端序）：
+//
+//	偏移  长度  字段
+//	0     8    vehicleId    车辆编号
+//	8     4    commandId    指令编号（应答中原样带回）
+//	12    1    commandType  指令类型
+//	13    2    paramsLen    参数长度 n
+//	15    n    params       参数，按指令类型：
+//	           dispatch_task:      taskId BYTE[32] + pickup lon/lat DWORD*2 + destination lon/lat DWORD*2
+//	           return_to_base:     base lon/lat DWORD*2
+//	           lock/unlock:        compartment BYTE
+//	           change_report_rate: intervalMs DWORD
+const (
+	cloud2VehCommandFixedLen = 15
+	commandTaskIdLen         = 32
+)
+
+// EncodeCommand 编码 CLOUD2VEH_COMMAND 数据段
+func EncodeCommand(c *Command) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("command is nil")
+	}
+	if len(c.VehicleId) > veh2CloudStateVehicleIdLen {
+		return nil, fmt.Errorf("vehicleId too long: %q", c.VehicleId)
+	}
+	var params []byte
+	be := binary.BigEndian
+	switch c.Type {
+	case CommandDispatchTask:
+		if len(c.TaskId) > commandTaskIdLen {
+			return nil, fmt.Errorf("taskId too long: %q", c.TaskId)
+		}
+		params = make([]byte, commandTaskIdLen+16)
+		_ = types.WriteFixedString(params, 0, commandTaskIdLen, c.TaskId)
+		be.PutUint32(params[32:36], encodeLon(c.Pickup.Lon))
+		be.PutUint32(params[36:40], encodeLat(c.Pickup.Lat))
+		be.PutUint32(params[40:44], encodeLon(c.Destination.Lon))
+		be.PutUint32(params[44:48], encodeLat(c.Destination.Lat))
+	case CommandReturnToBase:
+		params = make([]byte, 8)
+		if c.Destination.Lon == 0 && c.Destination.Lat == 0 {
+			// 未指定基地位置，由车端使用默认基地
+			be.PutUint32(params[0:4], invalidDWord)
+			be.PutUint32(params[4:8], invalidDWord)
+		} else {
+			be.PutUint32(params[0:4], encodeLon(c.Destination.Lon))
+			be.PutUint32(params[4:8], encodeLat(c.Destination.Lat))
+		}
+	case CommandLockCompartment, CommandUnlockCompartment:
+		if c.Compartment < 0 || c.Compartment > 255 {
+			return nil, fmt.Errorf("compartment out of range: %d", c.Compartment)
+		}
+		params = []byte{byte(c.Compartment)}
+	case CommandChangeReportRate:
+		if c.ReportIntervalMs == 0 {
+			return nil, fmt.Errorf("reportIntervalMs must be > 0")
+		}
+		params = make([]byte, 4)
+		be.PutUint32(params, c.ReportIntervalMs)
+	default:
+		return nil, fmt.Errorf("unknown command type: %d", c.Type)
+	}
+
+	out := make([]byte, cloud2VehCommandFixedLen+len(params))
+	_ = types.WriteFixedString(out, 0, veh2CloudStateVehicleIdLen, c.VehicleId)
+	be.PutUint32(out[8:12], c.CommandId)
+	out[12] = c.Type
+	be.PutUint16(out[13:15], uint16(len(params)))
+	copy(out[cloud2VehCommandFixedLen:], params)
+	return out, nil
+}
+
+// CommandAckData 车端指令应答（VEH2CLOUD_COMMAND_ACK）解码结果
+type CommandAckData struct {
+	VehicleId string `json:"vehicleId"`
+	CommandId uint32 `json:"commandId"`
+	Result    int    `json:"result"` // 0 执行成功，其它为车端拒绝/失败原因码
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// VEH2CLOUD_COMMAND_ACK 数据段布局（版本 1）：vehicleId BYTE[8] + commandId DWORD + result BYTE
+const veh2CloudCommandAckLen = 13
+
+// DecodeCommandAck 解码 VEH2CLOUD_COMMAND_ACK 数据段
+func DecodeCommandAck(payload []byte) (*CommandAckData, error) {
+	if len(payload) < veh2CloudCommandAckLen {
+		return nil, fmt.Errorf("VEH2CLOUD_COMMAND_ACK payload too short: %d < %d", len(payload), veh2CloudCommandAckLen)
+	}
+	a := &CommandAckData{}
+	a.VehicleId, _ = types.ReadFixedString(payload, 0, veh2CloudStateVehicleIdLen)
+	a.CommandId = binary.BigEndian.Uint32(payload[8:12])
+	a.Result = int(payload[12])
+	return a, nil
+}
+
+func encodeLon(lon float64) uint32 {
+	return uint32(math.Round((lon + 180) * 1e7))
+}
+
+func encodeLat(lat float64) uint32 {
+	return uint32(math.Round((lat + 90) * 1e7))
+}
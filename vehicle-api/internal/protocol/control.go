@@ -0,0 +1,273 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/emmansun/gmsm/sm3"
+	"github.com/emmansun/gmsm/sm4"
+
+	"vehicle-api/internal/types"
+)
+
+// 控制内容（FixedHeader.Control）按位定义：
+//
+//	bit7..bit4  报文优先级：0 普通，1 高（如告警/安全类），2 紧急
+//	bit3..bit0  加密方式：0 不加密，1 AES-GCM，2 SM4-GCM，3 SM4-CBC
+//
+// 加密后的数据段格式：
+//
+//	keyIdLen(1) + keyId + 加密内容
+//	GCM：nonce(12) + 密文 + tag(16)
+//	CBC：IV(16) + 密文（PKCS#7 填充）+ HMAC-SM3(32)
+//
+// keyId 为明文的密钥标识（车辆编号或 AppId，为空表示默认 AppId），服务端只按 keyId 选择一个密钥，不依次尝试。
+// 配置了车辆密钥的车辆必须发送加密报文，Crypto.required 开启时所有报文都必须加密，不加密的报文不被接受。
+// 附加认证数据 = 报文头中的数据类别、版本号、时间戳、控制内容 + keyId；GCM 作为 AAD，
+// CBC 模式先校验 HMAC（覆盖附加认证数据 + IV + 密文）再解密，认证失败的报文不会被接受
+const (
+	PriorityNormal byte = 0
+	PriorityHigh   byte = 1
+	PriorityUrgent byte = 2
+
+	EncryptNone   byte = 0
+	EncryptAESGCM byte = 1
+	EncryptSM4GCM byte = 2
+	EncryptSM4CBC byte = 3
+)
+
+var (
+	ErrNoKey             = errors.New("protocol: no decryption key")
+	ErrUnsupportedCipher = errors.New("protocol: unsupported encryption mode")
+	ErrAuthFailed        = errors.New("protocol: payload authentication failed")
+	ErrCleartext         = errors.New("protocol: cleartext frame not allowed")
+)
+
+// ControlPriority 返回控制内容中的优先级
+func ControlPriority(control byte) byte {
+	return control >> 4
+}
+
+// ControlEncryption 返回控制内容中的加密方式
+func ControlEncryption(control byte) byte {
+	return control & 0x0F
+}
+
+// MakeControl 由优先级与加密方式组合控制内容
+func MakeControl(priority, encryption byte) byte {
+	return priority<<4 | encryption&0x0F
+}
+
+// HighPriority 判断消息是否需要走高优先级通道：报文头声明高优先级，或本身为告警类消息
+func (m *Message) HighPriority() bool {
+	return ControlPriority(m.Header.Control) >= PriorityHigh || m.Kind == MessageKindAlarm
+}
+
+// Keyring 保存数据段解密密钥，可按车辆编号或 AppId 配置（十六进制字符串）。
+// 密钥由数据段中的明文 keyId 唯一确定，首个加密报文（连接尚不知道车辆编号）也能使用车辆密钥
+type Keyring struct {
+	vehicleKeys map[string][]byte
+	appKeys     map[string][]byte
+	defaultApp  string
+	required    bool // 所有报文都必须加密
+}
+
+// NewKeyring 由配置创建密钥环；任一密钥不是合法十六进制时返回错误。
+// required 为 true 时拒绝所有不加密的报文，否则只拒绝配置了车辆密钥的车辆的不加密报文
+func NewKeyring(vehicleKeys, appKeys map[string]string, defaultAppId string, required bool) (*Keyring, error) {
+	k := &Keyring{
+		vehicleKeys: make(map[string][]byte, len(vehicleKeys)),
+		appKeys:     make(map[string][]byte, len(appKeys)),
+		defaultApp:  defaultAppId,
+		required:    required,
+	}
+	for id, s := range vehicleKeys {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for vehicle %s: %w", id, err)
+		}
+		k.vehicleKeys[id] = b
+	}
+	for id, s := range appKeys {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for appId %s: %w", id, err)
+		}
+		k.appKeys[id] = b
+	}
+	return k, nil
+}
+
+// lookup 按 keyId 选择密钥：车辆密钥 > AppId 密钥；keyId 为空时使用默认 AppId 的密钥
+func (k *Keyring) lookup(keyId string) (key []byte, vehicleKey bool, err error) {
+	if k == nil {
+		return nil, false, ErrNoKey
+	}
+	if keyId == "" {
+		if key, ok := k.appKeys[k.defaultApp]; ok && k.defaultApp != "" {
+			return key, false, nil
+		}
+		return nil, false, ErrNoKey
+	}
+	if key, ok := k.vehicleKeys[keyId]; ok {
+		return key, true, nil
+	}
+	if key, ok := k.appKeys[keyId]; ok {
+		return key, false, nil
+	}
+	return nil, false, fmt.Errorf("%w: keyId %q", ErrNoKey, keyId)
+}
+
+// CheckCleartext 判断是否接受车辆 vehicleId 的不加密报文：开启 required 或该车辆配置了车辆密钥时返回 ErrCleartext，
+// 防止任意连接以明文冒充已配置密钥的车辆。密钥环为 nil 时不限制
+func (k *Keyring) CheckCleartext(vehicleId string) error {
+	if k == nil {
+		return nil
+	}
+	if k.required {
+		return fmt.Errorf("%w: encryption required", ErrCleartext)
+	}
+	if _, ok := k.vehicleKeys[vehicleId]; ok {
+		return fmt.Errorf("%w: vehicle %s has a key", ErrCleartext, vehicleId)
+	}
+	return nil
+}
+
+// DecryptFrame 按报文头中的加密方式认证并解密数据段（原地替换 frame.Payload），返回数据段中的 keyId。
+// vehicleKey 为 true 表示使用了车辆密钥，调用方应确认解码得到的车辆编号等于 keyId，
+// 防止一辆车用自己的密钥冒充其它车辆。不加密的报文原样返回，调用方解码后须用 CheckCleartext 确认是否接受
+func (k *Keyring) DecryptFrame(frame *Frame) (keyId string, vehicleKey bool, err error) {
+	mode := ControlEncryption(frame.Header.Control)
+	if mode == EncryptNone {
+		return "", false, nil
+	}
+	p := frame.Payload
+	if len(p) < 1 || len(p) < 1+int(p[0]) {
+		return "", false, fmt.Errorf("encrypted payload too short: %d", len(p))
+	}
+	keyId = string(p[1 : 1+int(p[0])])
+	key, vehicleKey, err := k.lookup(keyId)
+	if err != nil {
+		return keyId, false, err
+	}
+	plain, err := decrypt(mode, key, additionalData(frame.Header, keyId), p[1+int(p[0]):])
+	if err != nil {
+		return keyId, false, err
+	}
+	frame.Payload = plain
+	frame.Header.DataLength = uint32(len(plain))
+	return keyId, vehicleKey, nil
+}
+
+// EncryptPayload 按报文头中的加密方式加密数据段并加上 keyId 前缀，供下行或测试工具使用；
+// nonce/iv 由调用方提供随机值。h 须与实际发送的报文头一致（DataLength 除外）
+func EncryptPayload(h types.FixedHeader, keyId string, key, nonceOrIV, plain []byte) ([]byte, error) {
+	mode := ControlEncryption(h.Control)
+	if mode == EncryptNone {
+		return plain, nil
+	}
+	if len(keyId) > 255 {
+		return nil, fmt.Errorf("keyId too long: %d", len(keyId))
+	}
+	block, err := newBlock(mode, key)
+	if err != nil {
+		return nil, err
+	}
+	aad := additionalData(h, keyId)
+	out := append([]byte{byte(len(keyId))}, keyId...)
+	switch mode {
+	case EncryptAESGCM, EncryptSM4GCM:
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(nonceOrIV) != aead.NonceSize() {
+			return nil, fmt.Errorf("nonce must be %d bytes", aead.NonceSize())
+		}
+		out = append(out, nonceOrIV...)
+		return aead.Seal(out, nonceOrIV, plain, aad), nil
+	case EncryptSM4CBC:
+		if len(nonceOrIV) != block.BlockSize() {
+			return nil, fmt.Errorf("iv must be %d bytes", block.BlockSize())
+		}
+		pad := block.BlockSize() - len(plain)%block.BlockSize()
+		padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+		body := make([]byte, len(nonceOrIV)+len(padded))
+		copy(body, nonceOrIV)
+		cipher.NewCBCEncrypter(block, nonceOrIV).CryptBlocks(body[len(nonceOrIV):], padded)
+		out = append(out, body...)
+		return append(out, cbcMAC(key, aad, body)...), nil
+	}
+	return nil, ErrUnsupportedCipher
+}
+
+// additionalData 附加认证数据：数据类别、版本号、时间戳、控制内容与 keyId
+func additionalData(h types.FixedHeader, keyId string) []byte {
+	b := EncodeFixedHeader(h)[5:HeaderSize]
+	return append(b, keyId...)
+}
+
+// cbcMAC 计算 CBC 模式的 HMAC-SM3，MAC 密钥由数据段密钥派生，与加密密钥分离
+func cbcMAC(key, aad, body []byte) []byte {
+	kdf := hmac.New(sm3.New, key)
+	kdf.Write([]byte("vehicle-api cbc mac"))
+	m := hmac.New(sm3.New, kdf.Sum(nil))
+	m.Write(aad)
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+func decrypt(mode byte, key, aad, data []byte) ([]byte, error) {
+	block, err := newBlock(mode, key)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case EncryptAESGCM, EncryptSM4GCM:
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ns := aead.NonceSize()
+		if len(data) < ns+aead.Overhead() {
+			return nil, fmt.Errorf("ciphertext too short: %d", len(data))
+		}
+		plain, err := aead.Open(nil, data[:ns], data[ns:], aad)
+		if err != nil {
+			return nil, ErrAuthFailed
+		}
+		return plain, nil
+	case EncryptSM4CBC:
+		bs := block.BlockSize()
+		if len(data) < 2*bs+sm3.Size || (len(data)-sm3.Size)%bs != 0 {
+			return nil, fmt.Errorf("invalid CBC ciphertext length: %d", len(data))
+		}
+		body, mac := data[:len(data)-sm3.Size], data[len(data)-sm3.Size:]
+		if !hmac.Equal(mac, cbcMAC(key, aad, body)) {
+			return nil, ErrAuthFailed
+		}
+		out := make([]byte, len(body)-bs)
+		cipher.NewCBCDecrypter(block, body[:bs]).CryptBlocks(out, body[bs:])
+		pad := int(out[len(out)-1])
+		if pad == 0 || pad > bs || pad > len(out) || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+			return nil, errors.New("invalid PKCS#7 padding")
+		}
+		return out[:len(out)-pad], nil
+	}
+	return nil, ErrUnsupportedCipher
+}
+
+func newBlock(mode byte, key []byte) (cipher.Block, error) {
+	switch mode {
+	case EncryptAESGCM:
+		return aes.NewCipher(key)
+	case EncryptSM4GCM, EncryptSM4CBC:
+		return sm4.NewCipher(key)
+	}
+	return nil, ErrUnsupportedCipher
+}
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"vehicle-api/internal/types"
+)
+
+const (
+	testVehicleKey = "00112233445566778899aabbccddeeff"
+	testOtherKey   = "ffeeddccbbaa99887766554433221100"
+	testAppKey     = "000102030405060708090a0b0c0d0e0f"
+)
+
+func testKeyring(t *testing.T, vehicleKey string, required bool) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(map[string]string{"V0000001": vehicleKey}, map[string]string{"APP1": testAppKey}, "APP1", required)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// encryptedFrame 按 mode 加密 plain，返回可直接交给 DecryptFrame 的报文
+func encryptedFrame(t *testing.T, mode byte, keyId, keyHex string, plain []byte) *Frame {
+	t.Helper()
+	h := types.FixedHeader{StartByte: StartByte, DataCategory: DataCategoryVeh2CloudState, Version: 1, Timestamp: 1700000000000, Control: MakeControl(PriorityNormal, mode)}
+	iv := bytes.Repeat([]byte{0x5A}, 12)
+	if mode == EncryptSM4CBC {
+		iv = bytes.Repeat([]byte{0x5A}, 16)
+	}
+	payload, err := EncryptPayload(h, keyId, decodeHex(t, keyHex), iv, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.DataLength = uint32(len(payload))
+	return &Frame{Header: h, Payload: payload}
+}
+
+var cipherModes = []struct {
+	name string
+	mode byte
+}{
+	{"AES-GCM", EncryptAESGCM},
+	{"SM4-GCM", EncryptSM4GCM},
+	{"SM4-CBC+HMAC-SM3", EncryptSM4CBC},
+}
+
+func TestDecryptFrameRoundTrip(t *testing.T) {
+	k := testKeyring(t, testVehicleKey, false)
+	plain := decodeHex(t, stateV1Vector)
+	for _, m := range cipherModes {
+		t.Run(m.name, func(t *testing.T) {
+			f := encryptedFrame(t, m.mode, "V0000001", testVehicleKey, plain)
+			if bytes.Contains(f.Payload, plain[:16]) {
+				t.Fatal("payload is not encrypted")
+			}
+			keyId, vehicleKey, err := k.DecryptFrame(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keyId != "V0000001" || !vehicleKey {
+				t.Fatalf("keyId=%q vehicleKey=%v", keyId, vehicleKey)
+			}
+			if !bytes.Equal(f.Payload, plain) || int(f.Header.DataLength) != len(plain) {
+				t.Fatalf("decrypted payload mismatch (%d bytes)", len(f.Payload))
+			}
+
+			// 空 keyId 使用默认 AppId 的密钥，不是车辆密钥
+			f = encryptedFrame(t, m.mode, "", testAppKey, plain)
+			if keyId, vehicleKey, err := k.DecryptFrame(f); err != nil || keyId != "" || vehicleKey || !bytes.Equal(f.Payload, plain) {
+				t.Fatalf("default app key: keyId=%q vehicleKey=%v err=%v", keyId, vehicleKey, err)
+			}
+		})
+	}
+}
+
+func TestDecryptFrameTamper(t *testing.T) {
+	k := testKeyring(t, testVehicleKey, false)
+	plain := decodeHex(t, stateV1Vector)
+	tampers := []struct {
+		name   string
+		keyHex string
+		mutate func(f *Frame)
+		want   error
+	}{
+		{"wrong key", testOtherKey, func(f *Frame) {}, ErrAuthFailed},
+		{"flipped category", testVehicleKey, func(f *Frame) { f.Header.DataCategory = DataCategoryVeh2CloudAlarm }, ErrAuthFailed},
+		{"flipped timestamp", testVehicleKey, func(f *Frame) { f.Header.Timestamp++ }, ErrAuthFailed},
+		{"flipped priority", testVehicleKey, func(f *Frame) { f.Header.Control |= PriorityUrgent << 4 }, ErrAuthFailed},
+		{"flipped ciphertext", testVehicleKey, func(f *Frame) { f.Payload[len(f.Payload)/2] ^= 0x01 }, ErrAuthFailed},
+		{"flipped tag", testVehicleKey, func(f *Frame) { f.Payload[len(f.Payload)-1] ^= 0x80 }, ErrAuthFailed},
+		{"unknown keyId", testVehicleKey, func(f *Frame) { f.Payload[1] = 'X' }, ErrNoKey},
+	}
+	for _, m := range cipherModes {
+		for _, tc := range tampers {
+			t.Run(m.name+"/"+tc.name, func(t *testing.T) {
+				f := encryptedFrame(t, m.mode, "V0000001", tc.keyHex, plain)
+				tc.mutate(f)
+				before := append([]byte(nil), f.Payload...)
+				if _, _, err := k.DecryptFrame(f); !errors.Is(err, tc.want) {
+					t.Fatalf("err = %v, want %v", err, tc.want)
+				}
+				if !bytes.Equal(f.Payload, before) {
+					t.Fatal("payload must be left untouched when authentication fails")
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptFrameTruncated(t *testing.T) {
+	k := testKeyring(t, testVehicleKey, false)
+	for _, m := range cipherModes {
+		f := encryptedFrame(t, m.mode, "V0000001", testVehicleKey, []byte("x"))
+		f.Payload = f.Payload[:len(f.Payload)-20]
+		if _, _, err := k.DecryptFrame(f); err == nil {
+			t.Fatalf("%s: truncated ciphertext must fail", m.name)
+		}
+	}
+	f := &Frame{Header: types.FixedHeader{Control: MakeControl(PriorityNormal, EncryptAESGCM)}, Payload: []byte{8, 'V'}}
+	if _, _, err := k.DecryptFrame(f); err == nil {
+		t.Fatal("keyId longer than payload must fail")
+	}
+	f = &Frame{Header: types.FixedHeader{Control: MakeControl(PriorityNormal, 0x0F)}, Payload: []byte{0}}
+	if _, _, err := k.DecryptFrame(f); !errors.Is(err, ErrUnsupportedCipher) {
+		t.Fatalf("unsupported mode: err = %v", err)
+	}
+}
+
+func TestCheckCleartext(t *testing.T) {
+	k := testKeyring(t, testVehicleKey, false)
+	if err := k.CheckCleartext("V0000001"); !errors.Is(err, ErrCleartext) {
+		t.Fatalf("vehicle with a key: err = %v, want ErrCleartext", err)
+	}
+	if err := k.CheckCleartext("V0000002"); err != nil {
+		t.Fatalf("vehicle without a key: err = %v", err)
+	}
+	required := testKeyring(t, testVehicleKey, true)
+	for _, id := range []string{"V0000001", "V0000002", ""} {
+		if err := required.CheckCleartext(id); !errors.Is(err, ErrCleartext) {
+			t.Fatalf("required, vehicle %q: err = %v, want ErrCleartext", id, err)
+		}
+	}
+	var none *Keyring
+	if err := none.CheckCleartext("V0000001"); err != nil {
+		t.Fatalf("nil keyring: err = %v", err)
+	}
+
+	// 不加密的报文 DecryptFrame 原样返回，由调用方按上面的策略决定是否接受
+	f := &Frame{Header: types.FixedHeader{Control: MakeControl(PriorityHigh, EncryptNone)}, Payload: []byte("plain")}
+	if keyId, vehicleKey, err := k.DecryptFrame(f); err != nil || keyId != "" || vehicleKey || string(f.Payload) != "plain" {
+		t.Fatalf("cleartext: keyId=%q vehicleKey=%v err=%v", keyId, vehicleKey, err)
+	}
+}
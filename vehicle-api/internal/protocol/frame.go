@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"vehicle-api/internal/types"
+)
+
+// 固定报文头相关常量
+// 报文结构：标识位(1) + 数据段长度(4) + 数据类别(1) + 版本号(1) + 时间戳(8) + 控制内容(1) + 数据段(DataLength)
+const (
+	StartByte  byte = 0xF2 // 标识位，固定为 0xF2
+	HeaderSize      = 16   // 固定报文头长度（字节）
+
+	// DefaultMaxFrameSize 单帧数据段默认上限，超过视为非法帧（防止异常长度导致大量内存分配）
+	DefaultMaxFrameSize = 64 * 1024
+)
+
+// 数据类别（附录 A 表 A.2）
+const (
+	DataCategoryVeh2CloudState     byte = 0x01 // VEH2CLOUD_STATE 车辆状态上报
+	DataCategoryVeh2CloudAlarm     byte = 0x02 // VEH2CLOUD_ALARM 车辆告警上报
+	DataCategoryVeh2CloudHeartbeat byte = 0x03 // VEH2CLOUD_HEARTBEAT 车辆心跳
+	DataCategoryCloud2VehCommand   byte = 0x10 // CLOUD2VEH_COMMAND 云端下行指令
+	DataCategoryVeh2CloudCmdAck    byte = 0x11 // VEH2CLOUD_COMMAND_ACK 车端指令应答
+)
+
+var (
+	ErrBadStartByte  = errors.New("protocol: bad start byte")
+	ErrFrameTooLarge = errors.New("protocol: frame too large")
+	ErrShortBuffer   = errors.New("protocol: buffer too small for header")
+)
+
+// Frame 表示一个完整的二进制报文（报文头 + 数据段）
+type Frame struct {
+	Header  types.FixedHeader
+	Payload []byte
+}
+
+// ParseFixedHeader 从字节切片解析固定报文头，要求 len(b) >= HeaderSize
+func ParseFixedHeader(b []byte) (types.FixedHeader, error) {
+	var h types.FixedHeader
+	if len(b) < HeaderSize {
+		return h, ErrShortBuffer
+	}
+	if b[0] != StartByte {
+		return h, ErrBadStartByte
+	}
+	h.StartByte = b[0]
+	h.DataLength, _ = types.ReadUint32BE(b, 1)
+	h.DataCategory = b[5]
+	h.Version = b[6]
+	h.Timestamp, _ = types.ReadUint64BE(b, 7)
+	h.Control = b[15]
+	return h, nil
+}
+
+// EncodeFixedHeader 将固定报文头编码为 HeaderSize 字节（StartByte 总是写为 0xF2）
+func EncodeFixedHeader(h types.FixedHeader) []byte {
+	b := make([]byte, HeaderSize)
+	b[0] = StartByte
+	binary.BigEndian.PutUint32(b[1:5], h.DataLength)
+	b[5] = h.DataCategory
+	b[6] = h.Version
+	binary.BigEndian.PutUint64(b[7:15], h.Timestamp)
+	b[15] = h.Control
+	return b
+}
+
+// EncodeFrame 按固定报文头格式编码一帧，DataLength 以 payload 实际长度为准
+func EncodeFrame(h types.FixedHeader, payload []byte) []byte {
+	h.DataLength = uint32(len(payload))
+	out := make([]byte, 0, HeaderSize+len(payload))
+	out = append(out, EncodeFixedHeader(h)...)
+	out = append(out, payload...)
+	return out
+}
+
+// FrameReader 从字节流中切分报文。
+// TCP 是流式协议，一次 Read 可能只包含半帧（拆包），也可能包含多帧（粘包），
+// 这里基于 bufio.Reader 按报文头中的 DataLength 精确读取，从而同时处理两种情况。
+type FrameReader struct {
+	r            *bufio.Reader
+	maxFrameSize int
+	// Skipped 记录为重新同步而丢弃的字节数，便于排查上游发送异常
+	Skipped uint64
+}
+
+// NewFrameReader 创建 FrameReader，maxFrameSize<=0 时使用默认上限
+func NewFrameReader(r io.Reader, maxFrameSize int) *FrameReader {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameReader{r: bufio.NewReaderSize(r, 4096), maxFrameSize: maxFrameSize}
+}
+
+// ReadFrame 读取下一帧。
+// 遇到非 0xF2 的字节时逐字节丢弃直到找到下一个标识位；
+// 数据段长度超过上限时丢弃该标识位并重新同步，返回 ErrFrameTooLarge 供调用方记录。
+// 底层读取错误（包括 io.EOF）原样返回。
+func (fr *FrameReader) ReadFrame() (*Frame, error) {
+	for {
+		// 定位标识位
+		b, err := fr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != StartByte {
+			fr.Skipped++
+			continue
+		}
+		// 预读剩余报文头，不消费数据，便于长度非法时从下一个字节重新同步
+		rest, err := fr.r.Peek(HeaderSize - 1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		hdrBuf := make([]byte, HeaderSize)
+		hdrBuf[0] = b
+		copy(hdrBuf[1:], rest)
+		h, _ := ParseFixedHeader(hdrBuf)
+		if int64(h.DataLength) > int64(fr.maxFrameSize) {
+			fr.Skipped++
+			return nil, fmt.Errorf("%w: dataLength=%d max=%d", ErrFrameTooLarge, h.DataLength, fr.maxFrameSize)
+		}
+		if _, err := fr.r.Discard(HeaderSize - 1); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, h.DataLength)
+		if _, err := io.ReadFull(fr.r, payload); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return &Frame{Header: h, Payload: payload}, nil
+	}
+}
@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"vehicle-api/internal/types"
+)
+
+func testFrame(category byte, payload string) []byte {
+	return EncodeFrame(types.FixedHeader{DataCategory: category, Version: 1, Timestamp: 1700000000000}, []byte(payload))
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestFrameReader(t *testing.T) {
+	a := testFrame(DataCategoryVeh2CloudState, "state-a")
+	b := testFrame(DataCategoryVeh2CloudAlarm, "alarm-b")
+	// 数据段长度超过上限的报文头（其余字节不含 0xF2，跳过标识位后不会误同步）
+	oversize := EncodeFixedHeader(types.FixedHeader{DataLength: 1 << 20, DataCategory: DataCategoryVeh2CloudState, Version: 1})
+
+	cases := []struct {
+		name      string
+		reader    func(b []byte) io.Reader
+		input     []byte
+		want      []string // 依次读到的数据段
+		tooLarge  int      // 期间返回 ErrFrameTooLarge 的次数
+		skipped   uint64
+		wantFinal error // 读完 want 之后的错误
+	}{
+		{
+			name:      "split across one-byte reads",
+			reader:    func(b []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) },
+			input:     concat(a, b),
+			want:      []string{"state-a", "alarm-b"},
+			wantFinal: io.EOF,
+		},
+		{
+			name:      "two frames in one read",
+			input:     concat(a, b),
+			want:      []string{"state-a", "alarm-b"},
+			wantFinal: io.EOF,
+		},
+		{
+			name:      "junk before start byte",
+			input:     concat([]byte{0x00, 0x13, 0x37, 0xAB}, a),
+			want:      []string{"state-a"},
+			skipped:   4,
+			wantFinal: io.EOF,
+		},
+		{
+			name:      "oversize data length resyncs",
+			input:     concat(oversize, b),
+			want:      []string{"alarm-b"},
+			tooLarge:  1,
+			skipped:   1 + HeaderSize - 1, // 非法标识位与其后的报文头字节
+			wantFinal: io.EOF,
+		},
+		{
+			name:      "truncated final frame",
+			input:     concat(a, b[:len(b)-3]),
+			want:      []string{"state-a"},
+			wantFinal: io.ErrUnexpectedEOF,
+		},
+		{
+			name:      "truncated final header",
+			input:     concat(a, b[:5]),
+			want:      []string{"state-a"},
+			wantFinal: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r io.Reader = bytes.NewReader(tc.input)
+			if tc.reader != nil {
+				r = tc.reader(tc.input)
+			}
+			fr := NewFrameReader(r, 1024)
+			var got []string
+			tooLarge := 0
+			var final error
+			for {
+				f, err := fr.ReadFrame()
+				if errors.Is(err, ErrFrameTooLarge) {
+					tooLarge++
+					continue
+				}
+				if err != nil {
+					final = err
+					break
+				}
+				if int(f.Header.DataLength) != len(f.Payload) {
+					t.Fatalf("header dataLength=%d, payload %d bytes", f.Header.DataLength, len(f.Payload))
+				}
+				got = append(got, string(f.Payload))
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("frames = %q, want %q", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("frames = %q, want %q", got, tc.want)
+				}
+			}
+			if tooLarge != tc.tooLarge {
+				t.Fatalf("ErrFrameTooLarge returned %d times, want %d", tooLarge, tc.tooLarge)
+			}
+			if fr.Skipped != tc.skipped {
+				t.Fatalf("skipped = %d, want %d", fr.Skipped, tc.skipped)
+			}
+			if final != tc.wantFinal {
+				t.Fatalf("final error = %v, want %v", final, tc.wantFinal)
+			}
+		})
+	}
+}
+
+func TestParseFixedHeaderRoundTrip(t *testing.T) {
+	h := types.FixedHeader{DataLength: 73, DataCategory: DataCategoryVeh2CloudCmdAck, Version: 2, Timestamp: 1700000000123, Control: MakeControl(PriorityHigh, EncryptSM4GCM)}
+	got, err := ParseFixedHeader(EncodeFixedHeader(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.StartByte = StartByte
+	if got != h {
+		t.Fatalf("ParseFixedHeader = %+v, want %+v", got, h)
+	}
+	if _, err := ParseFixedHeader(make([]byte, HeaderSize)); err != ErrBadStartByte {
+		t.Fatalf("zero header: err = %v, want ErrBadStartByte", err)
+	}
+	if _, err := ParseFixedHeader([]byte{StartByte}); err != ErrShortBuffer {
+		t.Fatalf("short header: err = %v, want ErrShortBuffer", err)
+	}
+}
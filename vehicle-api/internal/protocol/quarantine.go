@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// quarantineRecord 为隔离日志中的一行（NDJSON）
+type quarantineRecord struct {
+	ReceivedAt   string `json:"receivedAt"`
+	Source       string `json:"source"`
+	Reason       string `json:"reason"`
+	DataCategory byte   `json:"dataCategory"`
+	Version      byte   `json:"version"`
+	Timestamp    uint64 `json:"timestamp"`
+	Control      byte   `json:"control"`
+	DataLength   uint32 `json:"dataLength"`
+	Payload      string `json:"payload"` // 十六进制编码的原始数据段
+}
+
+// Quarantine 保存无法识别或无法解码的报文，供后续补充解码器后排查/回放，而不是直接丢弃。
+// 配置了文件路径时以 NDJSON 追加写入文件，否则写入服务日志。
+type Quarantine struct {
+	path  string
+	mu    sync.Mutex
+	f     *os.File
+	count atomic.Uint64
+}
+
+// NewQuarantine 创建隔离日志；path 为空时仅写服务日志
+func NewQuarantine(path string) *Quarantine {
+	return &Quarantine{path: path}
+}
+
+// Put 记录一帧被隔离的报文
+func (q *Quarantine) Put(source, reason string, frame *Frame) {
+	if q == nil || frame == nil {
+		return
+	}
+	q.count.Add(1)
+	h := frame.Header
+	rec := quarantineRecord{
+		ReceivedAt:   time.Now().UTC().Format(time.RFC3339Nano),
+		Source:       source,
+		Reason:       reason,
+		DataCategory: h.DataCategory,
+		Version:      h.Version,
+		Timestamp:    h.Timestamp,
+		Control:      h.Control,
+		DataLength:   h.DataLength,
+		Payload:      hex.EncodeToString(frame.Payload),
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		logx.Errorf("序列化隔离报文失败: %v", err)
+		return
+	}
+	if q.path == "" {
+		logx.Infof("隔离报文: %s", string(b))
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logx.Errorf("打开隔离日志失败 path=%s err=%v, 报文: %s", q.path, err, string(b))
+			return
+		}
+		q.f = f
+	}
+	if _, err := q.f.Write(append(b, '\n')); err != nil {
+		logx.Errorf("写入隔离日志失败 path=%s err=%v", q.path, err)
+	}
+}
+
+// Count 返回累计隔离的报文数
+func (q *Quarantine) Count() uint64 {
+	if q == nil {
+		return 0
+	}
+	return q.count.Load()
+}
+
+// Close 关闭隔离日志文件
+func (q *Quarantine) Close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f != nil {
+		_ = q.f.Close()
+		q.f = nil
+	}
+}
@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"vehicle-api/internal/types"
+)
+
+// MessageKind 标识解码后的消息类型
+type MessageKind int
+
+const (
+	MessageKindState      MessageKind = iota + 1 // 车辆状态（VehicleStateData）
+	MessageKindAlarm                             // 车辆告警（AlarmData）
+	MessageKindHeartbeat                         // 车辆心跳（HeartbeatData）
+	MessageKindCommandAck                        // 下行指令应答（CommandAckData）
+)
+
+// Message 为解码器的统一输出，按 Kind 只填充对应的一个字段
+type Message struct {
+	Kind       MessageKind
+	Header     types.FixedHeader
+	State      *types.VehicleStateData
+	Alarm      *AlarmData
+	Heartbeat  *HeartbeatData
+	CommandAck *CommandAckData
+}
+
+// VehicleId 返回消息所属车辆编号（便于上层统一过滤/路由）
+func (m *Message) VehicleId() string {
+	switch {
+	case m.State != nil:
+		return m.State.VehicleId
+	case m.Alarm != nil:
+		return m.Alarm.VehicleId
+	case m.Heartbeat != nil:
+		return m.Heartbeat.VehicleId
+	case m.CommandAck != nil:
+		return m.CommandAck.VehicleId
+	}
+	return ""
+}
+
+// DecoderFunc 将某一 (数据类别, 版本号) 的数据段解码为 Message
+type DecoderFunc func(h types.FixedHeader, payload []byte) (*Message, error)
+
+// ErrUnknownDataset 表示 (数据类别, 版本号) 没有注册解码器
+var ErrUnknownDataset = errors.New("protocol: unknown dataset")
+
+type datasetKey struct {
+	category byte
+	version  byte
+}
+
+// Registry 维护 (DataCategory, Version) -> DecoderFunc 的映射。
+// 新的车端固件数据集只需注册对应的解码器，无需修改接入循环。
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[datasetKey]DecoderFunc
+}
+
+// NewRegistry 创建空的解码器注册表
+func NewRegistry() *Registry {
+	return &Registry{decoders: make(map[datasetKey]DecoderFunc)}
+}
+
+// NewDefaultRegistry 创建已注册内置数据集（状态/告警/心跳/指令应答 v1）的注册表
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(DataCategoryVeh2CloudState, 1, decodeStateV1)
+	r.Register(DataCategoryVeh2CloudAlarm, 1, decodeAlarmV1)
+	r.Register(DataCategoryVeh2CloudHeartbeat, 1, decodeHeartbeatV1)
+	r.Register(DataCategoryVeh2CloudCmdAck, 1, decodeCommandAckV1)
+	return r
+}
+
+// Register 注册（或覆盖）指定数据集的解码器
+func (r *Registry) Register(category, version byte, fn DecoderFunc) {
+	if fn == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[datasetKey{category, version}] = fn
+}
+
+// Lookup 查询指定数据集的解码器
+func (r *Registry) Lookup(category, version byte) (DecoderFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.decoders[datasetKey{category, version}]
+	return fn, ok
+}
+
+// Decode 按报文头选择解码器解码一帧；未注册的数据集返回 ErrUnknownDataset
+func (r *Registry) Decode(frame *Frame) (*Message, error) {
+	h := frame.Header
+	fn, ok := r.Lookup(h.DataCategory, h.Version)
+	if !ok {
+		return nil, fmt.Errorf("%w: category=0x%02X version=%d", ErrUnknownDataset, h.DataCategory, h.Version)
+	}
+	msg, err := fn(h, frame.Payload)
+	if err != nil {
+		return nil, err
+	}
+	msg.Header = h
+	return msg, nil
+}
+
+func decodeStateV1(h types.FixedHeader, payload []byte) (*Message, error) {
+	d, err := DecodeVeh2CloudState(payload)
+	if err != nil {
+		return nil, err
+	}
+	// GNSS 时间缺省时使用报文头中的采集时间
+	if d.Timestamp == 0 {
+		d.Timestamp = h.Timestamp
+	}
+	return &Message{Kind: MessageKindState, State: d}, nil
+}
+
+func decodeAlarmV1(h types.FixedHeader, payload []byte) (*Message, error) {
+	a, err := DecodeVeh2CloudAlarm(payload)
+	if err != nil {
+		return nil, err
+	}
+	if a.Timestamp == 0 {
+		a.Timestamp = h.Timestamp
+	}
+	return &Message{Kind: MessageKindAlarm, Alarm: a}, nil
+}
+
+func decodeHeartbeatV1(h types.FixedHeader, payload []byte) (*Message, error) {
+	hb, err := DecodeVeh2CloudHeartbeat(payload)
+	if err != nil {
+		return nil, err
+	}
+	hb.Timestamp = h.Timestamp
+	return &Message{Kind: MessageKindHeartbeat, Heartbeat: hb}, nil
+}
+
+func decodeCommandAckV1(h types.FixedHeader, payload []byte) (*Message, error) {
+	a, err := DecodeCommandAck(payload)
+	if err != nil {
+		return nil, err
+	}
+	a.Timestamp = h.Timestamp
+	return &Message{Kind: MessageKindCommandAck, CommandAck: a}, nil
+}
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"vehicle-api/internal/types"
+)
+
+// AlarmData 车辆告警（VEH2CLOUD_ALARM）解码结果
+type AlarmData struct {
+	VehicleId   string  `json:"vehicleId"`
+	Timestamp   uint64  `json:"timestamp"`   // 告警发生时间（ms）
+	AlarmType   int     `json:"alarmType"`   // 告警类型编码
+	AlarmLevel  int     `json:"alarmLevel"`  // 告警等级：1 提示 2 一般 3 严重
+	Lon         float64 `json:"lon"`         // 告警发生位置经度
+	Lat         float64 `json:"lat"`         // 告警发生位置纬度
+	Description string  `json:"description"` // 告警描述（UTF-8）
+}
+
+// HeartbeatData 车辆心跳（VEH2CLOUD_HEARTBEAT）解码结果
+type HeartbeatData struct {
+	VehicleId string `json:"vehicleId"`
+	Timestamp uint64 `json:"timestamp"` // 取自报文头时间戳（ms）
+	Status    int    `json:"status"`    // 车端运行状态：0 正常，其它为车端自定义
+}
+
+// VEH2CLOUD_ALARM 数据段布局（版本 1，大端序）：
+//
+//	偏移  长度  字段
+//	0     8    vehicleId     车辆编号
+//	8     8    timestamp     告警时间（ms）
+//	16    2    alarmType     告警类型
+//	18    1    alarmLevel    告警等级
+//	19    4    lon           经度，单位 1e-7°，偏移 180
+//	23    4    lat           纬度，单位 1e-7°，偏移 90
+//	27    1    descLen       描述长度 n
+//	28    n    description   描述（UTF-8）
+const veh2CloudAlarmFixedLen = 28
+
+// DecodeVeh2CloudAlarm 解码 VEH2CLOUD_ALARM 数据段
+func DecodeVeh2CloudAlarm(payload []byte) (*AlarmData, error) {
+	if len(payload) < veh2CloudAlarmFixedLen {
+		return nil, fmt.Errorf("VEH2CLOUD_ALARM payload too short: %d < %d", len(payload), veh2CloudAlarmFixedLen)
+	}
+	be := binary.BigEndian
+	a := &AlarmData{}
+	a.VehicleId, _ = types.ReadFixedString(payload, 0, veh2CloudStateVehicleIdLen)
+	a.Timestamp = be.Uint64(payload[8:16])
+	a.AlarmType = int(be.Uint16(payload[16:18]))
+	a.AlarmLevel = int(payload[18])
+	a.Lon = scaleOffset(be.Uint32(payload[19:23]), 1e-7, 180)
+	a.Lat = scaleOffset(be.Uint32(payload[23:27]), 1e-7, 90)
+	n := int(payload[27])
+	if len(payload) < veh2CloudAlarmFixedLen+n {
+		return nil, fmt.Errorf("VEH2CLOUD_ALARM description truncated: need %d bytes, got %d", veh2CloudAlarmFixedLen+n, len(payload))
+	}
+	a.Description = string(payload[veh2CloudAlarmFixedLen : veh2CloudAlarmFixedLen+n])
+	return a, nil
+}
+
+// VEH2CLOUD_HEARTBEAT 数据段布局（版本 1）：vehicleId BYTE[8] + status BYTE
+const veh2CloudHeartbeatLen = 9
+
+// DecodeVeh2CloudHeartbeat 解码 VEH2CLOUD_HEARTBEAT 数据段
+func DecodeVeh2CloudHeartbeat(payload []byte) (*HeartbeatData, error) {
+	if len(payload) < veh2CloudHeartbeatLen {
+		return nil, fmt.Errorf("VEH2CLOUD_HEARTBEAT payload too short: %d < %d", len(payload), veh2CloudHeartbeatLen)
+	}
+	hb := &HeartbeatData{}
+	hb.VehicleId, _ = types.ReadFixedString(payload, 0, veh2CloudStateVehicleIdLen)
+	hb.Status = int(payload[8])
+	return hb, nil
+}
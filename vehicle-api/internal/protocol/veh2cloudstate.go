@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"vehicle-api/internal/types"
+)
+
+// VEH2CLOUD_STATE 数据段布局（版本 1，大端序）：
+//
+//	偏移  长度  字段
+//	0     8    vehicleId         车辆编号，定长字符串，尾部补 0
+//	8     1    categoryCode      车辆类型编码
+//	9     8    timestampGNSS     GNSS 时间戳（ms），0xFFFFFFFFFFFFFFFF 表示异常
+//	17    2    velocityGNSS      车速，单位 0.01m/s，0xFFFF 表示异常
+//	19    4    lon               经度，单位 1e-7°，偏移 180，0xFFFFFFFF 表示异常
+//	23    4    lat               纬度，单位 1e-7°，偏移 90，0xFFFFFFFF 表示异常
+//	27    4    elevation         海拔，单位 dm，偏移 5000（VehicleStateData 暂不保存）
+//	31    4    heading           航向角，单位 1e-4°，0xFFFFFFFF 表示异常
+//	35    1    tapPos            挡位
+//	36    2    accelPos          油门开度，单位 0.1%
+//	38    1    brakeFlag         制动踏板开关
+//	39    2    brakePos          制动踏板开度，单位 0.1%
+//	41    2    fuelConsumption   油耗，单位 0.01L/100km
+//	43    1    driveMode         驾驶模式
+//	44    10   abs/tcs/esp/lka/accMode/fcw/ldw/aeb/lca/dms 各 1 字节
+//	54    2    soc               电池剩余电量，单位 0.01%
+//	56    4    mileage           里程，单位 0.1km
+//	60    2    accelerationLon   纵向加速度，有符号，单位 0.01m/s²
+//	62    2    accelerationLat   横向加速度，有符号，单位 0.01m/s²
+//	64    2    lights            灯光状态按位：bit0 近光 bit1 远光 bit2 左转 bit3 右转 bit4 双闪 bit5 自动大灯 bit6 日行灯 bit7 雾灯 bit8 停车灯
+//	66    4    vehFault          车辆故障状态位掩码
+//	70    1    doorsNum          车门数量 n
+//	71    n    doors             每个车门的状态位掩码
+const (
+	veh2CloudStateVehicleIdLen = 8
+	veh2CloudStateFixedLen     = 71
+
+	invalidWord  = 0xFFFF
+	invalidDWord = 0xFFFFFFFF
+)
+
+// DecodeVeh2CloudState 将 VEH2CLOUD_STATE 数据段解码为 VehicleStateData。
+// 协议中的定点数会换算为实际值（度、m/s、% 等）；异常值（全 F）保持原始哨兵值，
+// 由上层决定如何处理，避免被误换算成看似合法的坐标。
+func DecodeVeh2CloudState(payload []byte) (*types.VehicleStateData, error) {
+	if len(payload) < veh2CloudStateFixedLen {
+		return nil, fmt.Errorf("VEH2CLOUD_STATE payload too short: %d < %d", len(payload), veh2CloudStateFixedLen)
+	}
+	be := binary.BigEndian
+	d := &types.VehicleStateData{}
+
+	vid, _ := types.ReadFixedString(payload, 0, veh2CloudStateVehicleIdLen)
+	d.VehicleId = vid
+	d.CategoryCode = int(payload[8])
+	d.Timestamp = be.Uint64(payload[9:17])
+
+	if v := be.Uint16(payload[17:19]); v == invalidWord {
+		d.Speed = float64(v)
+	} else {
+		d.Speed = float64(v) * 0.01
+	}
+	d.Lon = scaleOffset(be.Uint32(payload[19:23]), 1e-7, 180)
+	d.Lat = scaleOffset(be.Uint32(payload[23:27]), 1e-7, 90)
+	d.Heading = scaleOffset(be.Uint32(payload[31:35]), 1e-4, 0)
+
+	d.TapPos = int(payload[35])
+	d.AccelPos = float64(be.Uint16(payload[36:38])) * 0.1
+	d.BrakeFlag = int(payload[38])
+	d.BrakePos = float64(be.Uint16(payload[39:41])) * 0.1
+	d.FuelConsumption = float64(be.Uint16(payload[41:43])) * 0.01
+	d.DriveMode = int(payload[43])
+
+	d.AbsFlag = int(payload[44])
+	d.TcsFlag = int(payload[45])
+	d.EspFlag = int(payload[46])
+	d.LkaFlag = int(payload[47])
+	d.AccMode = int(payload[48])
+	d.FcwFlag = int(payload[49])
+	d.LdwFlag = int(payload[50])
+	d.AebFlag = int(payload[51])
+	d.LcaFlag = int(payload[52])
+	d.DmsFlag = int(payload[53])
+
+	d.Soc = float64(be.Uint16(payload[54:56])) * 0.01
+	d.Mileage = float64(be.Uint32(payload[56:60])) * 0.1
+	d.AccelerationV = float64(int16(be.Uint16(payload[60:62]))) * 0.01
+	d.AccelerationH = float64(int16(be.Uint16(payload[62:64]))) * 0.01
+
+	lights := be.Uint16(payload[64:66])
+	d.LowBeam = bit(lights, 0)
+	d.HighBeam = bit(lights, 1)
+	d.LeftTurn = bit(lights, 2)
+	d.RightTurn = bit(lights, 3)
+	d.HazardSignal = bit(lights, 4)
+	d.Automatic = bit(lights, 5)
+	d.DaytimeRunning = bit(lights, 6)
+	d.FogLight = bit(lights, 7)
+	d.Parking = bit(lights, 8)
+
+	d.VehFault = int(be.Uint32(payload[66:70]))
+
+	n := int(payload[70])
+	if len(payload) < veh2CloudStateFixedLen+n {
+		return nil, fmt.Errorf("VEH2CLOUD_STATE doors truncated: need %d bytes, got %d", veh2CloudStateFixedLen+n, len(payload))
+	}
+	if n > 0 {
+		d.Doors = make([]int, n)
+		for i := 0; i < n; i++ {
+			d.Doors[i] = int(payload[veh2CloudStateFixedLen+i])
+		}
+	}
+	return d, nil
+}
+
+// scaleOffset 将定点 DWORD 换算为实际值：raw*unit - offset；0xFFFFFFFF 保留为哨兵值
+func scaleOffset(raw uint32, unit float64, offset float64) float64 {
+	if raw == invalidDWord {
+		return float64(raw)
+	}
+	return float64(raw)*unit - offset
+}
+
+func bit(v uint16, n uint) int {
+	return int((v >> n) & 1)
+}
@@ -0,0 +1,143 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"vehicle-api/internal/types"
+)
+
+// stateV1Vector 一条完整的 VEH2CLOUD_STATE v1 数据段（73 字节，含 2 个车门）
+const stateV1Vector = "" +
+	"5630303030303031" + // vehicleId "V0000001"
+	"02" + // categoryCode
+	"0000018bcfe56800" + // timestampGNSS 1700000000000
+	"04d2" + // velocityGNSS 1234 -> 12.34m/s
+	"b0aaa770" + // lon (116.3974+180)*1e7
+	"4d6e9608" + // lat (39.9093+90)*1e7
+	"0000c3c8" + // elevation
+	"000dcf28" + // heading 905000 -> 90.5°
+	"03" + "0064" + "01" + "00c8" + "0320" + "01" + // tapPos accelPos brakeFlag brakePos fuelConsumption driveMode
+	"01000100020000000001" + // abs tcs esp lka accMode fcw ldw aeb lca dms
+	"1f40" + // soc 8000 -> 80%
+	"0001e240" + // mileage 123456 -> 12345.6km
+	"ff6a" + "0019" + // accelerationLon -150, accelerationLat 25
+	"0105" + // lights: 近光、左转、停车灯
+	"00000004" + // vehFault
+	"02" + "0100" // doors
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestDecodeVeh2CloudState(t *testing.T) {
+	d, err := DecodeVeh2CloudState(decodeHex(t, stateV1Vector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.VehicleId != "V0000001" || d.CategoryCode != 2 || d.Timestamp != 1700000000000 {
+		t.Fatalf("identity fields: %+v", d)
+	}
+	floats := []struct {
+		name      string
+		got, want float64
+	}{
+		{"speed", d.Speed, 12.34},
+		{"lon", d.Lon, 116.3974},
+		{"lat", d.Lat, 39.9093},
+		{"heading", d.Heading, 90.5},
+		{"accelPos", d.AccelPos, 10},
+		{"brakePos", d.BrakePos, 20},
+		{"fuelConsumption", d.FuelConsumption, 8},
+		{"soc", d.Soc, 80},
+		{"mileage", d.Mileage, 12345.6},
+		{"accelerationLon", d.AccelerationV, -1.5},
+		{"accelerationLat", d.AccelerationH, 0.25},
+	}
+	for _, f := range floats {
+		if !near(f.got, f.want) {
+			t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+	ints := []struct {
+		name      string
+		got, want int
+	}{
+		{"tapPos", d.TapPos, 3},
+		{"brakeFlag", d.BrakeFlag, 1},
+		{"driveMode", d.DriveMode, 1},
+		{"abs", d.AbsFlag, 1},
+		{"esp", d.EspFlag, 1},
+		{"accMode", d.AccMode, 2},
+		{"dms", d.DmsFlag, 1},
+		{"lowBeam", d.LowBeam, 1},
+		{"highBeam", d.HighBeam, 0},
+		{"leftTurn", d.LeftTurn, 1},
+		{"parking", d.Parking, 1},
+		{"vehFault", d.VehFault, 4},
+	}
+	for _, f := range ints {
+		if f.got != f.want {
+			t.Errorf("%s = %d, want %d", f.name, f.got, f.want)
+		}
+	}
+	if len(d.Doors) != 2 || d.Doors[0] != 1 || d.Doors[1] != 0 {
+		t.Errorf("doors = %v, want [1 0]", d.Doors)
+	}
+}
+
+func TestDecodeVeh2CloudStateInvalidValues(t *testing.T) {
+	b := decodeHex(t, stateV1Vector)
+	copy(b[17:19], []byte{0xFF, 0xFF})             // velocity
+	copy(b[19:23], []byte{0xFF, 0xFF, 0xFF, 0xFF}) // lon
+	d, err := DecodeVeh2CloudState(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 异常值保留哨兵值，不换算成看似合法的坐标
+	if d.Speed != float64(invalidWord) || d.Lon != float64(invalidDWord) {
+		t.Fatalf("sentinels: speed=%v lon=%v", d.Speed, d.Lon)
+	}
+}
+
+func TestDecodeVeh2CloudStateTruncated(t *testing.T) {
+	b := decodeHex(t, stateV1Vector)
+	if _, err := DecodeVeh2CloudState(b[:veh2CloudStateFixedLen-1]); err == nil {
+		t.Fatal("short fixed part must fail")
+	}
+	if _, err := DecodeVeh2CloudState(b[:len(b)-1]); err == nil || !strings.Contains(err.Error(), "doors truncated") {
+		t.Fatalf("truncated doors: err = %v", err)
+	}
+}
+
+func TestRegistryDecodeStateFrame(t *testing.T) {
+	payload := decodeHex(t, stateV1Vector)
+	copy(payload[9:17], make([]byte, 8)) // GNSS 时间缺省时使用报文头时间戳
+	frame := &Frame{
+		Header:  types.FixedHeader{StartByte: StartByte, DataLength: uint32(len(payload)), DataCategory: DataCategoryVeh2CloudState, Version: 1, Timestamp: 1700000000999},
+		Payload: payload,
+	}
+	msg, err := NewDefaultRegistry().Decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Kind != MessageKindState || msg.VehicleId() != "V0000001" || msg.State.Timestamp != 1700000000999 {
+		t.Fatalf("unexpected message kind=%d vehicleId=%s ts=%d", msg.Kind, msg.VehicleId(), msg.State.Timestamp)
+	}
+	frame.Header.Version = 9
+	if _, err := NewDefaultRegistry().Decode(frame); !errors.Is(err, ErrUnknownDataset) {
+		t.Fatalf("unknown version: err = %v, want ErrUnknownDataset", err)
+	}
+}
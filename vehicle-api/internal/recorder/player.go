@@ -0,0 +1,259 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/types"
+)
+
+// ErrBusy 表示已有回放在进行中
+var ErrBusy = errors.New("replay already running")
+
+// ReplayOptions 回放参数
+type ReplayOptions struct {
+	Speed     float64 // 回放倍速，<=0 时为 1
+	Max       bool    // 不等待，以最快速度回放
+	VehicleId string  // 只回放该车辆的消息，为空表示全部
+	From, To  int64   // 只回放接收时间在 [From, To] 内的消息（Unix 毫秒），0 表示不限
+}
+
+// Session 一次回放使用的处理链路，每次 Start 时新建，回放结束（或停止）后 Close
+type Session interface {
+	Emit(e *Entry) error
+	Close()
+}
+
+// Player 把录制文件按记录的接收时间间隔回放给 Session（同一时间只允许一个回放）
+type Player struct {
+	dir     string
+	session func() Session
+
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	done       chan struct{}
+	file       string
+	opts       ReplayOptions
+	read       uint64
+	replayed   uint64
+	skipped    uint64
+	errors     uint64
+	startedAt  time.Time
+	finishedAt time.Time
+	lastError  string
+}
+
+// NewPlayer 创建回放器，只能回放 dir 目录中的文件；session 在每次回放开始时创建处理链路
+func NewPlayer(dir string, session func() Session) *Player {
+	return &Player{dir: dir, session: session}
+}
+
+// Start 在后台开始回放 file（目录中的文件名）
+func (p *Player) Start(file string, opts ReplayOptions) error {
+	if file == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+		return errors.New("invalid recording file name")
+	}
+	f, err := os.Open(filepath.Join(p.dir, file))
+	if err != nil {
+		return err
+	}
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		_ = f.Close()
+		return ErrBusy
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel, p.done = cancel, make(chan struct{})
+	p.file, p.opts = file, opts
+	p.read, p.replayed, p.skipped, p.errors = 0, 0, 0, 0
+	p.startedAt, p.finishedAt, p.lastError = time.Now(), time.Time{}, ""
+	go p.run(ctx, f, p.session())
+	logx.Infof("开始回放录制文件 file=%s speed=%v max=%v vehicleId=%s from=%d to=%d", file, opts.Speed, opts.Max, opts.VehicleId, opts.From, opts.To)
+	return nil
+}
+
+// Stop 停止进行中的回放并等待其退出
+func (p *Player) Stop() {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Status 返回当前或最近一次回放的进度
+func (p *Player) Status() types.ReplayStatusResp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := types.ReplayStatusResp{
+		Running:   p.cancel != nil,
+		File:      p.file,
+		Speed:     p.opts.Speed,
+		Max:       p.opts.Max,
+		VehicleId: p.opts.VehicleId,
+		Read:      p.read,
+		Replayed:  p.replayed,
+		Skipped:   p.skipped,
+		Errors:    p.errors,
+		LastError: p.lastError,
+	}
+	if !p.startedAt.IsZero() {
+		st.StartedAt = p.startedAt.UTC().Format(time.RFC3339)
+	}
+	if !p.finishedAt.IsZero() {
+		st.FinishedAt = p.finishedAt.UTC().Format(time.RFC3339)
+	}
+	return st
+}
+
+func (p *Player) run(ctx context.Context, f *os.File, sess Session) {
+	defer f.Close()
+	err := p.play(ctx, f, sess)
+	sess.Close()
+	p.mu.Lock()
+	if err != nil && err != context.Canceled {
+		p.lastError = err.Error()
+	}
+	p.finishedAt = time.Now()
+	done := p.done
+	p.cancel, p.done = nil, nil
+	read, replayed := p.read, p.replayed
+	p.mu.Unlock()
+	close(done)
+	logx.Infof("录制文件回放结束 file=%s read=%d replayed=%d err=%v", f.Name(), read, replayed, err)
+}
+
+func (p *Player) play(ctx context.Context, f *os.File, sess Session) error {
+	var r io.Reader = f
+	if strings.HasSuffix(f.Name(), ".gz") {
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+
+	var firstAt int64
+	var wallStart time.Time
+	for sc.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			p.count(func() { p.read++; p.errors++; p.lastError = err.Error() })
+			continue
+		}
+		p.count(func() { p.read++ })
+		if !p.match(&e) {
+			p.count(func() { p.skipped++ })
+			continue
+		}
+		if !p.opts.Max {
+			// 以第一条回放消息为基准，按接收时间间隔除以倍速等待
+			if firstAt == 0 {
+				firstAt, wallStart = e.Time, time.Now()
+			}
+			due := wallStart.Add(time.Duration(float64(time.Duration(e.Time-firstAt)*time.Millisecond) / p.opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if err := sess.Emit(&e); err != nil {
+			p.count(func() { p.errors++; p.lastError = err.Error() })
+			continue
+		}
+		p.count(func() { p.replayed++ })
+	}
+	err := sc.Err()
+	// 正在写入的文件末尾没有完整的 gzip 尾部，读到已刷新的位置即结束
+	if err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
+
+func (p *Player) count(fn func()) {
+	p.mu.Lock()
+	fn()
+	p.mu.Unlock()
+}
+
+// match 按时间范围与车辆过滤
+func (p *Player) match(e *Entry) bool {
+	if p.opts.From > 0 && e.Time < p.opts.From {
+		return false
+	}
+	if p.opts.To > 0 && e.Time > p.opts.To {
+		return false
+	}
+	if p.opts.VehicleId == "" {
+		return true
+	}
+	for _, id := range vehicleIds(e) {
+		if id == p.opts.VehicleId {
+			return true
+		}
+	}
+	return false
+}
+
+// vehicleIds 从原始消息中提取车辆编号：JSON 负载取 data.vehicleId / vehicleId（数组逐个取），
+// 另取主题的各段（二进制负载无法在不解密的情况下识别）
+func vehicleIds(e *Entry) []string {
+	type idOnly struct {
+		VehicleId string `json:"vehicleId"`
+		Data      struct {
+			VehicleId string `json:"vehicleId"`
+		} `json:"data"`
+	}
+	pick := func(v idOnly) string {
+		if v.Data.VehicleId != "" {
+			return v.Data.VehicleId
+		}
+		return v.VehicleId
+	}
+	var ids []string
+	if e.Msg != nil {
+		var one idOnly
+		var many []idOnly
+		if err := json.Unmarshal(e.Msg, &one); err == nil {
+			ids = append(ids, pick(one))
+		} else if err := json.Unmarshal(e.Msg, &many); err == nil {
+			for _, v := range many {
+				ids = append(ids, pick(v))
+			}
+		}
+	}
+	// MQTT 规则可以从主题中取车辆编号
+	if e.Topic != "" {
+		ids = append(ids, strings.Split(e.Topic, "/")...)
+	}
+	return ids
+}
@@ -0,0 +1,275 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+)
+
+// 消息来源类型
+const (
+	KindVEHState = "vehstate" // VEHState WebSocket 文本消息
+	KindMQTT     = "mqtt"     // MQTT 负载
+)
+
+const fileExt = ".ndjson.gz"
+
+// Entry 录制文件中的一行：接收时间与解析前的原始消息。JSON 负载原样保存在 msg 中，其它负载以 base64 保存在 bin 中
+type Entry struct {
+	Time   int64           `json:"t"`                // 接收时间（Unix 毫秒）
+	Kind   string          `json:"kind"`             // vehstate / mqtt
+	Source string          `json:"source,omitempty"` // 上游来源名称（vehstate）
+	Topic  string          `json:"topic,omitempty"`  // 主题（mqtt）
+	Msg    json.RawMessage `json:"msg,omitempty"`
+	Bin    []byte          `json:"bin,omitempty"`
+}
+
+// Payload 返回原始消息
+func (e *Entry) Payload() []byte {
+	if e.Msg != nil {
+		return e.Msg
+	}
+	return e.Bin
+}
+
+// Recorder 异步把原始消息写入 gzip 压缩的 NDJSON 文件：按天或达到大小上限时滚动，超过保留个数时删除最早的文件。
+// 文件名为 <prefix>-<yyyymmdd>-<序号>.ndjson.gz；每秒 Flush 一次，进程异常退出时最多丢失约 1 秒的数据。
+type Recorder struct {
+	dir      string
+	prefix   string
+	maxBytes int64
+	maxFiles int
+
+	ch   chan *Entry
+	done chan struct{}
+
+	// 以下字段只在写入协程中访问
+	f    *os.File
+	cw   *countingWriter
+	gz   *gzip.Writer
+	bw   *bufio.Writer
+	day  string
+	seq  int
+	path string
+
+	recorded atomic.Uint64
+	dropped  atomic.Uint64
+
+	closeOnce sync.Once
+}
+
+// New 创建录制器并启动写入协程
+func New(cfg config.RecorderConfig) (*Recorder, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "raw"
+	}
+	if cfg.MaxFileMB <= 0 {
+		cfg.MaxFileMB = 64
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 4096
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		dir:      cfg.Dir,
+		prefix:   cfg.Prefix,
+		maxBytes: int64(cfg.MaxFileMB) << 20,
+		maxFiles: cfg.MaxFiles,
+		ch:       make(chan *Entry, cfg.BufferSize),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	logx.Infof("原始消息录制已启动 dir=%s", cfg.Dir)
+	return r, nil
+}
+
+// Record 录制一条原始消息（不阻塞，缓冲已满时丢弃并计数）
+func (r *Recorder) Record(kind, source, topic string, payload []byte) {
+	e := &Entry{Time: time.Now().UnixMilli(), Kind: kind, Source: source, Topic: topic}
+	// 调用方可能复用 payload 的底层数组，这里复制一份
+	b := append([]byte(nil), payload...)
+	if json.Valid(b) {
+		e.Msg = b
+	} else {
+		e.Bin = b
+	}
+	select {
+	case r.ch <- e:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Recorded 返回已写入的消息数
+func (r *Recorder) Recorded() uint64 { return r.recorded.Load() }
+
+// Dropped 返回因缓冲已满丢弃的消息数
+func (r *Recorder) Dropped() uint64 { return r.dropped.Load() }
+
+// Close 写完缓冲中的消息并关闭当前文件
+func (r *Recorder) Close() {
+	r.closeOnce.Do(func() {
+		close(r.ch)
+		<-r.done
+	})
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer r.closeFile()
+	for {
+		select {
+		case e, ok := <-r.ch:
+			if !ok {
+				return
+			}
+			if err := r.write(e); err != nil {
+				r.dropped.Add(1)
+				logx.Errorf("写入录制文件失败: %v", err)
+				r.closeFile()
+				continue
+			}
+			r.recorded.Add(1)
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
+
+func (r *Recorder) write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	day := time.UnixMilli(e.Time).Format("20060102")
+	if r.gz == nil || day != r.day || r.cw.n >= r.maxBytes {
+		if err := r.roll(day); err != nil {
+			return err
+		}
+	}
+	if _, err := r.bw.Write(b); err != nil {
+		return err
+	}
+	return r.bw.WriteByte('\n')
+}
+
+// flush 把缓冲与压缩器中的数据写入文件（gzip sync flush，已写入部分可被读取）
+func (r *Recorder) flush() {
+	if r.gz == nil {
+		return
+	}
+	if err := r.bw.Flush(); err == nil {
+		err = r.gz.Flush()
+		if err != nil {
+			logx.Errorf("刷新录制文件失败 file=%s err=%v", r.path, err)
+		}
+	}
+}
+
+func (r *Recorder) closeFile() {
+	if r.gz == nil {
+		return
+	}
+	_ = r.bw.Flush()
+	if err := r.gz.Close(); err != nil {
+		logx.Errorf("关闭录制文件失败 file=%s err=%v", r.path, err)
+	}
+	_ = r.f.Close()
+	r.f, r.cw, r.gz, r.bw = nil, nil, nil, nil
+}
+
+// roll 关闭当前文件并打开新文件；重启后同一天继续使用新的序号，不覆盖已有文件
+func (r *Recorder) roll(day string) error {
+	r.closeFile()
+	if day != r.day {
+		r.day = day
+		r.seq = r.lastSeq(day)
+	}
+	r.seq++
+	path := filepath.Join(r.dir, fmt.Sprintf("%s-%s-%06d%s", r.prefix, day, r.seq, fileExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	r.f, r.path = f, path
+	r.cw = &countingWriter{w: f}
+	r.gz = gzip.NewWriter(r.cw)
+	r.bw = bufio.NewWriterSize(r.gz, 64*1024)
+	r.prune()
+	return nil
+}
+
+// lastSeq 返回目录中该日期已存在的最大序号
+func (r *Recorder) lastSeq(day string) int {
+	matches, _ := filepath.Glob(filepath.Join(r.dir, r.prefix+"-"+day+"-*"+fileExt))
+	max := 0
+	for _, m := range matches {
+		var seq int
+		name := strings.TrimSuffix(filepath.Base(m), fileExt)
+		if _, err := fmt.Sscanf(name[strings.LastIndex(name, "-")+1:], "%d", &seq); err == nil && seq > max {
+			max = seq
+		}
+	}
+	return max
+}
+
+// prune 删除超过保留个数的最早文件
+func (r *Recorder) prune() {
+	if r.maxFiles <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(r.dir, r.prefix+"-*"+fileExt))
+	if err != nil || len(matches) <= r.maxFiles {
+		return
+	}
+	sort.Strings(matches)
+	for _, m := range matches[:len(matches)-r.maxFiles] {
+		if err := os.Remove(m); err != nil {
+			logx.Errorf("删除过期录制文件失败 file=%s err=%v", m, err)
+		}
+	}
+}
+
+// Files 返回目录中的录制文件名（按名称排序）
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() && (strings.HasSuffix(e.Name(), fileExt) || strings.HasSuffix(e.Name(), ".ndjson")) {
+			out = append(out, e.Name())
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// countingWriter 统计写入文件的（压缩后）字节数
+type countingWriter struct {
+	w *os.File
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,319 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/dao"
+	"vehicle-api/internal/types"
+)
+
+// 未登记车辆的审核状态（unregistered_vehicle_reports.status）
+const (
+	StatusPending  = "pending"  // 待审核
+	StatusApproved = "approved" // 已审核通过并写入 vehicle_list
+	StatusBlocked  = "blocked"  // 已拉黑，数据全部拒绝
+)
+
+// 未登记车辆数据的处理方式
+const (
+	PolicyAccept = "accept" // 记录并照常处理
+	PolicyReject = "reject" // 仅记录，不入库不广播
+)
+
+// maxPending 两次批量写入之间最多汇总的未登记车辆数，超出的车辆本轮不记录（仍按策略处理数据）
+const maxPending = 10000
+
+var (
+	// ErrUnregistered 车辆未在 vehicle_list 登记且 unknownPolicy=reject
+	ErrUnregistered = errors.New("vehicle not registered")
+	// ErrBlocked 车辆已被拉黑
+	ErrBlocked = errors.New("vehicle blocked")
+)
+
+// Registry 维护已登记（vehicle_list）与已拉黑车辆的内存缓存，按缓存判断每条状态是否放行；
+// 未登记车辆的上报在内存中汇总，按 FlushIntervalSec 批量写入 unregistered_vehicle_reports。
+// 缓存首次加载成功之前全部放行且不记录，避免 MySQL 不可用时把所有车辆当作未登记。
+type Registry struct {
+	policy  string
+	refresh time.Duration
+	flushIv time.Duration
+	mysql   *dao.MySQLDao
+
+	mu      sync.RWMutex
+	loaded  bool
+	known   map[string]struct{}
+	blocked map[string]struct{}
+	added   map[string]struct{} // 本次加载期间 MarkKnown 的车辆，加载完成后并入新缓存
+
+	pmu     sync.Mutex
+	pending map[string]*pendingReport
+
+	unknown  atomic.Uint64
+	rejected atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type pendingReport struct {
+	report dao.UnregisteredReport
+	last   *types.VehicleStateData
+}
+
+// New 创建并立即加载缓存，之后在后台定期重新加载与批量写入上报记录
+func New(cfg config.RegistryConfig, mysql *dao.MySQLDao) *Registry {
+	if cfg.UnknownPolicy != PolicyReject {
+		cfg.UnknownPolicy = PolicyAccept
+	}
+	if cfg.RefreshIntervalSec <= 0 {
+		cfg.RefreshIntervalSec = 300
+	}
+	if cfg.FlushIntervalSec <= 0 {
+		cfg.FlushIntervalSec = 5
+	}
+	r := &Registry{
+		policy:  cfg.UnknownPolicy,
+		refresh: time.Duration(cfg.RefreshIntervalSec) * time.Second,
+		flushIv: time.Duration(cfg.FlushIntervalSec) * time.Second,
+		mysql:   mysql,
+		known:   make(map[string]struct{}),
+		blocked: make(map[string]struct{}),
+		pending: make(map[string]*pendingReport),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		logx.Errorf("加载已登记车辆失败，稍后重试: %v", err)
+	}
+	go r.loop()
+	return r
+}
+
+func (r *Registry) loop() {
+	defer close(r.done)
+	reload := time.NewTicker(r.refresh)
+	flush := time.NewTicker(r.flushIv)
+	defer reload.Stop()
+	defer flush.Stop()
+	for {
+		select {
+		case <-r.stop:
+			r.flush()
+			return
+		case <-reload.C:
+			if err := r.Reload(); err != nil {
+				logx.Errorf("重新加载已登记车辆失败: %v", err)
+			}
+		case <-flush.C:
+			r.flush()
+		}
+	}
+}
+
+// Close 停止后台协程并写入剩余的上报记录
+func (r *Registry) Close() {
+	select {
+	case <-r.stop:
+		return
+	default:
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// Reload 从 MySQL 重新加载已登记与已拉黑的车辆
+func (r *Registry) Reload() error {
+	r.mu.Lock()
+	r.added = make(map[string]struct{})
+	r.mu.Unlock()
+
+	ids, err := r.mysql.ListVehicleIds()
+	if err != nil {
+		return err
+	}
+	blockedIds, err := r.mysql.ListUnregisteredIdsByStatus(StatusBlocked)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		known[id] = struct{}{}
+	}
+	blocked := make(map[string]struct{}, len(blockedIds))
+	for _, id := range blockedIds {
+		blocked[id] = struct{}{}
+	}
+
+	r.mu.Lock()
+	for id := range r.added {
+		known[id] = struct{}{}
+	}
+	r.known, r.blocked, r.added, r.loaded = known, blocked, nil, true
+	r.mu.Unlock()
+	logx.Infof("已加载登记车辆 %d 辆，拉黑车辆 %d 辆", len(known), len(blocked))
+	return nil
+}
+
+// Check 判断车辆状态是否放行：已拉黑返回 ErrBlocked；未登记时记录上报，unknownPolicy=reject 时返回 ErrUnregistered
+func (r *Registry) Check(d *types.VehicleStateData) error {
+	r.mu.RLock()
+	loaded := r.loaded
+	_, blocked := r.blocked[d.VehicleId]
+	_, known := r.known[d.VehicleId]
+	r.mu.RUnlock()
+	if !loaded || (known && !blocked) {
+		return nil
+	}
+
+	r.unknown.Add(1)
+	r.record(d)
+	if blocked {
+		r.rejected.Add(1)
+		return ErrBlocked
+	}
+	if r.policy == PolicyReject {
+		r.rejected.Add(1)
+		return ErrUnregistered
+	}
+	return nil
+}
+
+// Blocked 判断车辆是否已被拉黑
+func (r *Registry) Blocked(vehicleId string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.blocked[vehicleId]
+	return ok
+}
+
+// record 汇总一条未登记车辆的上报，等待批量写入
+func (r *Registry) record(d *types.VehicleStateData) {
+	now := time.Now()
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+	p, ok := r.pending[d.VehicleId]
+	if !ok {
+		if len(r.pending) >= maxPending {
+			return
+		}
+		p = &pendingReport{report: dao.UnregisteredReport{VehicleId: d.VehicleId, FirstSeen: now}}
+		r.pending[d.VehicleId] = p
+		logx.Infof("收到未登记车辆的数据 vehicleId=%s", d.VehicleId)
+	}
+	p.report.LastSeen = now
+	p.report.Count++
+	p.last = d
+}
+
+// flush 把汇总的上报写入 MySQL；写入失败时丢弃本轮汇总（下次上报会重新记录）
+func (r *Registry) flush() {
+	r.pmu.Lock()
+	if len(r.pending) == 0 {
+		r.pmu.Unlock()
+		return
+	}
+	pending := r.pending
+	r.pending = make(map[string]*pendingReport)
+	r.pmu.Unlock()
+
+	reports := make([]dao.UnregisteredReport, 0, len(pending))
+	for _, p := range pending {
+		if p.last != nil {
+			if b, err := json.Marshal(p.last); err == nil {
+				p.report.Payload = b
+			}
+		}
+		reports = append(reports, p.report)
+	}
+	if err := r.mysql.UpsertUnregisteredReports(reports); err != nil {
+		logx.Errorf("写入未登记车辆上报记录失败 vehicles=%d err=%v", len(reports), err)
+	}
+}
+
+// MarkKnown 把车辆加入已登记缓存（写入 vehicle_list 之后调用）
+func (r *Registry) MarkKnown(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		r.known[id] = struct{}{}
+		if r.added != nil {
+			r.added[id] = struct{}{}
+		}
+	}
+}
+
+// Forget 把车辆移出已登记缓存（从 vehicle_list 删除之后调用）
+func (r *Registry) Forget(vehicleId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.known, vehicleId)
+	if r.added != nil {
+		delete(r.added, vehicleId)
+	}
+}
+
+// Approve 审核通过：vehicle_list 中没有该车时按 info 新建，解除拉黑并把上报记录标记为 approved
+func (r *Registry) Approve(info *types.VehicleInfo) error {
+	r.flush()
+	existing, err := r.mysql.GetVehicleByID(info.VehicleId)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		if info.CreateTime == "" {
+			info.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+		}
+		if err := r.mysql.InsertVehicle(info); err != nil {
+			return err
+		}
+	}
+	if err := r.mysql.SetUnregisteredStatus(info.VehicleId, StatusApproved); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.blocked, info.VehicleId)
+	r.mu.Unlock()
+	r.MarkKnown(info.VehicleId)
+	logx.Infof("车辆已审核通过 vehicleId=%s", info.VehicleId)
+	return nil
+}
+
+// Block 拉黑车辆，之后该车的数据全部拒绝（已登记的车辆同样生效）
+func (r *Registry) Block(vehicleId string) error {
+	r.flush()
+	if err := r.mysql.SetUnregisteredStatus(vehicleId, StatusBlocked); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.blocked[vehicleId] = struct{}{}
+	r.mu.Unlock()
+	logx.Infof("车辆已拉黑 vehicleId=%s", vehicleId)
+	return nil
+}
+
+// Get 查询单辆车的上报记录（先写入汇总中的上报），不存在时返回 nil, nil
+func (r *Registry) Get(vehicleId string) (*types.UnregisteredVehicle, error) {
+	r.flush()
+	return r.mysql.GetUnregisteredVehicle(vehicleId)
+}
+
+// List 列出上报记录（先写入汇总中的上报），status 为空时返回全部状态
+func (r *Registry) List(status string, limit int) ([]types.UnregisteredVehicle, error) {
+	r.flush()
+	return r.mysql.ListUnregisteredVehicles(status, limit)
+}
+
+// Stats 返回缓存中已登记的车辆数、收到的未登记车辆状态数与其中被拒绝的数量
+func (r *Registry) Stats() (known int, unknown, rejected uint64) {
+	r.mu.RLock()
+	known = len(r.known)
+	r.mu.RUnlock()
+	return known, r.unknown.Load(), r.rejected.Load()
+}
@@ -0,0 +1,243 @@
+package sampler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/geo"
+	"vehicle-api/internal/types"
+)
+
+// 保留原因
+const (
+	TriggerFirst       = "first"        // 该车第一条记录
+	TriggerField       = "field"        // 关注字段变化
+	TriggerMaxInterval = "max_interval" // 超过最大间隔
+	TriggerDistance    = "distance"     // 移动距离超过阈值
+	TriggerHeading     = "heading"      // 航向变化超过阈值
+	TriggerOutOfOrder  = "out_of_order" // 时间戳不晚于上一条保留记录，交给排序阶段去重/按迟到处理
+)
+
+var triggers = []string{TriggerFirst, TriggerField, TriggerMaxInterval, TriggerDistance, TriggerHeading, TriggerOutOfOrder}
+
+// DefaultWatchFields 未配置 watchFields 时关注的字段
+var DefaultWatchFields = []string{"aebFlag", "doors", "driveMode", "vehFault"}
+
+// watchGetters 可关注的字段（按 JSON 字段名），取值转换为字符串后比较
+var watchGetters = map[string]func(*types.VehicleStateData) string{
+	"driveMode":    func(d *types.VehicleStateData) string { return strconv.Itoa(d.DriveMode) },
+	"tapPos":       func(d *types.VehicleStateData) string { return strconv.Itoa(d.TapPos) },
+	"brakeFlag":    func(d *types.VehicleStateData) string { return strconv.Itoa(d.BrakeFlag) },
+	"absFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.AbsFlag) },
+	"tcsFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.TcsFlag) },
+	"espFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.EspFlag) },
+	"lkaFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.LkaFlag) },
+	"accMode":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.AccMode) },
+	"fcwFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.FcwFlag) },
+	"ldwFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.LdwFlag) },
+	"aebFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.AebFlag) },
+	"lcaFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.LcaFlag) },
+	"dmsFlag":      func(d *types.VehicleStateData) string { return strconv.Itoa(d.DmsFlag) },
+	"hazardSignal": func(d *types.VehicleStateData) string { return strconv.Itoa(d.HazardSignal) },
+	"vehFault":     func(d *types.VehicleStateData) string { return strconv.Itoa(d.VehFault) },
+	"doors": func(d *types.VehicleStateData) string {
+		parts := make([]string, len(d.Doors))
+		for i, v := range d.Doors {
+			parts[i] = strconv.Itoa(v)
+		}
+		return strings.Join(parts, ",")
+	},
+}
+
+// policy 为合并默认值后的策略
+type policy struct {
+	maxIntervalMs  int64
+	minIntervalMs  int64
+	distanceMeters float64
+	headingDeg     float64
+	watch          []string
+}
+
+// kept 该车上一条保留的记录
+type kept struct {
+	ts       uint64
+	lon, lat float64
+	heading  float64
+	fields   []string
+}
+
+// Sampler 变化感知降频：相对每辆车上一条保留的记录，满足任一条件即保留——关注字段变化、超过最大间隔、
+// 移动距离或航向变化超过阈值；否则丢弃。间隔按记录自身的 Timestamp 计算，与到达时间无关。
+type Sampler struct {
+	def        *policy
+	categories map[int]*policy
+
+	mu   sync.Mutex
+	last map[string]*kept
+
+	checked  atomic.Uint64
+	dropped  atomic.Uint64
+	triggers map[string]*atomic.Uint64
+}
+
+// New 按配置创建降频器；fallbackMaxIntervalMs 为默认策略未配置 maxIntervalMs 时使用的值（兼容 VEHState.sampleIntervalMs）
+func New(cfg config.SamplingConfig, fallbackMaxIntervalMs int) (*Sampler, error) {
+	base := config.SamplingPolicy{MaxIntervalMs: fallbackMaxIntervalMs, DistanceMeters: 20, HeadingDeg: 30, WatchFields: DefaultWatchFields}
+	if base.MaxIntervalMs <= 0 {
+		base.MaxIntervalMs = 1000
+	}
+	defCfg := merge(base, cfg.Default)
+	def, err := compile(defCfg)
+	if err != nil {
+		return nil, fmt.Errorf("sampling default: %w", err)
+	}
+	s := &Sampler{
+		def:        def,
+		categories: make(map[int]*policy),
+		last:       make(map[string]*kept),
+		triggers:   make(map[string]*atomic.Uint64, len(triggers)),
+	}
+	for _, pc := range cfg.Categories {
+		p, err := compile(merge(defCfg, pc))
+		if err != nil {
+			return nil, fmt.Errorf("sampling category %d: %w", pc.CategoryCode, err)
+		}
+		s.categories[pc.CategoryCode] = p
+	}
+	for _, t := range triggers {
+		s.triggers[t] = new(atomic.Uint64)
+	}
+	return s, nil
+}
+
+// Keep 判断是否保留该记录，保留时返回触发原因并以其作为该车新的参考记录
+func (s *Sampler) Keep(d *types.VehicleStateData) (bool, string) {
+	s.checked.Add(1)
+	p := s.def
+	if cp, ok := s.categories[d.CategoryCode]; ok {
+		p = cp
+	}
+	cur := &kept{ts: d.Timestamp, lon: d.Lon, lat: d.Lat, heading: d.Heading, fields: make([]string, len(p.watch))}
+	for i, f := range p.watch {
+		cur.fields[i] = watchGetters[f](d)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.last[d.VehicleId]
+	if !ok {
+		s.last[d.VehicleId] = cur
+		return s.keep(TriggerFirst)
+	}
+	if d.Timestamp <= prev.ts {
+		// 乱序或重复记录不更新参考记录
+		return s.keep(TriggerOutOfOrder)
+	}
+	trigger := evaluate(p, prev, cur)
+	if trigger == "" {
+		s.dropped.Add(1)
+		return false, ""
+	}
+	s.last[d.VehicleId] = cur
+	return s.keep(trigger)
+}
+
+// Stats 返回累计检查、丢弃数与各触发条件的保留数
+func (s *Sampler) Stats() *types.SamplingStatsResp {
+	resp := &types.SamplingStatsResp{
+		Checked:  s.checked.Load(),
+		Dropped:  s.dropped.Load(),
+		Triggers: make([]types.SamplingTriggerStats, 0, len(triggers)),
+	}
+	for _, t := range triggers {
+		n := s.triggers[t].Load()
+		resp.Kept += n
+		resp.Triggers = append(resp.Triggers, types.SamplingTriggerStats{Trigger: t, Kept: n})
+	}
+	return resp
+}
+
+func (s *Sampler) keep(trigger string) (bool, string) {
+	s.triggers[trigger].Add(1)
+	return true, trigger
+}
+
+// evaluate 按 关注字段 > 最大间隔 > 距离 > 航向 的顺序返回第一个满足的条件，均不满足时返回空
+func evaluate(p *policy, prev, cur *kept) string {
+	for i := range p.watch {
+		// 策略按车辆类型区分，同一辆车类型变化时字段数可能不同
+		if i >= len(prev.fields) || prev.fields[i] != cur.fields[i] {
+			return TriggerField
+		}
+	}
+	elapsed := int64(cur.ts - prev.ts)
+	if p.maxIntervalMs >= 0 && elapsed >= p.maxIntervalMs {
+		return TriggerMaxInterval
+	}
+	if elapsed < p.minIntervalMs {
+		return ""
+	}
+	if p.distanceMeters >= 0 && geo.HaversineMeters(prev.lat, prev.lon, cur.lat, cur.lon) > p.distanceMeters {
+		return TriggerDistance
+	}
+	if p.headingDeg >= 0 && headingDelta(prev.heading, cur.heading) > p.headingDeg {
+		return TriggerHeading
+	}
+	return ""
+}
+
+// headingDelta 两个航向角之间的最小夹角（0~180）
+func headingDelta(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// merge 以 base 为基础，用 over 中已配置（非零）的字段覆盖
+func merge(base, over config.SamplingPolicy) config.SamplingPolicy {
+	out := base
+	out.CategoryCode = over.CategoryCode
+	if over.MaxIntervalMs != 0 {
+		out.MaxIntervalMs = over.MaxIntervalMs
+	}
+	if over.MinIntervalMs != 0 {
+		out.MinIntervalMs = over.MinIntervalMs
+	}
+	if over.DistanceMeters != 0 {
+		out.DistanceMeters = over.DistanceMeters
+	}
+	if over.HeadingDeg != 0 {
+		out.HeadingDeg = over.HeadingDeg
+	}
+	if len(over.WatchFields) > 0 {
+		out.WatchFields = over.WatchFields
+	}
+	return out
+}
+
+func compile(c config.SamplingPolicy) (*policy, error) {
+	p := &policy{
+		maxIntervalMs:  int64(c.MaxIntervalMs),
+		minIntervalMs:  int64(c.MinIntervalMs),
+		distanceMeters: c.DistanceMeters,
+		headingDeg:     c.HeadingDeg,
+	}
+	for _, f := range c.WatchFields {
+		if f == "" || f == "-" {
+			// "-" 表示不关注任何字段
+			continue
+		}
+		if _, ok := watchGetters[f]; !ok {
+			return nil, fmt.Errorf("unsupported watch field %q", f)
+		}
+		p.watch = append(p.watch, f)
+	}
+	return p, nil
+}
@@ -0,0 +1,137 @@
+package sampler
+
+import (
+	"testing"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+const baseTs = 1700000000000
+
+func at(offsetMs uint64, lon, heading float64) *types.VehicleStateData {
+	return &types.VehicleStateData{VehicleId: "V1", Timestamp: baseTs + offsetMs, Lon: lon, Lat: 39.0, Heading: heading}
+}
+
+func newSampler(t *testing.T, cfg config.SamplingConfig, fallback int) *Sampler {
+	t.Helper()
+	s, err := New(cfg, fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestKeepTriggers(t *testing.T) {
+	// 经度 0.0003° 在纬度 39° 约 26m，0.0001° 约 8.6m
+	cases := []struct {
+		name    string
+		d       *types.VehicleStateData
+		trigger string // 空表示丢弃
+	}{
+		{"small move within interval", at(200, 116.0001, 0), ""},
+		{"max interval", at(1000, 116.0, 0), TriggerMaxInterval},
+		{"distance", at(200, 116.0003, 0), TriggerDistance},
+		{"heading", at(200, 116.0, 45), TriggerHeading},         // 相对 10° 变化 35°
+		{"heading wraps around north", at(200, 116.0, 340), ""}, // 相对 10° 变化 30°
+		{"watched field", &types.VehicleStateData{VehicleId: "V1", Timestamp: baseTs + 10, Lon: 116.0, Lat: 39.0, Heading: 10, AebFlag: 1}, TriggerField},
+		{"out of order", at(0, 116.5, 0), TriggerOutOfOrder},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSampler(t, config.SamplingConfig{}, 0)
+			if keep, trigger := s.Keep(at(0, 116.0, 10)); !keep || trigger != TriggerFirst {
+				t.Fatalf("first record: keep=%v trigger=%q", keep, trigger)
+			}
+			keep, trigger := s.Keep(tc.d)
+			if keep != (tc.trigger != "") || trigger != tc.trigger {
+				t.Fatalf("keep=%v trigger=%q, want trigger %q", keep, trigger, tc.trigger)
+			}
+		})
+	}
+}
+
+func TestDroppedRecordsDoNotMoveReference(t *testing.T) {
+	s := newSampler(t, config.SamplingConfig{}, 0)
+	s.Keep(at(0, 116.0, 0))
+	// 每次 8.6m 均低于 20m 阈值，但相对保留的参考记录累计超过阈值
+	for i, lon := range []float64{116.0001, 116.0002} {
+		if keep, _ := s.Keep(at(uint64(100*(i+1)), lon, 0)); keep {
+			t.Fatalf("step %d must be dropped", i)
+		}
+	}
+	if keep, trigger := s.Keep(at(300, 116.0003, 0)); !keep || trigger != TriggerDistance {
+		t.Fatalf("cumulative distance: keep=%v trigger=%q", keep, trigger)
+	}
+	// 乱序记录保留但不更新参考记录
+	if keep, trigger := s.Keep(at(250, 117.0, 0)); !keep || trigger != TriggerOutOfOrder {
+		t.Fatalf("out of order: keep=%v trigger=%q", keep, trigger)
+	}
+	if keep, _ := s.Keep(at(400, 116.0003, 0)); keep {
+		t.Fatal("reference must still be the last in-order kept record")
+	}
+
+	st := s.Stats()
+	if st.Checked != 6 || st.Dropped != 3 || st.Kept != 3 {
+		t.Fatalf("stats checked=%d kept=%d dropped=%d", st.Checked, st.Kept, st.Dropped)
+	}
+}
+
+func TestMinIntervalSuppressesMovementButNotFields(t *testing.T) {
+	s := newSampler(t, config.SamplingConfig{Default: config.SamplingPolicy{MinIntervalMs: 500}}, 0)
+	s.Keep(at(0, 116.0, 0))
+	if keep, _ := s.Keep(at(100, 116.01, 90)); keep {
+		t.Fatal("distance/heading within minInterval must be dropped")
+	}
+	d := at(200, 116.0, 0)
+	d.Doors = []int{1}
+	if keep, trigger := s.Keep(d); !keep || trigger != TriggerField {
+		t.Fatalf("watched field within minInterval: keep=%v trigger=%q", keep, trigger)
+	}
+	d = at(700, 116.01, 0)
+	d.Doors = []int{1}
+	if keep, trigger := s.Keep(d); !keep || trigger != TriggerDistance {
+		t.Fatalf("distance after minInterval: keep=%v trigger=%q", keep, trigger)
+	}
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	s := newSampler(t, config.SamplingConfig{
+		Default: config.SamplingPolicy{DistanceMeters: -1, WatchFields: []string{"-"}},
+		Categories: []config.SamplingPolicy{
+			{CategoryCode: 5, MaxIntervalMs: 5000},
+		},
+	}, 2000)
+
+	// 默认策略：maxInterval 取 fallback 2000ms，距离条件关闭，不关注字段
+	s.Keep(at(0, 116.0, 0))
+	d := at(1000, 117.0, 0)
+	d.AebFlag = 1
+	if keep, _ := s.Keep(d); keep {
+		t.Fatal("disabled distance and watch fields must not keep the record")
+	}
+	if keep, trigger := s.Keep(at(2000, 116.0, 0)); !keep || trigger != TriggerMaxInterval {
+		t.Fatalf("fallback max interval: keep=%v trigger=%q", keep, trigger)
+	}
+
+	// 车辆类型覆盖 maxInterval，其余条件沿用默认策略
+	c := func(offsetMs uint64) *types.VehicleStateData {
+		return &types.VehicleStateData{VehicleId: "C1", CategoryCode: 5, Timestamp: baseTs + offsetMs, Lon: 116.0, Lat: 39.0}
+	}
+	s.Keep(c(0))
+	if keep, _ := s.Keep(c(3000)); keep {
+		t.Fatal("category maxInterval 5000ms: record at 3000ms must be dropped")
+	}
+	if keep, trigger := s.Keep(c(5000)); !keep || trigger != TriggerMaxInterval {
+		t.Fatalf("category max interval: keep=%v trigger=%q", keep, trigger)
+	}
+}
+
+func TestNewRejectsUnknownWatchField(t *testing.T) {
+	if _, err := New(config.SamplingConfig{Default: config.SamplingPolicy{WatchFields: []string{"colour"}}}, 0); err == nil {
+		t.Fatal("unknown default watch field must be rejected")
+	}
+	if _, err := New(config.SamplingConfig{Categories: []config.SamplingPolicy{{CategoryCode: 1, WatchFields: []string{"colour"}}}}, 0); err == nil {
+		t.Fatal("unknown category watch field must be rejected")
+	}
+}
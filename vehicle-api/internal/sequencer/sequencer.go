@@ -0,0 +1,273 @@
+package sequencer
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+// 迟到数据处理方式
+const (
+	LateWriteOnly = "write_only" // 仅入库，不发布事件也不广播
+	LateBroadcast = "broadcast"  // 入库并广播（事件带 Late 标记，到达检测等消费者忽略）
+)
+
+// ErrDuplicate 表示该车已处理过相同时间戳的记录
+var ErrDuplicate = errors.New("sequencer: duplicate timestamp")
+
+// Record 为放行的记录；同一辆车的记录按放行顺序回调，非迟到记录的时间戳严格递增
+type Record struct {
+	State  *types.VehicleStateData
+	Urgent bool
+	Late   bool // 时间戳早于该车已放行的记录
+}
+
+type pending struct {
+	rec     Record
+	arrival time.Time
+}
+
+// vehicleSeq 单辆车的排序状态；mu 同时保证该车记录的回调不会并发、不会乱序
+type vehicleSeq struct {
+	mu       sync.Mutex
+	released uint64 // 已放行的最大时间戳
+	buf      []pending
+	seen     map[uint64]struct{}
+	history  []uint64 // seen 的插入顺序，超过 dedupHistory 时淘汰最早的
+}
+
+// Sequencer 按车辆对状态记录去重，并在重排窗口内按 Timestamp 排序后放行：
+// 记录到达后最多缓冲 ReorderWindowMs 等待更早的记录，超时、缓冲已满或收到高优先级记录时按时间戳顺序放行；
+// 时间戳早于已放行记录的迟到数据立即以 Late 标记放行，由调用方按 LatePolicy 决定是否广播。
+type Sequencer struct {
+	window       time.Duration
+	maxBuffered  int
+	dedupHistory int
+	latePolicy   string
+	emit         func(Record)
+
+	mu       sync.Mutex
+	vehicles map[string]*vehicleSeq
+
+	received   atomic.Uint64
+	duplicates atomic.Uint64
+	reordered  atomic.Uint64
+	late       atomic.Uint64
+	forced     atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New 创建排序器并启动窗口到期检查协程；emit 在持有该车锁时调用，不应再回调 Push
+func New(cfg config.SequencerConfig, emit func(Record)) *Sequencer {
+	if cfg.ReorderWindowMs == 0 {
+		cfg.ReorderWindowMs = 300
+	} else if cfg.ReorderWindowMs < 0 {
+		cfg.ReorderWindowMs = 0
+	}
+	if cfg.MaxBuffered <= 0 {
+		cfg.MaxBuffered = 64
+	}
+	if cfg.DedupHistory <= 0 {
+		cfg.DedupHistory = 256
+	}
+	if cfg.LatePolicy != LateBroadcast {
+		cfg.LatePolicy = LateWriteOnly
+	}
+	s := &Sequencer{
+		window:       time.Duration(cfg.ReorderWindowMs) * time.Millisecond,
+		maxBuffered:  cfg.MaxBuffered,
+		dedupHistory: cfg.DedupHistory,
+		latePolicy:   cfg.LatePolicy,
+		emit:         emit,
+		vehicles:     make(map[string]*vehicleSeq),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if s.window > 0 {
+		go s.run()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// LatePolicy 返回迟到数据的处理方式
+func (s *Sequencer) LatePolicy() string {
+	return s.latePolicy
+}
+
+// Push 提交一条记录：重复时间戳返回 ErrDuplicate；迟到记录立即放行；
+// 高优先级记录连同缓冲中更早的记录立即放行；其余记录进入重排窗口
+func (s *Sequencer) Push(d *types.VehicleStateData, urgent bool) error {
+	if d == nil {
+		return nil
+	}
+	s.received.Add(1)
+	v := s.vehicle(d.VehicleId)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, dup := v.seen[d.Timestamp]; dup {
+		s.duplicates.Add(1)
+		return ErrDuplicate
+	}
+	v.remember(d.Timestamp, s.dedupHistory)
+
+	rec := Record{State: d, Urgent: urgent}
+	if v.released != 0 && d.Timestamp < v.released {
+		s.late.Add(1)
+		rec.Late = true
+		s.emit(rec)
+		return nil
+	}
+	if s.window == 0 {
+		v.released = d.Timestamp
+		s.emit(rec)
+		return nil
+	}
+
+	// 按时间戳插入缓冲区；插入位置不在末尾说明该记录比已缓冲的记录更早到达（乱序）
+	i := sort.Search(len(v.buf), func(i int) bool { return v.buf[i].rec.State.Timestamp > d.Timestamp })
+	if i < len(v.buf) {
+		s.reordered.Add(1)
+	}
+	v.buf = append(v.buf, pending{})
+	copy(v.buf[i+1:], v.buf[i:])
+	v.buf[i] = pending{rec: rec, arrival: time.Now()}
+
+	switch {
+	case urgent:
+		s.releaseUpTo(v, d.Timestamp)
+	case len(v.buf) > s.maxBuffered:
+		s.forced.Add(1)
+		s.releaseUpTo(v, v.buf[0].rec.State.Timestamp)
+	}
+	return nil
+}
+
+// Stats 返回累计计数与当前缓冲情况
+func (s *Sequencer) Stats() *types.SequencerStatsResp {
+	resp := &types.SequencerStatsResp{
+		ReorderWindowMs: s.window.Milliseconds(),
+		LatePolicy:      s.latePolicy,
+		Received:        s.received.Load(),
+		Duplicates:      s.duplicates.Load(),
+		Reordered:       s.reordered.Load(),
+		Late:            s.late.Load(),
+		Forced:          s.forced.Load(),
+	}
+	for _, v := range s.snapshot() {
+		v.mu.Lock()
+		resp.Buffered += len(v.buf)
+		v.mu.Unlock()
+		resp.Vehicles++
+	}
+	return resp
+}
+
+// Close 停止到期检查并放行所有缓冲中的记录
+func (s *Sequencer) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	for _, v := range s.snapshot() {
+		v.mu.Lock()
+		if n := len(v.buf); n > 0 {
+			s.releaseUpTo(v, v.buf[n-1].rec.State.Timestamp)
+		}
+		v.mu.Unlock()
+	}
+}
+
+func (s *Sequencer) vehicle(vehicleId string) *vehicleSeq {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.vehicles[vehicleId]
+	if !ok {
+		v = &vehicleSeq{seen: make(map[uint64]struct{})}
+		s.vehicles[vehicleId] = v
+	}
+	return v
+}
+
+func (s *Sequencer) snapshot() []*vehicleSeq {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*vehicleSeq, 0, len(s.vehicles))
+	for _, v := range s.vehicles {
+		out = append(out, v)
+	}
+	return out
+}
+
+// run 定期放行窗口已到期的记录
+func (s *Sequencer) run() {
+	defer close(s.done)
+	interval := s.window / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			for _, v := range s.snapshot() {
+				v.mu.Lock()
+				s.releaseExpired(v, now)
+				v.mu.Unlock()
+			}
+		}
+	}
+}
+
+// releaseExpired 放行时间戳不晚于任一已到期记录的全部记录（调用方持有 v.mu）：
+// 晚到达但时间戳更小的记录即使未到期也要先于已到期的记录放行，以保证顺序
+func (s *Sequencer) releaseExpired(v *vehicleSeq, now time.Time) {
+	var upTo uint64
+	expired := false
+	for _, p := range v.buf {
+		if now.Sub(p.arrival) >= s.window {
+			upTo = p.rec.State.Timestamp
+			expired = true
+		}
+	}
+	if expired {
+		s.releaseUpTo(v, upTo)
+	}
+}
+
+// releaseUpTo 按顺序放行缓冲中时间戳不晚于 ts 的记录（调用方持有 v.mu）
+func (s *Sequencer) releaseUpTo(v *vehicleSeq, ts uint64) {
+	n := 0
+	for n < len(v.buf) && v.buf[n].rec.State.Timestamp <= ts {
+		rec := v.buf[n].rec
+		v.released = rec.State.Timestamp
+		s.emit(rec)
+		n++
+	}
+	v.buf = append(v.buf[:0], v.buf[n:]...)
+}
+
+// remember 记录已见过的时间戳（调用方持有 v.mu）
+func (v *vehicleSeq) remember(ts uint64, keep int) {
+	v.seen[ts] = struct{}{}
+	v.history = append(v.history, ts)
+	if len(v.history) > keep {
+		delete(v.seen, v.history[0])
+		v.history = v.history[1:]
+	}
+}
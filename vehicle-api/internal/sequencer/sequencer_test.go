@@ -0,0 +1,209 @@
+package sequencer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+// collector 记录放行顺序（emit 可能在到期检查协程中调用）
+type collector struct {
+	mu   sync.Mutex
+	recs []Record
+}
+
+func (c *collector) emit(r Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recs = append(c.recs, r)
+}
+
+func (c *collector) timestamps() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]uint64, len(c.recs))
+	for i, r := range c.recs {
+		out[i] = r.State.Timestamp
+	}
+	return out
+}
+
+func (c *collector) last() Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recs[len(c.recs)-1]
+}
+
+func state(ts uint64) *types.VehicleStateData {
+	return &types.VehicleStateData{VehicleId: "V1", Timestamp: ts}
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 足够长的窗口，只由 Close、高优先级记录或缓冲上限触发放行
+const longWindowMs = 60000
+
+func TestDuplicateTimestamp(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: -1}, c.emit)
+	defer s.Close()
+	if err := s.Push(state(100), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(state(100), false); err != ErrDuplicate {
+		t.Fatalf("err = %v, want ErrDuplicate", err)
+	}
+	// 其它车辆相同时间戳不算重复
+	if err := s.Push(&types.VehicleStateData{VehicleId: "V2", Timestamp: 100}, false); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Stats(); st.Duplicates != 1 || st.Received != 3 || st.Vehicles != 2 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestDedupHistoryEvictsOldest(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: -1, DedupHistory: 2}, c.emit)
+	defer s.Close()
+	for _, ts := range []uint64{1, 2, 3} {
+		if err := s.Push(state(ts), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1 已被淘汰，不再判为重复，而是作为迟到记录放行
+	if err := s.Push(state(1), false); err != nil {
+		t.Fatalf("evicted timestamp: err = %v", err)
+	}
+	if !c.last().Late {
+		t.Fatal("evicted timestamp must be emitted as late")
+	}
+	if err := s.Push(state(3), false); err != ErrDuplicate {
+		t.Fatalf("recent timestamp: err = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestReorderWithinWindow(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: longWindowMs}, c.emit)
+	for _, ts := range []uint64{30, 10, 20} {
+		if err := s.Push(state(ts), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.timestamps(); len(got) != 0 {
+		t.Fatalf("records released before the window expired: %v", got)
+	}
+	s.Close()
+	if got := c.timestamps(); !equal(got, []uint64{10, 20, 30}) {
+		t.Fatalf("released %v, want [10 20 30]", got)
+	}
+	if st := s.Stats(); st.Reordered != 2 || st.Buffered != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestWindowExpiry(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: 20}, c.emit)
+	defer s.Close()
+	_ = s.Push(state(2), false)
+	_ = s.Push(state(1), false)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.timestamps()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("window did not expire, released %v", c.timestamps())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := c.timestamps(); !equal(got, []uint64{1, 2}) {
+		t.Fatalf("released %v, want [1 2]", got)
+	}
+}
+
+func TestUrgentReleasesEarlierRecords(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: longWindowMs}, c.emit)
+	defer s.Close()
+	_ = s.Push(state(20), false)
+	_ = s.Push(state(10), false)
+	_ = s.Push(state(40), false)
+	_ = s.Push(state(30), true)
+	// 高优先级记录连同更早的记录立即放行，更晚的记录仍在窗口中
+	if got := c.timestamps(); !equal(got, []uint64{10, 20, 30}) {
+		t.Fatalf("released %v, want [10 20 30]", got)
+	}
+	if !c.last().Urgent {
+		t.Fatal("urgent flag lost")
+	}
+	if st := s.Stats(); st.Buffered != 1 {
+		t.Fatalf("buffered = %d, want 1", st.Buffered)
+	}
+}
+
+func TestMaxBufferedForcesRelease(t *testing.T) {
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: longWindowMs, MaxBuffered: 2}, c.emit)
+	defer s.Close()
+	for _, ts := range []uint64{3, 1, 2} {
+		_ = s.Push(state(ts), false)
+	}
+	if got := c.timestamps(); !equal(got, []uint64{1}) {
+		t.Fatalf("released %v, want [1]", got)
+	}
+	if st := s.Stats(); st.Forced != 1 || st.Buffered != 2 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestLatePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy, want string
+	}{
+		{"", LateWriteOnly},
+		{"drop", LateWriteOnly},
+		{LateBroadcast, LateBroadcast},
+	} {
+		s := New(config.SequencerConfig{ReorderWindowMs: -1, LatePolicy: tc.policy}, func(Record) {})
+		if got := s.LatePolicy(); got != tc.want {
+			t.Errorf("LatePolicy(%q) = %q, want %q", tc.policy, got, tc.want)
+		}
+		s.Close()
+	}
+
+	c := &collector{}
+	s := New(config.SequencerConfig{ReorderWindowMs: longWindowMs, LatePolicy: LateBroadcast}, c.emit)
+	defer s.Close()
+	_ = s.Push(state(50), true)
+	// 早于已放行记录的迟到数据不进入窗口，立即带 Late 标记放行
+	if err := s.Push(state(40), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.timestamps(); !equal(got, []uint64{50, 40}) {
+		t.Fatalf("released %v, want [50 40]", got)
+	}
+	if r := c.last(); !r.Late {
+		t.Fatal("late record must be emitted with Late")
+	}
+	// 迟到记录不改变已放行的最大时间戳：之后的正常记录不是迟到
+	_ = s.Push(state(60), true)
+	if r := c.last(); r.Late || r.State.Timestamp != 60 {
+		t.Fatalf("record after late data: %+v", r)
+	}
+	if st := s.Stats(); st.Late != 1 {
+		t.Fatalf("late = %d, want 1", st.Late)
+	}
+}
@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/types"
+)
+
+// FileSink 把车辆状态按行写入 NDJSON 文件：按天或达到大小上限时滚动到新文件，超过保留个数时删除最早的文件。
+// 文件名为 <prefix>-<yyyymmdd>-<序号>.ndjson。每批一次写入，写入失败时整批重试可能产生重复行。
+type FileSink struct {
+	dir      string
+	prefix   string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	day  string
+	seq  int
+	size int64
+}
+
+// NewFileSink 创建文件 Sink；maxMB<=0 时默认 64，maxFiles<=0 表示不删除旧文件
+func NewFileSink(dir, prefix string, maxMB, maxFiles int) (*FileSink, error) {
+	if prefix == "" {
+		prefix = "vehicle-state"
+	}
+	if maxMB <= 0 {
+		maxMB = 64
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSink{dir: dir, prefix: prefix, maxBytes: int64(maxMB) << 20, maxFiles: maxFiles}, nil
+}
+
+func (s *FileSink) Name() string { return NameFile }
+
+func (s *FileSink) Write(_ context.Context, batch []*types.VehicleStateData) error {
+	var buf bytes.Buffer
+	for _, d := range batch {
+		if d == nil {
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := time.Now().Format("20060102")
+	if s.f == nil || day != s.day || (s.size > 0 && s.size+int64(buf.Len()) > s.maxBytes) {
+		if err := s.roll(day); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(buf.Bytes())
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// roll 关闭当前文件并打开新文件（调用方持有 s.mu）；重启后同一天继续使用新的序号，不覆盖已有文件
+func (s *FileSink) roll(day string) error {
+	if s.f != nil {
+		_ = s.f.Close()
+		s.f = nil
+	}
+	if day != s.day {
+		s.day = day
+		s.seq = s.lastSeq(day)
+	}
+	s.seq++
+	path := filepath.Join(s.dir, fmt.Sprintf("%s-%s-%06d.ndjson", s.prefix, day, s.seq))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.size = 0
+	s.prune()
+	return nil
+}
+
+// lastSeq 返回目录中该日期已存在的最大序号
+func (s *FileSink) lastSeq(day string) int {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, s.prefix+"-"+day+"-*.ndjson"))
+	max := 0
+	for _, m := range matches {
+		var seq int
+		name := strings.TrimSuffix(filepath.Base(m), ".ndjson")
+		if _, err := fmt.Sscanf(name[strings.LastIndex(name, "-")+1:], "%d", &seq); err == nil && seq > max {
+			max = seq
+		}
+	}
+	return max
+}
+
+// prune 删除超过保留个数的最早文件（文件名按日期与序号排序）
+func (s *FileSink) prune() {
+	if s.maxFiles <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(s.dir, s.prefix+"-*.ndjson"))
+	if err != nil || len(matches) <= s.maxFiles {
+		return
+	}
+	sort.Strings(matches)
+	for _, m := range matches[:len(matches)-s.maxFiles] {
+		if err := os.Remove(m); err != nil {
+			logx.Errorf("删除过期 NDJSON 文件失败 file=%s err=%v", m, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	influxhttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/dao"
+	"vehicle-api/internal/types"
+)
+
+// InfluxSink 把车辆状态同步写入 Influx 的 vehicle_status measurement；
+// 同一车辆同一时间戳的点会被覆盖，重复写入是幂等的
+type InfluxSink struct {
+	dao *dao.InfluxDao
+}
+
+func NewInfluxSink(d *dao.InfluxDao) *InfluxSink {
+	return &InfluxSink{dao: d}
+}
+
+func (s *InfluxSink) Name() string { return NameInflux }
+
+func (s *InfluxSink) Write(ctx context.Context, batch []*types.VehicleStateData) error {
+	points := make([]*write.Point, 0, len(batch))
+	for _, d := range batch {
+		if d == nil {
+			continue
+		}
+		pt, err := s.dao.BuildPoint(d)
+		if err != nil {
+			logx.Errorf("构建 Influx 数据点失败，vehicleId=%s err=%v", d.VehicleId, err)
+			continue
+		}
+		points = append(points, pt)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	err := s.dao.WritePoints(ctx, points...)
+	// 400（行协议/字段类型冲突）、413（请求过大）、422（超出保留策略等）是数据本身的问题，重试不会成功；
+	// 401/403/404 等配置问题修正后可以写入，仍按可重试处理
+	var he *influxhttp.Error
+	if errors.As(err, &he) {
+		switch he.StatusCode {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+			return Permanent(err)
+		}
+	}
+	return err
+}
+
+// Close 不关闭 Influx 客户端（由 ServiceContext 统一关闭）
+func (s *InfluxSink) Close() error { return nil }
@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"vehicle-api/internal/dao"
+	"vehicle-api/internal/types"
+)
+
+// MySQLSink 把车辆状态写入 vehicle_records 表：经纬度按 1e-7 度、车速按 0.01m/s 存为整数（同二进制协议单位），
+// 完整状态以 JSON 存入 extra。依赖唯一索引 uq_vehicle_time(vehicle_id, timestamp) 与 INSERT IGNORE 保证重复写入幂等；
+// timestamp 列为毫秒精度，与上报时间戳一致，只有同一车辆同一毫秒的重复记录被忽略。
+type MySQLSink struct {
+	dao *dao.MySQLDao
+}
+
+func NewMySQLSink(d *dao.MySQLDao) *MySQLSink {
+	return &MySQLSink{dao: d}
+}
+
+func (s *MySQLSink) Name() string { return NameMySQL }
+
+func (s *MySQLSink) Write(ctx context.Context, batch []*types.VehicleStateData) error {
+	records := make([]dao.VehicleRecord, 0, len(batch))
+	for _, d := range batch {
+		if d == nil {
+			continue
+		}
+		extra, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		records = append(records, dao.VehicleRecord{
+			VehicleId: d.VehicleId,
+			Timestamp: time.UnixMilli(int64(d.Timestamp)),
+			Lon:       int64(math.Round(d.Lon * 1e7)),
+			Lat:       int64(math.Round(d.Lat * 1e7)),
+			Velocity:  int(math.Round(d.Speed * 100)),
+			Extra:     extra,
+		})
+	}
+	return classifyMySQLError(s.dao.BatchInsertRecords(ctx, records))
+}
+
+// classifyMySQLError 把数据本身不合法导致的 MySQL 错误标记为不可重试，连接、死锁、超时等其它错误保持可重试
+func classifyMySQLError(err error) error {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && permanentMySQLErrors[me.Number] {
+		return Permanent(err)
+	}
+	return err
+}
+
+// permanentMySQLErrors 数据本身不合法导致的错误，重试不会成功
+var permanentMySQLErrors = map[uint16]bool{
+	1048: true, // ER_BAD_NULL_ERROR
+	1264: true, // ER_WARN_DATA_OUT_OF_RANGE
+	1265: true, // WARN_DATA_TRUNCATED
+	1292: true, // ER_TRUNCATED_WRONG_VALUE
+	1366: true, // ER_TRUNCATED_WRONG_VALUE_FOR_FIELD
+	1406: true, // ER_DATA_TOO_LONG
+	3140: true, // ER_INVALID_JSON_TEXT
+}
+
+// Close 不关闭数据库连接（由 ServiceContext 统一关闭）
+func (s *MySQLSink) Close() error { return nil }
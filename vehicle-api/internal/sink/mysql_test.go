@@ -0,0 +1,34 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyMySQLError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{"nil", nil, false},
+		{"data too long", &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'vehicle_id'"}, true},
+		{"invalid json", fmt.Errorf("batch insert: %w", &mysql.MySQLError{Number: 3140}), true},
+		{"deadlock", &mysql.MySQLError{Number: 1213}, false},
+		{"connection refused", errors.New("dial tcp 127.0.0.1:3306: connect: connection refused"), false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+	for _, tc := range cases {
+		got := classifyMySQLError(tc.err)
+		if IsPermanent(got) != tc.permanent {
+			t.Errorf("%s: permanent = %v, want %v", tc.name, IsPermanent(got), tc.permanent)
+		}
+		if !errors.Is(got, tc.err) {
+			t.Errorf("%s: classified error %v does not wrap %v", tc.name, got, tc.err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vehicle-api/internal/types"
+)
+
+// 内置 Sink 名称
+const (
+	NameInflux = "influx"
+	NameMySQL  = "mysql"
+	NameFile   = "file"
+)
+
+// ErrPermanent 表示批次被写入目标拒绝（如数据格式错误），重试与 WAL 重放都不会成功
+var ErrPermanent = errors.New("sink: batch rejected")
+
+// Permanent 把写入错误标记为不可重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// IsPermanent 判断写入错误是否不可重试
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// Sink 为 Processor 批次的写入目标。Write 可能被多个协程并发调用（并发数由 Processor 按 Sink 配置），
+// 返回 error 表示整批失败，由 Processor 按该 Sink 的重试策略重新调用，因此实现应保证重复写入同一批次是幂等的；
+// 写入目标明确拒绝该批数据（重试不会成功）时用 Permanent 包装错误，Processor 把该批移入隔离区而不再重试。
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, batch []*types.VehicleStateData) error
+	Close() error
+}
@@ -0,0 +1,125 @@
+package statestore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/types"
+)
+
+// Store 在内存中保存每辆车的最新状态（订阅事件总线上的车辆状态事件），用于 websocket 连接建立时下发全量快照。
+// 超过 maxAge 未收到新状态的车辆（已下线、已退役或车辆编号变更）不出现在快照中，并定期从存储中移除
+type Store struct {
+	mu     sync.RWMutex
+	states map[string]*entry
+	sub    *eventbus.Subscription
+	maxAge time.Duration
+	stop   chan struct{}
+}
+
+type entry struct {
+	state *types.VehicleStateData
+	seen  time.Time // 收到该状态的本地时间，按此判断是否过期（不受车端时钟偏差影响）
+}
+
+// New 创建并订阅事件总线；迟到状态与回放状态不更新存储。maxAge <= 0 时不过期
+func New(bus *eventbus.Bus, maxAge time.Duration) *Store {
+	s := &Store{states: make(map[string]*entry), maxAge: maxAge, stop: make(chan struct{})}
+	s.sub = bus.Subscribe("state-store", eventbus.Filter{Types: []string{eventbus.EventVehicleState}}, eventbus.SubscribeOptions{})
+	go func() {
+		for ev := range s.sub.C() {
+			if ev.State == nil || ev.Late || ev.Replay {
+				continue
+			}
+			s.Update(ev.State)
+		}
+		logx.Infof("事件订阅已关闭，车辆状态存储停止更新")
+	}()
+	if maxAge > 0 {
+		go s.expireLoop()
+	}
+	return s
+}
+
+// Update 保存车辆状态，早于已保存状态的数据忽略
+func (s *Store) Update(d *types.VehicleStateData) {
+	if d == nil || d.VehicleId == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.states[d.VehicleId]; ok && d.Timestamp < old.state.Timestamp {
+		return
+	}
+	s.states[d.VehicleId] = &entry{state: d, seen: time.Now()}
+}
+
+// Get 返回单辆车的最新状态（包括已过期但尚未移除的状态）
+func (s *Store) Get(vehicleId string) (*types.VehicleStateData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.states[vehicleId]
+	if !ok {
+		return nil, false
+	}
+	return e.state, true
+}
+
+// Snapshot 返回未过期车辆的最新状态，按 vehicleId 排序；返回的状态不可修改
+func (s *Store) Snapshot() []*types.VehicleStateData {
+	now := time.Now()
+	s.mu.RLock()
+	out := make([]*types.VehicleStateData, 0, len(s.states))
+	for _, e := range s.states {
+		if s.expired(e, now) {
+			continue
+		}
+		out = append(out, e.state)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleId < out[j].VehicleId })
+	return out
+}
+
+// Close 取消订阅并停止过期清理
+func (s *Store) Close() {
+	s.sub.Close()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+func (s *Store) expired(e *entry, now time.Time) bool {
+	return s.maxAge > 0 && now.Sub(e.seen) > s.maxAge
+}
+
+// expireLoop 每隔 maxAge/2 移除过期车辆，避免存储随车辆编号的变化无限增长
+func (s *Store) expireLoop() {
+	ticker := time.NewTicker(s.maxAge / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			removed := 0
+			for id, e := range s.states {
+				if s.expired(e, now) {
+					delete(s.states, id)
+					removed++
+				}
+			}
+			s.mu.Unlock()
+			if removed > 0 {
+				logx.Infof("车辆状态存储移除 %d 辆超过 %s 未更新的车辆", removed, s.maxAge)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package statestore
+
+import (
+	"testing"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/types"
+)
+
+func TestSkipsReplayAndLateStates(t *testing.T) {
+	bus := eventbus.NewBus(config.EventBusConfig{})
+	defer bus.Close()
+	s := New(bus, 0)
+	defer s.Close()
+
+	bus.Publish(&eventbus.Event{Type: eventbus.EventVehicleState, VehicleId: "V1", State: &types.VehicleStateData{VehicleId: "V1", Timestamp: 1}, Replay: true})
+	bus.Publish(&eventbus.Event{Type: eventbus.EventVehicleState, VehicleId: "V1", State: &types.VehicleStateData{VehicleId: "V1", Timestamp: 2}, Late: true})
+	bus.PublishState(&types.VehicleStateData{VehicleId: "V2", Timestamp: 3})
+
+	// 同一订阅按发布顺序投递，V2 写入后之前的事件均已处理
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := s.Get("V2"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("live state was not stored")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if d, ok := s.Get("V1"); ok {
+		t.Fatalf("replay/late state overwrote the store: %+v", d)
+	}
+}
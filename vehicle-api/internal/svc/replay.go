@@ -0,0 +1,161 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/recorder"
+	"vehicle-api/internal/sampler"
+	"vehicle-api/internal/sequencer"
+	"vehicle-api/internal/sink"
+	"vehicle-api/internal/types"
+	"vehicle-api/internal/validator"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// replaySession 一次回放使用的独立处理链路：
+//  1. 车辆状态的时间戳整体平移到回放开始时刻（保持录制中的间隔），否则录制数据相对已发布的状态都是迟到数据，不会到达地图与到达检测；
+//  2. 使用独立的校验器、降频器与排序器，回放不改变线上车辆的降频参考、排序窗口与跳点判断；
+//  3. 发布到事件总线的状态与告警带 Replay 标记：websocket 以 replay_positions/replay_alarms 类型下发，
+//     最新状态、任务到达检测与派单转发忽略回放事件，线上任务与地图不受影响；
+//     不写入生产 Sink 与隔离 measurement，配置了 Recorder.replaySinkDir 时另写入该目录的 NDJSON 文件；
+//  4. 录制中的指令应答与心跳不回放，避免改写线上指令状态与在线时间。
+type replaySession struct {
+	sc        *ServiceContext
+	validator *validator.Validator
+	sampler   *sampler.Sampler
+	seq       *sequencer.Sequencer
+	file      *sink.FileSink
+	offset    int64 // 时间戳平移量（毫秒），由第一条回放消息确定
+}
+
+// newReplaySession 为一次回放创建处理链路；校验、降频与排序沿用顶层配置（启动时已校验过）
+func (sc *ServiceContext) newReplaySession() recorder.Session {
+	c := sc.Config
+	r := &replaySession{sc: sc}
+	if !c.Validation.Disabled {
+		if v, err := validator.New(c.Validation); err == nil {
+			r.validator = v
+		}
+	}
+	if !c.Sampling.Disabled {
+		if smp, err := sampler.New(c.Sampling, c.VEHState.SampleIntervalMs); err == nil {
+			r.sampler = smp
+		}
+	}
+	if !c.Sequencer.Disabled {
+		r.seq = sequencer.New(c.Sequencer, r.emit)
+	}
+	if c.Recorder.ReplaySinkDir != "" {
+		fs, err := sink.NewFileSink(c.Recorder.ReplaySinkDir, "replay", 0, 0)
+		if err != nil {
+			logx.Errorf("回放文件 Sink 创建失败，回放数据不落盘 dir=%s err=%v", c.Recorder.ReplaySinkDir, err)
+		} else {
+			r.file = fs
+		}
+	}
+	return r
+}
+
+// Emit 把一条录制的原始消息按对应上游的格式解析后交给回放链路
+func (r *replaySession) Emit(e *recorder.Entry) error {
+	if r.offset == 0 {
+		r.offset = time.Now().UnixMilli() - e.Time
+	}
+	sc := r.sc
+	switch e.Kind {
+	case recorder.KindVEHState:
+		if sc.Upstreams == nil {
+			return errors.New("VEHState 未启用")
+		}
+		data, err := sc.Upstreams.Decode(e.Source, e.Payload())
+		if err != nil || data == nil {
+			return err
+		}
+		r.handle(data)
+		return nil
+	case recorder.KindMQTT:
+		if sc.MQTTSubscriber == nil {
+			return errors.New("MQTT 未启用")
+		}
+		states, others, err := sc.MQTTSubscriber.DecodeTopic(e.Topic, e.Payload())
+		if err != nil {
+			return err
+		}
+		for _, d := range states {
+			r.handle(d)
+		}
+		for _, msg := range others {
+			if msg.Kind == protocol.MessageKindAlarm && msg.Alarm != nil {
+				msg.Alarm.Timestamp = r.rebase(msg.Alarm.Timestamp)
+				sc.publishAlarm(msg.Alarm, true)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown recording kind %q", e.Kind)
+}
+
+// Close 放行排序器中缓冲的记录并关闭回放文件
+func (r *replaySession) Close() {
+	if r.seq != nil {
+		r.seq.Close()
+	}
+	if r.file != nil {
+		_ = r.file.Close()
+	}
+}
+
+func (r *replaySession) rebase(ts uint64) uint64 {
+	if ts == 0 {
+		return 0
+	}
+	return uint64(int64(ts) + r.offset)
+}
+
+// handle 同 handleSourceState 的顺序：登记 → 校验 → 降频 → 排序，但只读取登记状态、不记录未登记上报
+func (r *replaySession) handle(data *types.VehicleStateData) {
+	if data == nil || data.VehicleId == "" {
+		return
+	}
+	data.Timestamp = r.rebase(data.Timestamp)
+	if reg := r.sc.Registry; reg != nil && reg.Blocked(data.VehicleId) {
+		return
+	}
+	if r.validator != nil {
+		if v := r.validator.Check(data); v != nil {
+			logx.Infof("回放车辆状态未通过校验 vehicleId=%s rule=%s reason=%s", data.VehicleId, v.Rule, v.Reason)
+			return
+		}
+	}
+	if r.sampler != nil {
+		if keep, _ := r.sampler.Keep(data); !keep {
+			return
+		}
+	}
+	if r.seq == nil {
+		r.emit(sequencer.Record{State: data})
+		return
+	}
+	if err := r.seq.Push(data, false); err != nil && err != sequencer.ErrDuplicate {
+		logx.Errorf("回放车辆状态排序失败 vehicleId=%s err=%v", data.VehicleId, err)
+	}
+}
+
+// emit 以 Replay 标记发布排序放行的回放记录；迟到记录按线上的 late 策略处理（broadcast 时另带 Late 标记发布，否则丢弃）
+func (r *replaySession) emit(rec sequencer.Record) {
+	data := rec.State
+	if bus := r.sc.EventBus; bus != nil && (!rec.Late || r.seq.LatePolicy() == sequencer.LateBroadcast) {
+		bus.Publish(&eventbus.Event{Type: eventbus.EventVehicleState, VehicleId: data.VehicleId, State: data, Late: rec.Late, Replay: true})
+	}
+	if r.file != nil {
+		if err := r.file.Write(context.Background(), []*types.VehicleStateData{data}); err != nil {
+			logx.Errorf("回放数据写入文件失败 vehicleId=%s err=%v", data.VehicleId, err)
+		}
+	}
+}
@@ -10,34 +10,75 @@ import (
 	"vehicle-api/internal/apiclient"
 	"vehicle-api/internal/config"
 	"vehicle-api/internal/dao"
+	"vehicle-api/internal/downlink"
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/middleware"
+	"vehicle-api/internal/mqtt"
 	"vehicle-api/internal/processor"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/recorder"
+	"vehicle-api/internal/registry"
+	"vehicle-api/internal/sampler"
+	"vehicle-api/internal/sequencer"
+	"vehicle-api/internal/signature"
+	"vehicle-api/internal/sink"
+	"vehicle-api/internal/statestore"
+	"vehicle-api/internal/tcpserver"
 	"vehicle-api/internal/types"
+	"vehicle-api/internal/upstream"
+	"vehicle-api/internal/validator"
+	"vehicle-api/internal/wal"
 	"vehicle-api/internal/websocket"
 
 	_ "github.com/go-sql-driver/mysql"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest"
 )
 
 type ServiceContext struct {
 	Config               config.Config
 	WSHub                *websocket.Hub
+	WSAuth               *websocket.Authenticator // websocket 握手的来源白名单与令牌校验
+	AdminAuth            rest.Middleware          // 运维接口的管理令牌校验
 	Dao                  *dao.InfluxDao
 	Processor            *processor.Processor
 	MySQLDB              *sql.DB
 	MySQLDao             *dao.MySQLDao
-	VEHInfoClient        *apiclient.VEHInfoClient
-	VEHStateClient       *apiclient.VEHStateClient
-	OnlineDrones         sync.Map                     // key: uasID, value: time.Time
-	VehicleLastProcessed sync.Map                     // 跟踪每辆车上一次被处理的时间戳（毫秒）, 进行反向降频, key: vehicleId string, value: int64 (unix ms)
-	vehInfoStop          chan struct{}                // vehInfoStop 用于停止定时拉取车辆信息的后台协程
-	VehicleEventChan     chan *types.VehicleStateData // VehicleEventChan 用于接收来自 VEHState 客户端（或外部平台）的车辆状态事件
-	TaskMonitor          *TaskMonitor                 // 任务监控器：用于根据车辆位置生成 task 级别的到达事件并推送给 orders
+	Platform             *apiclient.PlatformClient // 外部平台签名 HTTP 接口（车辆信息/位置/轨迹/行程），统一重试、熔断与限流
+	Recorder             *recorder.Recorder        // 上游原始消息录制（可选）
+	Replayer             *recorder.Player          // 录制文件回放：按原速/倍速解析原始消息，经独立的回放链路发布（不写生产 Sink）
+	Upstreams            *upstream.Manager         // 上游 VEHState 来源（可多个区域平台），各自独立连接、凭证、降频与车型过滤
+	MQTTSubscriber       *mqtt.Subscriber          // 车辆 MQTT 遥测订阅（可选）
+	TCPServer            *tcpserver.Server         // 二进制协议 TCP 接入服务（仅支持该协议的车辆直连上报）
+	Downlink             *downlink.Manager         // 下行指令管理：经 TCP 直连或 VEHState 上游投递并跟踪车端应答
+	Registry             *registry.Registry        // 未登记车辆识别：按 vehicle_list 缓存记录未登记车辆的上报，拒绝已拉黑车辆的数据
+	Validator            *validator.Validator      // 车辆状态校验：范围/相邻点速度/时间戳偏差，未通过的写入隔离 measurement
+	Sequencer            *sequencer.Sequencer      // 按车辆去重并在重排窗口内按时间戳排序，迟到数据按 Sequencer.latePolicy 处理
+	PushVerifier         *signature.Verifier       // HTTP 推送接入的签名与防重放校验
+	OnlineDrones         sync.Map                  // key: uasID, value: time.Time
+	VehicleLastHeartbeat sync.Map                  // 二进制协议车辆最近一次心跳时间, key: vehicleId string, value: time.Time
+	Sampler              *sampler.Sampler          // 上游状态的变化感知降频（距离/航向/关注字段变化/最大间隔，可按车辆类型配置）
+	vehInfoStop          chan struct{}             // vehInfoStop 用于停止定时拉取车辆信息的后台协程
+	EventBus             *eventbus.Bus             // 车辆事件总线：VEHState/TCP/HTTP 推送的状态与告警发布到此，各消费者独立订阅
+	TaskMonitor          *TaskMonitor              // 任务监控器：用于根据车辆位置生成 task 级别的到达事件并推送给 orders
+	StateStore           *statestore.Store         // 每辆车的最新状态，websocket 连接建立时下发快照
 }
 
 func NewServiceContext(c config.Config) *ServiceContext {
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(c.WebSocket)
 	go hub.Run()
+	wsAuth, err := websocket.NewAuthenticator(c.WSAuth)
+	if err != nil {
+		panic("WSAuth config error: " + err.Error())
+	}
+	if !wsAuth.AuthEnabled() {
+		logx.Errorf("警告：未配置 WSAuth.tokens / WSAuth.jwtSecret，/api/vehicle/ws 不校验令牌，任何人都可以接收全部车辆的位置与告警；带 serviceId 的连接将被拒绝")
+	}
+	adminAuth := middleware.NewAdminAuthMiddleware(c.AdminAuth.Tokens)
+	if !adminAuth.Enabled() {
+		logx.Errorf("警告：未配置 AdminAuth.tokens，运维接口（下发车辆指令、websocket 客户端管理、上游启停、回放、未登记车辆审核）将拒绝全部请求")
+	}
 	URL := "http://" + c.InfluxDBConfig.Host + ":" + c.InfluxDBConfig.Port
 	options := influxdb2.DefaultOptions().
 		SetBatchSize(c.InfluxDBConfig.BatchSize).               // 批量大小
@@ -45,59 +86,93 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		// SetPrecision(time.Second)
 	client := influxdb2.NewClientWithOptions(URL, c.InfluxDBConfig.Token, options)
 
-	_, err := client.Ping(context.Background())
+	_, err = client.Ping(context.Background())
 	if err != nil {
 		panic("InfluxDB connect error: " + err.Error())
 	}
 	ctx := &ServiceContext{
-		Config: c,
-		WSHub:  hub,
-		Dao:    dao.NewInfluxDao(client, c.InfluxDBConfig.Org, c.InfluxDBConfig.Bucket),
+		Config:    c,
+		WSHub:     hub,
+		WSAuth:    wsAuth,
+		AdminAuth: adminAuth.Handle,
+		Dao:       dao.NewInfluxDao(client, c.InfluxDBConfig.Org, c.InfluxDBConfig.Bucket),
 	}
 
-	// 初始化车辆事件通道，用于把外部平台或 VEHState 客户端的状态事件分发到内部消费者
-	ctx.VehicleEventChan = make(chan *types.VehicleStateData, 1024)
+	// 降频策略：相对每辆车上一条保留的记录，关注字段变化、移动距离/航向变化超过阈值或超过最大间隔时保留，
+	// 避免高频推送（例如 10Hz）导致后端阻塞，同时不丢失开门、AEB 触发、转弯等变化。
+	// 最大间隔默认取 VEHState.SampleIntervalMs（未配置时 1000ms），阈值可按车辆类型覆盖。
+	if !c.Sampling.Disabled {
+		smp, err := sampler.New(c.Sampling, c.VEHState.SampleIntervalMs)
+		if err != nil {
+			panic("Sampling config error: " + err.Error())
+		}
+		ctx.Sampler = smp
+	}
 
-	// 启动事件分发器：从 VehicleEventChan 读取事件并广播到 websocket 客户端
+	// 初始化车辆事件总线，用于把外部平台或 VEHState 客户端的状态事件分发到内部消费者（每个消费者都能收到全部订阅事件）
+	ctx.EventBus = eventbus.NewBus(c.EventBus)
+
+	// 启动事件分发器：订阅车辆状态事件并广播到 websocket 客户端
+	wsSub := ctx.EventBus.Subscribe("ws-broadcast", eventbus.Filter{Types: []string{eventbus.EventVehicleState}}, eventbus.SubscribeOptions{})
 	go func() {
-		for ev := range ctx.VehicleEventChan {
-			if ev == nil {
+		for ev := range wsSub.C() {
+			if ev.State == nil {
 				continue
 			}
-			b, err := json.Marshal(ev)
+			// 迟到数据带上 late 标记，前端据此不回退车辆位置
+			var v interface{} = ev.State
+			if ev.Late {
+				v = struct {
+					*types.VehicleStateData
+					Late bool `json:"late"`
+				}{ev.State, true}
+			}
+			b, err := json.Marshal(v)
 			if err != nil {
 				logx.Errorf("marshal vehicle event failed: %v", err)
 				continue
 			}
-			// 广播到所有 websocket 客户端
-			if ctx.WSHub != nil {
-				select {
-				case ctx.WSHub.Broadcast <- b:
-				default:
-					// 如果 Broadcast 通道阻塞则进行定向广播，避免阻塞调度
-					ctx.WSHub.BroadcastToService("orders", b)
-				}
+			// 交给 hub 按订阅（车辆、车型、地图范围）路由到 websocket 客户端；回放状态走单独的消息类型，不覆盖实时位置
+			typ := websocket.TypePositions
+			if ev.Replay {
+				typ = websocket.TypeReplayPositions
+			}
+			if ctx.WSHub != nil && !ctx.WSHub.Publish(websocket.NewMessage(typ, websocket.StateItem(ev.State, b))) {
+				logx.Errorf("Hub 广播超时，丢弃 vehicleId=%s", ev.State.VehicleId)
 			}
 		}
 	}()
 
-	// 初始化 TaskMonitor：监听 VehicleEventChan，并在车辆接近任务的取货点/目的地时生成事件推送给 orders
-	// 默认阈值使用 10m，可后续改为从配置读取
-	ctx.TaskMonitor = NewTaskMonitor(context.Background(), hub, 10.0, ctx.VehicleEventChan)
+	// 车辆状态校验阶段（发布事件与入库之前），规则配置错误时启动失败
+	if !c.Validation.Disabled {
+		v, err := validator.New(c.Validation)
+		if err != nil {
+			panic("Validation config error: " + err.Error())
+		}
+		ctx.Validator = v
+	}
 
-	// 初始化 Processor，用于异步批量写入 Influx
-	// batchSize 使用 InfluxDB 配置的 BatchSize（若为0则使用默认值），flushInterval 使用配置的秒数
-	batchSize := int(c.InfluxDBConfig.BatchSize)
-	if batchSize <= 0 {
-		batchSize = 200
+	// HTTP 推送接入的签名校验：优先使用 StatePush.partners，未配置时接受顶层 AppId/Key
+	pushKeys := c.StatePush.Partners
+	if len(pushKeys) == 0 && c.AppId != "" && c.Key != "" {
+		pushKeys = map[string]string{c.AppId: c.Key}
 	}
-	flushInterval := time.Duration(c.InfluxDBConfig.FlushInterval) * time.Second
-	if flushInterval <= 0 {
-		flushInterval = time.Second
+	ctx.PushVerifier = signature.NewVerifier(pushKeys, time.Duration(c.StatePush.ReplayWindowSec)*time.Second)
+
+	// 初始化 TaskMonitor：订阅车辆状态事件，并在车辆接近任务的取货点/目的地时生成事件推送给 orders
+	// 默认阈值使用 10m，可后续改为从配置读取
+	ctx.TaskMonitor = NewTaskMonitor(context.Background(), hub, 10.0, ctx.EventBus)
+	snapshotMaxAge := 10 * time.Minute
+	if c.WebSocket.SnapshotMaxAgeSec > 0 {
+		snapshotMaxAge = time.Duration(c.WebSocket.SnapshotMaxAgeSec) * time.Second
+	} else if c.WebSocket.SnapshotMaxAgeSec < 0 {
+		snapshotMaxAge = 0
 	}
-	// 并发写入限制，默认 4
-	maxConcurrency := 4
-	ctx.Processor = processor.NewProcessor(ctx.Dao, batchSize, flushInterval, maxConcurrency, hub)
+	ctx.StateStore = statestore.New(ctx.EventBus, snapshotMaxAge)
+	// websocket 连接建立时下发的快照：全部车辆的最新状态与监控中的任务
+	hub.SetSnapshotSource(func() ([]*types.VehicleStateData, []types.ActiveTask) {
+		return ctx.StateStore.Snapshot(), ctx.TaskMonitor.ActiveTasks()
+	})
 
 	// 如果配置了 MySQL，则尝试建立连接并自动建表
 	if c.MySQL.Host != "" {
@@ -125,80 +200,146 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		}
 	}
 
-	// 初始化 VEHState WebSocket 客户端（自动在后台运行，非对外暴露）
-	if c.VEHState.URL != "" {
-		if c.AppId == "" || c.Key == "" {
-			logx.Errorf("VEHState 已配置, 但配置文件中缺少AppId/Key")
-		} else {
-			// 降频策略：可以通过配置 VEHState.SampleIntervalMs 指定每辆车的最小处理间隔（毫秒），
-			// 如果配置为 0 则表示不降频（逐条处理）。默认降频到 1000ms（1Hz）以避免高频推送（例如 10Hz）导致后端阻塞。
-			minInterval := c.VEHState.SampleIntervalMs
-			if minInterval <= 0 {
-				minInterval = 1000 // 默认1s
-			}
+	// 未登记车辆识别依赖 vehicle_list 与 unregistered_vehicle_reports，需配置 MySQL
+	if ctx.MySQLDao != nil && !c.Registry.Disabled {
+		ctx.Registry = registry.New(c.Registry, ctx.MySQLDao)
+	}
 
-			// 包装回调：先根据 vehicleId 检查上次处理时间，低于阈值则跳过（丢弃），否则处理。
-			wrappedHandler := func(data *types.VehicleStateData) error {
-				if data == nil || data.VehicleId == "" {
-					return nil
-				}
-				nowMs := time.Now().UnixNano() / int64(time.Millisecond)
-
-				// 尝试读取上一次处理时间
-				if v, ok := ctx.VehicleLastProcessed.Load(data.VehicleId); ok {
-					if lastMs, ok2 := v.(int64); ok2 {
-						elapsed := nowMs - lastMs
-						if elapsed < int64(minInterval) {
-							// 在阈值内，跳过处理以实现降频；记录日志以便排查高频推送问题
-							// logx.Infof("VEHState %s 跳过（降频），距上次处理 %d ms，小于阈值 %d ms", data.VehicleId, elapsed, minInterval)
-							return nil
-						}
-					}
-				}
+	// 初始化 Processor，用于异步批量写入各个 Sink（Influx / MySQL vehicle_records / NDJSON 文件）
+	// batchSize 使用 InfluxDB 配置的 BatchSize（若为0则使用默认值），flushInterval 使用配置的秒数
+	batchSize := int(c.InfluxDBConfig.BatchSize)
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	flushInterval := time.Duration(c.InfluxDBConfig.FlushInterval) * time.Second
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	var sinks []processor.SinkOptions
+	if !c.Sinks.Influx.Disabled {
+		o := sinkOptions(sink.NewInfluxSink(ctx.Dao), c.Sinks.Influx)
+		// 配置了 WAL 目录时，Influx 不可用的批次落盘，恢复后按顺序重放
+		if c.WAL.Dir != "" {
+			w, err := wal.Open(c.WAL)
+			if err != nil {
+				panic("WAL open error: " + err.Error())
+			}
+			o.WAL = w
+			o.ReplayInterval = time.Duration(c.WAL.ReplayIntervalSec) * time.Second
+		}
+		sinks = append(sinks, o)
+	}
+	if ctx.MySQLDao != nil && !c.Sinks.MySQL.Disabled {
+		sinks = append(sinks, sinkOptions(sink.NewMySQLSink(ctx.MySQLDao), c.Sinks.MySQL))
+	}
+	if c.Sinks.File.Dir != "" && !c.Sinks.File.Disabled {
+		fs, err := sink.NewFileSink(c.Sinks.File.Dir, c.Sinks.File.Prefix, c.Sinks.File.MaxFileMB, c.Sinks.File.MaxFiles)
+		if err != nil {
+			panic("File sink error: " + err.Error())
+		}
+		o := sinkOptions(fs, c.Sinks.File)
+		// 顺序追加写入，默认单协程保证文件内按批次顺序
+		if c.Sinks.File.Concurrency <= 0 {
+			o.Concurrency = 1
+		}
+		sinks = append(sinks, o)
+	}
+	ctx.Processor = processor.NewProcessor(batchSize, flushInterval, hub, sinks...)
 
-				// 更新为本次处理时间
-				ctx.VehicleLastProcessed.Store(data.VehicleId, nowMs)
+	// 按车辆排序阶段（校验之后）：去重、重排窗口内按时间戳排序，放行后再发布事件与入队 Processor
+	if !c.Sequencer.Disabled {
+		ctx.Sequencer = sequencer.New(c.Sequencer, ctx.emitState)
+	}
 
-				// 将数据推送到 VehicleEventChan，供内部组件（例如 dispatch 逻辑或 web 推送）订阅处理。
-				// 使用非阻塞发送以避免阻塞上游客户端连接。
-				if ctx.VehicleEventChan != nil {
-					select {
-					case ctx.VehicleEventChan <- data:
-					default:
-						// 通道已满，丢弃以保障吞吐（可在未来改为回压或持久化）
-					}
-				}
+	// 二进制协议数据段解密密钥（TCP 直连与 MQTT 二进制负载共用）
+	defaultAppId := c.Crypto.DefaultAppId
+	if defaultAppId == "" {
+		defaultAppId = c.AppId
+	}
+	keyring, err := protocol.NewKeyring(c.Crypto.VehicleKeys, c.Crypto.AppKeys, defaultAppId, c.Crypto.Required)
+	if err != nil {
+		panic("Crypto config error: " + err.Error())
+	}
 
-				// 这里为实际处理逻辑入口：优先把数据投递到 Processor 队列以异步批量写入 Influx。
-				// 如果 Processor 未初始化则回退到记录日志。
-				if ctx.Processor != nil {
-					if err := ctx.Processor.Enqueue(data); err != nil {
-						logx.Errorf("将 VEHState 数据入队失败，vehicleId=%s err=%v", data.VehicleId, err)
-						return err
-					}
-					// 入队成功，不在此处阻塞写入，返回 nil 表示已处理
-					return nil
-				}
+	// 上游原始消息录制与回放：录制在解析前进行，回放时按消息类型交给对应的上游重新解析
+	if c.Recorder.Dir != "" {
+		if !c.Recorder.ReplayOnly {
+			rec, err := recorder.New(c.Recorder)
+			if err != nil {
+				panic("Recorder error: " + err.Error())
+			}
+			ctx.Recorder = rec
+		}
+		ctx.Replayer = recorder.NewPlayer(c.Recorder.Dir, ctx.newReplaySession)
+	}
 
-				// 未配置 Processor 时保留原有日志行为
-				// logx.Infof("收到 VEHState 数据: %+v", data)
-				return nil
+	// 初始化上游 VEHState 来源（每个来源一个 WebSocket 客户端，自动在后台运行，非对外暴露）
+	if sources := upstream.SourcesFromConfig(c); len(sources) > 0 {
+		// 上游转发的下行指令应答，Downlink 在下方创建，此处延迟取值
+		onAck := func(ack *protocol.CommandAckData) {
+			if ctx.Downlink != nil {
+				ctx.Downlink.HandleAck(ack)
 			}
+		}
+		m, err := upstream.NewManager(sources, c.AppId, c.Key, c.Sampling, ctx.Sampler, ctx.handleSourceState, onAck)
+		if err != nil {
+			panic("Upstreams config error: " + err.Error())
+		}
+		ctx.Upstreams = m
+		if ctx.Recorder != nil {
+			m.SetRawHandler(func(source string, msg []byte) {
+				ctx.Recorder.Record(recorder.KindVEHState, source, "", msg)
+			})
+		}
+		m.Start()
+	} else {
+		logx.Infof("未配置 VEHState URL 或 Upstreams，跳过 VEHState 客户端初始化")
+	}
 
-			scClient := apiclient.NewVEHStateClient(c.VEHState, c.AppId, c.Key, wrappedHandler)
-			ctx.VEHStateClient = scClient
-			// 在后台启动连接
-			go scClient.Start(context.Background())
-			// logx.Infof("VEHState 客户端已启动，URL=%s, 降频阈值=%dms", c.VEHState.URL, minInterval)
+	// 初始化 MQTT 遥测订阅：与 VEHState 共用降频与 Processor 处理流程，二进制负载中的告警/心跳等交给 ProcessBinaryMessage
+	if c.MQTT.Broker != "" {
+		ctx.MQTTSubscriber = mqtt.NewSubscriber(c.MQTT, protocol.NewDefaultRegistry(), keyring, ctx.handleUpstreamState)
+		ctx.MQTTSubscriber.SetMessageHandler(ctx.ProcessBinaryMessage)
+		if ctx.Recorder != nil {
+			ctx.MQTTSubscriber.SetRawHandler(func(topic string, payload []byte) {
+				ctx.Recorder.Record(recorder.KindMQTT, "", topic, payload)
+			})
 		}
+		ctx.MQTTSubscriber.Start()
+	} else {
+		logx.Infof("未配置 MQTT broker，跳过 MQTT 遥测订阅")
+	}
+
+	// 初始化二进制协议 TCP 接入服务：解码后的报文按类型交给 ProcessBinaryMessage
+	if c.TCPServer.Addr != "" {
+		ctx.TCPServer = tcpserver.NewServer(c.TCPServer, protocol.NewDefaultRegistry(), keyring, func(msg *protocol.Message) error {
+			ctx.ProcessBinaryMessage(msg)
+			return nil
+		})
+		go ctx.TCPServer.Start(context.Background())
+	} else {
+		logx.Infof("未配置 TCPServer 地址，跳过二进制 TCP 接入服务")
+	}
+
+	// 初始化下行指令通道：TCP 直连优先，VEHState 上游转发兜底（由 Downlink.transport 决定）
+	var transports []downlink.Transport
+	if ctx.TCPServer != nil {
+		transports = append(transports, downlink.NewTCPTransport(ctx.TCPServer))
+	}
+	if ctx.Upstreams != nil {
+		transports = append(transports, downlink.NewVEHStateTransport(ctx.Upstreams))
+	}
+	if len(transports) > 0 {
+		ctx.Downlink = downlink.NewManager(c.Downlink, ctx.MySQLDao, transports...)
 	} else {
-		logx.Infof("未配置 VEHState URL，跳过 VEHState 客户端初始化")
+		logx.Infof("未配置 TCPServer 与 VEHState，下行指令通道不可用")
 	}
 
-	// 初始化 VEHInfo HTTP 客户端并启动定时拉取（每 6 小时一次，启动时立即拉取一次）
+	// 外部平台 HTTP 客户端：车辆信息、位置、轨迹与行程接口共用签名、重试、熔断与限流
+	ctx.Platform = apiclient.NewPlatformClient(c)
+
+	// 启动车辆信息定时拉取（每 6 小时一次，启动时立即拉取一次）
 	if c.VEHInfo.URL != "" {
-		viClient := apiclient.NewVEHInfoClient(c.VEHInfo, c.AppId, c.Key)
-		ctx.VEHInfoClient = viClient
 		ctx.vehInfoStop = make(chan struct{})
 		// 后台协程：立即拉取一次，然后每 6 小时拉取一次
 		go func() {
@@ -208,43 +349,27 @@ func NewServiceContext(c config.Config) *ServiceContext {
 
 			fetchOnce := func() {
 				logx.Infof("开始拉取车辆信息列表 (外部 API)：%s", c.VEHInfo.URL)
-				b, err := viClient.FetchAll(context.Background())
+				list, err := ctx.Platform.VehicleList(context.Background(), 0)
 				if err != nil {
 					logx.Errorf("拉取车辆信息失败: %v", err)
 					return
 				}
-				// 解析响应并尝试持久化到 MySQL（如果已配置）
-				// 响应使用项目中定义的类型 types.VehicleInfoResp
-				var resp types.VehicleInfoResp
-				if err := json.Unmarshal(b, &resp); err != nil {
-					// 无法解析为预期结构，记录原始响应并返回
-					if len(b) <= 4096 {
-						logx.Errorf("解析车辆信息响应失败: %v, raw=%s", err, string(b))
-					} else {
-						logx.Errorf("解析车辆信息响应失败: %v, raw(first4KB)=%s", err, string(b[:4096]))
-					}
-					return
-				}
-
-				// // 打印响应摘要到日志（前4KB）
-				// if len(b) <= 4096 {
-				// 	logx.Infof("车辆信息响应 (%d bytes): %s", len(b), string(b))
-				// } else {
-				// 	logx.Infof("车辆信息响应长度=%d bytes, 前4KB: %s", len(b), string(b[:4096]))
-				// }
 
 				// 如果 MySQL 已配置且 dao 可用，则将每条车辆信息插入或更新到 vehicle_list 表
 				if ctx.MySQLDao != nil {
-					for _, v := range resp.Data {
+					for _, v := range list {
 						// InsertOrUpdateVehicleFromAPI 会根据 vehicleId 判断插入或更新
 						if err := ctx.MySQLDao.InsertOrUpdateVehicleFromAPI(&v); err != nil {
 							logx.Errorf("持久化车辆信息到 MySQL 失败，vehicleId=%s err=%v", v.VehicleId, err)
 						} else {
 							logx.Infof("已持久化车辆信息到 MySQL，vehicleId=%s", v.VehicleId)
+							if ctx.Registry != nil {
+								ctx.Registry.MarkKnown(v.VehicleId)
+							}
 						}
 					}
 				} else {
-					logx.Infof("MySQL 未配置，跳过持久化车辆信息，返回数据数量=%d", len(resp.Data))
+					logx.Infof("MySQL 未配置，跳过持久化车辆信息，返回数据数量=%d", len(list))
 				}
 			}
 
@@ -268,6 +393,18 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	return ctx
 }
 
+// sinkOptions 把 Sink 配置转换为 Processor 的写入参数，零值由 Processor 使用默认值
+func sinkOptions(s sink.Sink, c config.SinkConfig) processor.SinkOptions {
+	return processor.SinkOptions{
+		Sink:         s,
+		Concurrency:  c.Concurrency,
+		QueueSize:    c.QueueSize,
+		MaxRetries:   c.MaxRetries,
+		RetryBackoff: time.Duration(c.RetryBackoffMs) * time.Millisecond,
+		WriteTimeout: time.Duration(c.WriteTimeoutSec) * time.Second,
+	}
+}
+
 // autoMigrate 创建必要的 MySQL 表（使用 IF NOT EXISTS，安全可重入）
 func autoMigrate(db *sql.DB) error {
 	// vehicle_records: 存储每次上报的汇总记录，增加唯一索引避免重复
@@ -275,7 +412,7 @@ func autoMigrate(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS vehicle_records (
 		id INT AUTO_INCREMENT PRIMARY KEY,
 		vehicle_id VARCHAR(128) NOT NULL,
-		timestamp DATETIME NOT NULL,
+		timestamp DATETIME(3) NOT NULL,
 		longitude BIGINT,
 		latitude BIGINT,
 		velocity INT,
@@ -286,6 +423,10 @@ func autoMigrate(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	// 旧表的 timestamp 为秒精度，同一秒内的多条上报会被唯一索引当作重复丢弃，升级为毫秒精度
+	if err := widenDatetimeColumn(db, "vehicle_records", "timestamp", 3); err != nil {
+		return err
+	}
 
 	// 创建 vehicle_list 表（保留用于静态设备信息）
 	if err := createVehicleListTable(db); err != nil {
@@ -378,6 +519,7 @@ func autoMigrate(db *sql.DB) error {
 		vehicle_id VARCHAR(128) NOT NULL UNIQUE,
 		first_seen DATETIME NOT NULL,
 		last_seen DATETIME NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
 		report_count INT DEFAULT 1,
 		last_payload JSON,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -387,10 +529,61 @@ func autoMigrate(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	// 早期版本建表时没有审核状态列（pending / approved / blocked）
+	if err := addColumnIfMissing(db, "unregistered_vehicle_reports", "status", "VARCHAR(16) NOT NULL DEFAULT 'pending' AFTER last_seen"); err != nil {
+		return err
+	}
+
+	// 下行指令记录表：id 即报文中的 commandId，车端应答按 id 回写状态
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS vehicle_commands (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		vehicle_id VARCHAR(128) NOT NULL,
+		command_type VARCHAR(32) NOT NULL,
+		params JSON,
+		status VARCHAR(16) NOT NULL,
+		transport VARCHAR(16),
+		attempts INT DEFAULT 0,
+		result_code INT,
+		last_error VARCHAR(512),
+		sent_at DATETIME,
+		acked_at DATETIME,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_vehicle_id (vehicle_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// addColumnIfMissing 为已存在的表补充新增的列（MySQL 不支持 ADD COLUMN IF NOT EXISTS）
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// widenDatetimeColumn 把已存在表的 DATETIME 列升级到指定的小数秒精度（已满足时不做修改）
+func widenDatetimeColumn(db *sql.DB, table, column string, precision int) error {
+	var cur sql.NullInt64
+	err := db.QueryRow(`SELECT DATETIME_PRECISION FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&cur)
+	if err != nil || (cur.Valid && cur.Int64 >= int64(precision)) {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY %s DATETIME(%d) NOT NULL", table, column, precision))
+	return err
+}
+
 // 新增 vehicle_list 表：用于保存车辆静态设备信息（包括来自云端平台的车辆信息和内部管理信息）
 // 使用 IF NOT EXISTS 保证安全可重入
 func createVehicleListTable(db *sql.DB) error {
@@ -420,12 +613,47 @@ func createVehicleListTable(db *sql.DB) error {
 
 // Close 关闭 ServiceContext 中的外部资源（优雅停止）
 func (sc *ServiceContext) Close() {
+	// 停止进行中的回放
+	if sc.Replayer != nil {
+		sc.Replayer.Stop()
+	}
+
 	// 停止 VEHState 客户端
-	if sc.VEHStateClient != nil {
-		sc.VEHStateClient.Stop()
+	if sc.Upstreams != nil {
+		sc.Upstreams.Stop()
 		logx.Infof("VEHState 客户端已停止")
 	}
 
+	// 断开 MQTT 订阅
+	if sc.MQTTSubscriber != nil {
+		sc.MQTTSubscriber.Stop()
+		logx.Infof("MQTT 订阅已停止")
+	}
+
+	// 写完录制缓冲（上游均已停止）
+	if sc.Recorder != nil {
+		sc.Recorder.Close()
+		logx.Infof("原始消息录制已停止")
+	}
+
+	// 停止 TCP 接入服务
+	if sc.TCPServer != nil {
+		sc.TCPServer.Stop()
+		logx.Infof("TCP 接入服务已停止")
+	}
+
+	// 停止下行指令应答等待
+	if sc.Downlink != nil {
+		sc.Downlink.Close()
+		logx.Infof("下行指令通道已停止")
+	}
+
+	// 放行排序缓冲中的记录（需在 Processor 停止之前）
+	if sc.Sequencer != nil {
+		sc.Sequencer.Close()
+		logx.Infof("车辆状态排序已停止")
+	}
+
 	// 停止 Processor 并等待其写入完成
 	if sc.Processor != nil {
 		sc.Processor.Close()
@@ -438,6 +666,12 @@ func (sc *ServiceContext) Close() {
 		logx.Infof("Influx Dao 已关闭")
 	}
 
+	// 写入未登记车辆的剩余上报记录（需在关闭 MySQL 之前）
+	if sc.Registry != nil {
+		sc.Registry.Close()
+		logx.Infof("未登记车辆识别已停止")
+	}
+
 	// 关闭 MySQL 连接
 	if sc.MySQLDB != nil {
 		_ = sc.MySQLDB.Close()
@@ -455,6 +689,15 @@ func (sc *ServiceContext) Close() {
 		sc.TaskMonitor.Stop()
 		logx.Infof("TaskMonitor 已停止")
 	}
+	if sc.StateStore != nil {
+		sc.StateStore.Close()
+	}
+
+	// 关闭事件总线（关闭所有订阅通道，使订阅协程退出）
+	if sc.EventBus != nil {
+		sc.EventBus.Close()
+		logx.Infof("事件总线已关闭")
+	}
 }
 
 // RegisterTaskForMonitor 注册一个需要监控到达事件的任务（由上层派单调用）
@@ -490,27 +733,201 @@ func (sc *ServiceContext) UnregisterTaskFromMonitor(taskId string) {
 
 // ProcessVehicleState 处理标准化后的车辆状态数据。
 // 该方法用于内部处理流程（例如 HTTP 推送解析后、或外部平台回调处理后）将数据统一传入系统：
-// 1) 非阻塞地把事件发送到 VehicleEventChan，供事件分发器广播到 websocket 客户端；
+// 1) 把事件发布到 EventBus，供事件分发器、TaskMonitor 等订阅者消费；
 // 2) 将数据交给 Processor 入队，用于批量写入 Influx 并触发实时 hub 广播。
-func (sc *ServiceContext) ProcessVehicleState(data *types.VehicleStateData) {
+// 未通过校验的记录写入隔离 measurement，不发布也不入队，并返回 *validator.Violation；
+// 启用排序时通过校验的记录先经 Sequencer 去重与排序（重复时间戳返回 sequencer.ErrDuplicate），放行后再执行上述两步。
+func (sc *ServiceContext) ProcessVehicleState(data *types.VehicleStateData) error {
+	return sc.processVehicleState(data, false)
+}
+
+// ProcessUrgentVehicleState 处理高优先级车辆状态：不参与降频，并绕过 Processor 的攒批等待立即写入
+func (sc *ServiceContext) ProcessUrgentVehicleState(data *types.VehicleStateData) error {
+	return sc.processVehicleState(data, true)
+}
+
+func (sc *ServiceContext) processVehicleState(data *types.VehicleStateData, urgent bool) error {
 	if data == nil {
-		return
+		return nil
+	}
+	if err := sc.checkRegistered(data); err != nil {
+		return err
+	}
+	if v := sc.validate(data); v != nil {
+		return v
+	}
+	return sc.sequence(data, urgent)
+}
+
+// sequence 把通过校验的记录交给 Sequencer；未启用排序时直接放行
+func (sc *ServiceContext) sequence(data *types.VehicleStateData, urgent bool) error {
+	if sc.Sequencer == nil {
+		sc.emitState(sequencer.Record{State: data, Urgent: urgent})
+		return nil
 	}
+	return sc.Sequencer.Push(data, urgent)
+}
 
-	// 1) 发送到事件通道（非阻塞）
-	if sc.VehicleEventChan != nil {
-		select {
-		case sc.VehicleEventChan <- data:
-		default:
-			// 通道已满：记录但继续，不阻塞调用方
-			logx.Infof("VehicleEventChan 已满，丢弃事件 vehicleId=%s ts=%d", data.VehicleId, data.Timestamp)
+// emitState 处理排序放行的记录：
+// 1) 发布到事件总线（缓冲区满时按订阅者的丢弃策略处理并计入其指标）；
+// 2) 交给 Processor 进行批量处理（写入 Influx & Hub 广播）。
+// 迟到记录在 write_only 策略下只入库；broadcast 策略下以 Late 标记发布事件，Processor 仍只入库，避免前端位置回退。
+func (sc *ServiceContext) emitState(r sequencer.Record) {
+	data := r.State
+	if r.Late {
+		logx.Infof("迟到车辆状态 vehicleId=%s 时间戳=%d", data.VehicleId, data.Timestamp)
+		if sc.EventBus != nil && sc.Sequencer != nil && sc.Sequencer.LatePolicy() == sequencer.LateBroadcast {
+			sc.EventBus.Publish(&eventbus.Event{Type: eventbus.EventVehicleState, VehicleId: data.VehicleId, State: data, Late: true})
 		}
+		if sc.Processor != nil {
+			if err := sc.Processor.EnqueueWriteOnly(data); err != nil {
+				logx.Errorf("Processor 入队失败 vehicleId=%s late=true err=%v", data.VehicleId, err)
+			}
+		}
+		return
 	}
 
-	// 2) 交给 Processor 进行批量处理（写入 Influx & Hub 广播）
+	if sc.EventBus != nil {
+		sc.EventBus.PublishState(data)
+	}
 	if sc.Processor != nil {
-		if err := sc.Processor.Enqueue(data); err != nil {
-			logx.Errorf("Processor 入队失败 vehicleId=%s err=%v", data.VehicleId, err)
+		var err error
+		if r.Urgent {
+			err = sc.Processor.EnqueueUrgent(data)
+		} else {
+			err = sc.Processor.Enqueue(data)
+		}
+		if err != nil {
+			logx.Errorf("Processor 入队失败 vehicleId=%s urgent=%v err=%v", data.VehicleId, r.Urgent, err)
 		}
 	}
 }
+
+// handleUpstreamState 为 VEHState / MQTT 上游车辆状态的统一入口：
+// 先校验（未通过的写入隔离 measurement），再按降频策略判断与该车上一条保留记录相比是否有足够变化，无变化则跳过（丢弃），
+// 否则经 Sequencer 去重与排序后发布事件并入队 Processor。校验在降频之前进行，避免无效记录成为降频参考而丢掉随后的有效记录。
+func (sc *ServiceContext) handleUpstreamState(data *types.VehicleStateData) error {
+	return sc.handleSourceState(data, sc.Sampler)
+}
+
+// handleSourceState 同 handleUpstreamState，降频使用指定的降频器（各上游来源可独立配置，为 nil 时不降频）
+func (sc *ServiceContext) handleSourceState(data *types.VehicleStateData, smp *sampler.Sampler) error {
+	if data == nil || data.VehicleId == "" {
+		return nil
+	}
+	if sc.checkRegistered(data) != nil {
+		return nil
+	}
+	if sc.validate(data) != nil {
+		return nil
+	}
+	if smp != nil {
+		if keep, _ := smp.Keep(data); !keep {
+			return nil
+		}
+	}
+
+	// 重复记录直接丢弃；放行后的发布与入队在 emitState 中完成，入队失败在其中记录日志
+	if err := sc.sequence(data, false); err != nil && err != sequencer.ErrDuplicate {
+		return err
+	}
+	return nil
+}
+
+// checkRegistered 按登记缓存判断车辆状态是否放行，未登记车辆在其中记录上报（校验之前，已拉黑车辆的数据不进入隔离 measurement）
+func (sc *ServiceContext) checkRegistered(data *types.VehicleStateData) error {
+	if sc.Registry == nil {
+		return nil
+	}
+	return sc.Registry.Check(data)
+}
+
+// validate 按校验规则检查车辆状态；未通过时把记录连同规则与原因写入隔离 measurement
+func (sc *ServiceContext) validate(data *types.VehicleStateData) *validator.Violation {
+	if sc.Validator == nil {
+		return nil
+	}
+	v := sc.Validator.Check(data)
+	if v == nil {
+		return nil
+	}
+	logx.Infof("车辆状态未通过校验 vehicleId=%s rule=%s reason=%s", data.VehicleId, v.Rule, v.Reason)
+	if sc.Dao != nil {
+		_ = sc.Dao.AddPoint(sc.Dao.BuildQuarantinePoint(sc.Validator.QuarantineMeasurement(), data, v.Rule, v.Reason))
+	}
+	return v
+}
+
+// ProcessBinaryMessage 按消息类型分发二进制协议解码结果：
+// 状态进入 ProcessVehicleState（高优先级走 ProcessUrgentVehicleState）；告警立即广播给 websocket 客户端；
+// 心跳仅刷新在线时间；指令应答交给 Downlink 更新指令状态。
+func (sc *ServiceContext) ProcessBinaryMessage(msg *protocol.Message) {
+	if msg == nil {
+		return
+	}
+	switch msg.Kind {
+	case protocol.MessageKindState:
+		// 高优先级报文（控制内容声明）跳过降频与攒批等待，普通报文按车辆降频
+		if msg.HighPriority() {
+			_ = sc.ProcessUrgentVehicleState(msg.State)
+		} else {
+			_ = sc.handleUpstreamState(msg.State)
+		}
+	case protocol.MessageKindAlarm:
+		sc.ProcessVehicleAlarm(msg.Alarm)
+	case protocol.MessageKindHeartbeat:
+		if msg.Heartbeat != nil {
+			sc.VehicleLastHeartbeat.Store(msg.Heartbeat.VehicleId, time.Now())
+		}
+	case protocol.MessageKindCommandAck:
+		if sc.Downlink != nil {
+			sc.Downlink.HandleAck(msg.CommandAck)
+		}
+	}
+}
+
+// ProcessVehicleAlarm 将车辆告警广播给所有 websocket 客户端
+func (sc *ServiceContext) ProcessVehicleAlarm(alarm *protocol.AlarmData) {
+	if alarm == nil {
+		return
+	}
+	logx.Infof("收到车辆告警 vehicleId=%s type=%d level=%d desc=%s", alarm.VehicleId, alarm.AlarmType, alarm.AlarmLevel, alarm.Description)
+	sc.publishAlarm(alarm, false)
+}
+
+// publishAlarm 发布告警事件并交给 Hub 路由；replay 为 true 时事件带 Replay 标记，websocket 以 replay_alarms 类型下发
+func (sc *ServiceContext) publishAlarm(alarm *protocol.AlarmData, replay bool) {
+	if sc.Registry != nil && sc.Registry.Blocked(alarm.VehicleId) {
+		return
+	}
+	if sc.EventBus != nil {
+		sc.EventBus.Publish(&eventbus.Event{Type: eventbus.EventVehicleAlarm, VehicleId: alarm.VehicleId, Data: alarm, Replay: replay})
+	}
+	if sc.WSHub == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		"type":  "vehicle_alarm",
+		"alarm": alarm,
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		logx.Errorf("marshal vehicle alarm failed: %v", err)
+		return
+	}
+	// 告警不带车辆类型，从最新状态中查找，按车型限定范围的客户端同样能收到
+	item := websocket.Item{VehicleId: alarm.VehicleId, Data: b}
+	if sc.StateStore != nil {
+		if d, ok := sc.StateStore.Get(alarm.VehicleId); ok {
+			item.CategoryCode = d.CategoryCode
+		}
+	}
+	typ := websocket.TypeAlarms
+	if replay {
+		typ = websocket.TypeReplayAlarms
+	}
+	// 本函数在 TCP 读循环中调用，告警交给 Hub 异步路由，不等待 Broadcast 通道
+	if !sc.WSHub.PublishAsync(websocket.NewMessage(typ, item)) {
+		logx.Errorf("Hub 事件入口已满，丢弃告警 vehicleId=%s type=%d", alarm.VehicleId, alarm.AlarmType)
+	}
+}
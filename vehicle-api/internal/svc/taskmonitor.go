@@ -3,16 +3,19 @@ package svc
 import (
 	"context"
 	"encoding/json"
-	"math"
+	"sort"
 	"sync"
 
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/geo"
 	"vehicle-api/internal/types"
 	"vehicle-api/internal/websocket"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
-// TaskInfo 保存任务相关的位置信息与已触发状态
+// TaskInfo 保存任务相关的位置信息与已触发状态；注册后 AssignedVehicle、ReachedPick、ReachedDest 只在 mu 下读写
+// （到达检测、AssignVehicle 与 Hub 协程生成连接快照并发访问）
 type TaskInfo struct {
 	TaskId          string
 	OrderId         string
@@ -21,6 +24,29 @@ type TaskInfo struct {
 	AssignedVehicle string // 可选：分配给该任务的 vehicleId
 	ReachedPick     bool
 	ReachedDest     bool
+
+	mu sync.Mutex
+}
+
+func (t *TaskInfo) vehicle() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.AssignedVehicle
+}
+
+// view 返回任务的快照
+func (t *TaskInfo) view() types.ActiveTask {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return types.ActiveTask{
+		TaskId:             t.TaskId,
+		OrderId:            t.OrderId,
+		VehicleId:          t.AssignedVehicle,
+		Pickup:             t.Pickup,
+		Destination:        t.Destination,
+		ReachedPickup:      t.ReachedPick,
+		ReachedDestination: t.ReachedDest,
+	}
 }
 
 // vehicleTaskList 用于对单个 vehicleId 下的任务进行并发安全管理
@@ -59,14 +85,16 @@ func (v *vehicleTaskList) snapshot() []*TaskInfo {
 type TaskMonitor struct {
 	tasks        sync.Map // key: taskId string, value: *TaskInfo
 	vehicleIndex sync.Map // key: vehicleId string, value: *vehicleTaskList
+	lastTs       sync.Map // key: vehicleId string, value: uint64 已处理的最新状态时间戳，早于该时间的状态不参与到达检测
 	thrMeter     float64  // 距离阈值（米）
 	hub          *websocket.Hub
+	sub          *eventbus.Subscription
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
 
-// NewTaskMonitor 创建监控器并启动监听循环
-func NewTaskMonitor(ctx context.Context, hub *websocket.Hub, thresholdMeters float64, vehicleEventChan <-chan *types.VehicleStateData) *TaskMonitor {
+// NewTaskMonitor 创建监控器，订阅事件总线上的车辆状态事件并启动监听循环
+func NewTaskMonitor(ctx context.Context, hub *websocket.Hub, thresholdMeters float64, bus *eventbus.Bus) *TaskMonitor {
 	if thresholdMeters <= 0 {
 		thresholdMeters = 100.0 // 默认 100m
 	}
@@ -77,8 +105,10 @@ func NewTaskMonitor(ctx context.Context, hub *websocket.Hub, thresholdMeters flo
 		ctx:      cctx,
 		cancel:   cancel,
 	}
+	// 到达检测漏掉位置点会导致到达事件缺失，默认使用阻塞策略（可在 EventBus.subscribers.task-monitor 中覆盖）
+	tm.sub = bus.Subscribe("task-monitor", eventbus.Filter{Types: []string{eventbus.EventVehicleState}}, eventbus.SubscribeOptions{Policy: eventbus.Block})
 
-	go tm.runListener(vehicleEventChan)
+	go tm.runListener()
 	logx.Infof("TaskMonitor 启动，thresholdMeters=%.1f", tm.thrMeter)
 	return tm
 }
@@ -106,7 +136,9 @@ func (tm *TaskMonitor) AssignVehicle(taskId, vehicleId string) {
 		return
 	}
 	ti := v.(*TaskInfo)
+	ti.mu.Lock()
 	ti.AssignedVehicle = vehicleId
+	ti.mu.Unlock()
 	// 将任务加入 vehicleIndex
 	vi, _ := tm.vehicleIndex.LoadOrStore(vehicleId, newVehicleTaskList())
 	vi.(*vehicleTaskList).add(ti)
@@ -119,9 +151,8 @@ func (tm *TaskMonitor) Unregister(taskId string) {
 	}
 	v, ok := tm.tasks.Load(taskId)
 	if ok {
-		ti := v.(*TaskInfo)
-		if ti.AssignedVehicle != "" {
-			if vi, ok2 := tm.vehicleIndex.Load(ti.AssignedVehicle); ok2 {
+		if vehicleId := v.(*TaskInfo).vehicle(); vehicleId != "" {
+			if vi, ok2 := tm.vehicleIndex.Load(vehicleId); ok2 {
 				vi.(*vehicleTaskList).remove(taskId)
 			}
 		}
@@ -129,32 +160,49 @@ func (tm *TaskMonitor) Unregister(taskId string) {
 	tm.tasks.Delete(taskId)
 }
 
-// Stop 停止监控器
+// ActiveTasks 返回监控中的任务，按 taskId 排序
+func (tm *TaskMonitor) ActiveTasks() []types.ActiveTask {
+	out := make([]types.ActiveTask, 0)
+	tm.tasks.Range(func(_, v interface{}) bool {
+		out = append(out, v.(*TaskInfo).view())
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].TaskId < out[j].TaskId })
+	return out
+}
+
+// Stop 停止监控器并取消订阅
 func (tm *TaskMonitor) Stop() {
 	tm.cancel()
+	tm.sub.Close()
 }
 
-// runListener 从 vehicleEventChan 接收位置并检查每个任务的到达条件
-func (tm *TaskMonitor) runListener(vehicleEventChan <-chan *types.VehicleStateData) {
+// runListener 从订阅通道接收位置并检查每个任务的到达条件
+func (tm *TaskMonitor) runListener() {
 	for {
 		select {
 		case <-tm.ctx.Done():
 			logx.Infof("TaskMonitor 停止")
 			return
-		case ev, ok := <-vehicleEventChan:
+		case ev, ok := <-tm.sub.C():
 			if !ok {
-				logx.Infof("vehicleEventChan 已关闭，TaskMonitor 退出")
+				logx.Infof("事件订阅已关闭，TaskMonitor 退出")
 				return
 			}
-			if ev == nil || ev.VehicleId == "" {
+			// 迟到状态可能使车辆"回到"已离开的位置，不参与到达检测；回放数据不触发线上任务的到达事件
+			if ev.State == nil || ev.VehicleId == "" || ev.Late || ev.Replay {
 				continue
 			}
-			tm.handleEvent(ev)
+			tm.handleEvent(ev.State)
 		}
 	}
 }
 
 func (tm *TaskMonitor) handleEvent(ev *types.VehicleStateData) {
+	// 未启用排序或多个接入通道交错时仍可能收到更早的状态，保证每辆车的检测只随时间前进
+	if !tm.advance(ev.VehicleId, ev.Timestamp) {
+		return
+	}
 	// 仅查找与该 vehicleId 相关的任务，避免遍历全部任务
 	vi, ok := tm.vehicleIndex.Load(ev.VehicleId)
 	if !ok {
@@ -166,22 +214,26 @@ func (tm *TaskMonitor) handleEvent(ev *types.VehicleStateData) {
 		if ti == nil {
 			continue
 		}
-		if !ti.ReachedPick {
-			d := haversineMeters(ev.Lat, ev.Lon, ti.Pickup.Lat, ti.Pickup.Lon)
-			if d <= tm.thrMeter {
-				ti.ReachedPick = true
-				tm.emitEvent("arrived_pickup", ti, ev)
-			}
+		// 在任务锁内更新到达状态，锁外发出事件
+		ti.mu.Lock()
+		pick := !ti.ReachedPick && geo.HaversineMeters(ev.Lat, ev.Lon, ti.Pickup.Lat, ti.Pickup.Lon) <= tm.thrMeter
+		if pick {
+			ti.ReachedPick = true
 		}
-		if !ti.ReachedDest {
-			d2 := haversineMeters(ev.Lat, ev.Lon, ti.Destination.Lat, ti.Destination.Lon)
-			if d2 <= tm.thrMeter {
-				ti.ReachedDest = true
-				tm.emitEvent("arrived_destination", ti, ev)
-			}
+		dest := !ti.ReachedDest && geo.HaversineMeters(ev.Lat, ev.Lon, ti.Destination.Lat, ti.Destination.Lon) <= tm.thrMeter
+		if dest {
+			ti.ReachedDest = true
+		}
+		completed := ti.ReachedPick && ti.ReachedDest
+		ti.mu.Unlock()
+		if pick {
+			tm.emitEvent("arrived_pickup", ti, ev)
+		}
+		if dest {
+			tm.emitEvent("arrived_destination", ti, ev)
 		}
 		// 如果任务的取货点和目的地都已到达，则注销任务并发送完成事件
-		if ti.ReachedPick && ti.ReachedDest {
+		if completed {
 			tm.emitEvent("task_completed", ti, ev)
 			tm.Unregister(ti.TaskId)
 			logx.Infof("任务 %s 已完成并从监控中移除", ti.TaskId)
@@ -189,6 +241,23 @@ func (tm *TaskMonitor) handleEvent(ev *types.VehicleStateData) {
 	}
 }
 
+// advance 记录该车最新的状态时间戳；ts 早于已处理的时间戳时返回 false
+func (tm *TaskMonitor) advance(vehicleId string, ts uint64) bool {
+	for {
+		v, loaded := tm.lastTs.LoadOrStore(vehicleId, ts)
+		if !loaded {
+			return true
+		}
+		last := v.(uint64)
+		if ts < last {
+			return false
+		}
+		if ts == last || tm.lastTs.CompareAndSwap(vehicleId, last, ts) {
+			return true
+		}
+	}
+}
+
 func (tm *TaskMonitor) emitEvent(evtType string, ti *TaskInfo, ev *types.VehicleStateData) {
 	if tm.hub == nil {
 		return
@@ -204,21 +273,14 @@ func (tm *TaskMonitor) emitEvent(evtType string, ti *TaskInfo, ev *types.Vehicle
 	}
 	b, err := json.Marshal(payload)
 	if err != nil {
-		logx.Errorf("marshal task event failed: %v", err)
+		logx.Errorf("任务事件编码失败，事件未发出 type=%s taskId=%s vehicleId=%s err=%v", evtType, ti.TaskId, ev.VehicleId, err)
+		return
+	}
+	// 发给订阅了任务事件的客户端（orders 服务默认订阅）：经事件入口排队，不会因 Broadcast 通道满而丢弃，只在 Hub 长时间停滞、入口积压达到上限时丢弃；
+	// 到达事件不受客户端地图范围限制（HasPosition 为 false），否则车辆驶出可视范围时 orders 会错过到达
+	if !tm.hub.PublishAsync(websocket.NewMessage(websocket.TypeTasks, websocket.Item{VehicleId: ev.VehicleId, Data: b})) {
+		logx.Errorf("Hub 事件入口已满，任务事件未发出 type=%s taskId=%s vehicleId=%s", evtType, ti.TaskId, ev.VehicleId)
 		return
 	}
-	// 定向广播到 orders 服务
-	tm.hub.BroadcastToService("orders", b)
 	logx.Infof("发出任务事件 type=%s taskId=%s vehicleId=%s", evtType, ti.TaskId, ev.VehicleId)
 }
-
-// haversineMeters 计算两点间距离（米）
-func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371000.0 // 地球半径 米
-	toRad := func(d float64) float64 { return d * math.Pi / 180.0 }
-	dLat := toRad(lat2 - lat1)
-	dLon := toRad(lon2 - lon1)
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return R * c
-}
@@ -0,0 +1,68 @@
+package svc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/eventbus"
+	"vehicle-api/internal/types"
+	"vehicle-api/internal/websocket"
+)
+
+// 到达检测与连接快照、派车并发进行，配合 go test -race 检查任务状态的读写
+func TestActiveTasksWhileHandlingEvents(t *testing.T) {
+	bus := eventbus.NewBus(config.EventBusConfig{})
+	defer bus.Close()
+	tm := NewTaskMonitor(context.Background(), websocket.NewHub(config.WebSocketConfig{}), 10, bus)
+	defer tm.Stop()
+
+	pickup := types.Position2D{Lon: 116.0, Lat: 39.0}
+	dest := types.Position2D{Lon: 116.01, Lat: 39.0}
+	const n = 20
+	for i := 0; i < n; i++ {
+		tm.Register(&TaskInfo{TaskId: "T" + strconv.Itoa(i), Pickup: pickup, Destination: dest})
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				tm.ActiveTasks()
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			tm.AssignVehicle("T"+strconv.Itoa(i), "V1")
+		}
+	}()
+
+	// 车辆在取货点与目的地之间往返，直到全部任务完成
+	deadline := time.Now().Add(5 * time.Second)
+	for ts := uint64(1); len(tm.ActiveTasks()) > 0; ts++ {
+		if time.Now().After(deadline) {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("tasks not completed: %+v", tm.ActiveTasks())
+		}
+		p := pickup
+		if ts%2 == 0 {
+			p = dest
+		}
+		bus.PublishState(&types.VehicleStateData{VehicleId: "V1", Timestamp: ts, Lon: p.Lon, Lat: p.Lat})
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,279 @@
+package tcpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/protocol"
+)
+
+// Server 监听 TCP 端口，接收车辆以 0xF2 固定报文头协议上报的二进制数据，
+// 按 (数据类别, 版本号) 从解码器注册表中选择解码器，解码结果回调给上层处理函数；
+// 未注册或解码失败的报文写入隔离日志。
+type Server struct {
+	cfg        config.TCPServerConfig
+	registry   *protocol.Registry
+	quarantine *protocol.Quarantine
+	keyring    *protocol.Keyring
+	handle     func(*protocol.Message) error
+
+	mu       sync.Mutex
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	sessions map[string]*session // key: vehicleId，用于下行指令按车辆找到连接（只包含以车辆密钥认证过的连接）
+	wg       sync.WaitGroup
+	closed   chan struct{}
+}
+
+// session 表示一个车辆连接；写操作需串行化
+type session struct {
+	conn net.Conn
+	wmu  sync.Mutex
+	// vehicleId 为该连接最近一次以车辆密钥认证的报文所属车辆，用于下行路由与指令应答校验；仅由连接处理协程读写
+	vehicleId string
+}
+
+// ErrVehicleNotConnected 表示车辆当前没有通过 TCP 直连
+var ErrVehicleNotConnected = errors.New("tcpserver: vehicle not connected")
+
+// NewServer 创建 TCP 接入服务实例（不会立即监听，需调用 Start）
+// registry 为 nil 时使用内置数据集注册表；keyring 为 nil 时加密报文无法解密并进入隔离日志
+func NewServer(cfg config.TCPServerConfig, registry *protocol.Registry, keyring *protocol.Keyring, handle func(*protocol.Message) error) *Server {
+	if cfg.MaxFrameSize <= 0 {
+		cfg.MaxFrameSize = protocol.DefaultMaxFrameSize
+	}
+	if cfg.ReadTimeoutSec <= 0 {
+		cfg.ReadTimeoutSec = 120
+	}
+	if registry == nil {
+		registry = protocol.NewDefaultRegistry()
+	}
+	return &Server{
+		cfg:        cfg,
+		registry:   registry,
+		quarantine: protocol.NewQuarantine(cfg.QuarantineFile),
+		keyring:    keyring,
+		handle:     handle,
+		conns:      make(map[net.Conn]struct{}),
+		sessions:   make(map[string]*session),
+		closed:     make(chan struct{}),
+	}
+}
+
+// Start 开始监听并在当前 goroutine 中循环 Accept；在 ctx 取消或 Stop 被调用时返回
+func (s *Server) Start(ctx context.Context) {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		logx.Errorf("TCP 接入服务监听失败 addr=%s err=%v", s.cfg.Addr, err)
+		return
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	logx.Infof("TCP 接入服务已启动，addr=%s maxFrameSize=%d readTimeout=%ds", ln.Addr().String(), s.cfg.MaxFrameSize, s.cfg.ReadTimeoutSec)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-s.closed:
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.closed:
+				return
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			logx.Errorf("TCP 接入服务 Accept 失败: %v", err)
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Stop 关闭监听与所有连接，并等待连接处理协程退出
+func (s *Server) Stop() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return
+	default:
+		close(s.closed)
+	}
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.quarantine.Close()
+}
+
+// Registry 返回解码器注册表，可在启动后注册新的数据集
+func (s *Server) Registry() *protocol.Registry {
+	return s.registry
+}
+
+// QuarantinedCount 返回累计被隔离的报文数
+func (s *Server) QuarantinedCount() uint64 {
+	return s.quarantine.Count()
+}
+
+// Connected 判断车辆当前是否有可用的 TCP 连接（以车辆密钥认证过的连接）
+func (s *Server) Connected(vehicleId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[vehicleId]
+	return ok
+}
+
+// SendTo 向指定车辆的连接写入一帧完整报文
+func (s *Server) SendTo(vehicleId string, frame []byte) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[vehicleId]
+	s.mu.Unlock()
+	if !ok {
+		return ErrVehicleNotConnected
+	}
+	sess.wmu.Lock()
+	defer sess.wmu.Unlock()
+	_ = sess.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err := sess.conn.Write(frame)
+	return err
+}
+
+// bindSession 将车辆编号与连接关联；同一车辆重连时以新连接为准
+func (s *Server) bindSession(vehicleId string, sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[vehicleId] = sess
+}
+
+// unbindSession 连接关闭时解除关联（仅当仍指向该连接时）
+func (s *Server) unbindSession(vehicleId string, sess *session) {
+	if vehicleId == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.sessions[vehicleId]; ok && cur == sess {
+		delete(s.sessions, vehicleId)
+	}
+}
+
+// serveConn 处理单个车辆连接：按帧读取、校验报文头并分发数据段
+func (s *Server) serveConn(conn net.Conn) {
+	sess := &session{conn: conn}
+	defer func() {
+		s.unbindSession(sess.vehicleId, sess)
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+
+	remote := conn.RemoteAddr().String()
+	logx.Infof("TCP 车辆连接建立 remote=%s", remote)
+
+	idle := time.Duration(s.cfg.ReadTimeoutSec) * time.Second
+	fr := protocol.NewFrameReader(conn, s.cfg.MaxFrameSize)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idle))
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			if errors.Is(err, protocol.ErrFrameTooLarge) {
+				// 长度非法，FrameReader 已跳过该标识位，继续重新同步
+				logx.Errorf("TCP 报文长度非法 remote=%s err=%v", remote, err)
+				continue
+			}
+			if err == io.EOF {
+				logx.Infof("TCP 车辆连接关闭 remote=%s skipped=%d", remote, fr.Skipped)
+			} else {
+				logx.Errorf("TCP 读取报文失败 remote=%s err=%v skipped=%d", remote, err, fr.Skipped)
+			}
+			return
+		}
+		s.handleFrame(remote, sess, frame)
+	}
+}
+
+// handleFrame 按控制内容认证并解密数据段，通过注册表解码并回调上层；
+// 无法解密、未知数据集、解码失败、不允许的不加密报文以及车辆编号与车辆密钥不符的报文进入隔离日志。
+// 只有以该车辆的车辆密钥认证的报文才会把连接绑定为该车辆的下行通道；
+// 指令应答必须以车辆密钥认证且属于连接绑定的车辆，否则任何连接都可以伪造应答
+func (s *Server) handleFrame(remote string, sess *session, frame *protocol.Frame) {
+	vehicleId := sess.vehicleId
+	keyId, vehicleKey, err := s.keyring.DecryptFrame(frame)
+	if err != nil {
+		logx.Errorf("解密报文失败 remote=%s vehicleId=%s keyId=%s control=0x%02X err=%v", remote, vehicleId, keyId, frame.Header.Control, err)
+		s.quarantine.Put(remote, "decrypt: "+err.Error(), frame)
+		return
+	}
+	msg, err := s.registry.Decode(frame)
+	if err != nil {
+		if errors.Is(err, protocol.ErrUnknownDataset) {
+			s.quarantine.Put(remote, "unknown dataset", frame)
+		} else {
+			logx.Errorf("解码报文失败 remote=%s category=0x%02X version=%d err=%v", remote, frame.Header.DataCategory, frame.Header.Version, err)
+			s.quarantine.Put(remote, err.Error(), frame)
+		}
+		return
+	}
+	if protocol.ControlEncryption(frame.Header.Control) == protocol.EncryptNone {
+		if err := s.keyring.CheckCleartext(msg.VehicleId()); err != nil {
+			logx.Errorf("拒绝不加密报文 remote=%s vehicleId=%s err=%v", remote, msg.VehicleId(), err)
+			s.quarantine.Put(remote, err.Error(), frame)
+			return
+		}
+	}
+	if msg.VehicleId() == "" {
+		return
+	}
+	if vehicleKey && msg.VehicleId() != keyId {
+		logx.Errorf("报文车辆编号与车辆密钥不符 remote=%s keyId=%s vehicleId=%s", remote, keyId, msg.VehicleId())
+		s.quarantine.Put(remote, "vehicleId does not match keyId "+keyId, frame)
+		return
+	}
+	if vehicleKey && msg.VehicleId() != vehicleId {
+		// 同一车辆重连时以新连接为准
+		s.unbindSession(vehicleId, sess)
+		sess.vehicleId = msg.VehicleId()
+		s.bindSession(sess.vehicleId, sess)
+		logx.Infof("TCP 连接绑定车辆 remote=%s vehicleId=%s", remote, sess.vehicleId)
+	}
+	if msg.Kind == protocol.MessageKindCommandAck && (!vehicleKey || msg.VehicleId() != sess.vehicleId) {
+		logx.Errorf("拒绝未以车辆密钥认证的指令应答 remote=%s boundVehicleId=%s vehicleId=%s", remote, sess.vehicleId, msg.VehicleId())
+		s.quarantine.Put(remote, "command ack not authenticated for bound vehicle", frame)
+		return
+	}
+	if s.handle != nil {
+		if err := s.handle(msg); err != nil {
+			logx.Errorf("处理 TCP 车辆报文出错 vehicleId=%s kind=%d err=%v", msg.VehicleId(), msg.Kind, err)
+		}
+	}
+}
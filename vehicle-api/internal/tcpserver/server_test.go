@@ -0,0 +1,134 @@
+package tcpserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/types"
+)
+
+const testVehicleKey = "00112233445566778899aabbccddeeff"
+
+// statePayload 最小的 VEH2CLOUD_STATE v1 数据段（无车门）
+func statePayload(vehicleId string) []byte {
+	b := make([]byte, 71)
+	copy(b, vehicleId)
+	return b
+}
+
+func newTestServer(t *testing.T, required bool) (*Server, *[]*protocol.Message) {
+	t.Helper()
+	k, err := protocol.NewKeyring(map[string]string{"V0000001": testVehicleKey}, nil, "", required)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*protocol.Message
+	s := NewServer(config.TCPServerConfig{}, nil, k, func(m *protocol.Message) error {
+		got = append(got, m)
+		return nil
+	})
+	return s, &got
+}
+
+func frame(t *testing.T, category byte, mode byte, keyId string, payload []byte) *protocol.Frame {
+	t.Helper()
+	h := types.FixedHeader{StartByte: protocol.StartByte, DataCategory: category, Version: 1, Timestamp: 1700000000000, Control: protocol.MakeControl(protocol.PriorityNormal, mode)}
+	if mode != protocol.EncryptNone {
+		key, _ := hex.DecodeString(testVehicleKey)
+		var err error
+		payload, err = protocol.EncryptPayload(h, keyId, key, bytes.Repeat([]byte{1}, 12), payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.DataLength = uint32(len(payload))
+	return &protocol.Frame{Header: h, Payload: payload}
+}
+
+func TestHandleFrameCleartextPolicy(t *testing.T) {
+	cases := []struct {
+		name     string
+		required bool
+		frame    func(t *testing.T) *protocol.Frame
+		accepted bool
+	}{
+		{"cleartext from vehicle with key", false, func(t *testing.T) *protocol.Frame {
+			return frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptNone, "", statePayload("V0000001"))
+		}, false},
+		{"cleartext from vehicle without key", false, func(t *testing.T) *protocol.Frame {
+			return frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptNone, "", statePayload("V0000002"))
+		}, true},
+		{"cleartext when encryption required", true, func(t *testing.T) *protocol.Frame {
+			return frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptNone, "", statePayload("V0000002"))
+		}, false},
+		{"encrypted with vehicle key", true, func(t *testing.T) *protocol.Frame {
+			return frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptAESGCM, "V0000001", statePayload("V0000001"))
+		}, true},
+		{"vehicle key used for another vehicle", false, func(t *testing.T) *protocol.Frame {
+			return frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptAESGCM, "V0000001", statePayload("V0000002"))
+		}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, got := newTestServer(t, tc.required)
+			s.handleFrame("test", &session{}, tc.frame(t))
+			if accepted := len(*got) == 1; accepted != tc.accepted {
+				t.Fatalf("accepted = %v, want %v", accepted, tc.accepted)
+			}
+			if quarantined := s.QuarantinedCount() == 1; quarantined == tc.accepted {
+				t.Fatalf("quarantined = %v, want %v", quarantined, !tc.accepted)
+			}
+		})
+	}
+}
+
+func ackPayload(vehicleId string, commandId byte) []byte {
+	b := make([]byte, 13)
+	copy(b, vehicleId)
+	b[11] = commandId
+	return b
+}
+
+func TestHandleFrameBindsOnlyAuthenticatedVehicles(t *testing.T) {
+	s, got := newTestServer(t, false)
+	sess := &session{}
+
+	// 不加密报文（车辆未配置密钥）照常处理，但不绑定下行通道
+	s.handleFrame("a", sess, frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptNone, "", statePayload("V0000002")))
+	if len(*got) != 1 || s.Connected("V0000002") || sess.vehicleId != "" {
+		t.Fatalf("cleartext frame: handled=%d connected=%v bound=%q", len(*got), s.Connected("V0000002"), sess.vehicleId)
+	}
+	// 不加密的指令应答一律拒绝
+	s.handleFrame("a", sess, frame(t, protocol.DataCategoryVeh2CloudCmdAck, protocol.EncryptNone, "", ackPayload("V0000002", 1)))
+	if len(*got) != 1 || s.QuarantinedCount() != 1 {
+		t.Fatalf("cleartext ack: handled=%d quarantined=%d", len(*got), s.QuarantinedCount())
+	}
+
+	// 以车辆密钥认证的报文绑定连接，之后该车辆的应答被接受
+	s.handleFrame("a", sess, frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptAESGCM, "V0000001", statePayload("V0000001")))
+	if !s.Connected("V0000001") || sess.vehicleId != "V0000001" {
+		t.Fatalf("authenticated frame did not bind the session: bound=%q", sess.vehicleId)
+	}
+	s.handleFrame("a", sess, frame(t, protocol.DataCategoryVeh2CloudCmdAck, protocol.EncryptAESGCM, "V0000001", ackPayload("V0000001", 7)))
+	if last := (*got)[len(*got)-1]; last.Kind != protocol.MessageKindCommandAck || last.CommandAck.CommandId != 7 {
+		t.Fatalf("authenticated ack was not handled: %+v", last)
+	}
+
+	// 另一个连接上的伪造明文报文不能抢走下行通道
+	spoof := &session{}
+	s.handleFrame("b", spoof, frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptNone, "", statePayload("V0000001")))
+	if spoof.vehicleId != "" || s.sessions["V0000001"] != sess {
+		t.Fatal("cleartext frame rebound the vehicle's downlink session")
+	}
+
+	// 车辆以新连接重连时以新连接为准，旧连接关闭时不解除新连接
+	next := &session{}
+	s.handleFrame("c", next, frame(t, protocol.DataCategoryVeh2CloudState, protocol.EncryptAESGCM, "V0000001", statePayload("V0000001")))
+	s.unbindSession(sess.vehicleId, sess)
+	if s.sessions["V0000001"] != next {
+		t.Fatal("reconnected session is not bound")
+	}
+}
@@ -3,6 +3,16 @@
 
 package types
 
+type ActiveTask struct {
+	TaskId             string     `json:"taskId"`
+	OrderId            string     `json:"orderId"`
+	VehicleId          string     `json:"vehicleId"`          // 已分配的车辆，未分配时为空
+	Pickup             Position2D `json:"pickup"`             // 取货点
+	Destination        Position2D `json:"destination"`        // 目的地
+	ReachedPickup      bool       `json:"reachedPickup"`      // 已到达取货点
+	ReachedDestination bool       `json:"reachedDestination"` // 已到达目的地
+}
+
 type CreateVehicleReq struct {
 	PlateNumber   string `json:"plateNumber,optional"` // 车牌号
 	Type          int    `json:"type"`                 // 必填, 车型: 0 普通车, 1 大型车, 2 冷藏车, 3 冷冻车
@@ -28,6 +38,27 @@ type DispatchResp struct {
 	Status    int    `json:"status"`
 }
 
+type EventBusStatsResp struct {
+	Code        int                    `json:"code"`
+	Message     string                 `json:"message"`
+	Published   uint64                 `json:"published"`   // 累计发布事件数
+	Subscribers []EventSubscriberStats `json:"subscribers"` // 各订阅者指标
+}
+
+type EventSubscriberStats struct {
+	Id          uint64 `json:"id"`
+	Group       string `json:"group"`                // 订阅者类别：ws-broadcast / task-monitor / dispatch
+	Policy      string `json:"policy"`               // 缓冲区满时的丢弃策略
+	VehicleIds  string `json:"vehicleIds,omitempty"` // 车辆过滤（逗号分隔），为空表示全部
+	Types       string `json:"types,omitempty"`      // 事件类型过滤（逗号分隔），为空表示全部
+	Capacity    int    `json:"capacity"`             // 缓冲区容量
+	Buffered    int    `json:"buffered"`             // 当前缓冲的事件数
+	MaxBuffered int64  `json:"maxBuffered"`          // 缓冲区历史最高水位
+	Delivered   uint64 `json:"delivered"`            // 成功放入缓冲区的事件数
+	Dropped     uint64 `json:"dropped"`              // 因缓冲区已满被丢弃的事件数
+	Since       string `json:"since"`                // 订阅时间，RFC3339 UTC
+}
+
 type FixedHeader struct {
 	StartByte    byte   `json:"startByte"`    // 标识位：固定为 0xF2
 	DataLength   uint32 `json:"dataLength"`   // 数据段长度：[0..4294967296]，表示当前报文中数据段内容所占字节数，单位：字节，最多描述 4GB 数据
@@ -42,6 +73,26 @@ type OfflinePosition struct {
 	Position  Position2D `json:"position"`
 }
 
+type PlatformEndpointStatus struct {
+	Name                string `json:"name"`                // 接口名称：VEHInfo / VEHPosition / VEHTrajectory / VEHRoute
+	URL                 string `json:"url"`                 // 接口地址
+	BreakerState        string `json:"breakerState"`        // 熔断器状态：closed / open / half-open
+	ConsecutiveFailures int    `json:"consecutiveFailures"` // 连续失败次数
+	BreakerOpens        uint64 `json:"breakerOpens"`        // 累计熔断次数
+	Requests            uint64 `json:"requests"`            // 累计发出的请求数（含重试）
+	Retries             uint64 `json:"retries"`             // 累计重试次数
+	Failures            uint64 `json:"failures"`            // 最终失败的调用数
+	LastError           string `json:"lastError"`           // 最近一次错误
+	LastErrorAt         string `json:"lastErrorAt"`         // 最近一次错误时间（RFC3339）
+	LastSuccessAt       string `json:"lastSuccessAt"`       // 最近一次成功时间（RFC3339）
+}
+
+type PlatformStatusResp struct {
+	Code      int                      `json:"code"`
+	Message   string                   `json:"message"`
+	Endpoints []PlatformEndpointStatus `json:"endpoints"`
+}
+
 type Position struct {
 	Lon       float64 `json:"lon"`       // 经度: [0..3600000000]，单位：1e-7°，数据偏移量 180，表示-180.0000000°～180.0000000°，大于 0 表示东经，不可缺省，0xFFFFFFFF 表示异常
 	Lat       float64 `json:"lat"`       // 纬度: [0..1800000000]，单位：1e-7°，数据偏移量 90，表示-90.0000000°～90.0000000°，大于 0 表示北纬，不可缺省，0xFFFFFFFF 表示异常
@@ -59,6 +110,36 @@ type PositionPoint struct {
 	Latitude  int64  `json:"latitude"`
 }
 
+type ReplayReq struct {
+	File      string  `json:"file"`               // 录制目录中的文件名，见 /api/vehicle/replay/status 的 recordings
+	Speed     float64 `json:"speed,optional"`     // 回放倍速，默认 1
+	Max       bool    `json:"max,optional"`       // true 时不等待，以最快速度回放
+	VehicleId string  `json:"vehicleId,optional"` // 只回放该车辆的消息
+	From      int64   `json:"from,optional"`      // 只回放接收时间不早于该值的消息（Unix 毫秒）
+	To        int64   `json:"to,optional"`        // 只回放接收时间不晚于该值的消息（Unix 毫秒）
+}
+
+type ReplayStatusResp struct {
+	Code          int      `json:"code"`
+	Message       string   `json:"message"`
+	Running       bool     `json:"running"`       // 是否正在回放
+	File          string   `json:"file"`          // 当前或最近一次回放的文件
+	Speed         float64  `json:"speed"`         // 回放倍速
+	Max           bool     `json:"max"`           // 是否以最快速度回放
+	VehicleId     string   `json:"vehicleId"`     // 车辆过滤条件
+	Read          uint64   `json:"read"`          // 已读取的消息数
+	Replayed      uint64   `json:"replayed"`      // 已回放的消息数
+	Skipped       uint64   `json:"skipped"`       // 被过滤条件跳过的消息数
+	Errors        uint64   `json:"errors"`        // 无法解析或回放失败的消息数
+	StartedAt     string   `json:"startedAt"`     // 开始时间（RFC3339）
+	FinishedAt    string   `json:"finishedAt"`    // 结束时间（RFC3339），进行中为空
+	LastError     string   `json:"lastError"`     // 最近一次错误
+	Recording     bool     `json:"recording"`     // 是否正在录制
+	Recorded      uint64   `json:"recorded"`      // 已录制的消息数
+	RecordDropped uint64   `json:"recordDropped"` // 因缓冲已满或写入失败未录制的消息数
+	Recordings    []string `json:"recordings"`    // 录制目录中的文件
+}
+
 type ResultResp struct {
 	Result string `json:"result"`
 }
@@ -66,9 +147,84 @@ type ResultResp struct {
 type Route2TrajectoryResp struct {
 	Code    int          `json:"code"`    // 错误码，0 表示成功
 	Message string       `json:"message"` // 操作信息
+	Partial bool         `json:"partial"` // 为 true 表示行程或轨迹未取全（平台出错或分页未取满），data 只包含已取到的部分，原因见 message
 	Data    []Trajectory `json:"data"`
 }
 
+type SamplingStatsResp struct {
+	Code     int                    `json:"code"`
+	Message  string                 `json:"message"`
+	Checked  uint64                 `json:"checked"` // 累计检查记录数
+	Kept     uint64                 `json:"kept"`    // 累计保留记录数
+	Dropped  uint64                 `json:"dropped"` // 累计丢弃记录数
+	Triggers []SamplingTriggerStats `json:"triggers"`
+	Sources  []SamplingSourceStats  `json:"sources"` // 各降频器的计数，上面的合计为其总和
+}
+
+type SamplingSourceStats struct {
+	Source   string                 `json:"source"`  // 使用独立降频器的上游来源名称，为空表示共用降频器（TCP/MQTT 接入及未单独配置降频的来源）
+	Checked  uint64                 `json:"checked"` // 累计检查记录数
+	Kept     uint64                 `json:"kept"`    // 累计保留记录数
+	Dropped  uint64                 `json:"dropped"` // 累计丢弃记录数
+	Triggers []SamplingTriggerStats `json:"triggers"`
+}
+
+type SamplingTriggerStats struct {
+	Trigger string `json:"trigger"` // 触发条件: first / field / max_interval / distance / heading / out_of_order
+	Kept    uint64 `json:"kept"`    // 因该条件保留的记录数
+}
+
+type SequencerStatsResp struct {
+	Code            int    `json:"code"`
+	Message         string `json:"message"`
+	ReorderWindowMs int64  `json:"reorderWindowMs"` // 重排窗口（毫秒）
+	LatePolicy      string `json:"latePolicy"`      // 迟到数据处理方式: write_only / broadcast
+	Received        uint64 `json:"received"`        // 累计提交记录数
+	Duplicates      uint64 `json:"duplicates"`      // 因时间戳重复丢弃的记录数
+	Reordered       uint64 `json:"reordered"`       // 在重排窗口内被调整顺序的记录数
+	Late            uint64 `json:"late"`            // 迟到记录数（时间戳早于已放行记录）
+	Forced          uint64 `json:"forced"`          // 因缓冲已满提前放行的次数
+	Buffered        int    `json:"buffered"`        // 当前缓冲中的记录数
+	Vehicles        int    `json:"vehicles"`        // 已跟踪的车辆数
+}
+
+type SinkStats struct {
+	Name        string `json:"name"`        // Sink 名称: influx / mysql / file
+	Concurrency int    `json:"concurrency"` // 并发写入协程数
+	Queued      int    `json:"queued"`      // 当前排队的批次数
+	QueueSize   int    `json:"queueSize"`   // 队列容量（批次）
+	Batches     uint64 `json:"batches"`     // 写入成功的批次数
+	Records     uint64 `json:"records"`     // 写入成功的记录数
+	Failed      uint64 `json:"failed"`      // 重试后仍失败的批次数
+	Retries     uint64 `json:"retries"`     // 累计重试次数
+	Dropped     uint64 `json:"dropped"`     // 丢弃的记录数（未启用 WAL 或落盘失败）
+	Quarantined uint64 `json:"quarantined"` // 写入目标拒绝（不可重试）、移入 WAL 隔离目录的记录数
+	WAL         bool   `json:"wal"`         // 失败批次是否落盘重放
+	LastError   string `json:"lastError"`   // 最近一次失败原因
+	LastErrorAt string `json:"lastErrorAt"` // 最近一次失败时间（RFC3339）
+}
+
+type SinkStatsResp struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Sinks   []SinkStats `json:"sinks"`
+}
+
+type StatePushResp struct {
+	Code     int               `json:"code"`
+	Message  string            `json:"message"`
+	Accepted int               `json:"accepted"` // 接收条数
+	Rejected int               `json:"rejected"` // 拒绝条数
+	Results  []StatePushResult `json:"results"`  // 按请求顺序的逐条结果
+}
+
+type StatePushResult struct {
+	Index     int    `json:"index"`            // 在请求中的序号（从 0 开始）
+	VehicleId string `json:"vehicleId"`        // 车辆编号
+	Accepted  bool   `json:"accepted"`         // 是否接收
+	Reason    string `json:"reason,omitempty"` // 拒绝原因
+}
+
 type Trajectory struct {
 	RouteId            string          `json:"routeId"`
 	VehicleId          string          `json:"vehicleId"`
@@ -93,6 +249,47 @@ type TrajectoryReq struct {
 	EndUtc    string `json:"endUtc"`    // RFC3339 UTC 时间戳
 }
 
+type UnregisteredApproveReq struct {
+	VehicleId    string `json:"vehicleId"`             // 车辆编号
+	PlateNo      string `json:"plateNo,optional"`      // 车牌号
+	CategoryCode int    `json:"categoryCode,optional"` // 车辆类型编码，不传时取最近一次上报中的 categoryCode
+	CategoryName string `json:"categoryName,optional"` // 车辆类型
+	VinCode      string `json:"vinCode,optional"`      // VIN码
+}
+
+type UnregisteredBlockReq struct {
+	VehicleId string `json:"vehicleId"` // 车辆编号，拉黑后该车数据全部拒绝（审核通过可解除）
+}
+
+type UnregisteredListReq struct {
+	Status string `form:"status,optional"` // 可选，按状态过滤：pending / approved / blocked
+	Limit  int    `form:"limit,optional"`  // 可选，返回条数，默认 100，最大 500
+}
+
+type UnregisteredListResp struct {
+	Code          int                   `json:"code"`
+	Message       string                `json:"message"`
+	KnownVehicles int                   `json:"knownVehicles"` // 缓存中已登记的车辆数
+	Unknown       uint64                `json:"unknown"`       // 启动以来收到的未登记车辆状态数
+	Rejected      uint64                `json:"rejected"`      // 其中被拒绝（已拉黑或 unknownPolicy=reject）的状态数
+	Vehicles      []UnregisteredVehicle `json:"vehicles"`
+}
+
+type UnregisteredVehicle struct {
+	VehicleId   string            `json:"vehicleId"`   // 车辆编号
+	Status      string            `json:"status"`      // pending：待审核；approved：已登记；blocked：已拉黑
+	FirstSeen   string            `json:"firstSeen"`   // 首次上报时间（RFC3339）
+	LastSeen    string            `json:"lastSeen"`    // 最近一次上报时间（RFC3339）
+	ReportCount int64             `json:"reportCount"` // 累计上报次数
+	LastPayload *VehicleStateData `json:"lastPayload"` // 最近一次上报的数据
+}
+
+type UnregisteredVehicleResp struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Vehicle UnregisteredVehicle `json:"vehicle"`
+}
+
 type UpdateVehicleReq struct {
 	VehicleId     string  `json:"vehicleId"` // 必填
 	PlateNumber   *string `json:"plateNumber,optional"`
@@ -104,6 +301,100 @@ type UpdateVehicleReq struct {
 	Extra         *string `json:"extra,optional"`
 }
 
+type UpstreamEnableReq struct {
+	Name    string `json:"name"`    // 来源名称
+	Enabled bool   `json:"enabled"` // true 启用并连接，false 断开并停用
+}
+
+type UpstreamStatus struct {
+	Name               string `json:"name"`               // 上游名称
+	URL                string `json:"url"`                // 上游地址（不含鉴权参数）
+	State              string `json:"state"`              // connecting / connected / backoff / disconnected
+	ConnectedAt        string `json:"connectedAt"`        // 本次连接建立时间（RFC3339），未连接时为空
+	LastMessageAt      string `json:"lastMessageAt"`      // 最近一次收到消息的时间（RFC3339）
+	LastMessageAgo     int64  `json:"lastMessageAgo"`     // 距最近一次收到消息的秒数，从未收到时为 -1
+	LastPongAt         string `json:"lastPongAt"`         // 最近一次收到 Pong 的时间（RFC3339）
+	HeartbeatSec       int    `json:"heartbeatSec"`       // 心跳间隔（秒），0 表示未启用
+	ReadIdleTimeoutSec int    `json:"readIdleTimeoutSec"` // 读空闲超时（秒），0 表示未启用
+	BackoffMs          int64  `json:"backoffMs"`          // 处于 backoff 状态时距下次重连的等待时长
+	Reconnects         uint64 `json:"reconnects"`         // 累计断线重连次数
+	IdleTimeouts       uint64 `json:"idleTimeouts"`       // 其中由读空闲看门狗触发的次数
+	Messages           uint64 `json:"messages"`           // 累计收到的消息数
+	MessagesPerMin     uint64 `json:"messagesPerMin"`     // 最近 60 秒收到的消息数
+	LastError          string `json:"lastError"`          // 最近一次断开原因
+	LastErrorAt        string `json:"lastErrorAt"`        // 最近一次断开时间（RFC3339）
+	Enabled            bool   `json:"enabled"`            // 是否启用
+	Received           uint64 `json:"received"`           // 累计收到的车辆状态数
+	Filtered           uint64 `json:"filtered"`           // 因车辆类型不在 categories 中丢弃的状态数
+}
+
+type UpstreamStatusResp struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Sources []UpstreamStatus `json:"sources"`
+}
+
+type ValidationRuleStats struct {
+	Name     string `json:"name"`     // 规则名称
+	Type     string `json:"type"`     // 规则类型: range / max_speed / timestamp_skew
+	Rejected uint64 `json:"rejected"` // 该规则累计拒绝的记录数
+}
+
+type ValidationStatsResp struct {
+	Code        int                   `json:"code"`
+	Message     string                `json:"message"`
+	Checked     uint64                `json:"checked"`     // 累计校验记录数
+	Rejected    uint64                `json:"rejected"`    // 累计拒绝记录数
+	Measurement string                `json:"measurement"` // 被拒绝记录写入的 Influx measurement
+	Rules       []ValidationRuleStats `json:"rules"`
+}
+
+type VehicleCommand struct {
+	CommandId   int64  `json:"commandId"`   // 指令编号（同时作为报文中的 commandId）
+	VehicleId   string `json:"vehicleId"`   // 目标车辆
+	CommandType string `json:"commandType"` // 指令类型
+	Params      string `json:"params"`      // 指令参数（JSON）
+	Status      string `json:"status"`      // 状态: pending 待发送, sent 已发送待应答, acked 车端已执行, rejected 车端拒绝, timeout 应答超时, failed 发送失败
+	Transport   string `json:"transport"`   // 实际使用的下行通道: tcp / vehstate
+	Attempts    int    `json:"attempts"`    // 已发送次数（含重试）
+	ResultCode  int    `json:"resultCode"`  // 车端应答结果码，0 表示成功
+	LastError   string `json:"lastError"`   // 最近一次发送失败原因
+	CreatedAt   string `json:"createdAt"`   // RFC3339 UTC
+	SentAt      string `json:"sentAt"`      // RFC3339 UTC，最近一次发送时间
+	AckedAt     string `json:"ackedAt"`     // RFC3339 UTC，收到应答时间
+}
+
+type VehicleCommandListReq struct {
+	VehicleId string `form:"vehicleId,optional"` // 可选，按车辆过滤
+	Limit     int    `form:"limit,optional"`     // 可选，返回条数，默认 100，最大 500
+}
+
+type VehicleCommandListResp struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    []VehicleCommand `json:"data"`
+}
+
+type VehicleCommandQueryReq struct {
+	CommandId int64 `form:"commandId"` // 必填
+}
+
+type VehicleCommandReq struct {
+	VehicleId        string     `json:"vehicleId"`                 // 必填, 目标车辆
+	CommandType      string     `json:"commandType"`               // 必填, dispatch_task | return_to_base | lock_compartment | unlock_compartment | change_report_rate
+	TaskId           string     `json:"taskId,optional"`           // dispatch_task: 任务编号
+	Pickup           Position2D `json:"pickup,optional"`           // dispatch_task: 取货点
+	Destination      Position2D `json:"destination,optional"`      // dispatch_task: 目的地; return_to_base: 基地位置（不传则使用车端默认基地）
+	Compartment      int        `json:"compartment,optional"`      // lock/unlock_compartment: 货舱编号, 0 表示全部
+	ReportIntervalMs int        `json:"reportIntervalMs,optional"` // change_report_rate: 上报间隔（毫秒）
+}
+
+type VehicleCommandResp struct {
+	Code    int            `json:"code"`
+	Message string         `json:"message"`
+	Data    VehicleCommand `json:"data"`
+}
+
 type VehicleDetailResp struct {
 	Vehicle VehicleInfo       `json:"vehicle"`
 	Extra   map[string]string `json:"extra,omitempty"`
@@ -186,6 +477,7 @@ type VehicleStateData struct {
 	Parking         int     `json:"parking"`         // 停车灯 0/1
 	VehFault        int     `json:"vehFault"`        // 车辆故障状态 按位定义，返回按位掩码整数数组（Integer[]），若未上报返回空
 	Doors           []int   `json:"doors"`           // 车门状态 按位定义，返回按位掩码整数数组（Integer[]），若未上报返回空
+	Source          string  `json:"source,optional"` // 数据来源（上游来源名称），由服务端填写
 }
 
 type VehicleStateReq struct {
@@ -198,3 +490,85 @@ type VehicleStateResp struct {
 	Message string           `json:"message"` // 操作信息
 	Data    VehicleStateData `json:"data"`    // 返回的数据对象
 }
+
+type WALStatsResp struct {
+	Code         int    `json:"code"`
+	Message      string `json:"message"`
+	Dir          string `json:"dir"`          // 分段文件目录
+	Segments     int    `json:"segments"`     // 积压分段数（含当前写入中的分段）
+	Records      int    `json:"records"`      // 积压记录数
+	Bytes        int64  `json:"bytes"`        // 积压字节数
+	OldestAgeSec int64  `json:"oldestAgeSec"` // 最早积压分段的时长（秒），无积压时为 0
+	Spilled      uint64 `json:"spilled"`      // 累计落盘记录数
+	Replayed     uint64 `json:"replayed"`     // 累计重放成功记录数
+	Dropped      uint64 `json:"dropped"`      // 因达到总大小上限未能落盘而丢弃的记录数（已落盘的记录不会被丢弃）
+	Quarantined  uint64 `json:"quarantined"`  // 写入目标拒绝、移入隔离目录（quarantine/）的记录数
+}
+
+type WSClientDisconnectReq struct {
+	Id uint64 `json:"id"` // 连接编号（见 GET /api/vehicle/ws/clients）
+}
+
+type WSClientDisconnectResp struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Client  WSClientInfo `json:"client"` // 断开前的连接信息
+}
+
+type WSClientInfo struct {
+	Id             uint64         `json:"id"`             // 连接编号
+	RemoteAddr     string         `json:"remoteAddr"`     // 对端地址
+	ServiceId      string         `json:"serviceId"`      // 连接时指定的 serviceId
+	Token          string         `json:"token"`          // 令牌名称（静态令牌的 name 或 JWT 的 sub），未启用令牌校验时为空
+	Subscription   WSSubscription `json:"subscription"`   // 当前订阅
+	ConnectedAt    string         `json:"connectedAt"`    // 连接建立时间（RFC3339）
+	LastSeenAt     string         `json:"lastSeenAt"`     // 最近一次收到 Pong 或消息的时间（RFC3339）
+	Sent           uint64         `json:"sent"`           // 已写出的帧数
+	Coalesced      uint64         `json:"coalesced"`      // 被同一车辆更新的位置覆盖而未发送的条数（不算丢失，客户端收到的是更新的位置）
+	QueueOverflow  uint64         `json:"queueOverflow"`  // 事件队列已满而未能排入的任务/告警帧数（随后断开该客户端）
+	PublishDropped uint64         `json:"publishDropped"` // 连接期间 Hub 路由通道满、超时丢弃的位置消息数与事件入口满丢弃的任务/告警消息数（所有客户端都未收到）
+}
+
+type WSClientListResp struct {
+	Code       int            `json:"code"`
+	Message    string         `json:"message"`
+	Total      int            `json:"total"`
+	Evicted    uint64         `json:"evicted"`    // 事件队列溢出被断开的客户端累计数
+	IdleClosed uint64         `json:"idleClosed"` // 读空闲超时被断开的客户端累计数
+	Kicked     uint64         `json:"kicked"`     // 被管理接口强制断开的客户端累计数
+	Clients    []WSClientInfo `json:"clients"`
+}
+
+type WSClientStats struct {
+	Id              uint64 `json:"id"`              // 连接编号
+	ServiceId       string `json:"serviceId"`       // 连接时指定的 serviceId
+	QueuedPositions int    `json:"queuedPositions"` // 待发送的车辆位置数（每辆车最多一条）
+	QueuedEvents    int    `json:"queuedEvents"`    // 待发送的任务/告警事件数
+	LagMs           int64  `json:"lagMs"`           // 最早一条待发送数据已等待的时长（毫秒），无积压时为 0
+	LastLagMs       int64  `json:"lastLagMs"`       // 最近一次写出的数据在队列中等待的时长（毫秒）
+	MaxLagMs        int64  `json:"maxLagMs"`        // 连接以来最大的排队时长（毫秒）
+	Sent            uint64 `json:"sent"`            // 已写出的帧数
+	Coalesced       uint64 `json:"coalesced"`       // 被同一车辆更新的位置覆盖而未发送的条数
+	QueueOverflow   uint64 `json:"queueOverflow"`   // 事件队列已满而未能排入的任务/告警帧数
+}
+
+type WSStatsResp struct {
+	Code           int             `json:"code"`
+	Message        string          `json:"message"`
+	Pending        int             `json:"pending"`        // 等待路由的消息数
+	PublishDropped uint64          `json:"publishDropped"` // 路由通道满超时丢弃的位置消息数与事件入口满丢弃的任务/告警消息数
+	Evicted        uint64          `json:"evicted"`        // 事件队列溢出被断开的客户端数
+	IdleClosed     uint64          `json:"idleClosed"`     // 读空闲超时被断开的客户端数
+	Kicked         uint64          `json:"kicked"`         // 被管理接口强制断开的客户端数
+	Epoch          string          `json:"epoch"`          // 本次进程的序号空间标识
+	Seq            uint64          `json:"seq"`            // 最近分配的消息序号
+	Buffered       int             `json:"buffered"`       // 续传缓冲中的事件数
+	Clients        []WSClientStats `json:"clients"`
+}
+
+type WSSubscription struct {
+	Types         []string  `json:"types"`
+	VehicleIds    []string  `json:"vehicleIds"`     // null 表示不限，[] 表示不匹配任何车辆
+	CategoryCodes []int     `json:"categoryCodes"`  // null 表示不限，[] 表示不匹配任何车型
+	BBox          []float64 `json:"bbox,omitempty"` // [minLon, minLat, maxLon, maxLat]
+}
@@ -0,0 +1,367 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/apiclient"
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/protocol"
+	"vehicle-api/internal/sampler"
+	"vehicle-api/internal/types"
+)
+
+// DefaultSourceName 未配置 Upstreams 时由 VEHState 生成的来源名称
+const DefaultSourceName = "default"
+
+// healthLogInterval 各来源健康状况的日志间隔
+const healthLogInterval = time.Minute
+
+// ErrNotFound 表示来源名称不存在
+var ErrNotFound = errors.New("upstream source not found")
+
+// Handler 处理某个来源收到的车辆状态，smp 为该来源使用的降频器（为 nil 时不降频）
+type Handler func(data *types.VehicleStateData, smp *sampler.Sampler) error
+
+// Source 一个命名的上游来源：独立的 VEHState 连接、车辆类型过滤与降频器
+type Source struct {
+	name       string
+	client     *apiclient.VEHStateClient
+	sampler    *sampler.Sampler
+	categories map[int]struct{}
+
+	// life 串行化启停（停用需等待连接协程退出）；mu 只保护 enabled，读取状态不会被进行中的停用阻塞
+	life    sync.Mutex
+	mu      sync.Mutex
+	enabled bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	received atomic.Uint64
+	filtered atomic.Uint64
+}
+
+// Manager 管理多个上游来源的连接生命周期，并按车辆最近所在的来源转发下行指令
+type Manager struct {
+	sources []*Source
+	byName  map[string]*Source
+	handle  Handler
+	shared  *sampler.Sampler
+	// vehicleSource 车辆最近一次上报所在的来源（vehicleId -> *Source）
+	vehicleSource sync.Map
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// SourcesFromConfig 返回配置的上游来源；未配置 Upstreams 时把 VEHState 作为名为 default 的唯一来源
+func SourcesFromConfig(c config.Config) []config.UpstreamSourceConfig {
+	if len(c.Upstreams) > 0 {
+		return c.Upstreams
+	}
+	if c.VEHState.URL == "" {
+		return nil
+	}
+	return []config.UpstreamSourceConfig{{Name: DefaultSourceName, VEHStateConfig: c.VEHState}}
+}
+
+// NewManager 为每个来源创建客户端（不连接）。appId/key 为未单独配置凭证的来源使用的顶层凭证；
+// 配置了 sampling 或 sampleIntervalMs 的来源使用独立的降频器，其余来源共用 shared（可为 nil）
+func NewManager(sources []config.UpstreamSourceConfig, appId, key string, global config.SamplingConfig, shared *sampler.Sampler, handle Handler, onAck func(*protocol.CommandAckData)) (*Manager, error) {
+	m := &Manager{
+		byName: make(map[string]*Source, len(sources)),
+		handle: handle,
+		shared: shared,
+		stop:   make(chan struct{}),
+	}
+	for _, sc := range sources {
+		if sc.Name == "" {
+			return nil, errors.New("upstream source name is required")
+		}
+		if _, ok := m.byName[sc.Name]; ok {
+			return nil, fmt.Errorf("duplicate upstream source %q", sc.Name)
+		}
+		if sc.URL == "" {
+			return nil, fmt.Errorf("upstream source %q: url is required", sc.Name)
+		}
+		if sc.AppId == "" {
+			sc.AppId = appId
+		}
+		if sc.Key == "" {
+			sc.Key = key
+		}
+		if sc.AppId == "" || sc.Key == "" {
+			return nil, fmt.Errorf("upstream source %q: appId/key is required", sc.Name)
+		}
+		s := &Source{name: sc.Name, sampler: shared, enabled: !sc.Disabled}
+		if len(sc.Categories) > 0 {
+			s.categories = make(map[int]struct{}, len(sc.Categories))
+			for _, code := range sc.Categories {
+				s.categories[code] = struct{}{}
+			}
+		}
+		if ownSampling(sc) {
+			cfg := global
+			if samplingConfigured(sc.Sampling) {
+				cfg = sc.Sampling
+			}
+			s.sampler = nil
+			if !cfg.Disabled {
+				smp, err := sampler.New(cfg, sc.SampleIntervalMs)
+				if err != nil {
+					return nil, fmt.Errorf("upstream source %q: %w", sc.Name, err)
+				}
+				s.sampler = smp
+			}
+		}
+		s.client = apiclient.NewVEHStateClient(sc.VEHStateConfig, sc.AppId, sc.Key, m.receiver(s))
+		s.client.SetName(sc.Name)
+		if onAck != nil {
+			s.client.SetCommandAckHandler(onAck)
+		}
+		m.sources = append(m.sources, s)
+		m.byName[sc.Name] = s
+	}
+	return m, nil
+}
+
+// ownSampling 判断来源是否需要独立的降频器
+func ownSampling(sc config.UpstreamSourceConfig) bool {
+	return samplingConfigured(sc.Sampling) || sc.SampleIntervalMs > 0
+}
+
+func samplingConfigured(c config.SamplingConfig) bool {
+	return c.Disabled || len(c.Categories) > 0 || c.Default.MaxIntervalMs != 0 || c.Default.MinIntervalMs != 0 ||
+		c.Default.DistanceMeters != 0 || c.Default.HeadingDeg != 0 || len(c.Default.WatchFields) > 0
+}
+
+// SetRawHandler 设置各来源原始消息（解析前）的回调，需在 Start 之前调用
+func (m *Manager) SetRawHandler(fn func(source string, msg []byte)) {
+	for _, s := range m.sources {
+		name := s.name
+		s.client.SetRawHandler(func(msg []byte) { fn(name, msg) })
+	}
+}
+
+// Decode 按指定来源的格式与车辆类型过滤解析一条原始消息（例如回放录制的消息），不交给 Handler、不计入来源的计数；
+// 指令应答与被过滤的消息返回 nil。来源无需处于连接状态
+func (m *Manager) Decode(source string, msg []byte) (*types.VehicleStateData, error) {
+	s, ok := m.byName[source]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, err := s.client.Decode(msg)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if s.categories != nil {
+		if _, ok := s.categories[data.CategoryCode]; !ok {
+			return nil, nil
+		}
+	}
+	data.Source = s.name
+	return data, nil
+}
+
+// receiver 返回来源客户端的数据回调：标记来源、按车辆类型过滤后交给 Handler
+func (m *Manager) receiver(s *Source) func(*types.VehicleStateData) error {
+	return func(data *types.VehicleStateData) error {
+		s.received.Add(1)
+		if s.categories != nil {
+			if _, ok := s.categories[data.CategoryCode]; !ok {
+				s.filtered.Add(1)
+				return nil
+			}
+		}
+		data.Source = s.name
+		m.vehicleSource.Store(data.VehicleId, s)
+		if m.handle == nil {
+			return nil
+		}
+		return m.handle(data, s.sampler)
+	}
+}
+
+// Start 连接全部已启用的来源，并定期输出各来源的健康状况
+func (m *Manager) Start() {
+	for _, s := range m.sources {
+		s.life.Lock()
+		s.mu.Lock()
+		enabled := s.enabled
+		s.mu.Unlock()
+		if enabled {
+			s.start()
+		} else {
+			logx.Infof("上游来源 %s 已停用，跳过连接", s.name)
+		}
+		s.life.Unlock()
+	}
+	m.wg.Add(1)
+	go m.runHealthLog()
+}
+
+// Enable 启用并连接来源
+func (m *Manager) Enable(name string) error {
+	s, ok := m.byName[name]
+	if !ok {
+		return ErrNotFound
+	}
+	s.life.Lock()
+	defer s.life.Unlock()
+	s.mu.Lock()
+	enabled := s.enabled
+	s.enabled = true
+	s.mu.Unlock()
+	if enabled {
+		return nil
+	}
+	s.start()
+	logx.Infof("上游来源 %s 已启用", name)
+	return nil
+}
+
+// Disable 断开并停用来源，等待其连接协程退出
+func (m *Manager) Disable(name string) error {
+	s, ok := m.byName[name]
+	if !ok {
+		return ErrNotFound
+	}
+	s.life.Lock()
+	defer s.life.Unlock()
+	s.mu.Lock()
+	enabled := s.enabled
+	s.enabled = false
+	s.mu.Unlock()
+	if !enabled {
+		return nil
+	}
+	s.halt()
+	logx.Infof("上游来源 %s 已停用", name)
+	return nil
+}
+
+// Status 返回各来源的连接状态与计数
+func (m *Manager) Status() []types.UpstreamStatus {
+	out := make([]types.UpstreamStatus, 0, len(m.sources))
+	for _, s := range m.sources {
+		out = append(out, s.status())
+	}
+	return out
+}
+
+// SamplingStats 返回使用独立降频器的来源的降频计数（共用降频器的来源不在其中）
+func (m *Manager) SamplingStats() []types.SamplingSourceStats {
+	var out []types.SamplingSourceStats
+	for _, s := range m.sources {
+		if s.sampler == nil || s.sampler == m.shared {
+			continue
+		}
+		st := s.sampler.Stats()
+		out = append(out, types.SamplingSourceStats{
+			Source:   s.name,
+			Checked:  st.Checked,
+			Kept:     st.Kept,
+			Dropped:  st.Dropped,
+			Triggers: st.Triggers,
+		})
+	}
+	return out
+}
+
+// Connected 判断车辆最近所在来源的连接是否可用；从未收到该车数据时只要任一来源已连接即可
+func (m *Manager) Connected(vehicleId string) bool {
+	if v, ok := m.vehicleSource.Load(vehicleId); ok {
+		return v.(*Source).client.Connected()
+	}
+	for _, s := range m.sources {
+		if s.client.Connected() {
+			return true
+		}
+	}
+	return false
+}
+
+// SendCommand 经车辆最近所在来源的连接转发下行指令；从未收到该车数据时使用第一个已连接的来源
+func (m *Manager) SendCommand(vehicleId string, commandId uint32, frame []byte) error {
+	if v, ok := m.vehicleSource.Load(vehicleId); ok {
+		return v.(*Source).client.SendCommand(vehicleId, commandId, frame)
+	}
+	for _, s := range m.sources {
+		if s.client.Connected() {
+			return s.client.SendCommand(vehicleId, commandId, frame)
+		}
+	}
+	return fmt.Errorf("车辆 %s 没有可用的上游连接", vehicleId)
+}
+
+// Stop 断开全部来源
+func (m *Manager) Stop() {
+	close(m.stop)
+	m.wg.Wait()
+	for _, s := range m.sources {
+		s.life.Lock()
+		s.halt()
+		s.life.Unlock()
+	}
+}
+
+func (m *Manager) runHealthLog() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(healthLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			for _, st := range m.Status() {
+				if !st.Enabled {
+					continue
+				}
+				if st.State != apiclient.UpstreamConnected {
+					logx.Errorf("上游来源 %s 未连接 state=%s reconnects=%d lastError=%s", st.Name, st.State, st.Reconnects, st.LastError)
+					continue
+				}
+				logx.Infof("上游来源 %s 正常 messagesPerMin=%d lastMessageAgo=%ds received=%d filtered=%d reconnects=%d",
+					st.Name, st.MessagesPerMin, st.LastMessageAgo, st.Received, st.Filtered, st.Reconnects)
+			}
+		}
+	}
+}
+
+// start 在后台连接（调用方持有 s.life）
+func (s *Source) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		s.client.Start(ctx)
+	}()
+}
+
+// halt 断开连接并等待连接协程退出（调用方持有 s.life）
+func (s *Source) halt() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel, s.done = nil, nil
+}
+
+func (s *Source) status() types.UpstreamStatus {
+	st := s.client.Status()
+	s.mu.Lock()
+	st.Enabled = s.enabled
+	s.mu.Unlock()
+	st.Received = s.received.Load()
+	st.Filtered = s.filtered.Load()
+	return st
+}
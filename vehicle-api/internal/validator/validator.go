@@ -0,0 +1,252 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/geo"
+	"vehicle-api/internal/types"
+)
+
+// 规则类型
+const (
+	RuleRange         = "range"
+	RuleMaxSpeed      = "max_speed"
+	RuleTimestampSkew = "timestamp_skew"
+)
+
+// DefaultQuarantineMeasurement 被拒绝记录默认写入的 measurement
+const DefaultQuarantineMeasurement = "vehicle_status_quarantine"
+
+// Violation 描述一条记录未通过的规则
+type Violation struct {
+	Rule   string // 规则名称
+	Type   string // 规则类型
+	Reason string // 具体原因（含实际值），写入隔离 measurement
+}
+
+func (v *Violation) Error() string {
+	return v.Rule + ": " + v.Reason
+}
+
+// rule 为编译后的规则
+type rule struct {
+	cfg      config.ValidationRule
+	field    func(*types.VehicleStateData) float64
+	rejected atomic.Uint64
+}
+
+// lastPoint 该车上一条通过校验的位置
+type lastPoint struct {
+	ts       uint64
+	lon, lat float64
+	jumps    int // 相对该位置连续被 max_speed 拒绝的次数
+}
+
+// maxConsecutiveJumps 相对同一位置连续被 max_speed 拒绝的次数达到该值时，认为是参考点本身有误
+// （例如首个点漂移），放行当前记录并以其作为新的参考点，避免该车之后的数据被持续拒绝
+const maxConsecutiveJumps = 3
+
+// Validator 在持久化与广播之前按规则检查车辆状态。
+// max_speed 规则依赖每辆车上一条通过校验的位置，因此被拒绝的记录不会更新该位置。
+type Validator struct {
+	rules       []*rule
+	measurement string
+
+	mu   sync.Mutex
+	last map[string]lastPoint
+
+	checked  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// fieldGetters 可用于 range 规则的字段
+var fieldGetters = map[string]func(*types.VehicleStateData) float64{
+	"lon":       func(d *types.VehicleStateData) float64 { return d.Lon },
+	"lat":       func(d *types.VehicleStateData) float64 { return d.Lat },
+	"speed":     func(d *types.VehicleStateData) float64 { return d.Speed },
+	"heading":   func(d *types.VehicleStateData) float64 { return d.Heading },
+	"soc":       func(d *types.VehicleStateData) float64 { return d.Soc },
+	"mileage":   func(d *types.VehicleStateData) float64 { return d.Mileage },
+	"timestamp": func(d *types.VehicleStateData) float64 { return float64(d.Timestamp) },
+}
+
+// DefaultRules 未配置规则时使用的内置规则
+func DefaultRules() []config.ValidationRule {
+	f := func(v float64) *float64 { return &v }
+	return []config.ValidationRule{
+		{Name: "lon_range", Type: RuleRange, Field: "lon", Min: f(-180), Max: f(180)},
+		{Name: "lat_range", Type: RuleRange, Field: "lat", Min: f(-90), Max: f(90)},
+		{Name: "speed_range", Type: RuleRange, Field: "speed", Min: f(0), Max: f(70)},
+		{Name: "teleport", Type: RuleMaxSpeed, MaxSpeedKmh: 200},
+		{Name: "timestamp_skew", Type: RuleTimestampSkew, MaxFutureSec: 300},
+	}
+}
+
+// New 按配置创建校验器；规则配置非法时返回错误
+func New(cfg config.ValidationConfig) (*Validator, error) {
+	v := &Validator{measurement: cfg.QuarantineMeasurement, last: make(map[string]lastPoint)}
+	if v.measurement == "" {
+		v.measurement = DefaultQuarantineMeasurement
+	}
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	for i, rc := range rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("%s_%d", rc.Type, i)
+		}
+		r := &rule{cfg: rc}
+		switch rc.Type {
+		case RuleRange:
+			get, ok := fieldGetters[rc.Field]
+			if !ok {
+				return nil, fmt.Errorf("validation rule %s: unsupported field %q", rc.Name, rc.Field)
+			}
+			if rc.Min == nil && rc.Max == nil {
+				return nil, fmt.Errorf("validation rule %s: min or max required", rc.Name)
+			}
+			r.field = get
+		case RuleMaxSpeed:
+			if rc.MaxSpeedKmh <= 0 {
+				return nil, fmt.Errorf("validation rule %s: maxSpeedKmh must be > 0", rc.Name)
+			}
+		case RuleTimestampSkew:
+			if rc.MaxFutureSec <= 0 && rc.MaxPastSec <= 0 {
+				return nil, fmt.Errorf("validation rule %s: maxFutureSec or maxPastSec required", rc.Name)
+			}
+		default:
+			return nil, fmt.Errorf("validation rule %s: unknown type %q", rc.Name, rc.Type)
+		}
+		v.rules = append(v.rules, r)
+	}
+	return v, nil
+}
+
+// QuarantineMeasurement 返回被拒绝记录写入的 measurement
+func (v *Validator) QuarantineMeasurement() string {
+	return v.measurement
+}
+
+// Check 依次执行规则，返回第一条未通过的规则；全部通过时返回 nil 并记录该车最新位置
+func (v *Validator) Check(d *types.VehicleStateData) *Violation {
+	v.checked.Add(1)
+	now := time.Now()
+
+	v.mu.Lock()
+	prev, hasPrev := v.last[d.VehicleId]
+	v.mu.Unlock()
+
+	for _, r := range v.rules {
+		reason := ""
+		switch r.cfg.Type {
+		case RuleRange:
+			reason = checkRange(r, d)
+		case RuleMaxSpeed:
+			if hasPrev {
+				reason = checkSpeed(r, prev, d)
+				if reason != "" && v.countJump(d.VehicleId) {
+					reason = ""
+				}
+			}
+		case RuleTimestampSkew:
+			reason = checkSkew(r, d, now)
+		}
+		if reason != "" {
+			r.rejected.Add(1)
+			v.rejected.Add(1)
+			return &Violation{Rule: r.cfg.Name, Type: r.cfg.Type, Reason: reason}
+		}
+	}
+
+	v.mu.Lock()
+	// 仅在时间向前时更新，迟到的记录不覆盖更新的位置
+	if cur, ok := v.last[d.VehicleId]; !ok || d.Timestamp >= cur.ts {
+		v.last[d.VehicleId] = lastPoint{ts: d.Timestamp, lon: d.Lon, lat: d.Lat}
+	}
+	v.mu.Unlock()
+	return nil
+}
+
+// countJump 记录一次 max_speed 拒绝，返回是否已达到重置参考点的次数
+func (v *Validator) countJump(vehicleId string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p := v.last[vehicleId]
+	p.jumps++
+	if p.jumps >= maxConsecutiveJumps {
+		// 放行后由 Check 以当前记录覆盖参考点
+		p.ts = 0
+		v.last[vehicleId] = p
+		return true
+	}
+	v.last[vehicleId] = p
+	return false
+}
+
+// Stats 返回累计校验数与各规则拒绝数
+func (v *Validator) Stats() *types.ValidationStatsResp {
+	resp := &types.ValidationStatsResp{
+		Checked:     v.checked.Load(),
+		Rejected:    v.rejected.Load(),
+		Measurement: v.measurement,
+		Rules:       make([]types.ValidationRuleStats, 0, len(v.rules)),
+	}
+	for _, r := range v.rules {
+		resp.Rules = append(resp.Rules, types.ValidationRuleStats{Name: r.cfg.Name, Type: r.cfg.Type, Rejected: r.rejected.Load()})
+	}
+	return resp
+}
+
+func checkRange(r *rule, d *types.VehicleStateData) string {
+	val := r.field(d)
+	if math.IsNaN(val) {
+		return fmt.Sprintf("%s is NaN", r.cfg.Field)
+	}
+	if r.cfg.Min != nil && val < *r.cfg.Min {
+		return fmt.Sprintf("%s=%v below min %v", r.cfg.Field, val, *r.cfg.Min)
+	}
+	if r.cfg.Max != nil && val > *r.cfg.Max {
+		return fmt.Sprintf("%s=%v above max %v", r.cfg.Field, val, *r.cfg.Max)
+	}
+	return ""
+}
+
+// checkSpeed 由相邻两点的距离与时间差推算速度；时间差为 0 时无法推算，跳过
+func checkSpeed(r *rule, prev lastPoint, d *types.VehicleStateData) string {
+	var dtMs float64
+	if d.Timestamp >= prev.ts {
+		dtMs = float64(d.Timestamp - prev.ts)
+	} else {
+		dtMs = float64(prev.ts - d.Timestamp)
+	}
+	if dtMs == 0 {
+		return ""
+	}
+	dist := geo.HaversineMeters(prev.lat, prev.lon, d.Lat, d.Lon)
+	kmh := dist / (dtMs / 1000) * 3.6
+	if kmh > r.cfg.MaxSpeedKmh {
+		return fmt.Sprintf("implied speed %.1fkm/h over %.0fm in %.0fms exceeds %.0fkm/h", kmh, dist, dtMs, r.cfg.MaxSpeedKmh)
+	}
+	return ""
+}
+
+func checkSkew(r *rule, d *types.VehicleStateData, now time.Time) string {
+	nowMs := now.UnixMilli()
+	if d.Timestamp > math.MaxInt64 {
+		return fmt.Sprintf("invalid timestamp %d", d.Timestamp)
+	}
+	ts := int64(d.Timestamp)
+	if r.cfg.MaxFutureSec > 0 && ts-nowMs > int64(r.cfg.MaxFutureSec)*1000 {
+		return fmt.Sprintf("timestamp %d is %ds in the future", ts, (ts-nowMs)/1000)
+	}
+	if r.cfg.MaxPastSec > 0 && nowMs-ts > int64(r.cfg.MaxPastSec)*1000 {
+		return fmt.Sprintf("timestamp %d is %ds in the past", ts, (nowMs-ts)/1000)
+	}
+	return ""
+}
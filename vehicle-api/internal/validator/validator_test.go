@@ -0,0 +1,130 @@
+package validator
+
+import (
+	"testing"
+	"time"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+func newDefault(t *testing.T) *Validator {
+	t.Helper()
+	v, err := New(config.ValidationConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func point(ts uint64, lon, lat float64) *types.VehicleStateData {
+	return &types.VehicleStateData{VehicleId: "V1", Timestamp: ts, Lon: lon, Lat: lat, Speed: 10}
+}
+
+func ruleOf(v *Violation) string {
+	if v == nil {
+		return ""
+	}
+	return v.Rule
+}
+
+func TestDefaultRules(t *testing.T) {
+	base := uint64(time.Now().Add(-time.Minute).UnixMilli())
+	cases := []struct {
+		name string
+		d    *types.VehicleStateData
+		want string
+	}{
+		{"valid", point(base, 116.39, 39.90), ""},
+		{"lon out of range", point(base, 200, 39.90), "lon_range"},
+		{"invalid lon sentinel", point(base, 0xFFFFFFFF, 39.90), "lon_range"},
+		{"lat out of range", point(base, 116.39, -91), "lat_range"},
+		{"negative speed", &types.VehicleStateData{VehicleId: "V1", Timestamp: base, Lon: 116.39, Lat: 39.90, Speed: -1}, "speed_range"},
+		{"far future timestamp", point(uint64(time.Now().Add(10*time.Minute).UnixMilli()), 116.39, 39.90), "timestamp_skew"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ruleOf(newDefault(t).Check(tc.d)); got != tc.want {
+				t.Fatalf("rule = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxSpeedKeepsReferenceOnReject(t *testing.T) {
+	v := newDefault(t)
+	base := uint64(time.Now().Add(-time.Minute).UnixMilli())
+	if r := v.Check(point(base, 116.0, 39.0)); r != nil {
+		t.Fatal(r)
+	}
+	// 1 秒移动约 11km
+	if got := ruleOf(v.Check(point(base+1000, 116.0, 39.1))); got != "teleport" {
+		t.Fatalf("jump: rule = %q, want teleport", got)
+	}
+	// 被拒绝的记录不更新参考点：相对原位置正常移动的记录通过
+	if r := v.Check(point(base+2000, 116.0001, 39.0)); r != nil {
+		t.Fatalf("normal movement after a rejected jump: %v", r)
+	}
+	// 迟到记录通过校验但不覆盖更新的参考点
+	if r := v.Check(point(base+1500, 116.00005, 39.0)); r != nil {
+		t.Fatalf("late record: %v", r)
+	}
+	if got := v.last["V1"].ts; got != base+2000 {
+		t.Fatalf("reference ts = %d, want %d", got, base+2000)
+	}
+	// 相同时间戳无法推算速度，不按 max_speed 拒绝
+	if r := v.Check(point(base+2000, 117.0, 39.0)); r != nil {
+		t.Fatalf("same timestamp: %v", r)
+	}
+}
+
+func TestMaxSpeedResetsAfterConsecutiveJumps(t *testing.T) {
+	v := newDefault(t)
+	base := uint64(time.Now().Add(-time.Minute).UnixMilli())
+	// 首个点漂移，之后的记录都在另一处
+	if r := v.Check(point(base, 100.0, 30.0)); r != nil {
+		t.Fatal(r)
+	}
+	for i := 1; i < maxConsecutiveJumps; i++ {
+		if got := ruleOf(v.Check(point(base+uint64(i)*1000, 116.0, 39.0))); got != "teleport" {
+			t.Fatalf("jump %d: rule = %q, want teleport", i, got)
+		}
+	}
+	// 相对同一参考点连续被拒绝达到上限时放行，并以当前记录为新参考点
+	if r := v.Check(point(base+uint64(maxConsecutiveJumps)*1000, 116.0, 39.0)); r != nil {
+		t.Fatalf("jump %d must reset the reference: %v", maxConsecutiveJumps, r)
+	}
+	if r := v.Check(point(base+uint64(maxConsecutiveJumps+1)*1000, 116.0001, 39.0)); r != nil {
+		t.Fatalf("movement from the new reference: %v", r)
+	}
+	st := v.Stats()
+	if st.Checked != uint64(maxConsecutiveJumps+2) || st.Rejected != uint64(maxConsecutiveJumps-1) {
+		t.Fatalf("stats checked=%d rejected=%d", st.Checked, st.Rejected)
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	one := 1.0
+	cases := []config.ValidationRule{
+		{Name: "x", Type: "unknown"},
+		{Name: "x", Type: RuleRange, Field: "altitude", Max: &one},
+		{Name: "x", Type: RuleRange, Field: "speed"},
+		{Name: "x", Type: RuleMaxSpeed},
+		{Name: "x", Type: RuleTimestampSkew},
+	}
+	for _, rc := range cases {
+		if _, err := New(config.ValidationConfig{Rules: []config.ValidationRule{rc}}); err == nil {
+			t.Errorf("rule %+v must be rejected", rc)
+		}
+	}
+	v, err := New(config.ValidationConfig{Rules: []config.ValidationRule{{Type: RuleTimestampSkew, MaxPastSec: 60}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.QuarantineMeasurement() != DefaultQuarantineMeasurement {
+		t.Fatalf("measurement = %q", v.QuarantineMeasurement())
+	}
+	if got := ruleOf(v.Check(point(uint64(time.Now().Add(-2*time.Minute).UnixMilli()), 0, 0))); got != "timestamp_skew_0" {
+		t.Fatalf("unnamed rule: rule = %q, want timestamp_skew_0", got)
+	}
+}
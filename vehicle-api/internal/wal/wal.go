@@ -0,0 +1,379 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+// 分段文件名：<序号>-<创建时间毫秒>.wal，序号决定重放顺序，创建时间用于计算积压时长
+const segmentExt = ".wal"
+
+// quarantineDir 写入目标拒绝（重放不会成功）的分段与批次移入的子目录，不再重放，需人工处理
+const quarantineDir = "quarantine"
+
+var (
+	// ErrEmpty 表示没有待重放的分段
+	ErrEmpty = errors.New("wal: no pending segment")
+	// ErrFull 表示积压已达到总大小上限，本批未落盘
+	ErrFull = errors.New("wal: total size limit reached")
+)
+
+// Segment 一个已封存、等待重放的分段
+type Segment struct {
+	Seq     uint64
+	Created time.Time
+	Path    string
+	Size    int64
+	Records int
+}
+
+// WAL 以追加写入的 NDJSON 分段文件保存写入失败的车辆状态（每行一条），
+// 当前分段达到大小上限后封存并新建分段；总大小将超过上限时拒绝新的批次（返回 ErrFull），
+// 已落盘（Append 返回成功）的记录只会在重放成功或移入隔离目录后删除。
+// 重放方按序号取最早的分段，写入成功后调用 Remove 删除；写入目标拒绝的分段调用 Quarantine 移入隔离目录，
+// 避免一个坏分段一直阻塞在队首。
+type WAL struct {
+	dir             string
+	segmentMaxBytes int64
+	maxTotalBytes   int64
+
+	mu         sync.Mutex
+	sealed     []*Segment // 按 Seq 升序
+	cur        *os.File
+	curSeg     *Segment
+	nextSeq    uint64
+	totalBytes int64
+
+	spilled     atomic.Uint64
+	replayed    atomic.Uint64
+	dropped     atomic.Uint64
+	quarantined atomic.Uint64
+	batchSeq    atomic.Uint64 // 隔离批次文件名序号
+}
+
+// Open 打开（必要时创建）目录并加载已有分段；上次进程未封存的分段直接视为已封存
+func Open(cfg config.WALConfig) (*WAL, error) {
+	if cfg.SegmentMaxMB <= 0 {
+		cfg.SegmentMaxMB = 16
+	}
+	if cfg.MaxTotalMB <= 0 {
+		cfg.MaxTotalMB = 1024
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &WAL{
+		dir:             cfg.Dir,
+		segmentMaxBytes: int64(cfg.SegmentMaxMB) << 20,
+		maxTotalBytes:   int64(cfg.MaxTotalMB) << 20,
+		nextSeq:         1,
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		seg, ok := parseName(e.Name())
+		if !ok {
+			continue
+		}
+		seg.Path = filepath.Join(cfg.Dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() == 0 {
+			_ = os.Remove(seg.Path)
+			continue
+		}
+		seg.Size = info.Size()
+		seg.Records, err = countLines(seg.Path)
+		if err != nil {
+			return nil, err
+		}
+		w.sealed = append(w.sealed, seg)
+		w.totalBytes += seg.Size
+		if seg.Seq >= w.nextSeq {
+			w.nextSeq = seg.Seq + 1
+		}
+	}
+	sort.Slice(w.sealed, func(i, j int) bool { return w.sealed[i].Seq < w.sealed[j].Seq })
+	if len(w.sealed) > 0 {
+		logx.Infof("WAL 加载积压分段 dir=%s segments=%d bytes=%d", cfg.Dir, len(w.sealed), w.totalBytes)
+	}
+	return w, nil
+}
+
+// Append 把一批记录追加到当前分段并同步到磁盘；超过总大小上限时不写入并返回 ErrFull
+func (w *WAL) Append(items []*types.VehicleStateData) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	n := 0
+	for _, d := range items {
+		if d == nil {
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+		n++
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.totalBytes+int64(buf.Len()) > w.maxTotalBytes {
+		w.dropped.Add(uint64(n))
+		return fmt.Errorf("%w: %d+%d bytes > %d", ErrFull, w.totalBytes, buf.Len(), w.maxTotalBytes)
+	}
+	if w.cur == nil || w.curSeg.Size+int64(buf.Len()) > w.segmentMaxBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.cur.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.cur.Sync(); err != nil {
+		return err
+	}
+	w.curSeg.Size += int64(buf.Len())
+	w.curSeg.Records += n
+	w.totalBytes += int64(buf.Len())
+	w.spilled.Add(uint64(n))
+	return nil
+}
+
+// Oldest 返回最早的待重放分段；没有已封存分段时封存当前分段。没有任何积压时返回 ErrEmpty
+func (w *WAL) Oldest() (*Segment, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.sealed) == 0 {
+		if err := w.seal(); err != nil {
+			return nil, err
+		}
+	}
+	if len(w.sealed) == 0 {
+		return nil, ErrEmpty
+	}
+	seg := *w.sealed[0]
+	return &seg, nil
+}
+
+// Read 读取分段中的全部记录；无法解析的行（例如进程崩溃时写了一半）被跳过
+func (w *WAL) Read(seg *Segment) ([]*types.VehicleStateData, error) {
+	f, err := os.Open(seg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := make([]*types.VehicleStateData, 0, seg.Records)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		var d types.VehicleStateData
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+			logx.Errorf("WAL 跳过无法解析的记录 file=%s line=%d err=%v", seg.Path, line, err)
+			continue
+		}
+		out = append(out, &d)
+	}
+	return out, sc.Err()
+}
+
+// Remove 删除已重放成功的分段
+func (w *WAL) Remove(seg *Segment) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, s := range w.sealed {
+		if s.Seq != seg.Seq {
+			continue
+		}
+		w.sealed = append(w.sealed[:i], w.sealed[i+1:]...)
+		w.totalBytes -= s.Size
+		w.replayed.Add(uint64(s.Records))
+		return os.Remove(s.Path)
+	}
+	return nil
+}
+
+// Quarantine 把无法重放的分段移入隔离目录（文件名不变），之后的分段继续重放
+func (w *WAL) Quarantine(seg *Segment, reason string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, s := range w.sealed {
+		if s.Seq != seg.Seq {
+			continue
+		}
+		w.sealed = append(w.sealed[:i], w.sealed[i+1:]...)
+		w.totalBytes -= s.Size
+		w.quarantined.Add(uint64(s.Records))
+		dst := filepath.Join(w.dir, quarantineDir, filepath.Base(s.Path))
+		logx.Errorf("WAL 分段移入隔离目录 file=%s records=%d reason=%s", dst, s.Records, reason)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(s.Path, dst)
+	}
+	return nil
+}
+
+// QuarantineBatch 把写入目标拒绝的批次直接写入隔离目录（不进入重放队列）
+func (w *WAL) QuarantineBatch(items []*types.VehicleStateData, reason string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, d := range items {
+		if d == nil {
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	dir := filepath.Join(w.dir, quarantineDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("batch-%d-%d%s", time.Now().UnixMilli(), w.batchSeq.Add(1), segmentExt))
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	w.quarantined.Add(uint64(len(items)))
+	logx.Errorf("写入目标拒绝的批次移入隔离目录 file=%s records=%d reason=%s", path, len(items), reason)
+	return nil
+}
+
+// Pending 判断是否有积压记录
+func (w *WAL) Pending() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.sealed) > 0 || (w.curSeg != nil && w.curSeg.Records > 0)
+}
+
+// Stats 返回积压大小与时长以及累计落盘/重放/因超过上限未落盘的记录数
+func (w *WAL) Stats() *types.WALStatsResp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	resp := &types.WALStatsResp{
+		Dir:      w.dir,
+		Bytes:    w.totalBytes,
+		Spilled:  w.spilled.Load(),
+		Replayed: w.replayed.Load(),
+		Dropped:  w.dropped.Load(),
+	}
+	resp.Quarantined = w.quarantined.Load()
+	var oldest time.Time
+	for _, s := range w.sealed {
+		resp.Segments++
+		resp.Records += s.Records
+		if oldest.IsZero() {
+			oldest = s.Created
+		}
+	}
+	if w.curSeg != nil && w.curSeg.Records > 0 {
+		resp.Segments++
+		resp.Records += w.curSeg.Records
+		if oldest.IsZero() {
+			oldest = w.curSeg.Created
+		}
+	}
+	if !oldest.IsZero() {
+		resp.OldestAgeSec = int64(time.Since(oldest).Seconds())
+	}
+	return resp
+}
+
+// Close 封存当前分段
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seal()
+}
+
+// rotate 封存当前分段并新建分段（调用方持有 w.mu）
+func (w *WAL) rotate() error {
+	if err := w.seal(); err != nil {
+		return err
+	}
+	seg := &Segment{Seq: w.nextSeq, Created: time.Now()}
+	seg.Path = filepath.Join(w.dir, fmt.Sprintf("%020d-%d%s", seg.Seq, seg.Created.UnixMilli(), segmentExt))
+	f, err := os.OpenFile(seg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.nextSeq++
+	w.cur = f
+	w.curSeg = seg
+	return nil
+}
+
+// seal 关闭当前分段并加入待重放列表，空分段直接删除（调用方持有 w.mu）
+func (w *WAL) seal() error {
+	if w.cur == nil {
+		return nil
+	}
+	err := w.cur.Close()
+	seg := w.curSeg
+	w.cur, w.curSeg = nil, nil
+	if seg.Records == 0 {
+		_ = os.Remove(seg.Path)
+		return err
+	}
+	w.sealed = append(w.sealed, seg)
+	return err
+}
+
+func parseName(name string) (*Segment, bool) {
+	base := strings.TrimSuffix(name, segmentExt)
+	parts := strings.SplitN(base, "-", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	ms, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &Segment{Seq: seq, Created: time.UnixMilli(ms)}, true
+}
+
+func countLines(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Count(b, []byte{'\n'}), nil
+}
@@ -0,0 +1,213 @@
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
+)
+
+func openWAL(t *testing.T, dir string) *WAL {
+	t.Helper()
+	w, err := Open(config.WALConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// batchBytes 一批 n 条记录落盘后的字节数（时间戳位数相同时）
+func batchBytes(t *testing.T, n int) int64 {
+	t.Helper()
+	var size int64
+	for _, d := range batch(0, n) {
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += int64(len(b)) + 1
+	}
+	return size
+}
+
+func batch(from, n int) []*types.VehicleStateData {
+	out := make([]*types.VehicleStateData, n)
+	for i := range out {
+		out[i] = &types.VehicleStateData{VehicleId: "V1", Timestamp: uint64(from + i)}
+	}
+	return out
+}
+
+// drain 按顺序重放全部分段，返回读到的时间戳
+func drain(t *testing.T, w *WAL) []uint64 {
+	t.Helper()
+	var got []uint64
+	for {
+		seg, err := w.Oldest()
+		if err == ErrEmpty {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		items, err := w.Read(seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range items {
+			got = append(got, d.Timestamp)
+		}
+		if err := w.Remove(seg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func sequential(from, n int) []uint64 {
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = uint64(from + i)
+	}
+	return out
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAppendRotateAndReplayInOrder(t *testing.T) {
+	w := openWAL(t, t.TempDir())
+	w.segmentMaxBytes = batchBytes(t, 2) // 每个分段容纳一批
+	for i := 0; i < 5; i++ {
+		if err := w.Append(batch(i*2, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !w.Pending() {
+		t.Fatal("appended records must be pending")
+	}
+	if st := w.Stats(); st.Segments < 3 || st.Records != 10 || st.Spilled != 10 {
+		t.Fatalf("stats %+v", st)
+	}
+	if got := drain(t, w); !equal(got, sequential(0, 10)) {
+		t.Fatalf("replayed %v", got)
+	}
+	if st := w.Stats(); w.Pending() || st.Bytes != 0 || st.Replayed != 10 {
+		t.Fatalf("after replay: pending=%v stats %+v", w.Pending(), st)
+	}
+}
+
+func TestCapKeepsAcknowledgedRecords(t *testing.T) {
+	w := openWAL(t, t.TempDir())
+	w.segmentMaxBytes = batchBytes(t, 2)
+	w.maxTotalBytes = 3 * batchBytes(t, 2)
+	acked := 0
+	var full error
+	for i := 0; i < 20; i++ {
+		if err := w.Append(batch(acked, 2)); err != nil {
+			full = err
+			break
+		}
+		acked += 2
+	}
+	if !errors.Is(full, ErrFull) {
+		t.Fatalf("append beyond the cap: err = %v, want ErrFull", full)
+	}
+	// 达到上限后继续拒绝，且不淘汰已确认落盘的分段
+	if err := w.Append(batch(1000, 2)); !errors.Is(err, ErrFull) {
+		t.Fatalf("second append beyond the cap: err = %v", err)
+	}
+	st := w.Stats()
+	if st.Records != acked || acked != 6 || st.Dropped != 4 || st.Bytes > w.maxTotalBytes {
+		t.Fatalf("stats %+v, acked %d", st, acked)
+	}
+	if got := drain(t, w); !equal(got, sequential(0, acked)) {
+		t.Fatalf("replayed %v, want all %d acknowledged records", got, acked)
+	}
+	// 重放腾出空间后可以继续落盘
+	if err := w.Append(batch(2000, 2)); err != nil {
+		t.Fatalf("append after replay: %v", err)
+	}
+}
+
+func TestReopenLoadsBacklog(t *testing.T) {
+	dir := t.TempDir()
+	w := openWAL(t, dir)
+	w.segmentMaxBytes = batchBytes(t, 2)
+	for i := 0; i < 3; i++ {
+		if err := w.Append(batch(i*2, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟崩溃时写了一半的记录
+	seg, err := openWAL(t, dir).Oldest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(seg.Path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"vehicleId":"V1","timest` + "\n")
+	_ = f.Close()
+
+	w = openWAL(t, dir)
+	if st := w.Stats(); st.Records != 7 {
+		t.Fatalf("reopened records = %d, want 7 lines", st.Records)
+	}
+	// 新分段的序号排在已有分段之后
+	if err := w.Append(batch(6, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(t, w); !equal(got, sequential(0, 7)) {
+		t.Fatalf("replayed %v", got)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	w := openWAL(t, dir)
+	w.segmentMaxBytes = batchBytes(t, 2)
+	_ = w.Append(batch(0, 2))
+	_ = w.Append(batch(2, 2))
+	seg, err := w.Oldest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Quarantine(seg, "rejected"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir, filepath.Base(seg.Path))); err != nil {
+		t.Fatalf("quarantined segment not moved: %v", err)
+	}
+	// 坏分段不阻塞之后的分段
+	if got := drain(t, w); !equal(got, sequential(2, 2)) {
+		t.Fatalf("replayed %v", got)
+	}
+	if err := w.QuarantineBatch(batch(10, 3), "rejected"); err != nil {
+		t.Fatal(err)
+	}
+	if st := w.Stats(); st.Quarantined != 5 {
+		t.Fatalf("quarantined = %d, want 5", st.Quarantined)
+	}
+	// 隔离目录中的文件不会在重新打开时进入重放
+	if w := openWAL(t, dir); w.Pending() {
+		t.Fatal("quarantined files must not be replayed")
+	}
+}
@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"vehicle-api/internal/config"
+)
+
+var (
+	// ErrUnauthorized 缺少令牌或令牌无效（handler 据此返回 401）
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden 来源不在白名单或请求超出令牌范围（handler 据此返回 403）
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Scope 令牌允许的范围：可订阅的消息类型与可接收的车辆、车型，字段为空表示不限制；nil 表示不受限。
+// 限定了车辆或车型时，不带车辆或车型信息的数据（如派单事件、尚无状态车辆的告警）不下发
+type Scope struct {
+	Name          string // 令牌名称（静态令牌的 name 或 JWT 的 sub），仅用于日志
+	Types         map[string]bool
+	VehicleIds    map[string]bool
+	CategoryCodes map[int]bool
+}
+
+func (sc *Scope) allowType(t string) bool {
+	return sc == nil || len(sc.Types) == 0 || sc.Types[t]
+}
+
+func (sc *Scope) allow(it *Item) bool {
+	if sc == nil {
+		return true
+	}
+	if len(sc.VehicleIds) > 0 && !sc.VehicleIds[it.VehicleId] {
+		return false
+	}
+	if len(sc.CategoryCodes) > 0 && !sc.CategoryCodes[it.CategoryCode] {
+		return false
+	}
+	return true
+}
+
+type grant struct {
+	scope      *Scope
+	serviceIds map[string]bool // 可使用的 serviceId，不带 serviceId 的连接始终允许
+}
+
+// Authenticator 校验 websocket 握手的来源与令牌
+type Authenticator struct {
+	anyOrigin bool
+	origins   map[string]bool
+	tokens    map[[sha256.Size]byte]*grant // 按令牌的 SHA-256 查找，避免逐字节比较泄露令牌内容
+	jwtSecret []byte
+}
+
+// NewAuthenticator 按配置创建校验器，令牌为空、重复或范围中的消息类型未知时返回错误
+func NewAuthenticator(cfg config.WSAuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		origins:   make(map[string]bool),
+		tokens:    make(map[[sha256.Size]byte]*grant),
+		jwtSecret: []byte(cfg.JWTSecret),
+	}
+	a.anyOrigin = len(cfg.AllowedOrigins) == 0
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			a.anyOrigin = true
+		}
+		a.origins[normalizeOrigin(o)] = true
+	}
+	for i, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("tokens[%d]: token is empty", i)
+		}
+		key := sha256.Sum256([]byte(t.Token))
+		if _, ok := a.tokens[key]; ok {
+			return nil, fmt.Errorf("tokens[%d]: duplicate token", i)
+		}
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("tokens[%d]", i)
+		}
+		g, err := newGrant(name, t.ServiceIds, t.Types, t.VehicleIds, t.CategoryCodes)
+		if err != nil {
+			return nil, fmt.Errorf("tokens[%d]: %v", i, err)
+		}
+		a.tokens[key] = g
+	}
+	return a, nil
+}
+
+func newGrant(name string, serviceIds, typs, vehicleIds []string, codes []int) (*grant, error) {
+	g := &grant{scope: &Scope{Name: name}}
+	for _, t := range typs {
+		if !validType(t) {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+		if g.scope.Types == nil {
+			g.scope.Types = make(map[string]bool)
+		}
+		g.scope.Types[t] = true
+	}
+	g.serviceIds = make(map[string]bool)
+	for _, id := range serviceIds {
+		g.serviceIds[id] = true
+	}
+	if len(vehicleIds) > 0 {
+		g.scope.VehicleIds = make(map[string]bool)
+		for _, id := range vehicleIds {
+			g.scope.VehicleIds[id] = true
+		}
+	}
+	if len(codes) > 0 {
+		g.scope.CategoryCodes = make(map[int]bool)
+		for _, c := range codes {
+			g.scope.CategoryCodes[c] = true
+		}
+	}
+	return g, nil
+}
+
+// AuthEnabled 是否校验令牌（配置了静态令牌或 JWT 密钥）
+func (a *Authenticator) AuthEnabled() bool {
+	return len(a.tokens) > 0 || len(a.jwtSecret) > 0
+}
+
+// CheckOrigin 校验浏览器连接的 Origin 是否在白名单中
+func (a *Authenticator) CheckOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if a.anyOrigin || origin == "" || a.origins[normalizeOrigin(origin)] {
+		return nil
+	}
+	return fmt.Errorf("%w: origin %q not allowed", ErrForbidden, origin)
+}
+
+// Authenticate 校验令牌并确认令牌允许使用 serviceId，返回令牌范围；
+// 未启用令牌校验时只允许不带 serviceId 的连接并返回 nil（不受限），serviceId 会定向接收任务事件，必须凭令牌使用
+func (a *Authenticator) Authenticate(r *http.Request, serviceId string) (*Scope, error) {
+	if !a.AuthEnabled() {
+		if serviceId != "" {
+			return nil, fmt.Errorf("%w: serviceId %q requires token auth (WSAuth.tokens or WSAuth.jwtSecret)", ErrForbidden, serviceId)
+		}
+		return nil, nil
+	}
+	token := requestToken(r)
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing token", ErrUnauthorized)
+	}
+	g, ok := a.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		if len(a.jwtSecret) == 0 || strings.Count(token, ".") != 2 {
+			return nil, fmt.Errorf("%w: invalid token", ErrUnauthorized)
+		}
+		var err error
+		if g, err = a.parseJWT(token); err != nil {
+			return nil, fmt.Errorf("%w: invalid jwt: %v", ErrUnauthorized, err)
+		}
+	}
+	if serviceId != "" && !g.serviceIds[serviceId] {
+		return nil, fmt.Errorf("%w: token %s not allowed to use serviceId %q", ErrForbidden, g.scope.Name, serviceId)
+	}
+	return g.scope, nil
+}
+
+// wsClaims JWT 中的令牌范围
+type wsClaims struct {
+	ServiceIds    []string `json:"serviceIds,omitempty"`
+	Types         []string `json:"types,omitempty"`
+	VehicleIds    []string `json:"vehicleIds,omitempty"`
+	CategoryCodes []int    `json:"categoryCodes,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// parseJWT 校验 HS256 签名与 exp/nbf 并按 claims 生成范围；不带 exp 的令牌永不过期，拒绝使用
+func (a *Authenticator) parseJWT(token string) (*grant, error) {
+	var claims wsClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return a.jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("exp claim is required")
+	}
+	name := claims.Subject
+	if name == "" {
+		name = "jwt"
+	}
+	return newGrant(name, claims.ServiceIds, claims.Types, claims.VehicleIds, claims.CategoryCodes)
+}
+
+// requestToken 读取查询参数 token 或 Authorization: Bearer 头（浏览器 WebSocket 无法设置请求头，使用查询参数）
+func requestToken(r *http.Request) string {
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t
+	}
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+func normalizeOrigin(o string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(o)), "/")
+}
@@ -0,0 +1,435 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/types"
+)
+
+var clientSeq atomic.Uint64
+
+type Client struct {
+	Id   uint64 // 进程内唯一的连接编号
+	Conn *websocket.Conn
+	// ServiceId 可选，标识该连接属于哪个上层服务（例如: "orders"）
+	ServiceId   string
+	RemoteAddr  string
+	ConnectedAt time.Time
+
+	mu  sync.Mutex
+	sub *Subscription
+
+	// 待发送数据：位置按车辆只保留最新一条（客户端写入跟不上时合并），任务/告警与命令回复按顺序无损排队
+	omu       sync.Mutex
+	latest    map[string]*pending
+	order     []string // latest 中的 key，按首次排队顺序
+	events    []*pending
+	maxEvents int
+	closed    bool
+	notify    chan struct{}
+
+	resume       *resumeReq // 连接时请求的断线续传，注册到 Hub 时处理
+	wantSnapshot bool       // 注册到 Hub 时先下发全量快照
+
+	sent      atomic.Uint64 // 已写出的帧数
+	coalesced atomic.Uint64 // 被同一车辆更新的位置覆盖而未发送的条数
+	overflow  atomic.Uint64 // 事件队列已满而未能排入的帧数（随后断开该客户端）
+	dropBase  uint64        // 注册时 Hub 的 publishDropped，之后的增量为连接期间 Hub 丢弃的消息数
+	lastLag   atomic.Int64  // 最近一次写出的数据在队列中等待的时长（毫秒）
+	maxLag    atomic.Int64
+	lastSeen  atomic.Int64 // 最近一次收到 Pong 或消息的时间（UnixMilli）
+}
+
+type pending struct {
+	typ  string
+	data []byte
+	ts   uint64 // 数据时间，合并时比较
+	seq  uint64
+	at   time.Time
+}
+
+type resumeReq struct {
+	lastSeq uint64
+	epoch   string
+}
+
+// NewClient 创建客户端，sub 为空时使用 serviceId 对应的默认订阅
+func NewClient(conn *websocket.Conn, serviceId string, sub *Subscription) *Client {
+	if sub == nil {
+		sub = DefaultSubscription(serviceId)
+	}
+	now := time.Now()
+	c := &Client{
+		Id:          clientSeq.Add(1),
+		Conn:        conn,
+		ServiceId:   serviceId,
+		ConnectedAt: now,
+		sub:         sub,
+		latest:      make(map[string]*pending),
+		maxEvents:   defaultEventQueueSize,
+		notify:      make(chan struct{}, 1),
+	}
+	if conn != nil {
+		c.RemoteAddr = conn.RemoteAddr().String()
+	}
+	c.lastSeen.Store(now.UnixMilli())
+	return c
+}
+
+// Subscription 返回客户端当前订阅条件的快照
+func (c *Client) Subscription() SubscriptionView {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sub.View()
+}
+
+// WithSnapshot 请求在注册到 Hub 时先下发全量快照（车辆最新状态与监控中的任务），需在注册之前调用
+func (c *Client) WithSnapshot() {
+	c.wantSnapshot = true
+}
+
+// ResumeFrom 请求断线续传：注册到 Hub 时补发 seq 大于 lastSeq 的任务/告警事件；
+// epoch 与当前 Hub 不一致（服务已重启）时补发续传缓冲中的全部事件
+func (c *Client) ResumeFrom(lastSeq uint64, epoch string) {
+	c.resume = &resumeReq{lastSeq: lastSeq, epoch: epoch}
+}
+
+// push 按订阅把消息放入待发送队列，只在 Hub 协程中调用；事件队列超出上限时返回 false（调用方断开该客户端）
+func (c *Client) push(msg *Message) bool {
+	c.mu.Lock()
+	sub := c.sub
+	if !sub.Types[msg.Type] {
+		c.mu.Unlock()
+		return true
+	}
+	if msg.lossless() {
+		data, full := sub.render(msg)
+		c.mu.Unlock()
+		if data == nil {
+			return true
+		}
+		var frame []byte
+		if full {
+			if msg.frame == nil {
+				msg.frame = encodeEnvelope(msg.Type, msg.seq, msg.ts, data)
+			}
+			frame = msg.frame
+		} else {
+			frame = encodeEnvelope(msg.Type, msg.seq, msg.ts, data)
+		}
+		return c.enqueueEvent(frame)
+	}
+	now := time.Now()
+	c.omu.Lock()
+	queued := false
+	for i := range msg.Items {
+		it := &msg.Items[i]
+		if !sub.match(it) {
+			continue
+		}
+		key := msg.Type + "|" + it.VehicleId
+		if p, ok := c.latest[key]; ok {
+			// 不用更早的数据覆盖待发送的数据（迟到数据）
+			if it.Timestamp != 0 && it.Timestamp < p.ts {
+				c.coalesced.Add(1)
+				continue
+			}
+			p.data, p.ts, p.seq = it.Data, it.Timestamp, msg.seq
+			c.coalesced.Add(1)
+			continue
+		}
+		c.latest[key] = &pending{typ: msg.Type, data: it.Data, ts: it.Timestamp, seq: msg.seq, at: now}
+		c.order = append(c.order, key)
+		queued = true
+	}
+	c.omu.Unlock()
+	c.mu.Unlock()
+	if queued {
+		c.wake()
+	}
+	return true
+}
+
+// accepts 判断无损消息中是否有匹配客户端订阅的数据
+func (c *Client) accepts(msg *Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.sub.Types[msg.Type] {
+		return false
+	}
+	data, _ := c.sub.render(msg)
+	return data != nil
+}
+
+// eventRoom 返回无损队列的剩余空间
+func (c *Client) eventRoom() int {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+	return c.maxEvents - len(c.events)
+}
+
+// enqueueEvent 把一帧加入无损队列，队列已满时返回 false
+func (c *Client) enqueueEvent(b []byte) bool {
+	c.omu.Lock()
+	if c.closed {
+		c.omu.Unlock()
+		return true
+	}
+	if len(c.events) >= c.maxEvents {
+		c.omu.Unlock()
+		c.overflow.Add(1)
+		return false
+	}
+	c.events = append(c.events, &pending{data: b, at: time.Now()})
+	c.omu.Unlock()
+	c.wake()
+	return true
+}
+
+func (c *Client) queuedEvents() int {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+	return len(c.events)
+}
+
+func (c *Client) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// close 停止写协程，未发送的数据丢弃
+func (c *Client) close() {
+	c.omu.Lock()
+	c.closed = true
+	c.omu.Unlock()
+	c.wake()
+}
+
+// drain 取出全部待发送数据：事件按顺序各一帧，之后按类型把合并的数据各编码为一个 JSON 数组帧
+// （信封 seq 取其中最大的序号，此前序号的事件均已在前面写出）；oldest 为其中最早排队的时间
+func (c *Client) drain() (frames [][]byte, oldest time.Time, closed bool) {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+	if c.closed {
+		return nil, oldest, true
+	}
+	for _, p := range c.events {
+		frames = append(frames, p.data)
+		if oldest.IsZero() || p.at.Before(oldest) {
+			oldest = p.at
+		}
+	}
+	c.events = nil
+	if len(c.order) > 0 {
+		var typs []string
+		items := make(map[string][][]byte)
+		maxSeq := make(map[string]uint64)
+		for _, key := range c.order {
+			p := c.latest[key]
+			if _, ok := items[p.typ]; !ok {
+				typs = append(typs, p.typ)
+			}
+			items[p.typ] = append(items[p.typ], p.data)
+			if p.seq > maxSeq[p.typ] {
+				maxSeq[p.typ] = p.seq
+			}
+			if oldest.IsZero() || p.at.Before(oldest) {
+				oldest = p.at
+			}
+		}
+		now := time.Now().UnixMilli()
+		for _, typ := range typs {
+			frames = append(frames, encodeEnvelope(typ, maxSeq[typ], now, jsonArray(items[typ])))
+		}
+		c.latest = make(map[string]*pending, len(c.order))
+		c.order = c.order[:0]
+	}
+	return frames, oldest, false
+}
+
+// stats 返回客户端的排队与延迟统计
+func (c *Client) stats(now time.Time) types.WSClientStats {
+	c.omu.Lock()
+	var oldest time.Time
+	for _, p := range c.events {
+		if oldest.IsZero() || p.at.Before(oldest) {
+			oldest = p.at
+		}
+	}
+	for _, key := range c.order {
+		if p := c.latest[key]; oldest.IsZero() || p.at.Before(oldest) {
+			oldest = p.at
+		}
+	}
+	s := types.WSClientStats{
+		Id:              c.Id,
+		ServiceId:       c.ServiceId,
+		QueuedPositions: len(c.order),
+		QueuedEvents:    len(c.events),
+	}
+	c.omu.Unlock()
+	if !oldest.IsZero() {
+		s.LagMs = now.Sub(oldest).Milliseconds()
+	}
+	s.LastLagMs = c.lastLag.Load()
+	s.MaxLagMs = c.maxLag.Load()
+	s.Sent = c.sent.Load()
+	s.Coalesced = c.coalesced.Load()
+	s.QueueOverflow = c.overflow.Load()
+	return s
+}
+
+// info 返回客户端的连接信息，供管理接口列出；publishDropped 为 Hub 当前的累计值
+func (c *Client) info(publishDropped uint64) types.WSClientInfo {
+	c.mu.Lock()
+	view := c.sub.View()
+	var token string
+	if c.sub.scope != nil {
+		token = c.sub.scope.Name
+	}
+	c.mu.Unlock()
+	return types.WSClientInfo{
+		Id:             c.Id,
+		RemoteAddr:     c.RemoteAddr,
+		ServiceId:      c.ServiceId,
+		Token:          token,
+		Subscription:   types.WSSubscription(view),
+		ConnectedAt:    c.ConnectedAt.UTC().Format(time.RFC3339),
+		LastSeenAt:     time.UnixMilli(c.lastSeen.Load()).UTC().Format(time.RFC3339),
+		Sent:           c.sent.Load(),
+		Coalesced:      c.coalesced.Load(),
+		QueueOverflow:  c.overflow.Load(),
+		PublishDropped: publishDropped - c.dropBase,
+	}
+}
+
+// extendReadDeadline 收到 Pong 或消息时顺延读超时
+func (c *Client) extendReadDeadline(hub *Hub) {
+	now := time.Now()
+	c.lastSeen.Store(now.UnixMilli())
+	_ = c.Conn.SetReadDeadline(now.Add(hub.readTimeout))
+}
+
+// WritePump 把待发送数据写回客户端，并按 Ping 间隔发送 Ping；写超时或失败时关闭连接（读协程随之退出并注销）
+func (c *Client) WritePump(hub *Hub) {
+	ticker := time.NewTicker(hub.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(hub.writeTimeout)); err != nil {
+				return
+			}
+			continue
+		case <-c.notify:
+		}
+		frames, oldest, closed := c.drain()
+		if closed {
+			return
+		}
+		for _, b := range frames {
+			_ = c.Conn.SetWriteDeadline(time.Now().Add(hub.writeTimeout))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				return
+			}
+			c.sent.Add(1)
+		}
+		if !oldest.IsZero() {
+			lag := time.Since(oldest).Milliseconds()
+			c.lastLag.Store(lag)
+			if lag > c.maxLag.Load() {
+				c.maxLag.Store(lag)
+			}
+		}
+	}
+}
+
+// ReadPump 读取客户端的订阅命令（见 Command），处理后回复当前订阅或错误信息。
+// 收到 Pong 或消息时顺延读超时，超过读空闲超时（对端已失联，如浏览器标签页休眠或网络中断）时断开并注销
+func (c *Client) ReadPump(hub *Hub) {
+	defer func() {
+		hub.Unregister <- c
+		c.Conn.Close()
+	}()
+	c.extendReadDeadline(hub)
+	c.Conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline(hub)
+		return nil
+	})
+	for {
+		_, message, err := c.Conn.ReadMessage()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				hub.idleClosed.Add(1)
+				logx.Infof("websocket 客户端读空闲超时（%s），断开连接 id=%d remote=%s serviceId=%s", hub.readTimeout, c.Id, c.RemoteAddr, c.ServiceId)
+			}
+			break
+		}
+		c.extendReadDeadline(hub)
+		var cmd Command
+		if err := json.Unmarshal(message, &cmd); err != nil {
+			hub.reply(c, errorReply(fmt.Errorf("invalid command: %v", err)))
+			continue
+		}
+		c.mu.Lock()
+		err = c.sub.Apply(&cmd)
+		var reply []byte
+		if err != nil {
+			reply = errorReply(err)
+		} else {
+			reply = subscriptionReply(c.sub)
+		}
+		c.mu.Unlock()
+		if err != nil {
+			logx.Infof("websocket 订阅命令无效 serviceId=%s err=%v", c.ServiceId, err)
+		}
+		hub.reply(c, reply)
+		if err == nil && cmd.Snapshot {
+			if err := hub.QueueSnapshot(c); err != nil {
+				logx.Errorf("websocket 快照生成失败 id=%d err=%v", c.Id, err)
+			}
+		}
+	}
+}
+
+// snapshotFrame 生成快照帧（data 为 {epoch, vehicles, tasks}）：订阅了 positions 时包含匹配订阅的车辆最新状态，
+// 订阅了 tasks 时包含匹配的监控中任务；seq 为生成快照时 Hub 的最新序号
+func (c *Client) snapshotFrame(epoch string, seq uint64, vehicles []*types.VehicleStateData, tasks []types.ActiveTask) ([]byte, error) {
+	snap := struct {
+		Epoch    string                    `json:"epoch"`
+		Vehicles []*types.VehicleStateData `json:"vehicles"`
+		Tasks    []types.ActiveTask        `json:"tasks"`
+	}{Epoch: epoch, Vehicles: []*types.VehicleStateData{}, Tasks: []types.ActiveTask{}}
+	c.mu.Lock()
+	if c.sub.Types[TypePositions] {
+		for _, d := range vehicles {
+			if it := StateItem(d, nil); c.sub.match(&it) {
+				snap.Vehicles = append(snap.Vehicles, d)
+			}
+		}
+	}
+	if c.sub.Types[TypeTasks] {
+		for _, t := range tasks {
+			if c.sub.match(&Item{VehicleId: t.VehicleId}) {
+				snap.Tasks = append(snap.Tasks, t)
+			}
+		}
+	}
+	c.mu.Unlock()
+	return encodeEnvelopeValue(TypeSnapshot, seq, time.Now().UnixMilli(), snap)
+}
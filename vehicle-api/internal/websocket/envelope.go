@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// EnvelopeVersion 下发消息信封的版本号
+const EnvelopeVersion = 1
+
+// 信封类型：除订阅的 positions/tasks/alarms 外，服务端还会下发以下类型
+const (
+	TypeSnapshot     = "snapshot"     // 连接快照 {epoch, vehicles, tasks}
+	TypeResumed      = "resumed"      // 断线续传完成 {epoch, lastSeq, replayed, complete}
+	TypeSubscription = "subscription" // 订阅命令的回复，data 为当前订阅
+	TypeError        = "error"        // 命令错误 {message}
+)
+
+// encodeEnvelope 编码信封 {"v":1,"type":...,"seq":...,"ts":...,"data":...}。
+// seq 为 Hub 内全局递增的序号（各客户端按订阅过滤，收到的序号不连续），命令回复等不属于数据流的消息 seq 为 0；
+// ts 为服务端时间（毫秒）；data 为已编码的 JSON
+func encodeEnvelope(typ string, seq uint64, ts int64, data []byte) []byte {
+	b := make([]byte, 0, len(data)+64)
+	b = append(b, `{"v":`...)
+	b = strconv.AppendInt(b, EnvelopeVersion, 10)
+	b = append(b, `,"type":`...)
+	b = strconv.AppendQuote(b, typ)
+	b = append(b, `,"seq":`...)
+	b = strconv.AppendUint(b, seq, 10)
+	b = append(b, `,"ts":`...)
+	b = strconv.AppendInt(b, ts, 10)
+	b = append(b, `,"data":`...)
+	b = append(b, data...)
+	b = append(b, '}')
+	return b
+}
+
+// encodeEnvelopeValue 把 v 编码为 data 后封装
+func encodeEnvelopeValue(typ string, seq uint64, ts int64, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return encodeEnvelope(typ, seq, ts, data), nil
+}
@@ -1,138 +1,430 @@
 package websocket
 
 import (
-	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"vehicle-api/internal/config"
+	"vehicle-api/internal/types"
 )
 
-// 允许所有连接上来, prod 应该有限制
+// Upgrader 本身不限制来源，来源白名单与令牌在握手前由 Authenticator 校验（见 HandleWebSocketHandler）
 var Upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
 
-type Client struct {
-	Conn *websocket.Conn
-	Send chan []byte
-	// ServiceId 可选，标识该连接属于哪个上层服务（例如: "orders"）
-	ServiceId string
-}
+const (
+	// publishTimeout Broadcast 通道满时 Publish 的最长等待时间
+	publishTimeout = 200 * time.Millisecond
+	// defaultEventQueueSize 每个客户端待发送事件数上限的默认值
+	defaultEventQueueSize = 4096
+	// defaultPublishQueueSize 事件入口（PublishAsync）等待路由的消息数上限默认值
+	defaultPublishQueueSize = 65536
+	// defaultResumeBufferSize 续传缓冲保留的事件数默认值
+	defaultResumeBufferSize = 10000
+	// 保活默认值：Ping 间隔、读空闲超时与写超时
+	defaultPingInterval = 20 * time.Second
+	defaultReadTimeout  = 60 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+)
 
 type Hub struct {
 	Clients map[*Client]bool
-	// ClientsByService: 按 serviceId 分组的客户端集合，便于定向广播
-	ClientsByService map[string]map[*Client]bool
-	Broadcast        chan []byte
-	Register         chan *Client
-	Unregister       chan *Client
-	mu               sync.Mutex
+	// Broadcast 待路由的消息，按各客户端的订阅条件过滤后放入各自的待发送队列
+	Broadcast  chan *Message
+	Register   chan *Client
+	Unregister chan *Client
+	mu         sync.Mutex
+
+	// 事件入口：PublishAsync 追加后立即返回，由 Hub 协程按顺序路由，不阻塞发布方；
+	// 积压达到 queueCap（Hub 协程长时间停滞）时丢弃新消息并计入 publishDropped
+	qmu      sync.Mutex
+	queue    []*Message
+	queueCap int
+	queued   chan struct{}
+
+	eventQueueSize int
+	snapshot       func() ([]*types.VehicleStateData, []types.ActiveTask)
+
+	// epoch 标识本次进程的序号空间，客户端续传时一并提交，服务重启后序号从头开始
+	epoch  string
+	seq    atomic.Uint64 // 最近分配的序号，只在 Hub 协程中递增
+	resume *ring         // 最近的任务/告警事件，供断线重连的客户端补发
+
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	publishDropped atomic.Uint64 // Broadcast 通道满、Publish 超时丢弃的位置消息数与事件入口满丢弃的任务/告警消息数
+	evicted        atomic.Uint64 // 事件队列溢出被断开的客户端数
+	idleClosed     atomic.Uint64 // 读空闲超时被断开的客户端数
+	kicked         atomic.Uint64 // 被管理接口强制断开的客户端数
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		Clients:          make(map[*Client]bool),
-		ClientsByService: make(map[string]map[*Client]bool),
-		Broadcast:        make(chan []byte),
-		Register:         make(chan *Client),
-		Unregister:       make(chan *Client),
+func NewHub(cfg config.WebSocketConfig) *Hub {
+	if cfg.EventQueueSize <= 0 {
+		cfg.EventQueueSize = defaultEventQueueSize
+	}
+	if cfg.ResumeBufferSize <= 0 {
+		cfg.ResumeBufferSize = defaultResumeBufferSize
+	}
+	if cfg.PublishQueueSize <= 0 {
+		cfg.PublishQueueSize = defaultPublishQueueSize
+	}
+	h := &Hub{
+		Clients:        make(map[*Client]bool),
+		Broadcast:      make(chan *Message, 1024),
+		Register:       make(chan *Client),
+		Unregister:     make(chan *Client),
+		queueCap:       cfg.PublishQueueSize,
+		queued:         make(chan struct{}, 1),
+		eventQueueSize: cfg.EventQueueSize,
+		epoch:          strconv.FormatInt(time.Now().UnixMilli(), 36),
+		resume:         newRing(cfg.ResumeBufferSize),
+		pingInterval:   defaultPingInterval,
+		readTimeout:    defaultReadTimeout,
+		writeTimeout:   defaultWriteTimeout,
+	}
+	if cfg.PingIntervalSec > 0 {
+		h.pingInterval = time.Duration(cfg.PingIntervalSec) * time.Second
+	}
+	if cfg.ReadIdleTimeoutSec > 0 {
+		h.readTimeout = time.Duration(cfg.ReadIdleTimeoutSec) * time.Second
 	}
+	if h.readTimeout <= h.pingInterval {
+		h.readTimeout = 3 * h.pingInterval
+	}
+	if cfg.WriteTimeoutSec > 0 {
+		h.writeTimeout = time.Duration(cfg.WriteTimeoutSec) * time.Second
+	}
+	return h
+}
+
+// Epoch 返回本次进程的序号空间标识
+func (h *Hub) Epoch() string {
+	return h.epoch
 }
 
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
-			h.mu.Lock()
-			h.Clients[client] = true
-			// 如果 client 的 ServiceId 已设置，则加入按服务映射
-			if client != nil && client.ServiceId != "" {
-				set, ok := h.ClientsByService[client.ServiceId]
-				if !ok {
-					set = make(map[*Client]bool)
+			client.omu.Lock()
+			client.maxEvents = h.eventQueueSize
+			client.omu.Unlock()
+			client.dropBase = h.publishDropped.Load()
+			// 快照在 Hub 协程中生成：此前路由的实时数据已在快照之中，此后路由的排在快照之后，两者之间没有空档
+			var snap []byte
+			if client.wantSnapshot {
+				var err error
+				if snap, err = h.buildSnapshot(client); err != nil {
+					logx.Errorf("websocket 快照生成失败 id=%d err=%v", client.Id, err)
 				}
-				set[client] = true
-				h.ClientsByService[client.ServiceId] = set
 			}
+			h.mu.Lock()
+			if snap != nil {
+				client.enqueueEvent(snap)
+			}
+			// 续传在注册之前完成，补发的事件与之后的实时数据之间不重复、不遗漏
+			if client.resume != nil {
+				h.replay(client)
+			}
+			h.Clients[client] = true
 			h.mu.Unlock()
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
-				// 如果 client 有 ServiceId，从对应集合移除
-				if client != nil && client.ServiceId != "" {
-					if set, ok := h.ClientsByService[client.ServiceId]; ok {
-						if _, ok2 := set[client]; ok2 {
-							delete(set, client)
-						}
-						if len(set) == 0 {
-							delete(h.ClientsByService, client.ServiceId)
-						} else {
-							h.ClientsByService[client.ServiceId] = set
-						}
-					}
-				}
-				close(client.Send)
+				client.close()
 			}
 			h.mu.Unlock()
 
-		case message := <-h.Broadcast:
-			h.mu.Lock()
-			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+		case msg := <-h.Broadcast:
+			h.route(msg)
+
+		case <-h.queued:
+			h.qmu.Lock()
+			msgs := h.queue
+			h.queue = nil
+			h.qmu.Unlock()
+			for _, msg := range msgs {
+				h.route(msg)
 			}
-			h.mu.Unlock()
 		}
 	}
 }
 
-// BroadcastToService 向指定 serviceId 的所有客户端广播消息
-func (h *Hub) BroadcastToService(serviceId string, message []byte) {
-	if serviceId == "" || message == nil {
+// route 分配序号并按订阅放入各客户端的待发送队列，只在 Hub 协程中调用
+func (h *Hub) route(msg *Message) {
+	msg.seq = h.seq.Add(1)
+	msg.ts = time.Now().UnixMilli()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if msg.lossless() {
+		h.resume.add(msg)
+	}
+	for client := range h.Clients {
+		// 位置数据在客户端写入跟不上时按车辆合并，不会断开客户端；
+		// 任务/告警不能丢弃，事件队列溢出时断开该客户端
+		if !client.push(msg) {
+			h.evict(client)
+		}
+	}
+}
+
+// replay 把续传缓冲中 seq 大于客户端 lastSeq 且匹配订阅的事件补发，之后发送 resumed 消息；调用方持有 h.mu。
+// 补发数受事件队列剩余空间限制（为 resumed 保留一帧），超出时只补发最近的事件并报告 complete=false，
+// 客户端据此以快照重新同步，不会因补发过多被断开。epoch 不一致（服务已重启）时补发全部缓冲，同样报告 complete=false
+func (h *Hub) replay(c *Client) {
+	sameEpoch := c.resume.epoch == h.epoch
+	lastSeq := c.resume.lastSeq
+	if !sameEpoch {
+		lastSeq = 0
+	}
+	msgs, complete := h.resume.since(lastSeq)
+	complete = complete && sameEpoch
+	matched := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if c.accepts(msg) {
+			matched = append(matched, msg)
+		}
+	}
+	if room := c.eventRoom() - 1; len(matched) > room {
+		if room < 0 {
+			room = 0
+		}
+		logx.Errorf("websocket 续传事件 %d 条超出队列剩余空间，只补发最近 %d 条 id=%d serviceId=%s", len(matched), room, c.Id, c.ServiceId)
+		matched = matched[len(matched)-room:]
+		complete = false
+	}
+	for _, msg := range matched {
+		c.push(msg)
+	}
+	b, _ := encodeEnvelopeValue(TypeResumed, h.seq.Load(), time.Now().UnixMilli(), struct {
+		Epoch    string `json:"epoch"`
+		LastSeq  uint64 `json:"lastSeq"`
+		Replayed int    `json:"replayed"` // 补发的事件数
+		Complete bool   `json:"complete"` // false 表示部分事件已超出续传缓冲或队列空间、或服务已重启，无法完整补发
+	}{h.epoch, c.resume.lastSeq, len(matched), complete})
+	c.enqueueEvent(b)
+	if !complete {
+		logx.Infof("websocket 续传不完整 id=%d serviceId=%s lastSeq=%d epoch=%s", c.Id, c.ServiceId, c.resume.lastSeq, c.resume.epoch)
+	}
+}
+
+// evict 断开事件队列溢出的客户端，调用方持有 h.mu
+func (h *Hub) evict(c *Client) {
+	delete(h.Clients, c)
+	c.close()
+	h.evicted.Add(1)
+	logx.Errorf("websocket 客户端事件队列已满（%d），断开连接 id=%d remote=%s serviceId=%s sent=%d coalesced=%d overflow=%d",
+		h.eventQueueSize, c.Id, c.RemoteAddr, c.ServiceId, c.sent.Load(), c.coalesced.Load(), c.overflow.Load())
+}
+
+// Publish 把消息交给 Hub 路由：任务/告警等无损消息经 PublishAsync 排队，事件入口满时丢弃并返回 false；
+// 位置等可合并的消息在通道满时最多等待 200ms，超时丢弃并返回 false
+func (h *Hub) Publish(msg *Message) bool {
+	if msg == nil || len(msg.Items) == 0 {
+		return true
+	}
+	if msg.lossless() {
+		return h.PublishAsync(msg)
+	}
+	select {
+	case h.Broadcast <- msg:
+		return true
+	default:
+	}
+	t := time.NewTimer(publishTimeout)
+	defer t.Stop()
+	select {
+	case h.Broadcast <- msg:
+		return true
+	case <-t.C:
+		h.publishDropped.Add(1)
+		return false
+	}
+}
+
+// PublishAsync 把消息追加到事件入口后立即返回，不阻塞调用方（如 TCP 读循环）；
+// 入口积压达到上限时丢弃该消息、计入 publishDropped 并返回 false
+func (h *Hub) PublishAsync(msg *Message) bool {
+	if msg == nil || len(msg.Items) == 0 {
+		return true
+	}
+	h.qmu.Lock()
+	if len(h.queue) >= h.queueCap {
+		h.qmu.Unlock()
+		h.publishDropped.Add(1)
+		return false
+	}
+	h.queue = append(h.queue, msg)
+	h.qmu.Unlock()
+	select {
+	case h.queued <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// reply 向单个客户端发送命令回复（与任务/告警同一无损队列）；客户端已被移除时丢弃
+func (h *Hub) reply(c *Client, message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.Clients[c] {
 		return
 	}
+	if !c.enqueueEvent(message) {
+		h.evict(c)
+	}
+}
+
+// ClientList 返回当前连接的客户端，按编号排序
+func (h *Hub) ClientList() []types.WSClientInfo {
+	h.mu.Lock()
+	list := make([]types.WSClientInfo, 0, len(h.Clients))
+	for c := range h.Clients {
+		list = append(list, c.info(h.publishDropped.Load()))
+	}
+	h.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+// Disconnect 强制断开编号为 id 的客户端，返回其断开前的信息；客户端不存在时 ok 为 false。
+// 客户端可以重新连接（令牌失效需另行调整 WSAuth 配置）
+func (h *Hub) Disconnect(id uint64) (info types.WSClientInfo, ok bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	if set, ok := h.ClientsByService[serviceId]; ok {
-		for client := range set {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(set, client)
-				delete(h.Clients, client)
-			}
+	for c := range h.Clients {
+		if c.Id != id {
+			continue
 		}
-		h.ClientsByService[serviceId] = set
+		info = c.info(h.publishDropped.Load())
+		delete(h.Clients, c)
+		c.close()
+		h.kicked.Add(1)
+		logx.Infof("websocket 客户端被强制断开 id=%d remote=%s serviceId=%s", c.Id, c.RemoteAddr, c.ServiceId)
+		return info, true
 	}
+	return info, false
 }
 
-// 客户端消息读写函数
-func (c *Client) WritePump() {
-	defer c.Conn.Close()
-	for message := range c.Send {
-		if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			return
-		}
+// SetSnapshotSource 设置快照数据来源：全部车辆的最新状态与监控中的任务
+func (h *Hub) SetSnapshotSource(fn func() ([]*types.VehicleStateData, []types.ActiveTask)) {
+	h.mu.Lock()
+	h.snapshot = fn
+	h.mu.Unlock()
+}
+
+// QueueSnapshot 按客户端当前订阅重新生成快照并排入其无损队列（订阅命令带 snapshot 时）；
+// 连接建立时的快照由 Hub 在注册时生成（见 Client.WithSnapshot）。未设置快照来源时不下发
+func (h *Hub) QueueSnapshot(c *Client) error {
+	b, err := h.buildSnapshot(c)
+	if err != nil || b == nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !c.enqueueEvent(b) && h.Clients[c] {
+		h.evict(c)
 	}
+	return nil
 }
 
-func (c *Client) ReadPump(hub *Hub) {
-	defer func() {
-		hub.Unregister <- c
-		c.Conn.Close()
-	}()
-	for {
-		_, message, err := c.Conn.ReadMessage()
-		if err != nil {
-			break
+// buildSnapshot 按客户端订阅生成快照帧，未设置快照来源时返回 nil
+func (h *Hub) buildSnapshot(c *Client) ([]byte, error) {
+	h.mu.Lock()
+	fn := h.snapshot
+	h.mu.Unlock()
+	if fn == nil {
+		return nil, nil
+	}
+	seq := h.seq.Load()
+	vehicles, tasks := fn()
+	return c.snapshotFrame(h.epoch, seq, vehicles, tasks)
+}
+
+func (h *Hub) queueLen() int {
+	h.qmu.Lock()
+	defer h.qmu.Unlock()
+	return len(h.queue)
+}
+
+// HubStats Hub 的路由与各客户端的排队、延迟统计
+type HubStats struct {
+	Clients        []types.WSClientStats // 按编号排序
+	Pending        int                   // Broadcast 通道与事件入口中等待路由的消息数
+	Seq            uint64                // 最近分配的序号
+	Buffered       int                   // 续传缓冲中的事件数
+	PublishDropped uint64
+	Evicted        uint64
+	IdleClosed     uint64
+	Kicked         uint64
+}
+
+// Stats 返回 Hub 与各客户端的统计
+func (h *Hub) Stats() HubStats {
+	now := time.Now()
+	h.mu.Lock()
+	clients := make([]types.WSClientStats, 0, len(h.Clients))
+	for c := range h.Clients {
+		clients = append(clients, c.stats(now))
+	}
+	buffered := h.resume.len()
+	h.mu.Unlock()
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Id < clients[j].Id })
+	return HubStats{
+		Clients:        clients,
+		Pending:        len(h.Broadcast) + h.queueLen(),
+		Seq:            h.seq.Load(),
+		Buffered:       buffered,
+		PublishDropped: h.publishDropped.Load(),
+		Evicted:        h.evicted.Load(),
+		IdleClosed:     h.idleClosed.Load(),
+		Kicked:         h.kicked.Load(),
+	}
+}
+
+// ring 续传缓冲：保存最近 size 条任务/告警事件，只在持有 h.mu 时访问
+type ring struct {
+	buf     []*Message
+	start   int
+	n       int
+	dropped uint64 // 最近被挤出缓冲的事件序号，lastSeq 小于它时续传不完整
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]*Message, size)}
+}
+
+func (r *ring) add(m *Message) {
+	if r.n == len(r.buf) {
+		r.dropped = r.buf[r.start].seq
+		r.buf[r.start] = m
+		r.start = (r.start + 1) % len(r.buf)
+		return
+	}
+	r.buf[(r.start+r.n)%len(r.buf)] = m
+	r.n++
+}
+
+// since 返回 seq 大于 lastSeq 的事件（按序号递增）；complete 为 false 表示其中部分事件已被挤出
+func (r *ring) since(lastSeq uint64) (msgs []*Message, complete bool) {
+	for i := 0; i < r.n; i++ {
+		if m := r.buf[(r.start+i)%len(r.buf)]; m.seq > lastSeq {
+			msgs = append(msgs, m)
 		}
-		// hub.Broadcast <- message
-		fmt.Printf("recv: %v\n", message)
 	}
+	return msgs, lastSeq >= r.dropped
+}
+
+func (r *ring) len() int {
+	return r.n
 }
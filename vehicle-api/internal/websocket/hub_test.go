@@ -0,0 +1,224 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"vehicle-api/internal/config"
+)
+
+// envelope 解码后的信封
+type envelope struct {
+	V    int             `json:"v"`
+	Type string          `json:"type"`
+	Seq  uint64          `json:"seq"`
+	Ts   int64           `json:"ts"`
+	Data json.RawMessage `json:"data"`
+}
+
+type resumed struct {
+	Epoch    string `json:"epoch"`
+	LastSeq  uint64 `json:"lastSeq"`
+	Replayed int    `json:"replayed"`
+	Complete bool   `json:"complete"`
+}
+
+func taskMessage(i int) *Message {
+	return NewMessage(TypeTasks, Item{VehicleId: "V" + strconv.Itoa(i), Data: []byte(`{"n":` + strconv.Itoa(i) + `}`)})
+}
+
+// registerAndDrain 注册客户端并等待 Hub 处理完注册，返回其待发送的帧
+func registerAndDrain(t *testing.T, h *Hub, c *Client) []envelope {
+	t.Helper()
+	h.Register <- c
+	deadline := time.Now().Add(2 * time.Second)
+	for len(h.Stats().Clients) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("client was not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	frames, _, closed := c.drain()
+	if closed {
+		t.Fatal("client was closed during replay")
+	}
+	out := make([]envelope, len(frames))
+	for i, f := range frames {
+		if err := json.Unmarshal(f, &out[i]); err != nil {
+			t.Fatalf("frame %d is not a valid envelope: %v (%s)", i, err, f)
+		}
+	}
+	return out
+}
+
+func lastResumed(t *testing.T, frames []envelope) resumed {
+	t.Helper()
+	if len(frames) == 0 || frames[len(frames)-1].Type != TypeResumed {
+		t.Fatalf("last frame is not %q: %+v", TypeResumed, frames)
+	}
+	var r resumed
+	if err := json.Unmarshal(frames[len(frames)-1].Data, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEncodeEnvelope(t *testing.T) {
+	got := string(encodeEnvelope(TypeTasks, 42, 1700000000123, []byte(`{"taskId":"t1"}`)))
+	want := `{"v":1,"type":"tasks","seq":42,"ts":1700000000123,"data":{"taskId":"t1"}}`
+	if got != want {
+		t.Fatalf("encodeEnvelope = %s, want %s", got, want)
+	}
+
+	b, err := encodeEnvelopeValue(TypeError, 0, 5, struct {
+		Message string `json:"message"`
+	}{`bad "cmd"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	if env.V != EnvelopeVersion || env.Type != TypeError || env.Seq != 0 || env.Ts != 5 || string(env.Data) != `{"message":"bad \"cmd\""}` {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+}
+
+func TestRingSince(t *testing.T) {
+	r := newRing(3)
+	if msgs, complete := r.since(0); len(msgs) != 0 || !complete {
+		t.Fatalf("empty ring: got %d msgs complete=%v", len(msgs), complete)
+	}
+	for i := 1; i <= 5; i++ {
+		r.add(&Message{seq: uint64(i)})
+	}
+	if r.len() != 3 {
+		t.Fatalf("len = %d, want 3", r.len())
+	}
+	cases := []struct {
+		lastSeq  uint64
+		want     []uint64
+		complete bool
+	}{
+		{0, []uint64{3, 4, 5}, false}, // 1、2 已被挤出
+		{1, []uint64{3, 4, 5}, false},
+		{2, []uint64{3, 4, 5}, true},
+		{4, []uint64{5}, true},
+		{5, nil, true},
+	}
+	for _, tc := range cases {
+		msgs, complete := r.since(tc.lastSeq)
+		var got []uint64
+		for _, m := range msgs {
+			got = append(got, m.seq)
+		}
+		if len(got) != len(tc.want) || complete != tc.complete {
+			t.Fatalf("since(%d) = %v complete=%v, want %v complete=%v", tc.lastSeq, got, complete, tc.want, tc.complete)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("since(%d) = %v, want %v", tc.lastSeq, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestReplayFromLastSeq(t *testing.T) {
+	h := NewHub(config.WebSocketConfig{})
+	for i := 1; i <= 3; i++ {
+		h.route(taskMessage(i))
+	}
+	go h.Run()
+
+	c := NewClient(nil, "orders", nil)
+	c.ResumeFrom(2, h.Epoch())
+	frames := registerAndDrain(t, h, c)
+	if len(frames) != 2 || frames[0].Type != TypeTasks || frames[0].Seq != 3 {
+		t.Fatalf("want task seq 3 then resumed, got %+v", frames)
+	}
+	if r := lastResumed(t, frames); r.Replayed != 1 || !r.Complete || r.Epoch != h.Epoch() || r.LastSeq != 2 {
+		t.Fatalf("unexpected resumed %+v", r)
+	}
+}
+
+func TestReplayEpochMismatchIsIncomplete(t *testing.T) {
+	h := NewHub(config.WebSocketConfig{})
+	for i := 1; i <= 3; i++ {
+		h.route(taskMessage(i))
+	}
+	go h.Run()
+
+	c := NewClient(nil, "orders", nil)
+	c.ResumeFrom(2, "stale-epoch")
+	frames := registerAndDrain(t, h, c)
+	if len(frames) != 4 {
+		t.Fatalf("want all 3 buffered events and resumed, got %d frames", len(frames))
+	}
+	if r := lastResumed(t, frames); r.Replayed != 3 || r.Complete {
+		t.Fatalf("epoch mismatch must report complete=false, got %+v", r)
+	}
+}
+
+func TestReplayOverflowCapsInsteadOfEvicting(t *testing.T) {
+	h := NewHub(config.WebSocketConfig{EventQueueSize: 4, ResumeBufferSize: 100})
+	for i := 1; i <= 10; i++ {
+		h.route(taskMessage(i))
+	}
+	go h.Run()
+
+	c := NewClient(nil, "orders", nil)
+	c.ResumeFrom(0, h.Epoch())
+	frames := registerAndDrain(t, h, c)
+	// 队列上限 4：补发最近 3 条，留一帧给 resumed
+	if len(frames) != 4 {
+		t.Fatalf("want 3 replayed events and resumed, got %d frames", len(frames))
+	}
+	for i, want := range []uint64{8, 9, 10} {
+		if frames[i].Type != TypeTasks || frames[i].Seq != want {
+			t.Fatalf("frame %d = %s seq %d, want tasks seq %d", i, frames[i].Type, frames[i].Seq, want)
+		}
+	}
+	if r := lastResumed(t, frames); r.Replayed != 3 || r.Complete {
+		t.Fatalf("capped replay must report complete=false, got %+v", r)
+	}
+	if st := h.Stats(); st.Evicted != 0 || len(st.Clients) != 1 {
+		t.Fatalf("client must stay registered: evicted=%d clients=%d", st.Evicted, len(st.Clients))
+	}
+}
+
+func TestReplaySkipsUnsubscribedEvents(t *testing.T) {
+	h := NewHub(config.WebSocketConfig{})
+	h.route(taskMessage(1))
+	h.route(NewMessage(TypeAlarms, Item{VehicleId: "V1", Data: []byte(`{}`)}))
+	go h.Run()
+
+	// 默认订阅（非 orders）不含任务事件
+	c := NewClient(nil, "", nil)
+	c.ResumeFrom(0, h.Epoch())
+	frames := registerAndDrain(t, h, c)
+	if len(frames) != 2 || frames[0].Type != TypeAlarms || frames[0].Seq != 2 {
+		t.Fatalf("want only the alarm then resumed, got %+v", frames)
+	}
+	if r := lastResumed(t, frames); r.Replayed != 1 || !r.Complete {
+		t.Fatalf("unexpected resumed %+v", r)
+	}
+}
+
+func TestPublishAsyncCapCountsDrops(t *testing.T) {
+	// Hub 协程未运行，入口积压不会被消费
+	h := NewHub(config.WebSocketConfig{PublishQueueSize: 2})
+	for i := 0; i < 2; i++ {
+		if !h.PublishAsync(taskMessage(i)) {
+			t.Fatalf("message %d below the cap was dropped", i)
+		}
+	}
+	if h.PublishAsync(taskMessage(2)) || h.Publish(taskMessage(3)) {
+		t.Fatal("messages beyond the cap must be rejected")
+	}
+	if st := h.Stats(); st.Pending != 2 || st.PublishDropped != 2 {
+		t.Fatalf("pending=%d publishDropped=%d, want 2 and 2", st.Pending, st.PublishDropped)
+	}
+}
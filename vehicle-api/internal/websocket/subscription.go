@@ -0,0 +1,377 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vehicle-api/internal/types"
+)
+
+// 消息类型，客户端按类型订阅
+const (
+	TypePositions = "positions" // 车辆实时状态/位置
+	TypeTasks     = "tasks"     // 派单与任务到达事件
+	TypeAlarms    = "alarms"    // 车辆告警
+
+	// 录制回放的数据与线上数据分开下发，不覆盖地图上的实时位置；默认订阅不包含，需显式订阅
+	TypeReplayPositions = "replay_positions" // 回放的车辆状态，data 同 positions
+	TypeReplayAlarms    = "replay_alarms"    // 回放的车辆告警，data 同 alarms
+)
+
+var allTypes = []string{TypePositions, TypeTasks, TypeAlarms, TypeReplayPositions, TypeReplayAlarms}
+
+// Item 消息中的一条数据及其路由属性；VehicleId 为空、CategoryCode 为 0 或 HasPosition 为 false 时，
+// 该条数据不参与对应维度的过滤（例如告警没有位置，不受地图范围限制）
+type Item struct {
+	VehicleId    string
+	CategoryCode int
+	Lon, Lat     float64
+	HasPosition  bool
+	Timestamp    uint64 // 数据时间（毫秒），合并时不用更早的数据覆盖待发送的数据
+	Data         []byte // 已编码的 JSON
+}
+
+// StateItem 按车辆状态生成路由属性
+func StateItem(d *types.VehicleStateData, data []byte) Item {
+	return Item{VehicleId: d.VehicleId, CategoryCode: d.CategoryCode, Lon: d.Lon, Lat: d.Lat, HasPosition: true, Timestamp: d.Timestamp, Data: data}
+}
+
+// Message 交给 Hub 路由的消息：只发给订阅了 Type 且过滤条件匹配的客户端。
+// positions、replay_positions 与 Coalesce 为 true 的消息在客户端写入跟不上时按车辆只保留最新一条，多条合并为一个 JSON 数组下发；
+// 其它消息按顺序无损下发，并保存在 Hub 的续传缓冲中供断线重连的客户端补发
+type Message struct {
+	Type     string
+	Batch    bool // 为 true 时以 JSON 数组下发 Items 中与订阅匹配的元素，否则 Items 只有一个元素
+	Coalesce bool // 非 positions 类型的消息也按车辆合并（例如派单监听转发的车辆状态）
+	Items    []Item
+
+	// 以下字段由 Hub 在路由时设置，只在 Hub 协程中访问
+	seq   uint64
+	ts    int64
+	frame []byte // 全部元素匹配时的信封编码，供各客户端复用
+}
+
+func (m *Message) lossless() bool {
+	return m.Type != TypePositions && m.Type != TypeReplayPositions && !m.Coalesce
+}
+
+// NewMessage 构造单条数据的消息
+func NewMessage(typ string, item Item) *Message {
+	return &Message{Type: typ, Items: []Item{item}}
+}
+
+// BBox 地图范围（经纬度）
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+func (b *BBox) contains(lon, lat float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// Subscription 客户端的订阅条件：消息类型须在 Types 中；VehicleIds、CategoryCodes、BBox 不为 nil 时数据须同时满足，
+// 且不超出连接令牌的范围。VehicleIds/CategoryCodes 为 nil 表示不限，退订掉最后一项后为空集合，不匹配任何数据
+type Subscription struct {
+	Types         map[string]bool
+	VehicleIds    map[string]bool
+	CategoryCodes map[int]bool
+	BBox          *BBox
+
+	scope *Scope
+}
+
+// DefaultSubscription 连接建立后未发送订阅命令时的默认订阅：全部车辆的位置与告警；
+// orders 服务的连接另外默认接收任务事件（与之前的定向广播一致）
+func DefaultSubscription(serviceId string) *Subscription {
+	s := &Subscription{Types: map[string]bool{TypePositions: true, TypeAlarms: true}}
+	if serviceId == "orders" {
+		s.Types[TypeTasks] = true
+	}
+	return s
+}
+
+// Restrict 限定订阅不超出令牌范围，并移除范围外的已订阅类型
+func (s *Subscription) Restrict(scope *Scope) {
+	s.scope = scope
+	for t := range s.Types {
+		if !scope.allowType(t) {
+			delete(s.Types, t)
+		}
+	}
+}
+
+func (s *Subscription) match(it *Item) bool {
+	if !s.scope.allow(it) {
+		return false
+	}
+	if it.VehicleId != "" && s.VehicleIds != nil && !s.VehicleIds[it.VehicleId] {
+		return false
+	}
+	if it.CategoryCode != 0 && s.CategoryCodes != nil && !s.CategoryCodes[it.CategoryCode] {
+		return false
+	}
+	if it.HasPosition && s.BBox != nil && !s.BBox.contains(it.Lon, it.Lat) {
+		return false
+	}
+	return true
+}
+
+// render 返回消息中与订阅匹配的 data，不匹配时返回 nil；full 表示全部元素匹配（可复用 Message.frame）
+func (s *Subscription) render(msg *Message) (data []byte, full bool) {
+	if !s.Types[msg.Type] || len(msg.Items) == 0 {
+		return nil, false
+	}
+	if !msg.Batch {
+		if !s.match(&msg.Items[0]) {
+			return nil, false
+		}
+		return msg.Items[0].Data, true
+	}
+	matched := make([][]byte, 0, len(msg.Items))
+	for i := range msg.Items {
+		if s.match(&msg.Items[i]) {
+			matched = append(matched, msg.Items[i].Data)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, false
+	}
+	return jsonArray(matched), len(matched) == len(msg.Items)
+}
+
+func jsonArray(items [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	buf.Write(bytes.Join(items, []byte{','}))
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// Command 客户端发送的订阅命令：
+//
+//	{"action":"subscribe","types":["positions"],"vehicleIds":["V001"],"categoryCodes":[1],"bbox":[minLon,minLat,maxLon,maxLat],"snapshot":true}
+//	{"action":"unsubscribe","types":["alarms"],"vehicleIds":["V001"],"bbox":[]}
+//
+// subscribe 把 types/vehicleIds/categoryCodes 加入订阅，bbox 替换当前范围；
+// unsubscribe 从订阅中移除所列各项（移除全部已订阅的车辆或车型后不再收到按车辆/车型过滤的数据，而不是恢复为全部；
+// 未按车辆/车型订阅时移除无效果），带 bbox 字段（任意值）时取消范围限制，不带任何字段时取消全部订阅
+type Command struct {
+	Action        string          `json:"action"`
+	Types         []string        `json:"types,omitempty"`
+	VehicleIds    []string        `json:"vehicleIds,omitempty"`
+	CategoryCode  *int            `json:"categoryCode,omitempty"` // 同 categoryCodes 的单个值
+	CategoryCodes []int           `json:"categoryCodes,omitempty"`
+	BBox          json.RawMessage `json:"bbox,omitempty"`     // [minLon, minLat, maxLon, maxLat]
+	Snapshot      bool            `json:"snapshot,omitempty"` // 为 true 时在回复之后按新的订阅重新下发快照（例如地图移动到新的区域）
+}
+
+// 订阅命令
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// Apply 按命令修改订阅
+func (s *Subscription) Apply(cmd *Command) error {
+	for _, t := range cmd.Types {
+		if !validType(t) {
+			return fmt.Errorf("unknown type %q, expected one of %s", t, strings.Join(allTypes, "/"))
+		}
+		if cmd.Action == ActionSubscribe && !s.scope.allowType(t) {
+			return fmt.Errorf("%w: type %q not permitted by token", ErrForbidden, t)
+		}
+	}
+	codes := cmd.CategoryCodes
+	if cmd.CategoryCode != nil {
+		codes = append(codes, *cmd.CategoryCode)
+	}
+
+	switch cmd.Action {
+	case ActionSubscribe:
+		var bbox *BBox
+		if len(cmd.BBox) > 0 {
+			var vals []float64
+			if err := json.Unmarshal(cmd.BBox, &vals); err != nil {
+				return fmt.Errorf("invalid bbox: %v", err)
+			}
+			b, err := parseBBox(vals)
+			if err != nil {
+				return err
+			}
+			bbox = b
+		}
+		for _, t := range cmd.Types {
+			s.Types[t] = true
+		}
+		if len(cmd.VehicleIds) > 0 && s.VehicleIds == nil {
+			s.VehicleIds = make(map[string]bool)
+		}
+		for _, id := range cmd.VehicleIds {
+			s.VehicleIds[id] = true
+		}
+		if len(codes) > 0 && s.CategoryCodes == nil {
+			s.CategoryCodes = make(map[int]bool)
+		}
+		for _, c := range codes {
+			s.CategoryCodes[c] = true
+		}
+		if bbox != nil {
+			s.BBox = bbox
+		}
+	case ActionUnsubscribe:
+		if len(cmd.Types) == 0 && len(cmd.VehicleIds) == 0 && len(codes) == 0 && len(cmd.BBox) == 0 {
+			*s = Subscription{Types: map[string]bool{}, scope: s.scope}
+			return nil
+		}
+		for _, t := range cmd.Types {
+			delete(s.Types, t)
+		}
+		for _, id := range cmd.VehicleIds {
+			delete(s.VehicleIds, id)
+		}
+		for _, c := range codes {
+			delete(s.CategoryCodes, c)
+		}
+		if len(cmd.BBox) > 0 {
+			s.BBox = nil
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected subscribe/unsubscribe", cmd.Action)
+	}
+	return nil
+}
+
+// ApplyQuery 按连接 URL 的查询参数设置初始订阅（均可省略，逗号分隔）：
+// types=positions,alarms&vehicleIds=V001,V002&categoryCodes=1&bbox=minLon,minLat,maxLon,maxLat；
+// 指定 types 时替换默认的订阅类型
+func (s *Subscription) ApplyQuery(q url.Values) error {
+	cmd := Command{Action: ActionSubscribe}
+	if v := q.Get("types"); v != "" {
+		cmd.Types = splitList(v)
+	}
+	if v := q.Get("vehicleIds"); v != "" {
+		cmd.VehicleIds = splitList(v)
+	}
+	if v := q.Get("categoryCodes"); v != "" {
+		for _, p := range splitList(v) {
+			c, err := strconv.Atoi(p)
+			if err != nil {
+				return fmt.Errorf("invalid categoryCodes: %v", err)
+			}
+			cmd.CategoryCodes = append(cmd.CategoryCodes, c)
+		}
+	}
+	var bbox *BBox
+	if v := q.Get("bbox"); v != "" {
+		var vals []float64
+		for _, p := range splitList(v) {
+			f, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return fmt.Errorf("invalid bbox: %v", err)
+			}
+			vals = append(vals, f)
+		}
+		b, err := parseBBox(vals)
+		if err != nil {
+			return err
+		}
+		bbox = b
+	}
+	if len(cmd.Types) > 0 {
+		s.Types = map[string]bool{}
+	}
+	if err := s.Apply(&cmd); err != nil {
+		return err
+	}
+	if bbox != nil {
+		s.BBox = bbox
+	}
+	return nil
+}
+
+// SubscriptionView 订阅条件的 JSON 形式；vehicleIds/categoryCodes 为 null 表示不限，为 [] 表示不匹配任何数据
+type SubscriptionView struct {
+	Types         []string  `json:"types"`
+	VehicleIds    []string  `json:"vehicleIds"`
+	CategoryCodes []int     `json:"categoryCodes"`
+	BBox          []float64 `json:"bbox,omitempty"` // [minLon, minLat, maxLon, maxLat]
+}
+
+// View 返回订阅条件的快照
+func (s *Subscription) View() SubscriptionView {
+	v := SubscriptionView{Types: make([]string, 0, len(s.Types))}
+	for _, t := range allTypes {
+		if s.Types[t] {
+			v.Types = append(v.Types, t)
+		}
+	}
+	if s.VehicleIds != nil {
+		v.VehicleIds = make([]string, 0, len(s.VehicleIds))
+		for id := range s.VehicleIds {
+			v.VehicleIds = append(v.VehicleIds, id)
+		}
+		sort.Strings(v.VehicleIds)
+	}
+	if s.CategoryCodes != nil {
+		v.CategoryCodes = make([]int, 0, len(s.CategoryCodes))
+		for c := range s.CategoryCodes {
+			v.CategoryCodes = append(v.CategoryCodes, c)
+		}
+		sort.Ints(v.CategoryCodes)
+	}
+	if s.BBox != nil {
+		v.BBox = []float64{s.BBox.MinLon, s.BBox.MinLat, s.BBox.MaxLon, s.BBox.MaxLat}
+	}
+	return v
+}
+
+// subscriptionReply 订阅变更后回复给客户端的消息
+func subscriptionReply(s *Subscription) []byte {
+	b, _ := encodeEnvelopeValue(TypeSubscription, 0, time.Now().UnixMilli(), s.View())
+	return b
+}
+
+// errorReply 命令无法处理时回复给客户端的消息
+func errorReply(err error) []byte {
+	b, _ := encodeEnvelopeValue(TypeError, 0, time.Now().UnixMilli(), struct {
+		Message string `json:"message"`
+	}{err.Error()})
+	return b
+}
+
+func parseBBox(v []float64) (*BBox, error) {
+	if len(v) != 4 {
+		return nil, errors.New("bbox must be [minLon, minLat, maxLon, maxLat]")
+	}
+	b := &BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return nil, errors.New("bbox min must not exceed max")
+	}
+	return b, nil
+}
+
+func validType(t string) bool {
+	for _, v := range allTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}